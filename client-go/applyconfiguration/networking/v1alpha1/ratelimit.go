@@ -25,10 +25,13 @@ import (
 // RateLimitApplyConfiguration represents a declarative configuration of the RateLimit type for use
 // with apply.
 type RateLimitApplyConfiguration struct {
-	InputTokensPerUnit  *uint32                            `json:"inputTokensPerUnit,omitempty"`
-	OutputTokensPerUnit *uint32                            `json:"outputTokensPerUnit,omitempty"`
-	Unit                *networkingv1alpha1.RateLimitUnit  `json:"unit,omitempty"`
-	Global              *GlobalRateLimitApplyConfiguration `json:"global,omitempty"`
+	InputTokensPerUnit    *uint32                            `json:"inputTokensPerUnit,omitempty"`
+	OutputTokensPerUnit   *uint32                            `json:"outputTokensPerUnit,omitempty"`
+	RequestsPerUnit       *uint32                            `json:"requestsPerUnit,omitempty"`
+	MaxConcurrentRequests *uint32                            `json:"maxConcurrentRequests,omitempty"`
+	Unit                  *networkingv1alpha1.RateLimitUnit  `json:"unit,omitempty"`
+	Global                *GlobalRateLimitApplyConfiguration `json:"global,omitempty"`
+	Key                   *RateLimitKeyApplyConfiguration    `json:"key,omitempty"`
 }
 
 // RateLimitApplyConfiguration constructs a declarative configuration of the RateLimit type for use with
@@ -53,6 +56,22 @@ func (b *RateLimitApplyConfiguration) WithOutputTokensPerUnit(value uint32) *Rat
 	return b
 }
 
+// WithRequestsPerUnit sets the RequestsPerUnit field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the RequestsPerUnit field is set to the value of the last call.
+func (b *RateLimitApplyConfiguration) WithRequestsPerUnit(value uint32) *RateLimitApplyConfiguration {
+	b.RequestsPerUnit = &value
+	return b
+}
+
+// WithMaxConcurrentRequests sets the MaxConcurrentRequests field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the MaxConcurrentRequests field is set to the value of the last call.
+func (b *RateLimitApplyConfiguration) WithMaxConcurrentRequests(value uint32) *RateLimitApplyConfiguration {
+	b.MaxConcurrentRequests = &value
+	return b
+}
+
 // WithUnit sets the Unit field in the declarative configuration to the given value
 // and returns the receiver, so that objects can be built by chaining "With" function invocations.
 // If called multiple times, the Unit field is set to the value of the last call.
@@ -68,3 +87,11 @@ func (b *RateLimitApplyConfiguration) WithGlobal(value *GlobalRateLimitApplyConf
 	b.Global = value
 	return b
 }
+
+// WithKey sets the Key field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Key field is set to the value of the last call.
+func (b *RateLimitApplyConfiguration) WithKey(value *RateLimitKeyApplyConfiguration) *RateLimitApplyConfiguration {
+	b.Key = value
+	return b
+}
@@ -21,8 +21,9 @@ package v1alpha1
 // WorkloadPortApplyConfiguration represents a declarative configuration of the WorkloadPort type for use
 // with apply.
 type WorkloadPortApplyConfiguration struct {
-	Port     *int32  `json:"port,omitempty"`
-	Protocol *string `json:"protocol,omitempty"`
+	Port     *int32                         `json:"port,omitempty"`
+	Protocol *string                        `json:"protocol,omitempty"`
+	TLS      *WorkloadTLSApplyConfiguration `json:"tls,omitempty"`
 }
 
 // WorkloadPortApplyConfiguration constructs a declarative configuration of the WorkloadPort type for use with
@@ -46,3 +47,11 @@ func (b *WorkloadPortApplyConfiguration) WithProtocol(value string) *WorkloadPor
 	b.Protocol = &value
 	return b
 }
+
+// WithTLS sets the TLS field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the TLS field is set to the value of the last call.
+func (b *WorkloadPortApplyConfiguration) WithTLS(value *WorkloadTLSApplyConfiguration) *WorkloadPortApplyConfiguration {
+	b.TLS = value
+	return b
+}
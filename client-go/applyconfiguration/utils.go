@@ -36,10 +36,20 @@ func ForKind(kind schema.GroupVersionKind) interface{} {
 	// Group=networking.serving.volcano.sh, Version=v1alpha1
 	case v1alpha1.SchemeGroupVersion.WithKind("BodyMatch"):
 		return &networkingv1alpha1.BodyMatchApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("FallbackTarget"):
+		return &networkingv1alpha1.FallbackTargetApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("GlobalRateLimit"):
 		return &networkingv1alpha1.GlobalRateLimitApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("HeaderModifier"):
+		return &networkingv1alpha1.HeaderModifierApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("Hedging"):
+		return &networkingv1alpha1.HedgingApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("HTTPHeader"):
+		return &networkingv1alpha1.HTTPHeaderApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("KVConnectorSpec"):
 		return &networkingv1alpha1.KVConnectorSpecApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("Mirror"):
+		return &networkingv1alpha1.MirrorApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("ModelMatch"):
 		return &networkingv1alpha1.ModelMatchApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("ModelRoute"):
@@ -54,6 +64,8 @@ func ForKind(kind schema.GroupVersionKind) interface{} {
 		return &networkingv1alpha1.PDGroupApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("RateLimit"):
 		return &networkingv1alpha1.RateLimitApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("RateLimitKey"):
+		return &networkingv1alpha1.RateLimitKeyApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("RedisConfig"):
 		return &networkingv1alpha1.RedisConfigApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("Retry"):
@@ -70,6 +82,8 @@ func ForKind(kind schema.GroupVersionKind) interface{} {
 		return &networkingv1alpha1.WorkloadPortApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("WorkloadSelector"):
 		return &networkingv1alpha1.WorkloadSelectorApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("WorkloadTLS"):
+		return &networkingv1alpha1.WorkloadTLSApplyConfiguration{}
 
 		// Group=workload.serving.volcano.sh, Version=v1alpha1
 	case workloadv1alpha1.SchemeGroupVersion.WithKind("AutoscalingPolicy"):
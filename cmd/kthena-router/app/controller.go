@@ -80,11 +80,13 @@ func startControllers(store datastore.Store, stop <-chan struct{}, enableGateway
 
 	modelRouteController := controller.NewModelRouteController(kthenaInformerFactory, store)
 	modelServerController := controller.NewModelServerController(kthenaInformerFactory, kubeInformerFactory, store)
+	secretController := controller.NewSecretController(kthenaInformerFactory, kubeInformerFactory, store)
 
 	cacheSyncs := []cache.InformerSynced{
 		kthenaInformerFactory.Networking().V1alpha1().ModelRoutes().Informer().HasSynced,
 		kthenaInformerFactory.Networking().V1alpha1().ModelServers().Informer().HasSynced,
 		kubeInformerFactory.Core().V1().Pods().Informer().HasSynced,
+		kubeInformerFactory.Core().V1().Secrets().Informer().HasSynced,
 	}
 
 	var gatewayInformerFactory gatewayinformers.SharedInformerFactory
@@ -138,7 +140,7 @@ func startControllers(store datastore.Store, stop <-chan struct{}, enableGateway
 		klog.Fatalf("Failed to sync informer caches")
 	}
 
-	controllers := []Controller{modelRouteController, modelServerController}
+	controllers := []Controller{modelRouteController, modelServerController, secretController}
 
 	go func() {
 		if err := modelRouteController.Run(stop); err != nil {
@@ -150,6 +152,11 @@ func startControllers(store datastore.Store, stop <-chan struct{}, enableGateway
 			klog.Fatalf("Error running model server controller: %s", err.Error())
 		}
 	}()
+	go func() {
+		if err := secretController.Run(stop); err != nil {
+			klog.Fatalf("Error running secret controller: %s", err.Error())
+		}
+	}()
 
 	if enableGatewayAPI {
 		go func() {
@@ -49,7 +49,7 @@ func (s *Server) startRouter(ctx context.Context, router *router.Router, store d
 	gin.SetMode(gin.ReleaseMode)
 
 	// Start debug server on localhost
-	s.startDebugServer(ctx, store)
+	s.startDebugServer(ctx, router, store)
 
 	// Gateway API features are optional
 	if s.EnableGatewayAPI {
@@ -99,6 +99,7 @@ func (s *Server) startRouter(ctx context.Context, router *router.Router, store d
 			logListenErr: func(err error) {
 				klog.Fatalf("listen failed: %v", err)
 			},
+			shutdowns: &s.listenerShutdowns,
 		})
 		klog.Info("Gateway API features are disabled")
 	}
@@ -106,7 +107,7 @@ func (s *Server) startRouter(ctx context.Context, router *router.Router, store d
 
 // startDebugServer starts a separate debug server on localhost
 // This server only handles debug endpoints and is not accessible from outside
-func (s *Server) startDebugServer(ctx context.Context, store datastore.Store) {
+func (s *Server) startDebugServer(ctx context.Context, router *router.Router, store datastore.Store) {
 	engine := gin.New()
 	engine.Use(gin.Recovery())
 
@@ -121,6 +122,7 @@ func (s *Server) startDebugServer(ctx context.Context, store datastore.Store) {
 		debugGroup.GET("/gateways", debugHandler.ListGateways)
 		debugGroup.GET("/httproutes", debugHandler.ListHTTPRoutes)
 		debugGroup.GET("/inferencepools", debugHandler.ListInferencePools)
+		debugGroup.GET("/routerconfig", router.ConfigStatusHandler())
 
 		// Get specific resources
 		debugGroup.GET("/namespaces/:namespace/modelroutes/:name", debugHandler.GetModelRoute)
@@ -130,6 +132,8 @@ func (s *Server) startDebugServer(ctx context.Context, store datastore.Store) {
 		debugGroup.GET("/namespaces/:namespace/httproutes/:name", debugHandler.GetHTTPRoute)
 		debugGroup.GET("/namespaces/:namespace/inferencepools/:name", debugHandler.GetInferencePool)
 	}
+	// Explain how a request is scheduled, without proxying it
+	engine.POST("/debug/schedule", router.ExplainHandler())
 
 	server := &http.Server{
 		Addr:    fmt.Sprintf("localhost:%d", s.DebugPort),
@@ -196,6 +200,8 @@ type listenerConfig struct {
 	shutdownDoneLog  string
 	logShutdownErr   func(err error)
 	logListenErr     func(err error)
+	// shutdowns, if set, tracks the graceful shutdown of the listener.
+	shutdowns *sync.WaitGroup
 }
 
 // startListener: build Gin, listen, graceful shutdown on ctx.
@@ -242,6 +248,7 @@ func startListener(ctx context.Context, cfg listenerConfig) *http.Server {
 			c.Set(router.GatewayKey, matched.GatewayKey)
 			c.Next()
 		})
+		engine.Use(TracingMiddleware(lm.router))
 		engine.Use(AccessLogMiddleware(lm.router))
 		engine.Use(AuthMiddleware(lm.router))
 		engine.Any("/*path", lm.router.HandlerFunc())
@@ -255,6 +262,7 @@ func startListener(ctx context.Context, cfg listenerConfig) *http.Server {
 		})
 		engine.GET("/metrics", gin.WrapH(promhttp.Handler()))
 		v1Group := engine.Group("/v1")
+		v1Group.Use(TracingMiddleware(cfg.defaultRouter))
 		v1Group.Use(AccessLogMiddleware(cfg.defaultRouter))
 		v1Group.Use(AuthMiddleware(cfg.defaultRouter))
 		v1Group.Any("/*path", cfg.defaultRouter.HandlerFunc())
@@ -286,7 +294,13 @@ func startListener(ctx context.Context, cfg listenerConfig) *http.Server {
 		}
 	}()
 
+	if cfg.shutdowns != nil {
+		cfg.shutdowns.Add(1)
+	}
 	go func() {
+		if cfg.shutdowns != nil {
+			defer cfg.shutdowns.Done()
+		}
 		<-ctx.Done()
 		klog.Info(cfg.shutdownStartLog)
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), gracefulShutdownTimeout)
@@ -502,6 +516,7 @@ func (lm *ListenerManager) addListenerToPort(port int32, config ListenerConfig,
 			logListenErr: func(err error) {
 				klog.Errorf("listen failed for port %d: %v", port, err)
 			},
+			shutdowns: &lm.server.listenerShutdowns,
 		})
 
 		portInfo = &PortListenerInfo{
@@ -637,6 +652,19 @@ func (lm *ListenerManager) checkAndClosePortIfEmpty(port int32) {
 	}
 }
 
+func TracingMiddleware(gwRouter *router.Router) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Tracing for "/v1/" only
+		if !strings.HasPrefix(c.Request.URL.Path, "/v1/") {
+			c.Next()
+			return
+		}
+
+		// Calling Middleware
+		gwRouter.Tracing()(c)
+	}
+}
+
 func AccessLogMiddleware(gwRouter *router.Router) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Access log for "/v1/" only
@@ -18,6 +18,7 @@ package app
 
 import (
 	"context"
+	"sync"
 
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
@@ -38,6 +39,10 @@ type Server struct {
 	DebugPort                          int
 	KubeAPIQPS                         float32
 	KubeAPIBurst                       int
+
+	// listenerShutdowns tracks the graceful shutdown of the listeners, which drain the requests in
+	// flight.
+	listenerShutdowns sync.WaitGroup
 }
 
 func NewServer(port string, enableTLS bool, cert, key string, enableGatewayAPI bool, enableGatewayAPIInferenceExtension bool, debugPort int, kubeAPIQPS float32, kubeAPIBurst int) *Server {
@@ -62,6 +67,8 @@ func (s *Server) Run(ctx context.Context) {
 
 	// must be run before the controller, because it will register callbacks
 	r := NewRouter(store)
+	// reload the router configuration when its ConfigMap changes
+	go r.WatchConfig(ctx)
 	// start controller
 	s.controllers = startControllers(store, ctx.Done(), s.EnableGatewayAPI, s.Port, s.EnableGatewayAPIInferenceExtension, s.KubeAPIQPS, s.KubeAPIBurst)
 
@@ -78,6 +85,14 @@ func (s *Server) Run(ctx context.Context) {
 	klog.Info("Router server started, waiting for shutdown signal...")
 	<-ctx.Done()
 	klog.Info("Router server shutting down...")
+	// The requests drained by the listeners are still traced and logged, so the tracer and the
+	// access logger are only shut down once the listeners are.
+	s.listenerShutdowns.Wait()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), gracefulShutdownTimeout)
+	defer cancel()
+	if err := r.Shutdown(shutdownCtx); err != nil {
+		klog.Errorf("Failed to export the last spans and access log entries: %v", err)
+	}
 }
 
 func (s *Server) HasSynced() bool {
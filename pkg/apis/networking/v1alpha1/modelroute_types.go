@@ -66,6 +66,83 @@ type Rule struct {
 	// +kubebuilder:validation:MinItems=1
 	// +kubebuilder:validation:MaxItems=16
 	TargetModels []*TargetModel `json:"targetModels"`
+	// Hedging sends a second copy of a slow non-streaming request to another pod, and keeps the
+	// first successful response. There is no hedging if this field is not set.
+	// +optional
+	Hedging *Hedging `json:"hedging,omitempty"`
+	// Mirror copies a share of the requests matching the rule to a shadow model server. The
+	// responses of the shadow are discarded.
+	// +optional
+	Mirror *Mirror `json:"mirror,omitempty"`
+	// Fallbacks are the model servers tried in order when the target model selected among
+	// TargetModels fails to serve the request. Each fallback is only tried on the failures it is
+	// configured for.
+	// +optional
+	// +kubebuilder:validation:MaxItems=8
+	Fallbacks []*FallbackTarget `json:"fallbacks,omitempty"`
+	// RequestHeaderModifier modifies the headers of the requests matching the rule before they
+	// are scheduled and sent to the model server.
+	// +optional
+	RequestHeaderModifier *HeaderModifier `json:"requestHeaderModifier,omitempty"`
+	// ResponseHeaderModifier modifies the headers of the responses to the requests matching the
+	// rule before they are returned to the client.
+	// +optional
+	ResponseHeaderModifier *HeaderModifier `json:"responseHeaderModifier,omitempty"`
+}
+
+// HeaderModifier modifies the headers of a request or a response. Headers are removed first,
+// then set, then added to.
+type HeaderModifier struct {
+	// Set overwrites the headers with the given values, adding them if they are missing.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MaxItems=16
+	Set []HTTPHeader `json:"set,omitempty"`
+	// Add appends the given values to the headers, adding them if they are missing.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MaxItems=16
+	Add []HTTPHeader `json:"add,omitempty"`
+	// Remove lists the names of the headers to remove.
+	// +optional
+	// +listType=set
+	// +kubebuilder:validation:MaxItems=16
+	Remove []string `json:"remove,omitempty"`
+}
+
+// HTTPHeader is the name and value of an HTTP header. Header names are case-insensitive.
+type HTTPHeader struct {
+	// Name is the name of the header.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=256
+	Name string `json:"name"`
+	// Value is the value of the header.
+	// +kubebuilder:validation:MaxLength=4096
+	Value string `json:"value"`
+}
+
+// Hedging configures the hedged requests of a rule. A request that has not been answered after
+// the hedging delay is sent to the next best pod as well, and the slower of the two is cancelled.
+// Exactly one of Delay and DelayPercentile must be set.
+type Hedging struct {
+	// Delay is the fixed time to wait for the first pod before sending the hedged request.
+	// +optional
+	Delay *metav1.Duration `json:"delay,omitempty"`
+	// DelayPercentile sets the delay to the given percentile of the time to first token of the
+	// first pod, e.g. 95 hedges the requests slower than 95% of the requests of the pod.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=99
+	DelayPercentile *int32 `json:"delayPercentile,omitempty"`
+	// MaxHedgedPercent caps the extra load of hedging: at most this percentage of the requests
+	// matching the rule are hedged.
+	// +optional
+	// +kubebuilder:default=10
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	MaxHedgedPercent *int32 `json:"maxHedgedPercent,omitempty"`
 }
 
 // ModelMatch defines the predicate used to match LLM inference requests to a given
@@ -103,6 +180,49 @@ type StringMatch struct {
 	Regex  *string `json:"regex,omitempty"`
 }
 
+// Mirror defines the shadow model server that receives a copy of the requests of a rule.
+type Mirror struct {
+	// ModelServerName is the shadow modelServer within the same namespace.
+	//
+	// +kubebuilder:validation:required
+	ModelServerName string `json:"modelServerName"`
+	// Percent is the percentage of the requests that are mirrored.
+	//
+	// +optional
+	// +kubebuilder:default=100
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	Percent *int32 `json:"percent,omitempty"`
+}
+
+// FallbackTrigger is a failure of a model server which hands the request over to the next fallback.
+// +kubebuilder:validation:Enum=NoEndpoints;5xx;Timeout;RateLimited
+type FallbackTrigger string
+
+const (
+	// FallbackOnNoEndpoints falls back when the model server has no ready pod to schedule the request to.
+	FallbackOnNoEndpoints FallbackTrigger = "NoEndpoints"
+	// FallbackOn5xx falls back when every attempt failed with a 5xx response or a connection error.
+	FallbackOn5xx FallbackTrigger = "5xx"
+	// FallbackOnTimeout falls back when the timeout of the traffic policy of the model server expired.
+	FallbackOnTimeout FallbackTrigger = "Timeout"
+	// FallbackOnRateLimited falls back when the model server responded with 429 Too Many Requests.
+	FallbackOnRateLimited FallbackTrigger = "RateLimited"
+)
+
+// FallbackTarget is a model server serving the requests that the previous target failed to serve.
+type FallbackTarget struct {
+	// ModelServerName is the fallback modelServer within the same namespace.
+	//
+	// +kubebuilder:validation:required
+	ModelServerName string `json:"modelServerName"`
+	// On lists the failures of the previous target that this fallback is tried on. It is tried on
+	// all of them if this field is not set.
+	//
+	// +optional
+	On []FallbackTrigger `json:"on,omitempty"`
+}
+
 // LLM inference traffic target model
 type TargetModel struct {
 	// ModelServerName is used to specify the correlated modelServer within the same namespace.
@@ -117,6 +237,12 @@ type TargetModel struct {
 	// +kubebuilder:validation:Minimum=0
 	// +kubebuilder:validation:Maximum=100
 	Weight *uint32 `json:"weight,omitempty"`
+	// ModelName overrides the model of the requests sent to the model server, and of their
+	// scheduling. It takes precedence over the model of the ModelServer, so that a ModelRoute can
+	// expose a stable alias for a model whose name changes.
+	//
+	// +optional
+	ModelName *string `json:"modelName,omitempty"`
 }
 
 type RateLimit struct {
@@ -130,6 +256,16 @@ type RateLimit struct {
 	// +optional
 	// +kubebuilder:validation:Minimum=1
 	OutputTokensPerUnit *uint32 `json:"outputTokensPerUnit,omitempty"`
+	// RequestsPerUnit is the maximum number of requests allowed per unit of time.
+	// If this field is not set, there is no limit on requests.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	RequestsPerUnit *uint32 `json:"requestsPerUnit,omitempty"`
+	// MaxConcurrentRequests is the maximum number of requests in flight at once, streams included.
+	// It does not depend on the unit. If this field is not set, there is no limit on concurrency.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxConcurrentRequests *uint32 `json:"maxConcurrentRequests,omitempty"`
 	// Unit is the time unit for the rate limit.
 	// +kubebuilder:default=second
 	// +kubebuilder:validation:Enum=second;minute;hour;day;month
@@ -138,8 +274,42 @@ type RateLimit struct {
 	// If this field is set, global rate limiting will be used; otherwise, local rate limiting will be used.
 	// +optional
 	Global *GlobalRateLimit `json:"global,omitempty"`
+	// Key partitions the rate limit by a key of the requests, e.g. the user or the tenant, so that
+	// each key has its own input and output token budgets. Requests without a key share one budget.
+	// If this field is not set, all the requests to the model share the budgets.
+	// +optional
+	Key *RateLimitKey `json:"key,omitempty"`
 }
 
+// RateLimitKey describes where the rate limit key of a request is taken from.
+type RateLimitKey struct {
+	// Source is where the key is taken from.
+	// +kubebuilder:validation:Required
+	Source RateLimitKeySource `json:"source"`
+	// Header is the name of the request header holding the key. It is required when the source is
+	// Header.
+	// +optional
+	Header string `json:"header,omitempty"`
+	// Claim is the claim of the bearer token holding the key, when the source is JWTClaim.
+	// Defaults to sub.
+	// +optional
+	Claim string `json:"claim,omitempty"`
+}
+
+// RateLimitKeySource is where the rate limit key of a request is taken from.
+// +kubebuilder:validation:Enum=Header;JWTClaim;User
+type RateLimitKeySource string
+
+const (
+	// RateLimitKeySourceHeader takes the key from a request header.
+	RateLimitKeySourceHeader RateLimitKeySource = "Header"
+	// RateLimitKeySourceJWTClaim takes the key from a claim of the bearer token. The token is
+	// verified only when the authentication of the router is enabled.
+	RateLimitKeySourceJWTClaim RateLimitKeySource = "JWTClaim"
+	// RateLimitKeySourceUser takes the key from the user field of the request body.
+	RateLimitKeySourceUser RateLimitKeySource = "User"
+)
+
 // GlobalRateLimit contains configuration for global rate limiting
 type GlobalRateLimit struct {
 	// Redis contains configuration for Redis-based global rate limiting.
@@ -99,6 +99,32 @@ type WorkloadPort struct {
 	// +kubebuilder:default="http"
 	// +kubebuilder:validation:Enum=http;https
 	Protocol string `json:"protocol,omitempty"`
+
+	// TLS configures how the router verifies and authenticates to the model server when the protocol is "https".
+	// +optional
+	TLS *WorkloadTLS `json:"tls,omitempty"`
+}
+
+// WorkloadTLS defines the TLS settings used by the router to connect to the model server instances.
+// The referenced Secrets must be in the same namespace as the modelServer object.
+type WorkloadTLS struct {
+	// CASecretName is the name of the Secret holding the CA bundle under the "ca.crt" key,
+	// which is used to verify the certificates served by the model server instances.
+	// If not set, the system root CAs are used.
+	// +optional
+	CASecretName string `json:"caSecretName,omitempty"`
+	// ClientCertificateSecretName is the name of a kubernetes.io/tls Secret holding the client certificate
+	// and key ("tls.crt" and "tls.key") presented by the router, which enables mutual TLS.
+	// +optional
+	ClientCertificateSecretName string `json:"clientCertificateSecretName,omitempty"`
+	// ServerName is used to verify the hostname on the certificates served by the model server instances.
+	// The instances are accessed by pod IP, so it must be set unless the certificates contain the pod IPs.
+	// +optional
+	ServerName string `json:"serverName,omitempty"`
+	// InsecureSkipVerify disables the verification of the certificates served by the model server instances.
+	// It should only be used for testing.
+	// +optional
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
 }
 
 type KVConnectorType string
@@ -0,0 +1,179 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
+)
+
+const (
+	defaultHTTPBatchSize     = 100
+	defaultHTTPFlushInterval = 5 * time.Second
+	defaultHTTPTimeout       = 10 * time.Second
+	defaultHTTPQueueSize     = 10000
+)
+
+// httpLogger posts the entries in batches, as a JSON array, to an HTTP endpoint. The entries are
+// queued, so that the requests are not slowed down by the endpoint, and a batch that fails to be
+// sent is dropped.
+type httpLogger struct {
+	name    string
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	batchSize     int
+	flushInterval time.Duration
+
+	// mutex guards closed, since the requests in flight may still log once the sink is closed
+	mutex  sync.RWMutex
+	closed bool
+	queue  chan *AccessLogEntry
+	// done is closed once the last batch is sent
+	done chan struct{}
+}
+
+func newHTTPLogger(name string, config *conf.AccessLogHTTPSink) (AccessLogger, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("http.url is required")
+	}
+	if u, err := url.Parse(config.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("http.url %q is not an http or https URL", config.URL)
+	}
+	if config.BatchSize < 0 || config.QueueSize < 0 || config.FlushInterval.Duration < 0 || config.Timeout.Duration < 0 {
+		return nil, fmt.Errorf("http limits must not be negative")
+	}
+
+	l := &httpLogger{
+		name:          name,
+		url:           config.URL,
+		headers:       config.Headers,
+		client:        &http.Client{Timeout: orDefault(config.Timeout.Duration, defaultHTTPTimeout)},
+		batchSize:     orDefault(config.BatchSize, defaultHTTPBatchSize),
+		flushInterval: orDefault(config.FlushInterval.Duration, defaultHTTPFlushInterval),
+		queue:         make(chan *AccessLogEntry, orDefault(config.QueueSize, defaultHTTPQueueSize)),
+		done:          make(chan struct{}),
+	}
+	go l.run()
+	return l, nil
+}
+
+func orDefault[T int | time.Duration](value, defaultValue T) T {
+	if value == 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// Log queues the entry, or drops it if the queue is full
+func (l *httpLogger) Log(entry *AccessLogEntry) error {
+	if entry == nil {
+		return nil
+	}
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	if l.closed {
+		return fmt.Errorf("sink is closed")
+	}
+	select {
+	case l.queue <- entry:
+		return nil
+	default:
+		return fmt.Errorf("queue of %d entries is full, dropping the entry", cap(l.queue))
+	}
+}
+
+// Close sends the entries that are queued, and stops the sink
+func (l *httpLogger) Close() error {
+	l.mutex.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.queue)
+	}
+	l.mutex.Unlock()
+	<-l.done
+	return nil
+}
+
+// run sends the queued entries once a batch is full or has waited for the flush interval, until
+// the queue is closed.
+func (l *httpLogger) run() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*AccessLogEntry, 0, l.batchSize)
+	for {
+		select {
+		case entry, ok := <-l.queue:
+			if !ok {
+				l.send(batch)
+				return
+			}
+			batch = append(batch, entry)
+			if len(batch) < l.batchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+		l.send(batch)
+		batch = batch[:0]
+	}
+}
+
+func (l *httpLogger) send(batch []*AccessLogEntry) {
+	if len(batch) == 0 {
+		return
+	}
+	body, err := json.Marshal(batch)
+	if err != nil {
+		klog.Errorf("Failed to format %d access log entries of sink %s: %v", len(batch), l.name, err)
+		return
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, l.url, bytes.NewReader(body))
+	if err != nil {
+		klog.Errorf("Failed to send %d access log entries to sink %s: %v", len(batch), l.name, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range l.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		klog.Errorf("Failed to send %d access log entries to sink %s: %v", len(batch), l.name, err)
+		return
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		klog.Errorf("Failed to send %d access log entries to sink %s: status %s", len(batch), l.name, resp.Status)
+	}
+}
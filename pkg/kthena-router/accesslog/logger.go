@@ -180,6 +180,9 @@ func (l *accessLoggerImpl) formatText(entry *AccessLogEntry) (string, error) {
 	if entry.RequestID != "" {
 		fmt.Fprintf(&line, " request_id=%s", entry.RequestID)
 	}
+	if entry.Fallback > 0 {
+		fmt.Fprintf(&line, " fallback=%d", entry.Fallback)
+	}
 
 	// Add Gateway API / Inference Extension fields (if present)
 	if entry.Gateway != "" {
@@ -197,6 +200,35 @@ func (l *accessLoggerImpl) formatText(entry *AccessLogEntry) (string, error) {
 		fmt.Fprintf(&line, " tokens=%d/%d", entry.InputTokens, entry.OutputTokens)
 	}
 
+	// Add upstream attempts, e.g. attempts=pod-a:503,pod-b:200
+	if len(entry.UpstreamAttempts) > 0 {
+		attempts := make([]string, 0, len(entry.UpstreamAttempts))
+		for _, attempt := range entry.UpstreamAttempts {
+			if attempt.StatusCode > 0 {
+				attempts = append(attempts, fmt.Sprintf("%s:%d", attempt.Pod, attempt.StatusCode))
+			} else {
+				attempts = append(attempts, fmt.Sprintf("%s:error", attempt.Pod))
+			}
+		}
+		fmt.Fprintf(&line, " attempts=%s", strings.Join(attempts, ","))
+	}
+
+	// Add the selected request headers and body fields, as JSON
+	if len(entry.RequestHeaders) > 0 {
+		headers, err := json.Marshal(entry.RequestHeaders)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&line, " request_headers=%s", headers)
+	}
+	if len(entry.RequestBody) > 0 {
+		body, err := json.Marshal(entry.RequestBody)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&line, " request_body=%s", body)
+	}
+
 	// Add complete timing breakdown with total and breakdown
 	fmt.Fprintf(&line, " timings=%dms(%d+%d+%d)",
 		entry.DurationTotal,
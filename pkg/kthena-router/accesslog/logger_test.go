@@ -90,6 +90,7 @@ func TestAccessLogEntry_ToText(t *testing.T) {
 		ModelServer:                "default/llama2-server",
 		SelectedPod:                "llama2-deployment-5f7b8c9d-xk2p4",
 		RequestID:                  "test-request-id",
+		Fallback:                   1,
 		InputTokens:                150,
 		OutputTokens:               75,
 		DurationTotal:              2350,
@@ -118,6 +119,7 @@ func TestAccessLogEntry_ToText(t *testing.T) {
 		`model_server=default/llama2-server`,
 		`selected_pod=llama2-deployment-5f7b8c9d-xk2p4`,
 		`request_id=test-request-id`,
+		`fallback=1`,
 		`tokens=150/75`,
 		`timings=2350ms(45+2180+5)`,
 	}
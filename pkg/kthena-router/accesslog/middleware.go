@@ -45,6 +45,7 @@ func AccessLogMiddleware(logger AccessLogger) gin.HandlerFunc {
 			c.Request.Proto,
 			"", // ModelName will be set later when parsed from request body
 		)
+		ctx.RequestHeader = c.Request.Header
 
 		// Store context in gin.Context for other handlers to access
 		c.Set(AccessLogContextKey, ctx)
@@ -101,6 +102,34 @@ func SetTokenCounts(c *gin.Context, inputTokens, outputTokens int) {
 	}
 }
 
+// SetSelectedPod sets the pod that finally served the request in the access log context
+func SetSelectedPod(c *gin.Context, selectedPod string) {
+	if ctx := GetAccessLogContext(c); ctx != nil {
+		ctx.SelectedPod = selectedPod
+	}
+}
+
+// SetFallback sets the position in the fallback chain of the target serving the request
+func SetFallback(c *gin.Context, fallback int) {
+	if ctx := GetAccessLogContext(c); ctx != nil {
+		ctx.Fallback = fallback
+	}
+}
+
+// AddUpstreamAttempt records an upstream attempt in the access log context
+func AddUpstreamAttempt(c *gin.Context, attempt UpstreamAttempt) {
+	if ctx := GetAccessLogContext(c); ctx != nil {
+		ctx.AddUpstreamAttempt(attempt)
+	}
+}
+
+// SetRequestBody sets the parsed request body in the access log context
+func SetRequestBody(c *gin.Context, body map[string]interface{}) {
+	if ctx := GetAccessLogContext(c); ctx != nil {
+		ctx.SetRequestBody(body)
+	}
+}
+
 // SetError sets error information in the access log context
 func SetError(c *gin.Context, errorType, message string) {
 	if ctx := GetAccessLogContext(c); ctx != nil {
@@ -0,0 +1,167 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/tracing"
+)
+
+const (
+	// otlpEventName is the event name of the log records of the access log
+	otlpEventName = "kthena.access_log"
+	// otlpShutdownTimeout bounds the export of the last log records when the sink is closed
+	otlpShutdownTimeout = 10 * time.Second
+)
+
+// otlpLogger exports the entries as log records to an OTLP collector. Their attributes are the
+// fields of the JSON format.
+type otlpLogger struct {
+	provider *sdklog.LoggerProvider
+	logger   log.Logger
+}
+
+func newOTLPLogger(config *conf.AccessLogOTLPSink) (AccessLogger, error) {
+	exporter, err := newOTLPExporter(config)
+	if err != nil {
+		return nil, err
+	}
+	res, err := tracing.NewResource()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP resource: %w", err)
+	}
+	provider := sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)),
+		sdklog.WithResource(res),
+	)
+	return &otlpLogger{provider: provider, logger: provider.Logger(tracing.InstrumentationName)}, nil
+}
+
+// newOTLPExporter returns the exporter of the sink, which connects to the collector lazily so
+// that the router starts without it.
+func newOTLPExporter(config *conf.AccessLogOTLPSink) (sdklog.Exporter, error) {
+	switch config.Protocol {
+	case "", tracing.ProtocolGRPC:
+		var opts []otlploggrpc.Option
+		if config.Endpoint != "" {
+			opts = append(opts, otlploggrpc.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			opts = append(opts, otlploggrpc.WithInsecure())
+		}
+		return otlploggrpc.New(context.Background(), opts...)
+	case tracing.ProtocolHTTPProtobuf:
+		var opts []otlploghttp.Option
+		if config.Endpoint != "" {
+			opts = append(opts, otlploghttp.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			opts = append(opts, otlploghttp.WithInsecure())
+		}
+		return otlploghttp.New(context.Background(), opts...)
+	default:
+		return nil, fmt.Errorf("unknown OTLP protocol %q, expected %s or %s", config.Protocol, tracing.ProtocolGRPC, tracing.ProtocolHTTPProtobuf)
+	}
+}
+
+func (l *otlpLogger) Log(entry *AccessLogEntry) error {
+	if entry == nil {
+		return nil
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to format access log entry: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return fmt.Errorf("failed to format access log entry: %w", err)
+	}
+
+	var record log.Record
+	record.SetEventName(otlpEventName)
+	record.SetTimestamp(entry.Timestamp)
+	record.SetObservedTimestamp(time.Now())
+	if entry.IsError() {
+		record.SetSeverity(log.SeverityError)
+	} else {
+		record.SetSeverity(log.SeverityInfo)
+	}
+	record.SetBody(log.StringValue(fmt.Sprintf("%s %s %s %d", entry.Method, entry.Path, entry.Protocol, entry.StatusCode)))
+	record.AddAttributes(keyValues(fields)...)
+	l.logger.Emit(context.Background(), record)
+	return nil
+}
+
+// Close exports the log records that are not exported yet
+func (l *otlpLogger) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), otlpShutdownTimeout)
+	defer cancel()
+	return l.provider.Shutdown(ctx)
+}
+
+// keyValues converts the fields of a JSON object to log attributes, sorted by key.
+func keyValues(fields map[string]interface{}) []log.KeyValue {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	kvs := make([]log.KeyValue, 0, len(keys))
+	for _, key := range keys {
+		kvs = append(kvs, log.KeyValue{Key: key, Value: logValue(fields[key])})
+	}
+	return kvs
+}
+
+func logValue(value interface{}) log.Value {
+	switch v := value.(type) {
+	case string:
+		return log.StringValue(v)
+	case bool:
+		return log.BoolValue(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return log.Int64Value(i)
+		}
+		f, _ := v.Float64()
+		return log.Float64Value(f)
+	case []interface{}:
+		values := make([]log.Value, 0, len(v))
+		for _, elem := range v {
+			values = append(values, logValue(elem))
+		}
+		return log.SliceValue(values...)
+	case map[string]interface{}:
+		return log.MapValue(keyValues(v)...)
+	default:
+		return log.Value{}
+	}
+}
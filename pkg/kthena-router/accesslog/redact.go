@@ -0,0 +1,153 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package accesslog
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
+)
+
+const (
+	// RedactedValue replaces the values that must not be logged
+	RedactedValue = "[REDACTED]"
+	// selectAll selects all the headers or body fields
+	selectAll = "*"
+)
+
+// alwaysRedactedHeaders carry credentials, and are never logged
+var alwaysRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key"}
+
+// selector selects the request headers and body fields logged, and redacts those that must not be
+type selector struct {
+	allHeaders bool
+	// headers are canonical header names
+	headers    []string
+	allFields  bool
+	fields     []string
+	redacted   map[string]bool
+	redactions [][]string
+}
+
+func newSelector(config *conf.AccessLogConfiguration) *selector {
+	s := &selector{redacted: make(map[string]bool)}
+	for _, name := range config.Headers {
+		if name == selectAll {
+			s.allHeaders = true
+		} else {
+			s.headers = append(s.headers, http.CanonicalHeaderKey(name))
+		}
+	}
+	for _, field := range config.BodyFields {
+		if field == selectAll {
+			s.allFields = true
+		} else {
+			s.fields = append(s.fields, field)
+		}
+	}
+	for _, name := range slices.Concat(alwaysRedactedHeaders, config.Redact.Headers) {
+		s.redacted[http.CanonicalHeaderKey(name)] = true
+	}
+	for _, path := range config.Redact.BodyFields {
+		s.redactions = append(s.redactions, strings.Split(path, "."))
+	}
+	return s
+}
+
+// apply sets the request headers and body fields of the entry.
+func (s *selector) apply(entry *AccessLogEntry) {
+	if entry.header != nil && (s.allHeaders || len(s.headers) > 0) {
+		entry.RequestHeaders = s.selectHeaders(entry.header)
+	}
+	if entry.body != nil && (s.allFields || len(s.fields) > 0) {
+		entry.RequestBody = s.selectBody(entry.body)
+	}
+}
+
+func (s *selector) selectHeaders(header http.Header) map[string]string {
+	names := s.headers
+	if s.allHeaders {
+		names = make([]string, 0, len(header))
+		for name := range header {
+			names = append(names, http.CanonicalHeaderKey(name))
+		}
+	}
+	selected := make(map[string]string, len(names))
+	for _, name := range names {
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		// Headers are logged lowercase, as in HTTP/2.
+		key := strings.ToLower(name)
+		if s.redacted[name] {
+			selected[key] = RedactedValue
+		} else {
+			selected[key] = strings.Join(values, ",")
+		}
+	}
+	return selected
+}
+
+func (s *selector) selectBody(body map[string]interface{}) map[string]interface{} {
+	var selected map[string]interface{}
+	if s.allFields {
+		selected = maps.Clone(body)
+	} else {
+		selected = make(map[string]interface{}, len(s.fields))
+		for _, field := range s.fields {
+			if value, ok := body[field]; ok {
+				selected[field] = value
+			}
+		}
+	}
+	for _, path := range s.redactions {
+		selected = redact(selected, path).(map[string]interface{})
+	}
+	return selected
+}
+
+// redact returns a copy of value whose field at path is redacted. The path is applied to each
+// element of the arrays on the way, and value itself is left unchanged, since it is shared with
+// the request.
+func redact(value interface{}, path []string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		field, ok := v[path[0]]
+		if !ok {
+			return v
+		}
+		copied := maps.Clone(v)
+		if len(path) == 1 {
+			copied[path[0]] = RedactedValue
+		} else {
+			copied[path[0]] = redact(field, path[1:])
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, elem := range v {
+			copied[i] = redact(elem, path)
+		}
+		return copied
+	default:
+		return value
+	}
+}
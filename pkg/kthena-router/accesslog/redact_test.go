@@ -0,0 +1,111 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package accesslog
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
+)
+
+func TestSelector(t *testing.T) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer secret")
+	header.Set("X-User-Id", "alice")
+	header.Set("X-Tenant", "team-a")
+	header.Add("Accept", "text/plain")
+	header.Add("Accept", "application/json")
+	body := map[string]interface{}{
+		"model":       "llama",
+		"temperature": 0.5,
+		"messages": []interface{}{
+			map[string]interface{}{"role": "system", "content": "be nice"},
+			map[string]interface{}{"role": "user", "content": "my password is hunter2"},
+		},
+		"metadata": map[string]interface{}{"email": "alice@example.com", "team": "a"},
+	}
+
+	tests := []struct {
+		name            string
+		config          conf.AccessLogConfiguration
+		expectedHeaders map[string]string
+		expectedBody    map[string]interface{}
+	}{
+		{
+			name: "nothing selected",
+		},
+		{
+			name: "selected headers and fields",
+			config: conf.AccessLogConfiguration{
+				Headers:    []string{"x-user-id", "accept", "x-missing"},
+				BodyFields: []string{"temperature", "missing"},
+			},
+			expectedHeaders: map[string]string{"x-user-id": "alice", "accept": "text/plain,application/json"},
+			expectedBody:    map[string]interface{}{"temperature": 0.5},
+		},
+		{
+			name: "credentials are always redacted",
+			config: conf.AccessLogConfiguration{
+				Headers: []string{"Authorization"},
+			},
+			expectedHeaders: map[string]string{"authorization": RedactedValue},
+		},
+		{
+			name: "all redacted",
+			config: conf.AccessLogConfiguration{
+				Headers:    []string{"*"},
+				BodyFields: []string{"*"},
+				Redact: conf.AccessLogRedaction{
+					Headers:    []string{"x-tenant"},
+					BodyFields: []string{"messages.content", "metadata.email", "missing.field"},
+				},
+			},
+			expectedHeaders: map[string]string{
+				"authorization": RedactedValue,
+				"x-user-id":     "alice",
+				"x-tenant":      RedactedValue,
+				"accept":        "text/plain,application/json",
+			},
+			expectedBody: map[string]interface{}{
+				"model":       "llama",
+				"temperature": 0.5,
+				"messages": []interface{}{
+					map[string]interface{}{"role": "system", "content": RedactedValue},
+					map[string]interface{}{"role": "user", "content": RedactedValue},
+				},
+				"metadata": map[string]interface{}{"email": RedactedValue, "team": "a"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := &AccessLogEntry{header: header, body: body}
+			newSelector(&tt.config).apply(entry)
+			assert.Equal(t, tt.expectedHeaders, entry.RequestHeaders)
+			assert.Equal(t, tt.expectedBody, entry.RequestBody)
+		})
+	}
+
+	// The request is left unchanged.
+	assert.Equal(t, "Bearer secret", header.Get("Authorization"))
+	assert.Equal(t, "my password is hunter2", body["messages"].([]interface{})[1].(map[string]interface{})["content"])
+	assert.Equal(t, "alice@example.com", body["metadata"].(map[string]interface{})["email"])
+}
@@ -0,0 +1,196 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package accesslog
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
+)
+
+// Sink types
+const (
+	SinkStdout = "stdout"
+	SinkStderr = "stderr"
+	SinkFile   = "file"
+	SinkOTLP   = "otlp"
+	SinkHTTP   = "http"
+)
+
+// New creates the access logger of the configuration, writing the entries to each of its sinks
+// accepting them. The entries are written as text to stdout if there is no sink.
+func New(config *conf.AccessLogConfiguration) (AccessLogger, error) {
+	if config.Enabled != nil && !*config.Enabled {
+		return &noopAccessLogger{}, nil
+	}
+
+	sinks := config.Sinks
+	if len(sinks) == 0 {
+		sinks = []conf.AccessLogSink{{Type: SinkStdout}}
+	}
+	logger := &multiLogger{selector: newSelector(config)}
+	for i := range sinks {
+		s, err := newSink(&sinks[i])
+		if err != nil {
+			_ = logger.Close()
+			return nil, fmt.Errorf("invalid access log sink %s: %w", sinkName(&sinks[i]), err)
+		}
+		logger.sinks = append(logger.sinks, s)
+	}
+	return logger, nil
+}
+
+// sinkName returns the name of the sink, its type by default
+func sinkName(config *conf.AccessLogSink) string {
+	if config.Name != "" {
+		return config.Name
+	}
+	return config.Type
+}
+
+// multiLogger writes the entries to its sinks
+type multiLogger struct {
+	selector *selector
+	sinks    []*sink
+}
+
+func (l *multiLogger) Log(entry *AccessLogEntry) error {
+	if entry == nil {
+		return nil
+	}
+	l.selector.apply(entry)
+	var errs []error
+	for _, s := range l.sinks {
+		if !s.accepts(entry) {
+			continue
+		}
+		if err := s.logger.Log(entry); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", s.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes the sinks, sending the entries they have not sent yet
+func (l *multiLogger) Close() error {
+	var errs []error
+	for _, s := range l.sinks {
+		if err := s.logger.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", s.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sink is an access logger with the filter of its entries
+type sink struct {
+	name   string
+	logger AccessLogger
+	// successRatio is the ratio of the entries of successful requests accepted
+	successRatio float64
+}
+
+// accepts returns whether the entry is written to the sink. Errors always are.
+func (s *sink) accepts(entry *AccessLogEntry) bool {
+	if entry.IsError() || s.successRatio >= 1 {
+		return true
+	}
+	return rand.Float64() < s.successRatio
+}
+
+func newSink(config *conf.AccessLogSink) (*sink, error) {
+	successRatio := 1.0
+	if filter := config.Filter; filter.ErrorsOnly {
+		if filter.SuccessSamplingRatio != nil {
+			return nil, fmt.Errorf("errorsOnly and successSamplingRatio are exclusive")
+		}
+		successRatio = 0
+	} else if filter.SuccessSamplingRatio != nil {
+		successRatio = *filter.SuccessSamplingRatio
+		if successRatio < 0 || successRatio > 1 {
+			return nil, fmt.Errorf("successSamplingRatio %v is not between 0 and 1", successRatio)
+		}
+	}
+
+	logger, err := newSinkLogger(config)
+	if err != nil {
+		return nil, err
+	}
+	return &sink{name: sinkName(config), logger: logger, successRatio: successRatio}, nil
+}
+
+func newSinkLogger(config *conf.AccessLogSink) (AccessLogger, error) {
+	switch config.Type {
+	case SinkStdout, SinkStderr:
+		format, err := parseFormat(config.Format)
+		if err != nil {
+			return nil, err
+		}
+		return NewAccessLogger(&AccessLoggerConfig{Format: format, Output: config.Type, Enabled: true})
+	case SinkFile:
+		format, err := parseFormat(config.Format)
+		if err != nil {
+			return nil, err
+		}
+		if config.File == nil || config.File.Path == "" {
+			return nil, fmt.Errorf("file.path is required")
+		}
+		if config.File.MaxSizeMB < 0 || config.File.MaxAgeDays < 0 || config.File.MaxBackups < 0 {
+			return nil, fmt.Errorf("file limits must not be negative")
+		}
+		// The file is opened by the first entry, and rotated once it reaches its maximum size.
+		writer := &lumberjack.Logger{
+			Filename:   config.File.Path,
+			MaxSize:    config.File.MaxSizeMB,
+			MaxAge:     config.File.MaxAgeDays,
+			MaxBackups: config.File.MaxBackups,
+			Compress:   config.File.Compress,
+		}
+		return &accessLoggerImpl{
+			config: &AccessLoggerConfig{Format: format, Output: config.File.Path, Enabled: true},
+			writer: writer,
+		}, nil
+	case SinkOTLP:
+		otlpConfig := config.OTLP
+		if otlpConfig == nil {
+			otlpConfig = &conf.AccessLogOTLPSink{}
+		}
+		return newOTLPLogger(otlpConfig)
+	case SinkHTTP:
+		if config.HTTP == nil {
+			return nil, fmt.Errorf("http.url is required")
+		}
+		return newHTTPLogger(sinkName(config), config.HTTP)
+	default:
+		return nil, fmt.Errorf("unknown type %q, expected %s, %s, %s, %s or %s", config.Type, SinkStdout, SinkStderr, SinkFile, SinkOTLP, SinkHTTP)
+	}
+}
+
+func parseFormat(format string) (LogFormat, error) {
+	switch LogFormat(format) {
+	case "", FormatText:
+		return FormatText, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("unknown format %q, expected %s or %s", format, FormatText, FormatJSON)
+	}
+}
@@ -0,0 +1,331 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package accesslog
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
+)
+
+func ratio(r float64) *float64 {
+	return &r
+}
+
+func TestNew(t *testing.T) {
+	disabled := false
+	tests := []struct {
+		name        string
+		config      conf.AccessLogConfiguration
+		expectedErr string
+	}{
+		{
+			name: "default stdout sink",
+		},
+		{
+			name:   "disabled",
+			config: conf.AccessLogConfiguration{Enabled: &disabled},
+		},
+		{
+			name: "all sinks",
+			config: conf.AccessLogConfiguration{Sinks: []conf.AccessLogSink{
+				{Type: SinkStderr, Format: "json"},
+				{Type: SinkFile, File: &conf.AccessLogFileSink{Path: filepath.Join(t.TempDir(), "access.log")}},
+				{Type: SinkOTLP, OTLP: &conf.AccessLogOTLPSink{Endpoint: "otel-collector:4317", Insecure: true}},
+				{Type: SinkHTTP, HTTP: &conf.AccessLogHTTPSink{URL: "http://logs.example.com/ingest"}},
+			}},
+		},
+		{
+			name:        "unknown type",
+			config:      conf.AccessLogConfiguration{Sinks: []conf.AccessLogSink{{Name: "kafka", Type: "kafka"}}},
+			expectedErr: `invalid access log sink kafka: unknown type "kafka"`,
+		},
+		{
+			name:        "unknown format",
+			config:      conf.AccessLogConfiguration{Sinks: []conf.AccessLogSink{{Type: SinkStdout, Format: "xml"}}},
+			expectedErr: `invalid access log sink stdout: unknown format "xml"`,
+		},
+		{
+			name:        "file without path",
+			config:      conf.AccessLogConfiguration{Sinks: []conf.AccessLogSink{{Type: SinkFile}}},
+			expectedErr: "file.path is required",
+		},
+		{
+			name:        "http without url",
+			config:      conf.AccessLogConfiguration{Sinks: []conf.AccessLogSink{{Type: SinkHTTP}}},
+			expectedErr: "http.url is required",
+		},
+		{
+			name:        "http url without scheme",
+			config:      conf.AccessLogConfiguration{Sinks: []conf.AccessLogSink{{Type: SinkHTTP, HTTP: &conf.AccessLogHTTPSink{URL: "logs.example.com"}}}},
+			expectedErr: `http.url "logs.example.com" is not an http or https URL`,
+		},
+		{
+			name:        "unknown OTLP protocol",
+			config:      conf.AccessLogConfiguration{Sinks: []conf.AccessLogSink{{Type: SinkOTLP, OTLP: &conf.AccessLogOTLPSink{Protocol: "http/json"}}}},
+			expectedErr: `unknown OTLP protocol "http/json"`,
+		},
+		{
+			name: "invalid sampling ratio",
+			config: conf.AccessLogConfiguration{Sinks: []conf.AccessLogSink{
+				{Type: SinkStdout, Filter: conf.AccessLogFilter{SuccessSamplingRatio: ratio(1.5)}},
+			}},
+			expectedErr: "successSamplingRatio 1.5 is not between 0 and 1",
+		},
+		{
+			name: "errors only and sampling ratio",
+			config: conf.AccessLogConfiguration{Sinks: []conf.AccessLogSink{
+				{Type: SinkStdout, Filter: conf.AccessLogFilter{ErrorsOnly: true, SuccessSamplingRatio: ratio(0.5)}},
+			}},
+			expectedErr: "errorsOnly and successSamplingRatio are exclusive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, err := New(&tt.config)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.NoError(t, logger.Close())
+		})
+	}
+}
+
+func TestSinkFilter(t *testing.T) {
+	success := &AccessLogEntry{StatusCode: http.StatusOK}
+	failure := &AccessLogEntry{StatusCode: http.StatusTooManyRequests}
+	aborted := &AccessLogEntry{StatusCode: http.StatusOK, Error: &ErrorInfo{Type: "upstream"}}
+
+	tests := []struct {
+		name     string
+		filter   conf.AccessLogFilter
+		expected []bool
+	}{
+		{name: "all", expected: []bool{true, true, true}},
+		{name: "errors only", filter: conf.AccessLogFilter{ErrorsOnly: true}, expected: []bool{false, true, true}},
+		{name: "no success", filter: conf.AccessLogFilter{SuccessSamplingRatio: ratio(0)}, expected: []bool{false, true, true}},
+		{name: "all successes", filter: conf.AccessLogFilter{SuccessSamplingRatio: ratio(1)}, expected: []bool{true, true, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newSink(&conf.AccessLogSink{Type: SinkStdout, Filter: tt.filter})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, []bool{s.accepts(success), s.accepts(failure), s.accepts(aborted)})
+		})
+	}
+
+	// A sample of the successes is accepted.
+	s, err := newSink(&conf.AccessLogSink{Type: SinkStdout, Filter: conf.AccessLogFilter{SuccessSamplingRatio: ratio(0.5)}})
+	require.NoError(t, err)
+	accepted := 0
+	for range 1000 {
+		if s.accepts(success) {
+			accepted++
+		}
+	}
+	assert.InDelta(t, 500, accepted, 100)
+}
+
+func TestMultiLogger(t *testing.T) {
+	dir := t.TempDir()
+	all := filepath.Join(dir, "all.log")
+	errorsOnly := filepath.Join(dir, "errors.log")
+	logger, err := New(&conf.AccessLogConfiguration{
+		Headers: []string{"Authorization", "X-User-Id"},
+		Sinks: []conf.AccessLogSink{
+			{Name: "all", Type: SinkFile, Format: "json", File: &conf.AccessLogFileSink{Path: all, MaxSizeMB: 10}},
+			{Name: "errors", Type: SinkFile, File: &conf.AccessLogFileSink{Path: errorsOnly}, Filter: conf.AccessLogFilter{ErrorsOnly: true}},
+		},
+	})
+	require.NoError(t, err)
+
+	header := http.Header{"Authorization": {"Bearer secret"}, "X-User-Id": {"alice"}}
+	require.NoError(t, logger.Log(&AccessLogEntry{Path: "/v1/completions", StatusCode: http.StatusOK, header: header}))
+	require.NoError(t, logger.Log(&AccessLogEntry{Path: "/v1/chat/completions", StatusCode: http.StatusBadGateway, header: header}))
+	require.NoError(t, logger.Close())
+
+	data, err := os.ReadFile(all)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	var entry AccessLogEntry
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "/v1/completions", entry.Path)
+	assert.Equal(t, map[string]string{"authorization": RedactedValue, "x-user-id": "alice"}, entry.RequestHeaders)
+
+	data, err = os.ReadFile(errorsOnly)
+	require.NoError(t, err)
+	lines = strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], "/v1/chat/completions")
+	assert.Contains(t, lines[0], `request_headers={"authorization":"[REDACTED]","x-user-id":"alice"}`)
+}
+
+func TestHTTPSink(t *testing.T) {
+	var mutex sync.Mutex
+	var batches [][]AccessLogEntry
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		var batch []AccessLogEntry
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+		mutex.Lock()
+		batches = append(batches, batch)
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	logger, err := New(&conf.AccessLogConfiguration{Sinks: []conf.AccessLogSink{{
+		Type: SinkHTTP,
+		HTTP: &conf.AccessLogHTTPSink{
+			URL:           server.URL + "/ingest",
+			Headers:       map[string]string{"Authorization": "Bearer token"},
+			BatchSize:     2,
+			FlushInterval: metav1.Duration{Duration: time.Hour},
+		},
+	}}})
+	require.NoError(t, err)
+
+	for _, path := range []string{"/v1/a", "/v1/b", "/v1/c"} {
+		require.NoError(t, logger.Log(&AccessLogEntry{Path: path, StatusCode: http.StatusOK}))
+	}
+	// A full batch is sent right away.
+	assert.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(batches) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	// The rest is sent when the sink is closed.
+	require.NoError(t, logger.Close())
+	assert.ErrorContains(t, logger.Log(&AccessLogEntry{Path: "/v1/d"}), "sink is closed")
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	require.Len(t, batches, 2)
+	require.Len(t, batches[0], 2)
+	assert.Equal(t, "/v1/a", batches[0][0].Path)
+	assert.Equal(t, "/v1/b", batches[0][1].Path)
+	require.Len(t, batches[1], 1)
+	assert.Equal(t, "/v1/c", batches[1][0].Path)
+}
+
+func TestHTTPSink_QueueFull(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	logger, err := newHTTPLogger("slow", &conf.AccessLogHTTPSink{URL: server.URL, BatchSize: 1, QueueSize: 1})
+	require.NoError(t, err)
+	// The first entry is being sent, the second is queued and the third is dropped.
+	require.NoError(t, logger.Log(&AccessLogEntry{}))
+	assert.Eventually(t, func() bool {
+		return len(logger.(*httpLogger).queue) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, logger.Log(&AccessLogEntry{}))
+	assert.ErrorContains(t, logger.Log(&AccessLogEntry{}), "queue of 1 entries is full")
+
+	close(release)
+	require.NoError(t, logger.Close())
+}
+
+func TestOTLPSink(t *testing.T) {
+	requests := make(chan *collogspb.ExportLogsServiceRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/logs", r.URL.Path)
+		data, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		request := &collogspb.ExportLogsServiceRequest{}
+		assert.NoError(t, proto.Unmarshal(data, request))
+		requests <- request
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer server.Close()
+
+	logger, err := New(&conf.AccessLogConfiguration{Sinks: []conf.AccessLogSink{{
+		Type: SinkOTLP,
+		OTLP: &conf.AccessLogOTLPSink{
+			Endpoint: strings.TrimPrefix(server.URL, "http://"),
+			Protocol: "http/protobuf",
+			Insecure: true,
+		},
+	}}})
+	require.NoError(t, err)
+	require.NoError(t, logger.Log(&AccessLogEntry{
+		Timestamp:     time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC),
+		Method:        "POST",
+		Path:          "/v1/chat/completions",
+		Protocol:      "HTTP/1.1",
+		StatusCode:    http.StatusServiceUnavailable,
+		ModelName:     "llama",
+		InputTokens:   12,
+		Error:         &ErrorInfo{Type: "upstream", Message: "no pod"},
+		RequestBody:   map[string]interface{}{"temperature": 0.5},
+		DurationTotal: 3,
+	}))
+	// The records are exported when the sink is closed.
+	require.NoError(t, logger.Close())
+
+	var record *logspb.LogRecord
+	select {
+	case request := <-requests:
+		record = request.GetResourceLogs()[0].GetScopeLogs()[0].GetLogRecords()[0]
+	case <-time.After(5 * time.Second):
+		t.Fatal("no log record was exported")
+	}
+	assert.Equal(t, otlpEventName, record.GetEventName())
+	assert.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, record.GetSeverityNumber())
+	assert.Equal(t, "POST /v1/chat/completions HTTP/1.1 503", record.GetBody().GetStringValue())
+	attributes := make(map[string]interface{})
+	for _, kv := range record.GetAttributes() {
+		switch value := kv.GetValue(); {
+		case value.GetKvlistValue() != nil:
+			attributes[kv.GetKey()] = len(value.GetKvlistValue().GetValues())
+		case value.GetStringValue() != "":
+			attributes[kv.GetKey()] = value.GetStringValue()
+		default:
+			attributes[kv.GetKey()] = value.GetIntValue()
+		}
+	}
+	assert.Equal(t, "llama", attributes["model_name"])
+	assert.Equal(t, int64(12), attributes["input_tokens"])
+	assert.Equal(t, int64(503), attributes["status_code"])
+	assert.Equal(t, 2, attributes["error"])
+	assert.Equal(t, 1, attributes["request_body"])
+}
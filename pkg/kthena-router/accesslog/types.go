@@ -17,6 +17,8 @@ limitations under the License.
 package accesslog
 
 import (
+	"maps"
+	"net/http"
 	"time"
 )
 
@@ -38,6 +40,9 @@ type AccessLogEntry struct {
 	ModelServer string `json:"model_server,omitempty"`
 	SelectedPod string `json:"selected_pod,omitempty"`
 	RequestID   string `json:"request_id,omitempty"`
+	// Fallback is the position in the fallback chain of the ModelRoute rule of the target that
+	// served the request, starting at 1, or 0 if it was served by the target model of the rule.
+	Fallback int `json:"fallback,omitempty"`
 
 	// Gateway API / Gateway API Inference Extension information
 	Gateway       string `json:"gateway,omitempty"`
@@ -48,11 +53,28 @@ type AccessLogEntry struct {
 	InputTokens  int `json:"input_tokens,omitempty"`
 	OutputTokens int `json:"output_tokens,omitempty"`
 
+	// Upstream attempts, in the order they were made
+	UpstreamAttempts []UpstreamAttempt `json:"upstream_attempts,omitempty"`
+
+	// Request headers and body fields selected by the access log configuration, redacted
+	RequestHeaders map[string]string      `json:"request_headers,omitempty"`
+	RequestBody    map[string]interface{} `json:"request_body,omitempty"`
+
 	// Timing breakdown (in milliseconds) - flattened fields
 	DurationTotal              int64 `json:"duration_total"`
 	DurationRequestProcessing  int64 `json:"duration_request_processing"`
 	DurationUpstreamProcessing int64 `json:"duration_upstream_processing"`
 	DurationResponseProcessing int64 `json:"duration_response_processing"`
+
+	// header and body are those of the request, from which RequestHeaders and RequestBody are
+	// selected
+	header http.Header
+	body   map[string]interface{}
+}
+
+// IsError returns whether the request failed.
+func (e *AccessLogEntry) IsError() bool {
+	return e.StatusCode >= http.StatusBadRequest || e.Error != nil
 }
 
 // ErrorInfo contains error details for failed requests
@@ -61,6 +83,15 @@ type ErrorInfo struct {
 	Message string `json:"message"`
 }
 
+// UpstreamAttempt records a single attempt to send the request to a backend pod
+type UpstreamAttempt struct {
+	Pod        string `json:"pod"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// Duration of the attempt in milliseconds
+	Duration int64 `json:"duration"`
+}
+
 // AccessLogContext tracks timing and metadata throughout request lifecycle
 type AccessLogContext struct {
 	// Request metadata
@@ -73,6 +104,7 @@ type AccessLogContext struct {
 	ModelRoute    string
 	ModelServer   string
 	SelectedPod   string
+	Fallback      int
 	Gateway       string
 	HTTPRoute     string
 	InferencePool string
@@ -81,6 +113,13 @@ type AccessLogContext struct {
 	InputTokens  int
 	OutputTokens int
 
+	// Upstream attempts
+	UpstreamAttempts []UpstreamAttempt
+
+	// Request headers and body, as received from the client
+	RequestHeader http.Header
+	RequestBody   map[string]interface{}
+
 	// Timing checkpoints
 	RequestProcessingStart  time.Time
 	RequestProcessingEnd    time.Time
@@ -136,6 +175,17 @@ func (ctx *AccessLogContext) SetTokenCounts(inputTokens, outputTokens int) {
 	ctx.OutputTokens = outputTokens
 }
 
+// AddUpstreamAttempt appends an upstream attempt
+func (ctx *AccessLogContext) AddUpstreamAttempt(attempt UpstreamAttempt) {
+	ctx.UpstreamAttempts = append(ctx.UpstreamAttempts, attempt)
+}
+
+// SetRequestBody sets the parsed body of the request. Its top-level fields are copied, so that
+// the changes made to the request for the model servers are not logged.
+func (ctx *AccessLogContext) SetRequestBody(body map[string]interface{}) {
+	ctx.RequestBody = maps.Clone(body)
+}
+
 // SetError sets error information
 func (ctx *AccessLogContext) SetError(errorType, message string) {
 	ctx.Error = &ErrorInfo{
@@ -208,15 +258,19 @@ func (ctx *AccessLogContext) ToAccessLogEntry(statusCode int) *AccessLogEntry {
 		ModelServer:                modelServerName,
 		SelectedPod:                ctx.SelectedPod,
 		RequestID:                  ctx.RequestID,
+		Fallback:                   ctx.Fallback,
 		Gateway:                    ctx.Gateway,
 		HTTPRoute:                  ctx.HTTPRoute,
 		InferencePool:              ctx.InferencePool,
 		InputTokens:                ctx.InputTokens,
 		OutputTokens:               ctx.OutputTokens,
+		UpstreamAttempts:           ctx.UpstreamAttempts,
 		DurationTotal:              total,
 		DurationRequestProcessing:  requestProcessing,
 		DurationUpstreamProcessing: upstreamProcessing,
 		DurationResponseProcessing: responseProcessing,
+		header:                     ctx.RequestHeader,
+		body:                       ctx.RequestBody,
 	}
 
 	return entry
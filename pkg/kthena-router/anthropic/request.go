@@ -0,0 +1,272 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// messagesFields are the fields of a Messages request that do not exist in chat completions, or
+// have another shape there. The other fields, such as temperature or stream, are passed through.
+var messagesFields = []string{
+	"messages", "system", "stop_sequences", "metadata", "tools", "tool_choice", "thinking",
+	"service_tier", "container", "mcp_servers",
+}
+
+// ToChatCompletion translates the body of a Messages request into the body of a chat completions request.
+func ToChatCompletion(body map[string]interface{}) (map[string]interface{}, error) {
+	rawMessages, ok := body["messages"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("messages is not a list")
+	}
+
+	// The messages are kept as generic values, the way a decoded JSON request body holds them.
+	messages := make([]interface{}, 0, len(rawMessages)+1)
+	if system, ok := body["system"]; ok && system != nil {
+		text, err := textOf(system)
+		if err != nil {
+			return nil, fmt.Errorf("invalid system: %w", err)
+		}
+		messages = append(messages, map[string]interface{}{"role": "system", "content": text})
+	}
+	for i, raw := range rawMessages {
+		msg, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("message %d is not an object", i)
+		}
+		converted, err := convertMessage(msg)
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", i, err)
+		}
+		messages = append(messages, converted...)
+	}
+
+	chat := make(map[string]interface{}, len(body))
+	for k, v := range body {
+		chat[k] = v
+	}
+	for _, field := range messagesFields {
+		delete(chat, field)
+	}
+	chat["messages"] = messages
+
+	if stream, _ := body["stream"].(bool); stream {
+		// The usage of the last chunk is needed for the final events of the message.
+		chat["stream_options"] = map[string]interface{}{"include_usage": true}
+	}
+	if stops, ok := body["stop_sequences"].([]interface{}); ok && len(stops) > 0 {
+		chat["stop"] = stops
+	}
+	if metadata, ok := body["metadata"].(map[string]interface{}); ok {
+		if userID, ok := metadata["user_id"].(string); ok {
+			chat["user"] = userID
+		}
+	}
+	if tools, ok := body["tools"].([]interface{}); ok && len(tools) > 0 {
+		chatTools, err := convertTools(tools)
+		if err != nil {
+			return nil, err
+		}
+		chat["tools"] = chatTools
+	}
+	if choice, ok := body["tool_choice"].(map[string]interface{}); ok {
+		convertToolChoice(choice, chat)
+	}
+	return chat, nil
+}
+
+// convertMessage translates a message of the Messages API into chat messages. The tool_result
+// blocks of a user message become tool messages, which precede the rest of the user message.
+func convertMessage(msg map[string]interface{}) ([]interface{}, error) {
+	role, _ := msg["role"].(string)
+	if role != "user" && role != "assistant" {
+		return nil, fmt.Errorf("unsupported role %q", role)
+	}
+
+	var blocks []interface{}
+	switch content := msg["content"].(type) {
+	case string:
+		return []interface{}{map[string]interface{}{"role": role, "content": content}}, nil
+	case []interface{}:
+		blocks = content
+	default:
+		return nil, fmt.Errorf("content is neither a string nor a list")
+	}
+
+	var result []interface{}
+	var parts []interface{}
+	var toolCalls []interface{}
+	hasImage := false
+	for _, raw := range blocks {
+		block, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("content block is not an object")
+		}
+		switch blockType, _ := block["type"].(string); blockType {
+		case BlockTypeText:
+			parts = append(parts, map[string]interface{}{"type": "text", "text": block["text"]})
+		case BlockTypeImage:
+			url, err := imageURL(block)
+			if err != nil {
+				return nil, err
+			}
+			hasImage = true
+			parts = append(parts, map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": url}})
+		case BlockTypeToolUse:
+			arguments, err := json.Marshal(block["input"])
+			if err != nil {
+				return nil, fmt.Errorf("invalid tool_use input: %w", err)
+			}
+			toolCalls = append(toolCalls, map[string]interface{}{
+				"id":   block["id"],
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":      block["name"],
+					"arguments": string(arguments),
+				},
+			})
+		case BlockTypeToolResult:
+			text := ""
+			if content, ok := block["content"]; ok && content != nil {
+				var err error
+				if text, err = textOf(content); err != nil {
+					return nil, fmt.Errorf("invalid tool_result content: %w", err)
+				}
+			}
+			if isError, _ := block["is_error"].(bool); isError {
+				text = "Error: " + text
+			}
+			result = append(result, map[string]interface{}{
+				"role":         "tool",
+				"tool_call_id": block["tool_use_id"],
+				"content":      text,
+			})
+		case "thinking", "redacted_thinking":
+			// Thinking blocks are produced by the model and are not sent back to it.
+			continue
+		default:
+			return nil, fmt.Errorf("unsupported content block type %q", blockType)
+		}
+	}
+
+	if len(parts) == 0 && len(toolCalls) == 0 {
+		return result, nil
+	}
+	converted := map[string]interface{}{"role": role}
+	if hasImage {
+		converted["content"] = parts
+	} else if len(parts) > 0 {
+		// Text only content is sent as a string, which all engines and chat templates accept.
+		texts := make([]string, 0, len(parts))
+		for _, part := range parts {
+			text, _ := part.(map[string]interface{})["text"].(string)
+			texts = append(texts, text)
+		}
+		converted["content"] = strings.Join(texts, "\n")
+	}
+	if len(toolCalls) > 0 {
+		converted["tool_calls"] = toolCalls
+	}
+	return append(result, converted), nil
+}
+
+// textOf returns the text of a system prompt or tool result, a string or a list of text blocks.
+func textOf(content interface{}) (string, error) {
+	switch v := content.(type) {
+	case string:
+		return v, nil
+	case []interface{}:
+		texts := make([]string, 0, len(v))
+		for _, raw := range v {
+			block, ok := raw.(map[string]interface{})
+			if !ok {
+				return "", fmt.Errorf("content block is not an object")
+			}
+			if block["type"] != BlockTypeText {
+				return "", fmt.Errorf("unsupported content block type %v", block["type"])
+			}
+			text, _ := block["text"].(string)
+			texts = append(texts, text)
+		}
+		return strings.Join(texts, "\n"), nil
+	default:
+		return "", fmt.Errorf("content is neither a string nor a list")
+	}
+}
+
+// imageURL returns the url of an image block, a data url for base64 encoded images.
+func imageURL(block map[string]interface{}) (string, error) {
+	source, ok := block["source"].(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("image has no source")
+	}
+	switch source["type"] {
+	case "base64":
+		return fmt.Sprintf("data:%v;base64,%v", source["media_type"], source["data"]), nil
+	case "url":
+		url, _ := source["url"].(string)
+		return url, nil
+	default:
+		return "", fmt.Errorf("unsupported image source type %v", source["type"])
+	}
+}
+
+// convertTools translates the tools of a Messages request into chat completion tools.
+func convertTools(tools []interface{}) ([]interface{}, error) {
+	chatTools := make([]interface{}, 0, len(tools))
+	for _, raw := range tools {
+		tool, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("tool is not an object")
+		}
+		// Client tools have no type, or the "custom" type. Server tools, such as web search, are not supported.
+		if toolType, ok := tool["type"].(string); ok && toolType != "custom" {
+			return nil, fmt.Errorf("unsupported tool type %q", toolType)
+		}
+		function := map[string]interface{}{
+			"name":       tool["name"],
+			"parameters": tool["input_schema"],
+		}
+		if description, ok := tool["description"]; ok {
+			function["description"] = description
+		}
+		chatTools = append(chatTools, map[string]interface{}{"type": "function", "function": function})
+	}
+	return chatTools, nil
+}
+
+// convertToolChoice sets the tool choice of the chat completion from the tool choice of a Messages request.
+func convertToolChoice(choice map[string]interface{}, chat map[string]interface{}) {
+	switch choice["type"] {
+	case "auto":
+		chat["tool_choice"] = "auto"
+	case "any":
+		chat["tool_choice"] = "required"
+	case "none":
+		chat["tool_choice"] = "none"
+	case "tool":
+		chat["tool_choice"] = map[string]interface{}{
+			"type":     "function",
+			"function": map[string]interface{}{"name": choice["name"]},
+		}
+	}
+	if disable, ok := choice["disable_parallel_tool_use"].(bool); ok {
+		chat["parallel_tool_calls"] = !disable
+	}
+}
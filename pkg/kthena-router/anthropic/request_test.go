@@ -0,0 +1,146 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package anthropic
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeBody(t *testing.T, body string) map[string]interface{} {
+	var m map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(body), &m))
+	return m
+}
+
+func TestToChatCompletion(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "system prompt and string content",
+			body: `{"model": "m", "max_tokens": 16, "temperature": 0.5, "system": "be brief",
+				"messages": [{"role": "user", "content": "hi"}], "stop_sequences": ["END"], "metadata": {"user_id": "u1"}}`,
+			want: `{"model": "m", "max_tokens": 16, "temperature": 0.5, "stop": ["END"], "user": "u1",
+				"messages": [{"role": "system", "content": "be brief"}, {"role": "user", "content": "hi"}]}`,
+		},
+		{
+			name: "system text blocks",
+			body: `{"model": "m", "system": [{"type": "text", "text": "be"}, {"type": "text", "text": "brief"}],
+				"messages": [{"role": "user", "content": [{"type": "text", "text": "hi"}]}]}`,
+			want: `{"model": "m", "messages": [{"role": "system", "content": "be\nbrief"}, {"role": "user", "content": "hi"}]}`,
+		},
+		{
+			name: "images",
+			body: `{"model": "m", "messages": [{"role": "user", "content": [
+				{"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "aGk="}},
+				{"type": "image", "source": {"type": "url", "url": "https://example.com/a.png"}},
+				{"type": "text", "text": "what are these"}]}]}`,
+			want: `{"model": "m", "messages": [{"role": "user", "content": [
+				{"type": "image_url", "image_url": {"url": "data:image/png;base64,aGk="}},
+				{"type": "image_url", "image_url": {"url": "https://example.com/a.png"}},
+				{"type": "text", "text": "what are these"}]}]}`,
+		},
+		{
+			name: "tool use and tool results",
+			body: `{"model": "m", "messages": [
+				{"role": "user", "content": "weather?"},
+				{"role": "assistant", "content": [{"type": "thinking", "thinking": "..."}, {"type": "text", "text": "checking"},
+					{"type": "tool_use", "id": "toolu_1", "name": "weather", "input": {"city": "Paris"}}]},
+				{"role": "user", "content": [{"type": "tool_result", "tool_use_id": "toolu_1", "content": [{"type": "text", "text": "sunny"}]},
+					{"type": "text", "text": "thanks"}]},
+				{"role": "user", "content": [{"type": "tool_result", "tool_use_id": "toolu_2", "content": "boom", "is_error": true}]}
+			]}`,
+			want: `{"model": "m", "messages": [
+				{"role": "user", "content": "weather?"},
+				{"role": "assistant", "content": "checking", "tool_calls": [
+					{"id": "toolu_1", "type": "function", "function": {"name": "weather", "arguments": "{\"city\":\"Paris\"}"}}]},
+				{"role": "tool", "tool_call_id": "toolu_1", "content": "sunny"},
+				{"role": "user", "content": "thanks"},
+				{"role": "tool", "tool_call_id": "toolu_2", "content": "Error: boom"}]}`,
+		},
+		{
+			name: "tools and tool choice",
+			body: `{"model": "m", "stream": true, "messages": [{"role": "user", "content": "hi"}],
+				"tools": [{"name": "weather", "description": "get the weather", "input_schema": {"type": "object"}}],
+				"tool_choice": {"type": "tool", "name": "weather", "disable_parallel_tool_use": true}}`,
+			want: `{"model": "m", "stream": true, "stream_options": {"include_usage": true}, "messages": [{"role": "user", "content": "hi"}],
+				"tools": [{"type": "function", "function": {"name": "weather", "description": "get the weather", "parameters": {"type": "object"}}}],
+				"tool_choice": {"type": "function", "function": {"name": "weather"}}, "parallel_tool_calls": false}`,
+		},
+		{
+			name: "any tool choice",
+			body: `{"model": "m", "messages": [{"role": "user", "content": "hi"}], "tool_choice": {"type": "any"}}`,
+			want: `{"model": "m", "messages": [{"role": "user", "content": "hi"}], "tool_choice": "required"}`,
+		},
+		{
+			name:    "missing messages",
+			body:    `{"model": "m"}`,
+			wantErr: true,
+		},
+		{
+			name:    "unsupported role",
+			body:    `{"model": "m", "messages": [{"role": "system", "content": "hi"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "server tools are not supported",
+			body:    `{"model": "m", "messages": [{"role": "user", "content": "hi"}], "tools": [{"type": "web_search_20250305", "name": "web_search"}]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chat, err := ToChatCompletion(decodeBody(t, tt.body))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			got, _ := json.Marshal(chat)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestStopReason(t *testing.T) {
+	reason := func(s string) *string { return &s }
+	tests := []struct {
+		choice       chatChoice
+		wantReason   string
+		wantSequence *string
+	}{
+		{choice: chatChoice{}, wantReason: StopReasonEndTurn},
+		{choice: chatChoice{FinishReason: reason("stop")}, wantReason: StopReasonEndTurn},
+		{choice: chatChoice{FinishReason: reason("stop"), StopReason: "END"}, wantReason: StopReasonStopSequence, wantSequence: reason("END")},
+		{choice: chatChoice{FinishReason: reason("stop"), StopReason: float64(2)}, wantReason: StopReasonEndTurn},
+		{choice: chatChoice{FinishReason: reason("length")}, wantReason: StopReasonMaxTokens},
+		{choice: chatChoice{FinishReason: reason("tool_calls")}, wantReason: StopReasonToolUse},
+		{choice: chatChoice{FinishReason: reason("content_filter")}, wantReason: StopReasonRefusal},
+	}
+	for _, tt := range tests {
+		gotReason, gotSequence := stopReason(&tt.choice)
+		assert.Equal(t, tt.wantReason, gotReason)
+		assert.Equal(t, tt.wantSequence, gotSequence)
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package anthropic implements the Anthropic Messages API (/v1/messages) on top of the chat
+// completions API served by the inference engines. Requests are translated into chat completions,
+// and the results, streaming or not, are translated back into messages.
+package anthropic
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	BlockTypeText       = "text"
+	BlockTypeImage      = "image"
+	BlockTypeToolUse    = "tool_use"
+	BlockTypeToolResult = "tool_result"
+
+	StopReasonEndTurn      = "end_turn"
+	StopReasonMaxTokens    = "max_tokens"
+	StopReasonStopSequence = "stop_sequence"
+	StopReasonToolUse      = "tool_use"
+	StopReasonRefusal      = "refusal"
+)
+
+// Message is a message of the Messages API, returned by non-streaming requests.
+type Message struct {
+	ID           string         `json:"id"`
+	Type         string         `json:"type"`
+	Role         string         `json:"role"`
+	Model        string         `json:"model"`
+	Content      []ContentBlock `json:"content"`
+	StopReason   *string        `json:"stop_reason"`
+	StopSequence *string        `json:"stop_sequence"`
+	Usage        Usage          `json:"usage"`
+}
+
+// ContentBlock is a text or tool_use block of a message generated by the model.
+type ContentBlock struct {
+	Type string `json:"type"`
+	// Text of text blocks.
+	Text *string `json:"text,omitempty"`
+	// Fields of tool_use blocks.
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+// Usage is the token usage of a message.
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// chatCompletion is the part of a chat completion response, or of a streamed chunk, that is
+// translated into a message.
+type chatCompletion struct {
+	Choices []chatChoice `json:"choices"`
+	Usage   *chatUsage   `json:"usage"`
+}
+
+type chatChoice struct {
+	Index        int         `json:"index"`
+	Message      chatMessage `json:"message"`
+	Delta        chatMessage `json:"delta"`
+	FinishReason *string     `json:"finish_reason"`
+	// StopReason is set by vLLM to the stop sequence that ended the generation.
+	StopReason interface{} `json:"stop_reason"`
+}
+
+type chatMessage struct {
+	Content   *string        `json:"content"`
+	ToolCalls []chatToolCall `json:"tool_calls"`
+}
+
+type chatToolCall struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type chatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+func newID(prefix string) string {
+	return prefix + "_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+// stopReason translates the finish reason of a chat completion into the stop reason of a message.
+func stopReason(choice *chatChoice) (string, *string) {
+	if choice.FinishReason == nil {
+		return StopReasonEndTurn, nil
+	}
+	switch *choice.FinishReason {
+	case "length":
+		return StopReasonMaxTokens, nil
+	case "tool_calls":
+		return StopReasonToolUse, nil
+	case "content_filter":
+		return StopReasonRefusal, nil
+	default:
+		if sequence, ok := choice.StopReason.(string); ok && sequence != "" {
+			return StopReasonStopSequence, &sequence
+		}
+		return StopReasonEndTurn, nil
+	}
+}
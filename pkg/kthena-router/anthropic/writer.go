@@ -0,0 +1,343 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package anthropic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog/v2"
+)
+
+// Writer translates the chat completion written by the proxy into a message of the Messages API.
+// Non-streaming completions and error responses are buffered and translated on Close. Streaming
+// completions are translated chunk by chunk into Messages events.
+type Writer struct {
+	gin.ResponseWriter
+
+	stream  bool
+	message *Message
+
+	decided bool
+	// failed is set when the response is an error, which is translated into an error of the Messages API.
+	failed bool
+	buf    bytes.Buffer
+
+	// Streaming state.
+	started      bool
+	done         bool
+	finishChoice *chatChoice
+	blockIndex   int
+	// blockType is the type of the open content block, empty if there is none.
+	blockType string
+	toolIndex int
+}
+
+// NewWriter returns a Writer wrapping w for a Messages request of the model.
+func NewWriter(w gin.ResponseWriter, model string, stream bool) *Writer {
+	return &Writer{
+		ResponseWriter: w,
+		stream:         stream,
+		message: &Message{
+			ID:      newID("msg"),
+			Type:    "message",
+			Role:    "assistant",
+			Model:   model,
+			Content: []ContentBlock{},
+		},
+		blockIndex: -1,
+	}
+}
+
+func (w *Writer) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		w.failed = w.Status() >= http.StatusBadRequest
+		w.Header().Del("Content-Length")
+		if w.stream && !w.failed {
+			w.Header().Set("Content-Type", "text/event-stream")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+	}
+
+	w.buf.Write(data)
+	if w.stream && !w.failed {
+		w.processLines()
+	}
+	return len(data), nil
+}
+
+func (w *Writer) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Close writes the translated non-streaming message or error, or completes a stream that ended
+// without a final chunk.
+func (w *Writer) Close() {
+	if !w.decided {
+		return
+	}
+	if w.failed {
+		w.writeError()
+		return
+	}
+	if w.stream {
+		if w.started && !w.done {
+			w.finishStream()
+		}
+		return
+	}
+
+	var completion chatCompletion
+	if err := json.Unmarshal(w.buf.Bytes(), &completion); err != nil {
+		klog.Errorf("failed to parse chat completion: %v", err)
+		w.ResponseWriter.WriteHeader(http.StatusBadGateway)
+		_, _ = w.ResponseWriter.Write(errorBody(http.StatusBadGateway, "invalid response from model server"))
+		return
+	}
+	w.buildMessage(&completion)
+	data, _ := json.Marshal(w.message)
+	_, _ = w.ResponseWriter.Write(data)
+}
+
+// buildMessage fills the message with the output of a non-streaming chat completion.
+func (w *Writer) buildMessage(completion *chatCompletion) {
+	if len(completion.Choices) > 0 {
+		choice := &completion.Choices[0]
+		if choice.Message.Content != nil && *choice.Message.Content != "" {
+			w.message.Content = append(w.message.Content, ContentBlock{Type: BlockTypeText, Text: choice.Message.Content})
+		}
+		for _, toolCall := range choice.Message.ToolCalls {
+			w.message.Content = append(w.message.Content, ContentBlock{
+				Type:  BlockTypeToolUse,
+				ID:    toolCall.ID,
+				Name:  toolCall.Function.Name,
+				Input: toolInput(toolCall.Function.Arguments),
+			})
+		}
+		w.setStopReason(choice)
+	}
+	w.setUsage(completion.Usage)
+}
+
+func (w *Writer) setStopReason(choice *chatChoice) {
+	reason, sequence := stopReason(choice)
+	w.message.StopReason = &reason
+	w.message.StopSequence = sequence
+}
+
+func (w *Writer) setUsage(usage *chatUsage) {
+	if usage == nil {
+		return
+	}
+	w.message.Usage = Usage{InputTokens: usage.PromptTokens, OutputTokens: usage.CompletionTokens}
+}
+
+// writeError translates the buffered error response of the model server, or of the router, into
+// an error of the Messages API.
+func (w *Writer) writeError() {
+	var message string
+	var upstream struct {
+		Message string `json:"message"`
+		Error   struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	var text string
+	switch {
+	case json.Unmarshal(w.buf.Bytes(), &upstream) == nil && upstream.Error.Message != "":
+		message = upstream.Error.Message
+	case upstream.Message != "":
+		message = upstream.Message
+	case json.Unmarshal(w.buf.Bytes(), &text) == nil:
+		message = text
+	default:
+		message = string(bytes.TrimSpace(w.buf.Bytes()))
+	}
+	_, _ = w.ResponseWriter.Write(errorBody(w.Status(), message))
+}
+
+func errorBody(status int, message string) []byte {
+	errorType := "api_error"
+	switch status {
+	case http.StatusBadRequest:
+		errorType = "invalid_request_error"
+	case http.StatusUnauthorized:
+		errorType = "authentication_error"
+	case http.StatusForbidden:
+		errorType = "permission_error"
+	case http.StatusNotFound:
+		errorType = "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		errorType = "request_too_large"
+	case http.StatusTooManyRequests:
+		errorType = "rate_limit_error"
+	case http.StatusServiceUnavailable:
+		errorType = "overloaded_error"
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":  "error",
+		"error": map[string]interface{}{"type": errorType, "message": message},
+	})
+	return data
+}
+
+// toolInput returns the arguments of a tool call as the input of a tool_use block, which must be an object.
+func toolInput(arguments string) json.RawMessage {
+	if arguments == "" || !json.Valid([]byte(arguments)) {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(arguments)
+}
+
+// processLines translates the complete server-sent event lines in the buffer.
+func (w *Writer) processLines() {
+	for {
+		line, err := w.buf.ReadBytes('\n')
+		if err != nil {
+			// Keep the incomplete line until the rest of it is written.
+			rest := append([]byte(nil), line...)
+			w.buf.Reset()
+			w.buf.Write(rest)
+			return
+		}
+		line = bytes.TrimSpace(line)
+		data, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			continue
+		}
+		data = bytes.TrimSpace(data)
+		if bytes.Equal(data, []byte("[DONE]")) {
+			if w.started && !w.done {
+				w.finishStream()
+			}
+			continue
+		}
+		var chunk chatCompletion
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			klog.V(4).Infof("skip invalid chat completion chunk: %v", err)
+			continue
+		}
+		w.processChunk(&chunk)
+	}
+}
+
+func (w *Writer) processChunk(chunk *chatCompletion) {
+	if !w.started {
+		w.started = true
+		w.emit("message_start", map[string]interface{}{"message": w.message})
+	}
+
+	for i := range chunk.Choices {
+		choice := &chunk.Choices[i]
+		if choice.Index != 0 {
+			continue
+		}
+		if choice.Delta.Content != nil && *choice.Delta.Content != "" {
+			w.appendText(*choice.Delta.Content)
+		}
+		for _, toolCall := range choice.Delta.ToolCalls {
+			w.appendToolCall(toolCall)
+		}
+		if choice.FinishReason != nil {
+			w.finishChoice = choice
+		}
+	}
+	w.setUsage(chunk.Usage)
+}
+
+func (w *Writer) appendText(delta string) {
+	if w.blockType != BlockTypeText {
+		empty := ""
+		w.startBlock(ContentBlock{Type: BlockTypeText, Text: &empty})
+	}
+	w.emit("content_block_delta", map[string]interface{}{
+		"index": w.blockIndex,
+		"delta": map[string]interface{}{"type": "text_delta", "text": delta},
+	})
+}
+
+func (w *Writer) appendToolCall(toolCall chatToolCall) {
+	// The content blocks are sent one after the other, so a tool call, which is streamed in several
+	// chunks, closes the open block when it starts.
+	if w.blockType != BlockTypeToolUse || w.toolIndex != toolCall.Index {
+		w.toolIndex = toolCall.Index
+		w.startBlock(ContentBlock{
+			Type:  BlockTypeToolUse,
+			ID:    toolCall.ID,
+			Name:  toolCall.Function.Name,
+			Input: json.RawMessage("{}"),
+		})
+	}
+	if toolCall.Function.Arguments != "" {
+		w.emit("content_block_delta", map[string]interface{}{
+			"index": w.blockIndex,
+			"delta": map[string]interface{}{"type": "input_json_delta", "partial_json": toolCall.Function.Arguments},
+		})
+	}
+}
+
+func (w *Writer) startBlock(block ContentBlock) {
+	w.stopBlock()
+	w.blockIndex++
+	w.blockType = block.Type
+	w.emit("content_block_start", map[string]interface{}{"index": w.blockIndex, "content_block": block})
+}
+
+func (w *Writer) stopBlock() {
+	if w.blockType == "" {
+		return
+	}
+	w.emit("content_block_stop", map[string]interface{}{"index": w.blockIndex})
+	w.blockType = ""
+}
+
+// finishStream closes the open content block and sends the final events of the message.
+func (w *Writer) finishStream() {
+	w.done = true
+	w.stopBlock()
+
+	if w.finishChoice != nil {
+		w.setStopReason(w.finishChoice)
+	} else {
+		reason := StopReasonEndTurn
+		w.message.StopReason = &reason
+	}
+	w.emit("message_delta", map[string]interface{}{
+		"delta": map[string]interface{}{
+			"stop_reason":   w.message.StopReason,
+			"stop_sequence": w.message.StopSequence,
+		},
+		"usage": w.message.Usage,
+	})
+	w.emit("message_stop", map[string]interface{}{})
+}
+
+// emit writes a server-sent event of the Messages API.
+func (w *Writer) emit(eventType string, payload map[string]interface{}) {
+	payload["type"] = eventType
+	data, err := json.Marshal(payload)
+	if err != nil {
+		klog.Errorf("failed to marshal %s event: %v", eventType, err)
+		return
+	}
+	_, _ = fmt.Fprintf(w.ResponseWriter, "event: %s\ndata: %s\n\n", eventType, data)
+}
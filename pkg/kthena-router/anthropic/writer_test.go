@@ -0,0 +1,171 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package anthropic
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestWriter(stream bool) (*httptest.ResponseRecorder, *Writer) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	return w, NewWriter(c.Writer, "m", stream)
+}
+
+func TestWriterNonStreaming(t *testing.T) {
+	w, writer := newTestWriter(false)
+	writer.Header().Set("Content-Length", "1000")
+	writer.WriteHeader(http.StatusOK)
+	_, _ = writer.Write([]byte(`{"id": "chatcmpl-1", "choices": [{"index": 0, "message": {"role": "assistant", "content": "hello",`))
+	_, _ = writer.Write([]byte(`"tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "lookup", "arguments": "{\"q\":1}"}}]},
+		"finish_reason": "tool_calls"}], "usage": {"prompt_tokens": 3, "completion_tokens": 5, "total_tokens": 8}}`))
+	writer.Close()
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Length"))
+	var msg map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &msg))
+	assert.True(t, strings.HasPrefix(msg["id"].(string), "msg_"))
+	delete(msg, "id")
+	got, _ := json.Marshal(msg)
+	assert.JSONEq(t, `{"type": "message", "role": "assistant", "model": "m",
+		"content": [{"type": "text", "text": "hello"}, {"type": "tool_use", "id": "call_1", "name": "lookup", "input": {"q": 1}}],
+		"stop_reason": "tool_use", "stop_sequence": null, "usage": {"input_tokens": 3, "output_tokens": 5}}`, string(got))
+}
+
+func TestWriterErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   string
+	}{
+		{status: http.StatusTooManyRequests, body: `"rate limited"`,
+			want: `{"type": "error", "error": {"type": "rate_limit_error", "message": "rate limited"}}`},
+		{status: http.StatusBadRequest, body: `{"object": "error", "message": "too long", "code": 400}`,
+			want: `{"type": "error", "error": {"type": "invalid_request_error", "message": "too long"}}`},
+		{status: http.StatusInternalServerError, body: `{"error": {"message": "oops"}}`,
+			want: `{"type": "error", "error": {"type": "api_error", "message": "oops"}}`},
+		{status: http.StatusNotFound, body: `not found`,
+			want: `{"type": "error", "error": {"type": "not_found_error", "message": "not found"}}`},
+	}
+	for _, tt := range tests {
+		w, writer := newTestWriter(true)
+		writer.WriteHeader(tt.status)
+		_, _ = writer.Write([]byte(tt.body))
+		writer.Close()
+
+		assert.Equal(t, tt.status, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.JSONEq(t, tt.want, w.Body.String())
+	}
+}
+
+type sseEvent struct {
+	name string
+	data map[string]interface{}
+}
+
+func parseEvents(t *testing.T, body string) []sseEvent {
+	var events []sseEvent
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	var name string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if v, ok := strings.CutPrefix(line, "event: "); ok {
+			name = v
+		}
+		if v, ok := strings.CutPrefix(line, "data: "); ok {
+			var data map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(v), &data))
+			assert.Equal(t, name, data["type"])
+			events = append(events, sseEvent{name: name, data: data})
+		}
+	}
+	return events
+}
+
+func TestWriterStreaming(t *testing.T) {
+	w, writer := newTestWriter(true)
+	chunks := strings.Join([]string{
+		`data: {"choices": [{"index": 0, "delta": {"role": "assistant", "content": ""}}]}`,
+		`data: {"choices": [{"index": 0, "delta": {"content": "Hel"}}]}`,
+		`data: {"choices": [{"index": 0, "delta": {"content": "lo"}}]}`,
+		`data: {"choices": [{"index": 0, "delta": {"tool_calls": [{"index": 0, "id": "call_1", "function": {"name": "lookup", "arguments": "{\"q\""}}]}}]}`,
+		`data: {"choices": [{"index": 0, "delta": {"tool_calls": [{"index": 0, "function": {"arguments": ":1}"}}]}}]}`,
+		`data: {"choices": [{"index": 0, "delta": {"tool_calls": [{"index": 1, "id": "call_2", "function": {"name": "search", "arguments": "{}"}}]}, "finish_reason": "tool_calls"}]}`,
+		`data: {"choices": [], "usage": {"prompt_tokens": 3, "completion_tokens": 5, "total_tokens": 8}}`,
+		`data: [DONE]`,
+	}, "\n\n") + "\n\n"
+	// Write in small pieces, which split the lines.
+	for i := 0; i < len(chunks); i += 7 {
+		end := min(i+7, len(chunks))
+		_, _ = writer.Write([]byte(chunks[i:end]))
+	}
+	writer.Close()
+
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	events := parseEvents(t, w.Body.String())
+	var names []string
+	for _, event := range events {
+		names = append(names, event.name)
+	}
+	assert.Equal(t, []string{
+		"message_start",
+		"content_block_start",
+		"content_block_delta",
+		"content_block_delta",
+		"content_block_stop",
+		"content_block_start",
+		"content_block_delta",
+		"content_block_delta",
+		"content_block_stop",
+		"content_block_start",
+		"content_block_delta",
+		"content_block_stop",
+		"message_delta",
+		"message_stop",
+	}, names)
+
+	assert.Equal(t, "m", events[0].data["message"].(map[string]interface{})["model"])
+	assert.Equal(t, map[string]interface{}{"type": "text", "text": ""}, events[1].data["content_block"])
+	assert.Equal(t, map[string]interface{}{"type": "text_delta", "text": "Hel"}, events[2].data["delta"])
+	assert.Equal(t, float64(1), events[5].data["index"])
+	assert.Equal(t, map[string]interface{}{"type": "tool_use", "id": "call_1", "name": "lookup", "input": map[string]interface{}{}},
+		events[5].data["content_block"])
+	assert.Equal(t, map[string]interface{}{"type": "input_json_delta", "partial_json": `{"q"`}, events[6].data["delta"])
+	assert.Equal(t, float64(2), events[11].data["index"])
+	assert.Equal(t, map[string]interface{}{"stop_reason": "tool_use", "stop_sequence": nil}, events[12].data["delta"])
+	assert.Equal(t, map[string]interface{}{"input_tokens": float64(3), "output_tokens": float64(5)}, events[12].data["usage"])
+}
+
+func TestWriterStreamingWithoutDone(t *testing.T) {
+	w, writer := newTestWriter(true)
+	_, _ = writer.Write([]byte("data: {\"choices\": [{\"index\": 0, \"delta\": {\"content\": \"hi\"}, \"finish_reason\": \"length\"}]}\n\n"))
+	writer.Close()
+
+	events := parseEvents(t, w.Body.String())
+	assert.Equal(t, "message_stop", events[len(events)-1].name)
+	assert.Equal(t, "max_tokens", events[len(events)-2].data["delta"].(map[string]interface{})["stop_reason"])
+}
@@ -18,6 +18,7 @@ package metrics
 
 import (
 	"fmt"
+	"math"
 	"net/http"
 	"time"
 
@@ -70,3 +71,32 @@ func LastPeriodAvg(previous, current *dto.Histogram) float64 {
 
 	return deltaSum / float64(deltaCount)
 }
+
+// HistogramQuantile estimates the q-quantile (0 < q < 1) of the observations of a histogram, by
+// linear interpolation within the bucket holding it, the way PromQL histogram_quantile does.
+// It returns 0 when the histogram has no observation.
+func HistogramQuantile(q float64, h *dto.Histogram) float64 {
+	count := h.GetSampleCount()
+	if count == 0 {
+		return 0
+	}
+	rank := q * float64(count)
+
+	lowerBound, lowerCount := 0.0, 0.0
+	for _, bucket := range h.GetBucket() {
+		upperBound := bucket.GetUpperBound()
+		upperCount := float64(bucket.GetCumulativeCount())
+		if upperCount >= rank {
+			if math.IsInf(upperBound, 1) {
+				return lowerBound
+			}
+			if upperCount == lowerCount {
+				return upperBound
+			}
+			return lowerBound + (upperBound-lowerBound)*(rank-lowerCount)/(upperCount-lowerCount)
+		}
+		lowerBound, lowerCount = upperBound, upperCount
+	}
+	// The quantile is in the implicit +Inf bucket.
+	return lowerBound
+}
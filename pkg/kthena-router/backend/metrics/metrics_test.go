@@ -0,0 +1,46 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"math"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/ptr"
+)
+
+func TestHistogramQuantile(t *testing.T) {
+	histogram := &dto.Histogram{
+		SampleCount: ptr.To[uint64](100),
+		Bucket: []*dto.Bucket{
+			{UpperBound: ptr.To(0.1), CumulativeCount: ptr.To[uint64](50)},
+			{UpperBound: ptr.To(0.5), CumulativeCount: ptr.To[uint64](90)},
+			{UpperBound: ptr.To(1.0), CumulativeCount: ptr.To[uint64](98)},
+			{UpperBound: ptr.To(math.Inf(1)), CumulativeCount: ptr.To[uint64](100)},
+		},
+	}
+
+	assert.InDelta(t, 0.05, HistogramQuantile(0.25, histogram), 1e-9)
+	assert.InDelta(t, 0.1, HistogramQuantile(0.5, histogram), 1e-9)
+	assert.InDelta(t, 0.3, HistogramQuantile(0.7, histogram), 1e-9)
+	assert.InDelta(t, 0.75, HistogramQuantile(0.94, histogram), 1e-9)
+	// The quantile in the +Inf bucket is the largest finite bound.
+	assert.InDelta(t, 1.0, HistogramQuantile(0.99, histogram), 1e-9)
+	assert.Equal(t, 0.0, HistogramQuantile(0.5, &dto.Histogram{}))
+}
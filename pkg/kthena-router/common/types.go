@@ -17,14 +17,22 @@ limitations under the License.
 package common
 
 const (
-	UserIdKey     = "user_id"
-	TokenUsageKey = "token_usage"
+	UserIdKey        = "user_id"
+	ClaimsKey        = "claims"
+	AllowedModelsKey = "allowed_models"
+	TokenUsageKey    = "token_usage"
 )
 
 // Message represents a single message in a chat conversation
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+
+	// MediaHashes are the hashes of the image, audio and video parts of the message content, in order.
+	// The text parts are concatenated into Content.
+	MediaHashes []uint64 `json:"-"`
+	// MediaOffsets are the offsets in Content where the media parts appear, in order.
+	MediaOffsets []int `json:"-"`
 }
 
 // ChatMessage represents either a direct text prompt or structured chat messages
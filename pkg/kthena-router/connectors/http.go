@@ -23,6 +23,7 @@ import (
 	"k8s.io/klog/v2"
 
 	"github.com/volcano-sh/kthena/pkg/kthena-router/metrics"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/tracing"
 )
 
 // HTTPConnector implements simple HTTP-based KV transfer
@@ -44,8 +45,7 @@ func (h *HTTPConnector) Name() string {
 
 // prefill executes prefill request
 func (h *HTTPConnector) prefill(req *http.Request, prefillAddr string) error {
-	req.URL.Host = prefillAddr
-	req.URL.Scheme = "http"
+	SetUpstreamURL(req, prefillAddr)
 
 	klog.V(4).Infof("Sending prefill request to %s", prefillAddr)
 	return prefillerProxy(nil, req)
@@ -53,8 +53,7 @@ func (h *HTTPConnector) prefill(req *http.Request, prefillAddr string) error {
 
 // decode executes decode request and streams response
 func (h *HTTPConnector) decode(c *gin.Context, req *http.Request, decodeAddr string) (int, error) {
-	req.URL.Host = decodeAddr
-	req.URL.Scheme = "http"
+	SetUpstreamURL(req, decodeAddr)
 
 	klog.V(4).Infof("Sending decode request to %s", decodeAddr)
 	return decoderProxy(c, req)
@@ -86,7 +85,9 @@ func (h *HTTPConnector) Proxy(c *gin.Context, reqBody map[string]interface{}, pr
 		metricsRecorder.IncActiveUpstreamRequests()
 	}
 
-	err := h.prefill(h.prefillRequest, prefillAddr)
+	prefillRequest, span := startPhase(c.Request.Context(), h.prefillRequest, tracing.SpanPrefill, prefillAddr)
+	err := h.prefill(prefillRequest, prefillAddr)
+	tracing.End(span, err)
 
 	// End prefill phase metrics and handle upstream requests
 	if metricsRecorder != nil {
@@ -107,7 +108,9 @@ func (h *HTTPConnector) Proxy(c *gin.Context, reqBody map[string]interface{}, pr
 		return 0, err
 	}
 
-	result, decodeErr := h.decode(c, h.decodeRequest, decodeAddr)
+	decodeRequest, span := startPhase(c.Request.Context(), h.decodeRequest, tracing.SpanDecode, decodeAddr)
+	result, decodeErr := h.decode(c, decodeRequest, decodeAddr)
+	tracing.End(span, decodeErr)
 
 	// End decode phase metrics and decrement upstream request
 	if metricsRecorder != nil {
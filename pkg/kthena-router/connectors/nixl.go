@@ -17,9 +17,7 @@ limitations under the License.
 package connectors
 
 import (
-	"bytes"
 	"encoding/json"
-	"fmt"
 	"io"
 	"maps"
 	"net/http"
@@ -29,6 +27,7 @@ import (
 	"k8s.io/klog/v2"
 
 	"github.com/volcano-sh/kthena/pkg/kthena-router/metrics"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/tracing"
 )
 
 // NIXLConnector implements high-performance distributed in-memory KV cache using NIXL
@@ -76,7 +75,9 @@ func (n *NIXLConnector) Proxy(c *gin.Context, reqBody map[string]interface{}, pr
 	}
 
 	// 1. send prefill request
-	kvTransferParams, err := n.prefill(n.prefillRequest, prefillAddr)
+	prefillRequest, span := startPhase(c.Request.Context(), n.prefillRequest, tracing.SpanPrefill, prefillAddr)
+	kvTransferParams, err := n.prefill(prefillRequest, prefillAddr)
+	tracing.End(span, err)
 
 	// End prefill phase metrics and handle upstream requests
 	if metricsRecorder != nil {
@@ -99,7 +100,9 @@ func (n *NIXLConnector) Proxy(c *gin.Context, reqBody map[string]interface{}, pr
 
 	// 2. send decode request
 	decodeReq := n.buildDecodeRequest(c, n.decodeRequestBody, kvTransferParams)
+	decodeReq, span = startPhase(c.Request.Context(), decodeReq, tracing.SpanDecode, decodeAddr)
 	result, decodeErr := n.decode(c, decodeReq, decodeAddr)
+	tracing.End(span, decodeErr)
 
 	// End decode phase metrics and decrement upstream request
 	if metricsRecorder != nil {
@@ -116,18 +119,20 @@ func (n *NIXLConnector) Proxy(c *gin.Context, reqBody map[string]interface{}, pr
 
 // prefill send prefill request, returns kv_transfer_params
 func (n *NIXLConnector) prefill(req *http.Request, prefillAddr string) (interface{}, error) {
-	req.URL.Host = prefillAddr
-	req.URL.Scheme = "http"
+	SetUpstreamURL(req, prefillAddr)
 	klog.V(4).Infof("%s prefill: sending to %s", n.name, req.URL.String())
 
 	// Send prefill request
-	resp, err := http.DefaultTransport.RoundTrip(req)
-	if err != nil {
+	if err := RewindRequestBody(req); err != nil {
 		return nil, err
 	}
+	resp, err := RoundTrip(req)
+	if err != nil {
+		return nil, &UpstreamPhaseError{Phase: "prefill", Err: err}
+	}
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("prefill request failed with status %d", resp.StatusCode)
+		return nil, &UpstreamStatusError{Phase: "prefill", StatusCode: resp.StatusCode}
 	}
 
 	// Parse prefill response
@@ -156,9 +161,8 @@ func (n *NIXLConnector) buildDecodeRequest(c *gin.Context, reqBody map[string]in
 
 	// build request
 	reqCopy := c.Request.Clone(c.Request.Context())
-	reqCopy.URL.Scheme = "http"
-	reqCopy.Body = io.NopCloser(bytes.NewBuffer(body))
-	reqCopy.ContentLength = int64(len(body))
+	reqCopy.URL.Scheme = UpstreamFromContext(reqCopy.Context()).Scheme
+	setRequestBody(reqCopy, body)
 
 	return reqCopy
 }
@@ -166,8 +170,7 @@ func (n *NIXLConnector) buildDecodeRequest(c *gin.Context, reqBody map[string]in
 // decode send decode request with streaming response
 func (n *NIXLConnector) decode(c *gin.Context, req *http.Request, decodeAddr string) (int, error) {
 	// Set kv_transfer_params from prefill response
-	req.URL.Host = decodeAddr
-	req.URL.Scheme = "http"
+	SetUpstreamURL(req, decodeAddr)
 
 	klog.V(4).Infof("%s decode: sending to %s", n.name, req.URL.String())
 
@@ -199,9 +202,8 @@ func (n *NIXLConnector) buildPrefillRequest(req *http.Request, reqBody map[strin
 	}
 
 	prefillReq := req.Clone(req.Context())
-	prefillReq.URL.Scheme = "http"
-	prefillReq.Body = io.NopCloser(bytes.NewBuffer(body))
-	prefillReq.ContentLength = int64(len(body))
+	prefillReq.URL.Scheme = UpstreamFromContext(prefillReq.Context()).Scheme
+	setRequestBody(prefillReq, body)
 
 	return prefillReq
 }
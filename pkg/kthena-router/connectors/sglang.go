@@ -17,11 +17,10 @@ limitations under the License.
 package connectors
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"math/rand"
 	"net"
 	"net/http"
@@ -31,6 +30,7 @@ import (
 
 	v1alpha1 "github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/metrics"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/tracing"
 )
 
 // ConnectorTypeSGLang is the KVConnectorType value for SGLang disaggregated prefill-decode.
@@ -151,15 +151,18 @@ func (s *SGLangConnector) Proxy(c *gin.Context, reqBody map[string]interface{},
 	type prefillOutcome struct{ err error }
 	prefillCh := make(chan prefillOutcome, 1)
 
+	prefillRequest, prefillSpan := startPhase(prefillCtx, s.prefillRequest.WithContext(prefillCtx), tracing.SpanPrefill, prefillAddr)
 	go func() {
-		prefillCh <- prefillOutcome{
-			err: s.prefill(s.prefillRequest.WithContext(prefillCtx), prefillAddr),
-		}
+		err := s.prefill(prefillRequest, prefillAddr)
+		tracing.End(prefillSpan, err)
+		prefillCh <- prefillOutcome{err: err}
 	}()
 
 	// Run decode in the current goroutine so that streaming writes reach the
 	// gin.Context from the request-handling goroutine.
-	result, decodeErr := s.decode(c, s.decodeRequest, decodeAddr)
+	decodeRequest, decodeSpan := startPhase(c.Request.Context(), s.decodeRequest, tracing.SpanDecode, decodeAddr)
+	result, decodeErr := s.decode(c, decodeRequest, decodeAddr)
+	tracing.End(decodeSpan, decodeErr)
 
 	if decodeErr != nil {
 		// Decode failed: cancel the prefill context so the prefill goroutine is
@@ -186,7 +189,8 @@ func (s *SGLangConnector) Proxy(c *gin.Context, reqBody map[string]interface{},
 		metricsRecorder.DecActiveUpstreamRequests()
 	}
 
-	if prefillResult.err != nil {
+	// A prefill cancelled because the decode failed is not the cause of the failure.
+	if prefillResult.err != nil && (decodeErr == nil || !errors.Is(prefillResult.err, context.Canceled)) {
 		klog.Errorf("sglang prefill error (bootstrap_room=%d): %v", s.bootstrapRoom, prefillResult.err)
 		return http.StatusInternalServerError, prefillResult.err
 	}
@@ -195,15 +199,13 @@ func (s *SGLangConnector) Proxy(c *gin.Context, reqBody map[string]interface{},
 }
 
 func (s *SGLangConnector) prefill(req *http.Request, prefillAddr string) error {
-	req.URL.Host = prefillAddr
-	req.URL.Scheme = "http"
+	SetUpstreamURL(req, prefillAddr)
 	klog.V(4).Infof("sglang prefill: sending to %s (bootstrap_room=%d)", req.URL.String(), s.bootstrapRoom)
 	return prefillerProxy(nil, req)
 }
 
 func (s *SGLangConnector) decode(c *gin.Context, req *http.Request, decodeAddr string) (int, error) {
-	req.URL.Host = decodeAddr
-	req.URL.Scheme = "http"
+	SetUpstreamURL(req, decodeAddr)
 	klog.V(4).Infof("sglang decode: sending to %s (bootstrap_room=%d)", req.URL.String(), s.bootstrapRoom)
 	return decoderProxy(c, req)
 }
@@ -214,8 +216,7 @@ func buildRequest(req *http.Request, reqBody map[string]interface{}) (*http.Requ
 		return nil, fmt.Errorf("sglang: failed to marshal request body: %w", err)
 	}
 	reqCopy := req.Clone(req.Context())
-	reqCopy.URL.Scheme = "http"
-	reqCopy.Body = io.NopCloser(bytes.NewBuffer(body))
-	reqCopy.ContentLength = int64(len(body))
+	reqCopy.URL.Scheme = UpstreamFromContext(reqCopy.Context()).Scheme
+	setRequestBody(reqCopy, body)
 	return reqCopy, nil
 }
@@ -19,26 +19,117 @@ package connectors
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/common"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/handlers"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 	"k8s.io/klog/v2"
 )
 
+// UpstreamStatusError is returned when a model server pod answers with a non-2xx status code.
+type UpstreamStatusError struct {
+	// Phase is the kind of upstream request that failed, e.g. "prefill" or "decode".
+	Phase      string
+	StatusCode int
+}
+
+func (e *UpstreamStatusError) Error() string {
+	return fmt.Sprintf("%s request failed with status %d", e.Phase, e.StatusCode)
+}
+
+// UpstreamPhaseError is returned when the prefill or decode request of a PD disaggregated request
+// gets no response, e.g. because the connection to the pod failed.
+type UpstreamPhaseError struct {
+	// Phase is the kind of upstream request that failed, e.g. "prefill" or "decode".
+	Phase string
+	Err   error
+}
+
+func (e *UpstreamPhaseError) Error() string {
+	return fmt.Sprintf("%s request failed: %v", e.Phase, e.Err)
+}
+
+func (e *UpstreamPhaseError) Unwrap() error {
+	return e.Err
+}
+
+// FailedPhase returns the phase of the PD disaggregated request the error comes from, or an empty
+// string if it is unknown.
+func FailedPhase(err error) string {
+	var statusErr *UpstreamStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Phase
+	}
+	var phaseErr *UpstreamPhaseError
+	if errors.As(err, &phaseErr) {
+		return phaseErr.Phase
+	}
+	return ""
+}
+
+// setRequestBody sets the body of req and makes it replayable through GetBody,
+// so that the same request can be sent again when it is retried on another pod.
+func setRequestBody(req *http.Request, body []byte) {
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+}
+
+// RewindRequestBody resets the body of a request built by this package before it is sent.
+// A request body can only be read once, so this must be called before every attempt.
+func RewindRequestBody(req *http.Request) error {
+	if req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return fmt.Errorf("failed to rewind request body: %w", err)
+	}
+	req.Body = body
+	return nil
+}
+
+// startPhase starts the span of the prefill or decode request sent to addr, as a child of the span
+// of the upstream attempt carried by parent, and returns req carrying it. The requests of a
+// connector are reused by the attempts, so their own context may carry the span of another attempt.
+func startPhase(parent context.Context, req *http.Request, name, addr string) (*http.Request, trace.Span) {
+	attrs := []attribute.KeyValue{semconv.ServerAddress(addr)}
+	if host, port, err := net.SplitHostPort(addr); err == nil {
+		attrs = []attribute.KeyValue{semconv.ServerAddress(host)}
+		if p, err := strconv.Atoi(port); err == nil {
+			attrs = append(attrs, semconv.ServerPort(p))
+		}
+	}
+	_, span := tracing.Start(parent, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return req.WithContext(trace.ContextWithSpan(req.Context(), span)), span
+}
+
 func prefillerProxy(_ *gin.Context, req *http.Request) error {
-	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err := RewindRequestBody(req); err != nil {
+		return err
+	}
+	resp, err := RoundTrip(req)
 	if err != nil {
-		return fmt.Errorf("prefill request failed: %w", err)
+		return &UpstreamPhaseError{Phase: "prefill", Err: err}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("prefill request failed with status %d", resp.StatusCode)
+		return &UpstreamStatusError{Phase: "prefill", StatusCode: resp.StatusCode}
 	}
 
 	klog.V(4).Infof("Prefill request completed successfully")
@@ -46,14 +137,17 @@ func prefillerProxy(_ *gin.Context, req *http.Request) error {
 }
 
 func decoderProxy(c *gin.Context, req *http.Request) (int, error) {
-	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err := RewindRequestBody(req); err != nil {
+		return 0, err
+	}
+	resp, err := RoundTrip(req)
 	if err != nil {
-		return 0, fmt.Errorf("decode request failed: %w", err)
+		return 0, &UpstreamPhaseError{Phase: "decode", Err: err}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return 0, fmt.Errorf("decode request failed with status %d", resp.StatusCode)
+		return 0, &UpstreamStatusError{Phase: "decode", StatusCode: resp.StatusCode}
 	}
 
 	// Copy response headers
@@ -100,9 +194,8 @@ func buildPrefillRequest(req *http.Request, modelRequest map[string]interface{})
 
 	// build request
 	reqCopy := req.Clone(req.Context())
-	reqCopy.URL.Scheme = "http"
-	reqCopy.Body = io.NopCloser(bytes.NewBuffer(body))
-	reqCopy.ContentLength = int64(len(body))
+	reqCopy.URL.Scheme = UpstreamFromContext(reqCopy.Context()).Scheme
+	setRequestBody(reqCopy, body)
 
 	return reqCopy
 }
@@ -115,9 +208,8 @@ func BuildDecodeRequest(c *gin.Context, req *http.Request, modelRequest map[stri
 	}
 
 	// build request
-	req.URL.Scheme = "http"
-	req.Body = io.NopCloser(bytes.NewBuffer(body))
-	req.ContentLength = int64(len(body))
+	req.URL.Scheme = UpstreamFromContext(req.Context()).Scheme
+	setRequestBody(req, body)
 
 	return req
 }
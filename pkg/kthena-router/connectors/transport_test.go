@@ -18,16 +18,22 @@ package connectors
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/common"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/tracing"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestIsTokenUsageEnabled(t *testing.T) {
@@ -646,3 +652,47 @@ func TestDecoderProxy(t *testing.T) {
 		})
 	}
 }
+
+func TestHTTPConnectorProxy_Tracing(t *testing.T) {
+	traceparents := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		// The prefill request generates a single token.
+		phase := tracing.SpanDecode
+		if body["max_tokens"] == float64(1) {
+			phase = tracing.SpanPrefill
+		}
+		traceparents[phase] = r.Header.Get("traceparent")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"response-id","usage":{"completion_tokens":5}}`)
+	}))
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, attempt := provider.Tracer("test").Start(context.Background(), tracing.SpanUpstream)
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", "/v1/chat/completions", nil)
+	c, _ := gin.CreateTestContext(CreateTestResponseRecorder())
+	c.Request = req
+	_, err := NewHTTPConnector().Proxy(c, map[string]interface{}{"model": "test-model", "prompt": "hello", "max_tokens": 100}, addr, addr)
+	require.NoError(t, err)
+	attempt.End()
+
+	// The prefill and decode requests are sent in spans of their own, children of the attempt.
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	for _, phase := range []string{tracing.SpanPrefill, tracing.SpanDecode} {
+		span, ok := spans[phase]
+		if !assert.True(t, ok, "span %s", phase) {
+			continue
+		}
+		assert.Equal(t, attempt.SpanContext().SpanID(), span.Parent().SpanID())
+		expected := fmt.Sprintf("00-%s-%s-01", span.SpanContext().TraceID(), span.SpanContext().SpanID())
+		assert.Equal(t, expected, traceparents[phase], "traceparent of %s", phase)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectors
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/tracing"
+)
+
+// Upstream describes how the router connects to the model server pods of a request.
+type Upstream struct {
+	// Scheme is the URL scheme used to reach the pods, "http" or "https".
+	Scheme string
+	// Transport sends the requests to the pods.
+	Transport http.RoundTripper
+}
+
+var defaultUpstream = &Upstream{
+	Scheme:    "http",
+	Transport: http.DefaultTransport,
+}
+
+type upstreamKey struct{}
+
+// DefaultUpstream returns the upstream used by requests without one, which connects over plain HTTP.
+func DefaultUpstream() *Upstream {
+	return defaultUpstream
+}
+
+// WithUpstream returns a copy of ctx carrying the upstream used for the requests derived from it.
+// Requests without an upstream are sent over plain HTTP with http.DefaultTransport.
+func WithUpstream(ctx context.Context, upstream *Upstream) context.Context {
+	return context.WithValue(ctx, upstreamKey{}, upstream)
+}
+
+// UpstreamFromContext returns the upstream carried by ctx, or the plain HTTP upstream if there is none.
+func UpstreamFromContext(ctx context.Context) *Upstream {
+	if upstream, ok := ctx.Value(upstreamKey{}).(*Upstream); ok && upstream != nil {
+		return upstream
+	}
+	return defaultUpstream
+}
+
+// SetUpstreamURL points req to the pod listening at addr, using the scheme of the request's upstream.
+func SetUpstreamURL(req *http.Request, addr string) {
+	req.URL.Host = addr
+	req.URL.Scheme = UpstreamFromContext(req.Context()).Scheme
+}
+
+// RoundTrip sends req with the transport of the request's upstream, with the trace context of the
+// request in its traceparent header.
+func RoundTrip(req *http.Request) (*http.Response, error) {
+	tracing.Inject(req.Context(), req.Header)
+	return UpstreamFromContext(req.Context()).Transport.RoundTrip(req)
+}
@@ -0,0 +1,79 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpstreamFromContext(t *testing.T) {
+	assert.Equal(t, DefaultUpstream(), UpstreamFromContext(context.Background()))
+
+	upstream := &Upstream{Scheme: "https", Transport: http.DefaultTransport}
+	ctx := WithUpstream(context.Background(), upstream)
+	assert.Equal(t, upstream, UpstreamFromContext(ctx))
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", "/v1/completions", nil)
+	SetUpstreamURL(req, "10.0.0.1:8443")
+	assert.Equal(t, "https://10.0.0.1:8443/v1/completions", req.URL.String())
+}
+
+func TestHTTPConnectorProxyHTTPS(t *testing.T) {
+	var prefillCalls, decodeCalls atomic.Int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil {
+			t.Error("expected request over TLS")
+		}
+		body, _ := parseRequestBody(r)
+		// The prefill request only generates a single token.
+		if body["max_tokens"] == 1.0 {
+			prefillCalls.Add(1)
+			fmt.Fprint(w, `{"id":"prefill"}`)
+			return
+		}
+		decodeCalls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"decode","usage":{"prompt_tokens":1,"completion_tokens":2,"total_tokens":3}}`)
+	}))
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "https://")
+
+	req, _ := http.NewRequest("POST", "/v1/completions", nil)
+	req = req.WithContext(WithUpstream(req.Context(), &Upstream{Scheme: "https", Transport: server.Client().Transport}))
+	w := CreateTestResponseRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	reqBody := map[string]interface{}{
+		"model":  "test-model",
+		"prompt": "hello",
+	}
+	_, err := NewHTTPConnector().Proxy(c, reqBody, addr, addr)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), prefillCalls.Load())
+	assert.Equal(t, int32(1), decodeCalls.Load())
+	assert.Contains(t, w.Body.String(), `"id":"decode"`)
+}
@@ -0,0 +1,233 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+
+	informersv1alpha1 "github.com/volcano-sh/kthena/client-go/informers/externalversions"
+	listerv1alpha1 "github.com/volcano-sh/kthena/client-go/listers/networking/v1alpha1"
+	aiv1alpha1 "github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/datastore"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/filters/auth"
+)
+
+// SecretController syncs Secrets into the store, so that the router can load
+// the credentials referenced by routing resources, e.g. the TLS certificates of a ModelServer.
+// Only the Secrets referenced by the TLS config of a ModelServer and the API key Secrets are stored.
+type SecretController struct {
+	secretLister      corelisters.SecretLister
+	modelServerLister listerv1alpha1.ModelServerLister
+	secretSynced      cache.InformerSynced
+	modelServerSynced cache.InformerSynced
+
+	registration            cache.ResourceEventHandlerRegistration
+	modelServerRegistration cache.ResourceEventHandlerRegistration
+
+	workqueue   workqueue.TypedRateLimitingInterface[any]
+	initialSync *atomic.Bool
+	store       datastore.Store
+}
+
+func NewSecretController(
+	kthenaInformerFactory informersv1alpha1.SharedInformerFactory,
+	kubeInformerFactory informers.SharedInformerFactory,
+	store datastore.Store,
+) *SecretController {
+	secretInformer := kubeInformerFactory.Core().V1().Secrets()
+	modelServerInformer := kthenaInformerFactory.Networking().V1alpha1().ModelServers()
+
+	controller := &SecretController{
+		secretLister:      secretInformer.Lister(),
+		modelServerLister: modelServerInformer.Lister(),
+		secretSynced:      secretInformer.Informer().HasSynced,
+		modelServerSynced: modelServerInformer.Informer().HasSynced,
+		workqueue:         workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[any]()),
+		initialSync:       &atomic.Bool{},
+		store:             store,
+	}
+
+	controller.registration, _ = secretInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.enqueueSecret,
+		UpdateFunc: func(old, new interface{}) {
+			controller.enqueueSecret(new)
+		},
+		DeleteFunc: controller.enqueueSecret,
+	})
+
+	// A Secret is stored or dropped when a ModelServer starts or stops referencing it
+	controller.modelServerRegistration, _ = modelServerInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.enqueueReferencedSecrets,
+		UpdateFunc: func(old, new interface{}) {
+			controller.enqueueReferencedSecrets(old)
+			controller.enqueueReferencedSecrets(new)
+		},
+		DeleteFunc: controller.enqueueReferencedSecrets,
+	})
+
+	return controller
+}
+
+func (c *SecretController) Run(stopCh <-chan struct{}) error {
+	defer utilruntime.HandleCrash()
+	defer c.workqueue.ShutDown()
+
+	if ok := cache.WaitForCacheSync(stopCh, c.registration.HasSynced, c.modelServerRegistration.HasSynced); !ok {
+		return fmt.Errorf("failed to wait for caches to sync")
+	}
+	// add initialSync signal
+	c.workqueue.Add(initialSyncSignal)
+
+	go wait.Until(c.runWorker, time.Second, stopCh)
+
+	<-stopCh
+	return nil
+}
+
+func (c *SecretController) HasSynced() bool {
+	return c.initialSync.Load()
+}
+
+func (c *SecretController) runWorker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *SecretController) processNextWorkItem() bool {
+	obj, shutdown := c.workqueue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.workqueue.Done(obj)
+
+	if obj == initialSyncSignal {
+		klog.V(2).Info("initial secrets have been synced")
+		c.workqueue.Forget(obj)
+		c.initialSync.Store(true)
+		return true
+	}
+
+	var key string
+	var ok bool
+	if key, ok = obj.(string); !ok {
+		c.workqueue.Forget(obj)
+		utilruntime.HandleError(fmt.Errorf("expected string in workqueue but got %#v", obj))
+		return true
+	}
+
+	if err := c.syncHandler(key); err != nil {
+		if c.workqueue.NumRequeues(key) < maxRetries {
+			klog.V(2).Infof("error syncing secret %q: %s, requeuing", key, err.Error())
+			c.workqueue.AddRateLimited(key)
+			return true
+		}
+		klog.V(2).Infof("giving up on syncing secret %q after %d retries: %s", key, maxRetries, err)
+		c.workqueue.Forget(obj)
+	}
+	return true
+}
+
+func (c *SecretController) syncHandler(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("invalid resource key: %s", key))
+		return nil
+	}
+
+	secret, err := c.secretLister.Secrets(namespace).Get(name)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if secret == nil || !c.isWanted(secret) {
+		_ = c.store.DeleteSecret(types.NamespacedName{Namespace: namespace, Name: name})
+		return nil
+	}
+
+	return c.store.AddOrUpdateSecret(secret)
+}
+
+// isWanted returns whether the router uses the Secret, either as an API key
+// or as the CA bundle or the client certificate of a ModelServer in its namespace.
+func (c *SecretController) isWanted(secret *corev1.Secret) bool {
+	if secret.Labels[auth.APIKeyLabel] == "true" {
+		return true
+	}
+	modelServers, err := c.modelServerLister.ModelServers(secret.Namespace).List(labels.Everything())
+	if err != nil {
+		klog.Errorf("failed to list model servers in namespace %s: %v", secret.Namespace, err)
+		return false
+	}
+	for _, ms := range modelServers {
+		for _, name := range referencedSecrets(ms) {
+			if name == secret.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// referencedSecrets returns the names of the Secrets referenced by the TLS config of the ModelServer.
+func referencedSecrets(ms *aiv1alpha1.ModelServer) []string {
+	tlsSpec := ms.Spec.WorkloadPort.TLS
+	if tlsSpec == nil {
+		return nil
+	}
+	var names []string
+	for _, name := range []string{tlsSpec.CASecretName, tlsSpec.ClientCertificateSecretName} {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (c *SecretController) enqueueReferencedSecrets(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	ms, ok := obj.(*aiv1alpha1.ModelServer)
+	if !ok {
+		return
+	}
+	for _, name := range referencedSecrets(ms) {
+		c.workqueue.Add(ms.Namespace + "/" + name)
+	}
+}
+
+func (c *SecretController) enqueueSecret(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.workqueue.Add(key)
+}
@@ -0,0 +1,80 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	kthenafake "github.com/volcano-sh/kthena/client-go/clientset/versioned/fake"
+	informersv1alpha1 "github.com/volcano-sh/kthena/client-go/informers/externalversions"
+	aiv1alpha1 "github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/datastore"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/filters/auth"
+)
+
+func TestSecretController_SyncHandler(t *testing.T) {
+	kubeInformerFactory := informers.NewSharedInformerFactory(kubefake.NewSimpleClientset(), 0)
+	kthenaInformerFactory := informersv1alpha1.NewSharedInformerFactory(kthenafake.NewSimpleClientset(), 0)
+	store := datastore.New()
+	controller := NewSecretController(kthenaInformerFactory, kubeInformerFactory, store)
+
+	secrets := kubeInformerFactory.Core().V1().Secrets().Informer().GetIndexer()
+	modelServers := kthenaInformerFactory.Networking().V1alpha1().ModelServers().Informer().GetIndexer()
+	for _, secret := range []*corev1.Secret{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "model-ca"}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "model-ca"}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api-key", Labels: map[string]string{auth.APIKeyLabel: "true"}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "unrelated"}},
+	} {
+		assert.NoError(t, secrets.Add(secret))
+	}
+	ms := &aiv1alpha1.ModelServer{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ms-1"},
+		Spec: aiv1alpha1.ModelServerSpec{
+			WorkloadPort: aiv1alpha1.WorkloadPort{TLS: &aiv1alpha1.WorkloadTLS{CASecretName: "model-ca"}},
+		},
+	}
+	assert.NoError(t, modelServers.Add(ms))
+
+	tests := []struct {
+		secret types.NamespacedName
+		stored bool
+	}{
+		{secret: types.NamespacedName{Namespace: "default", Name: "model-ca"}, stored: true},
+		{secret: types.NamespacedName{Namespace: "other", Name: "model-ca"}, stored: false},
+		{secret: types.NamespacedName{Namespace: "default", Name: "api-key"}, stored: true},
+		{secret: types.NamespacedName{Namespace: "default", Name: "unrelated"}, stored: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.secret.String(), func(t *testing.T) {
+			assert.NoError(t, controller.syncHandler(tt.secret.String()))
+			assert.Equal(t, tt.stored, store.GetSecret(tt.secret) != nil)
+		})
+	}
+
+	// The Secret is dropped once no ModelServer references it
+	assert.NoError(t, modelServers.Delete(ms))
+	assert.NoError(t, controller.syncHandler("default/model-ca"))
+	assert.Nil(t, store.GetSecret(types.NamespacedName{Namespace: "default", Name: "model-ca"}))
+}
@@ -0,0 +1,320 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package datastore
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// OutlierDetectionConfig configures the passive health tracking of pods. A pod failing requests
+// is ejected from scheduling for an exponentially growing period, then receives a limited number
+// of trial requests (half-open) before it is fully restored.
+type OutlierDetectionConfig struct {
+	// ConsecutiveFailures ejects a pod after this many failed requests in a row. 0 disables it.
+	ConsecutiveFailures int
+	// FailureRateThreshold ejects a pod whose ratio of failed requests within an interval reaches
+	// this value, once the pod served MinRequests requests in the interval. 0 disables it.
+	FailureRateThreshold float64
+	MinRequests          int
+	Interval             time.Duration
+	// BaseEjectionTime is the duration of the first ejection, doubled by each following ejection up to MaxEjectionTime.
+	BaseEjectionTime time.Duration
+	MaxEjectionTime  time.Duration
+	// HalfOpenRequests is the number of trial requests that must succeed to restore an ejected pod.
+	HalfOpenRequests int
+}
+
+// DefaultOutlierDetectionConfig returns the default outlier detection configuration.
+func DefaultOutlierDetectionConfig() OutlierDetectionConfig {
+	return OutlierDetectionConfig{
+		ConsecutiveFailures:  5,
+		FailureRateThreshold: 0.5,
+		MinRequests:          10,
+		Interval:             10 * time.Second,
+		BaseEjectionTime:     30 * time.Second,
+		MaxEjectionTime:      5 * time.Minute,
+		HalfOpenRequests:     3,
+	}
+}
+
+// WithOutlierDetectionConfig sets the outlier detection configuration of the store.
+func WithOutlierDetectionConfig(cfg OutlierDetectionConfig) Option {
+	return func(s *store) {
+		s.outlierDetectionConfig = cfg
+	}
+}
+
+// createOutlierDetectionConfig reads outlier detection configuration from environment variables.
+func createOutlierDetectionConfig() OutlierDetectionConfig {
+	cfg := DefaultOutlierDetectionConfig()
+
+	if v := os.Getenv("OUTLIER_CONSECUTIVE_FAILURES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.ConsecutiveFailures = n
+		} else {
+			klog.Warningf("Invalid OUTLIER_CONSECUTIVE_FAILURES: %q, using default %d", v, cfg.ConsecutiveFailures)
+		}
+	}
+
+	if v := os.Getenv("OUTLIER_FAILURE_RATE_THRESHOLD"); v != "" {
+		if n, err := strconv.ParseFloat(v, 64); err == nil && n >= 0 && n <= 1 {
+			cfg.FailureRateThreshold = n
+		} else {
+			klog.Warningf("Invalid OUTLIER_FAILURE_RATE_THRESHOLD: %q, using default %v", v, cfg.FailureRateThreshold)
+		}
+	}
+
+	if v := os.Getenv("OUTLIER_MIN_REQUESTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.MinRequests = n
+		} else {
+			klog.Warningf("Invalid OUTLIER_MIN_REQUESTS: %q, using default %d", v, cfg.MinRequests)
+		}
+	}
+
+	if v := os.Getenv("OUTLIER_HALF_OPEN_REQUESTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.HalfOpenRequests = n
+		} else {
+			klog.Warningf("Invalid OUTLIER_HALF_OPEN_REQUESTS: %q, using default %d", v, cfg.HalfOpenRequests)
+		}
+	}
+
+	for key, d := range map[string]*time.Duration{
+		"OUTLIER_INTERVAL":           &cfg.Interval,
+		"OUTLIER_BASE_EJECTION_TIME": &cfg.BaseEjectionTime,
+		"OUTLIER_MAX_EJECTION_TIME":  &cfg.MaxEjectionTime,
+	} {
+		if v := os.Getenv(key); v != "" {
+			if n, err := time.ParseDuration(v); err == nil && n > 0 {
+				*d = n
+			} else {
+				klog.Warningf("Invalid %s: %q, using default %v", key, v, *d)
+			}
+		}
+	}
+	if cfg.MaxEjectionTime < cfg.BaseEjectionTime {
+		cfg.MaxEjectionTime = cfg.BaseEjectionTime
+	}
+
+	return cfg
+}
+
+// PodHealthState is the state of a pod for outlier detection.
+type PodHealthState string
+
+const (
+	PodHealthy  PodHealthState = "Healthy"
+	PodEjected  PodHealthState = "Ejected"
+	PodHalfOpen PodHealthState = "HalfOpen"
+)
+
+// PodHealth is a snapshot of the health of a pod.
+type PodHealth struct {
+	State               PodHealthState
+	ConsecutiveFailures int
+	// Requests and Failures are counted in the current interval.
+	Requests int
+	Failures int
+	// Ejections is the number of ejections in a row, which sets the duration of the next ejection.
+	Ejections    int
+	EjectedUntil time.Time
+}
+
+// podHealth tracks the results of the requests sent to a pod.
+type podHealth struct {
+	mutex  sync.Mutex
+	config *OutlierDetectionConfig
+
+	state               PodHealthState
+	consecutiveFailures int
+	intervalStart       time.Time
+	requests            int
+	failures            int
+	ejections           int
+	ejectedUntil        time.Time
+	// Trial requests of the half-open state.
+	trialsInFlight int
+	trialSuccesses int
+}
+
+func newPodHealth(config *OutlierDetectionConfig) *podHealth {
+	return &podHealth{config: config, state: PodHealthy}
+}
+
+// available reports whether a request may be sent to the pod. An ejected pod becomes half-open once
+// its ejection ends, and then accepts requests until all the trial requests are sent.
+func (h *podHealth) available(now time.Time) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.refresh(now)
+	switch h.state {
+	case PodEjected:
+		return false
+	case PodHalfOpen:
+		return h.trialsInFlight+h.trialSuccesses < h.config.HalfOpenRequests
+	default:
+		return true
+	}
+}
+
+func (h *podHealth) refresh(now time.Time) {
+	if h.state == PodEjected && !now.Before(h.ejectedUntil) {
+		h.state = PodHalfOpen
+		h.trialsInFlight = 0
+		h.trialSuccesses = 0
+	}
+}
+
+// start is called when a request is sent to the pod, and reports whether it is a trial request.
+func (h *podHealth) start(now time.Time) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.refresh(now)
+	if h.state != PodHalfOpen {
+		return false
+	}
+	h.trialsInFlight++
+	return true
+}
+
+// record records the result of a request sent to the pod, and returns the state of the pod and
+// whether the request changed it.
+func (h *podHealth) record(trial, success bool, now time.Time) (PodHealthState, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if trial {
+		h.trialsInFlight--
+		// Another trial may have ejected the pod again in the meantime.
+		if h.state != PodHalfOpen {
+			return h.state, false
+		}
+		if !success {
+			h.eject(now)
+			return h.state, true
+		}
+		h.trialSuccesses++
+		if h.trialSuccesses < h.config.HalfOpenRequests {
+			return h.state, false
+		}
+		h.state = PodHealthy
+		h.consecutiveFailures = 0
+		h.intervalStart = now
+		h.requests, h.failures = 0, 0
+		return h.state, true
+	}
+
+	// Requests sent to a pod that is not healthy, e.g. when no filter excludes ejected pods, are not
+	// trials and do not change its state.
+	if h.state != PodHealthy {
+		return h.state, false
+	}
+	if now.Sub(h.intervalStart) >= h.config.Interval {
+		h.intervalStart = now
+		h.requests, h.failures = 0, 0
+	}
+	h.requests++
+	if success {
+		h.consecutiveFailures = 0
+		// A pod healthy for long enough starts over with the base ejection time.
+		if h.ejections > 0 && now.Sub(h.ejectedUntil) >= h.config.MaxEjectionTime {
+			h.ejections = 0
+		}
+		return h.state, false
+	}
+	h.failures++
+	h.consecutiveFailures++
+
+	cfg := h.config
+	if (cfg.ConsecutiveFailures > 0 && h.consecutiveFailures >= cfg.ConsecutiveFailures) ||
+		(cfg.FailureRateThreshold > 0 && h.requests >= cfg.MinRequests &&
+			float64(h.failures)/float64(h.requests) >= cfg.FailureRateThreshold) {
+		h.eject(now)
+		return h.state, true
+	}
+	return h.state, false
+}
+
+func (h *podHealth) eject(now time.Time) {
+	h.ejections++
+	duration := h.config.BaseEjectionTime
+	for i := 1; i < h.ejections && duration < h.config.MaxEjectionTime; i++ {
+		duration *= 2
+	}
+	duration = min(duration, h.config.MaxEjectionTime)
+
+	h.state = PodEjected
+	h.ejectedUntil = now.Add(duration)
+	h.consecutiveFailures = 0
+	h.requests, h.failures = 0, 0
+	h.trialSuccesses = 0
+}
+
+func (h *podHealth) snapshot(now time.Time) PodHealth {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.refresh(now)
+	return PodHealth{
+		State:               h.state,
+		ConsecutiveFailures: h.consecutiveFailures,
+		Requests:            h.requests,
+		Failures:            h.failures,
+		Ejections:           h.ejections,
+		EjectedUntil:        h.ejectedUntil,
+	}
+}
+
+// IsEjected reports whether the pod is ejected by outlier detection: it failed too many requests
+// and must not be scheduled until its ejection ends, or until its trial requests complete.
+func (p *PodInfo) IsEjected() bool {
+	if p.health == nil {
+		return false
+	}
+	return !p.health.available(time.Now())
+}
+
+// StartRequest is called when a request is sent to the pod. It reports whether the request is a
+// trial request of a half-open pod, which must be passed to RecordRequestResult.
+func (p *PodInfo) StartRequest() bool {
+	if p.health == nil {
+		return false
+	}
+	return p.health.start(time.Now())
+}
+
+// RecordRequestResult records whether a request sent to the pod succeeded. It returns the health
+// state of the pod, and whether the request changed it, i.e. ejected or restored the pod.
+func (p *PodInfo) RecordRequestResult(trial, success bool) (PodHealthState, bool) {
+	if p.health == nil {
+		return PodHealthy, false
+	}
+	return p.health.record(trial, success, time.Now())
+}
+
+// GetHealth returns the outlier detection state of the pod.
+func (p *PodInfo) GetHealth() PodHealth {
+	if p.health == nil {
+		return PodHealth{State: PodHealthy}
+	}
+	return p.health.snapshot(time.Now())
+}
@@ -0,0 +1,161 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package datastore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func testOutlierDetectionConfig() *OutlierDetectionConfig {
+	return &OutlierDetectionConfig{
+		ConsecutiveFailures:  3,
+		FailureRateThreshold: 0.5,
+		MinRequests:          10,
+		Interval:             10 * time.Second,
+		BaseEjectionTime:     10 * time.Second,
+		MaxEjectionTime:      30 * time.Second,
+		HalfOpenRequests:     2,
+	}
+}
+
+func TestPodHealthConsecutiveFailures(t *testing.T) {
+	h := newPodHealth(testOutlierDetectionConfig())
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		state, changed := h.record(false, false, now)
+		assert.Equal(t, PodHealthy, state)
+		assert.False(t, changed)
+	}
+	// A success resets the consecutive failures.
+	h.record(false, true, now)
+	h.record(false, false, now)
+	h.record(false, false, now)
+	assert.True(t, h.available(now))
+
+	state, changed := h.record(false, false, now)
+	assert.Equal(t, PodEjected, state)
+	assert.True(t, changed)
+	assert.False(t, h.available(now))
+	assert.False(t, h.available(now.Add(9*time.Second)))
+	assert.Equal(t, now.Add(10*time.Second), h.snapshot(now).EjectedUntil)
+}
+
+func TestPodHealthFailureRate(t *testing.T) {
+	h := newPodHealth(testOutlierDetectionConfig())
+	now := time.Now()
+
+	// Alternating results never reach the consecutive failures, but reach the failure rate.
+	for i := 0; i < 9; i++ {
+		state, _ := h.record(false, i%2 == 0, now)
+		assert.Equal(t, PodHealthy, state)
+	}
+	state, changed := h.record(false, false, now)
+	assert.Equal(t, PodEjected, state)
+	assert.True(t, changed)
+
+	// The requests are counted per interval.
+	h = newPodHealth(testOutlierDetectionConfig())
+	for i := 0; i < 9; i++ {
+		h.record(false, i%2 == 0, now)
+	}
+	state, _ = h.record(false, false, now.Add(11*time.Second))
+	assert.Equal(t, PodHealthy, state)
+	assert.Equal(t, 1, h.snapshot(now).Requests)
+}
+
+func TestPodHealthHalfOpen(t *testing.T) {
+	h := newPodHealth(testOutlierDetectionConfig())
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		h.record(false, false, now)
+	}
+
+	// Once the ejection ends, the pod accepts as many trial requests as HalfOpenRequests.
+	now = now.Add(10 * time.Second)
+	assert.True(t, h.available(now))
+	assert.Equal(t, PodHalfOpen, h.snapshot(now).State)
+	assert.True(t, h.start(now))
+	assert.True(t, h.available(now))
+	assert.True(t, h.start(now))
+	assert.False(t, h.available(now))
+
+	state, changed := h.record(true, true, now)
+	assert.Equal(t, PodHalfOpen, state)
+	assert.False(t, changed)
+	assert.False(t, h.available(now))
+	state, changed = h.record(true, true, now)
+	assert.Equal(t, PodHealthy, state)
+	assert.True(t, changed)
+	assert.True(t, h.available(now))
+	assert.False(t, h.start(now))
+}
+
+func TestPodHealthEjectionBackoff(t *testing.T) {
+	h := newPodHealth(testOutlierDetectionConfig())
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		h.record(false, false, now)
+	}
+	assert.Equal(t, now.Add(10*time.Second), h.snapshot(now).EjectedUntil)
+
+	// A failed trial ejects the pod again, for twice as long, up to MaxEjectionTime.
+	for _, want := range []time.Duration{20 * time.Second, 30 * time.Second, 30 * time.Second} {
+		now = h.snapshot(now).EjectedUntil
+		assert.True(t, h.start(now))
+		state, changed := h.record(true, false, now)
+		assert.Equal(t, PodEjected, state)
+		assert.True(t, changed)
+		assert.Equal(t, now.Add(want), h.snapshot(now).EjectedUntil)
+	}
+
+	// Requests that are not trials do not change the state of an ejected pod.
+	state, changed := h.record(false, true, now)
+	assert.Equal(t, PodEjected, state)
+	assert.False(t, changed)
+}
+
+func TestPodInfoHealth(t *testing.T) {
+	cfg := *testOutlierDetectionConfig()
+	cfg.ConsecutiveFailures = 1
+	s := New(WithOutlierDetectionConfig(cfg), WithPodRuntimeInspector(&fakePodRuntimeInspector{}))
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "default"}}
+	assert.NoError(t, s.AddOrUpdatePod(pod, nil))
+
+	podInfo := s.GetPodInfo(types.NamespacedName{Namespace: "default", Name: "pod-1"})
+	assert.False(t, podInfo.IsEjected())
+	trial := podInfo.StartRequest()
+	assert.False(t, trial)
+	state, changed := podInfo.RecordRequestResult(trial, false)
+	assert.Equal(t, PodEjected, state)
+	assert.True(t, changed)
+	assert.True(t, podInfo.IsEjected())
+
+	// Updating the pod keeps its health.
+	assert.NoError(t, s.AddOrUpdatePod(pod, nil))
+	assert.True(t, s.GetPodInfo(types.NamespacedName{Namespace: "default", Name: "pod-1"}).IsEjected())
+
+	// Pods not created by the store are always healthy.
+	assert.False(t, (&PodInfo{}).IsEjected())
+	assert.Equal(t, PodHealthy, (&PodInfo{}).GetHealth().State)
+}
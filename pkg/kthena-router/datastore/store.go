@@ -42,6 +42,7 @@ import (
 
 	aiv1alpha1 "github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/backend"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/backend/metrics"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/utils"
 	inferencev1 "sigs.k8s.io/gateway-api-inference-extension/api/v1"
 )
@@ -127,6 +128,9 @@ type EventData struct {
 
 	ModelName  string
 	ModelRoute *aiv1alpha1.ModelRoute
+
+	Secret      types.NamespacedName
+	ModelServer types.NamespacedName
 }
 
 // CallbackFunc is the type of function that can be registered as a callback
@@ -177,6 +181,8 @@ type Store interface {
 
 	// New methods for routing functionality
 	MatchModelServer(modelName string, request *http.Request, gatewayKey string) (types.NamespacedName, bool, *aiv1alpha1.ModelRoute, error)
+	// MatchRoute is like MatchModelServer, and also returns the matched rule and target model
+	MatchRoute(modelName string, request *http.Request, gatewayKey string) (*RouteMatch, error)
 
 	// Model routing methods
 	AddOrUpdateModelRoute(mr *aiv1alpha1.ModelRoute) error
@@ -232,6 +238,14 @@ type Store interface {
 	GetAllHTTPRoutes() []*gatewayv1.HTTPRoute
 	GetHTTPRoutesByGateway(gatewayKey string) []*gatewayv1.HTTPRoute
 	GetModelRoutesByGateway(gatewayKey string) []*aiv1alpha1.ModelRoute
+	// GetModelRoutesForRequest returns the ModelRoutes serving the requests received through the gateway,
+	// or through the default listener if gatewayKey is empty
+	GetModelRoutesForRequest(gatewayKey string) []*aiv1alpha1.ModelRoute
+
+	// Secret methods, used for the credentials referenced by routing resources
+	AddOrUpdateSecret(secret *corev1.Secret) error
+	DeleteSecret(name types.NamespacedName) error
+	GetSecret(name types.NamespacedName) *corev1.Secret
 
 	// Debug interface methods
 	GetAllModelRoutes() map[string]*aiv1alpha1.ModelRoute
@@ -263,6 +277,9 @@ type PodInfo struct {
 	// Protected fields - use accessor methods for thread-safe access
 	models      sets.Set[string]               // running models. Including base model and lora adapters.
 	modelServer sets.Set[types.NamespacedName] // The modelservers this pod belongs to
+
+	// health tracks the results of the requests sent to the pod for outlier detection.
+	health *podHealth
 }
 
 // modelRouteInfo stores the mapping between a ModelRoute resource and its associated models.
@@ -300,6 +317,10 @@ type store struct {
 	httpRouteMutex sync.RWMutex
 	httpRoutes     map[string]*gatewayv1.HTTPRoute // key: namespace/name, value: *gatewayv1.HTTPRoute
 	gatewayRoutes  map[string]sets.Set[string]     // key: gateway key (namespace/name), value: set of HTTPRoute keys
+
+	secretMutex sync.RWMutex
+	secrets     map[types.NamespacedName]*corev1.Secret
+
 	// New fields for callback management
 	callbacks map[string][]CallbackFunc
 
@@ -311,6 +332,8 @@ type store struct {
 	podRuntimeInspector PodRuntimeInspector
 	rootCtx             context.Context // Lifecycle context for queue goroutines, set by Run()
 	fairnessQueueConfig FairnessQueueConfig
+	// outlierDetectionConfig is shared by the health trackers of all pods.
+	outlierDetectionConfig OutlierDetectionConfig
 }
 
 func New(opts ...Option) Store {
@@ -325,6 +348,7 @@ func New(opts ...Option) Store {
 		inferencePools:      make(map[string]*inferencev1.InferencePool),
 		httpRoutes:          make(map[string]*gatewayv1.HTTPRoute),
 		gatewayRoutes:       make(map[string]sets.Set[string]),
+		secrets:             make(map[types.NamespacedName]*corev1.Secret),
 		callbacks:           make(map[string][]CallbackFunc),
 		initialSynced:       &atomic.Bool{},
 		requestWaitingQueue: sync.Map{},
@@ -332,6 +356,8 @@ func New(opts ...Option) Store {
 		tokenTracker:        createTokenTracker(),
 		podRuntimeInspector: realPodRuntimeInspector{},
 		fairnessQueueConfig: createFairnessQueueConfig(),
+		// Create outlier detection configuration from environment variables
+		outlierDetectionConfig: createOutlierDetectionConfig(),
 	}
 	for _, opt := range opts {
 		if opt != nil {
@@ -540,6 +566,10 @@ func (s *store) DeleteModelServer(ms types.NamespacedName) error {
 		}
 	}
 
+	s.triggerCallbacks("ModelServer", EventData{
+		EventType:   EventDelete,
+		ModelServer: ms,
+	})
 	return nil
 }
 
@@ -681,6 +711,7 @@ func (s *store) AddOrUpdatePod(pod *corev1.Pod, modelServers []*aiv1alpha1.Model
 		engine:      engine,
 		modelServer: newModelServers,
 		models:      sets.New[string](),
+		health:      newPodHealth(&s.outlierDetectionConfig),
 	}
 	s.pods.Store(podName, newPodInfo)
 	s.updatePodMetrics(newPodInfo)
@@ -933,7 +964,24 @@ func (s *store) DeleteModelRoute(namespacedName string) error {
 	return nil
 }
 
+// RouteMatch is the result of matching a request against the ModelRoutes.
+type RouteMatch struct {
+	ModelServerName types.NamespacedName
+	IsLora          bool
+	ModelRoute      *aiv1alpha1.ModelRoute
+	Rule            *aiv1alpha1.Rule
+	Target          *aiv1alpha1.TargetModel
+}
+
 func (s *store) MatchModelServer(model string, req *http.Request, gatewayKey string) (types.NamespacedName, bool, *aiv1alpha1.ModelRoute, error) {
+	match, err := s.MatchRoute(model, req, gatewayKey)
+	if err != nil {
+		return types.NamespacedName{}, false, nil, err
+	}
+	return match.ModelServerName, match.IsLora, match.ModelRoute, nil
+}
+
+func (s *store) MatchRoute(model string, req *http.Request, gatewayKey string) (*RouteMatch, error) {
 	s.routeMutex.RLock()
 	defer s.routeMutex.RUnlock()
 
@@ -949,7 +997,7 @@ func (s *store) MatchModelServer(model string, req *http.Request, gatewayKey str
 		// Try to find routes by lora name
 		loraRoutes, ok := s.loraRoutes[model]
 		if !ok {
-			return types.NamespacedName{}, false, nil, fmt.Errorf("not found route rules for model %s", model)
+			return nil, fmt.Errorf("not found route rules for model %s", model)
 		}
 		candidateRoutes = loraRoutes
 		isLora = true
@@ -957,25 +1005,8 @@ func (s *store) MatchModelServer(model string, req *http.Request, gatewayKey str
 
 	// candidateRoutes are kept sorted oldest-first by AddOrUpdateModelRoute
 	for _, mr := range candidateRoutes {
-		// Check parentRefs if specified
-		if len(mr.Spec.ParentRefs) > 0 {
-			// If gatewayKey is provided (not empty), check if ModelRoute matches the specific gateway
-			if gatewayKey != "" {
-				if !s.matchesSpecificGateway(mr, gatewayKey) {
-					continue // Try next ModelRoute
-				}
-			} else {
-				// If ModelRoute has parentRefs but gatewayKey is empty, skip it
-				continue // Skip ModelRoute with parentRefs when gatewayKey is not specified
-			}
-		} else {
-			// If gatewayKey is specified, we only match ModelRoute with parentRefs
-			// ModelRoute without parentRefs should not match when gatewayKey is provided
-			if gatewayKey != "" {
-				continue // Skip ModelRoute without parentRefs when gatewayKey is specified
-			}
-			// If gatewayKey is empty, ModelRoute without parentRefs can match
-			// (ModelRoute without parentRefs attaches to all Gateways in the same namespace)
+		if !s.attachedToGateway(mr, gatewayKey) {
+			continue // Try next ModelRoute
 		}
 
 		// Try to match rules
@@ -990,11 +1021,50 @@ func (s *store) MatchModelServer(model string, req *http.Request, gatewayKey str
 		}
 
 		// Found a matching ModelRoute
-		return types.NamespacedName{Namespace: mr.Namespace, Name: dst.ModelServerName}, isLora, mr, nil
+		return &RouteMatch{
+			ModelServerName: types.NamespacedName{Namespace: mr.Namespace, Name: dst.ModelServerName},
+			IsLora:          isLora,
+			ModelRoute:      mr,
+			Rule:            rule,
+			Target:          dst,
+		}, nil
 	}
 
 	// No matching ModelRoute found
-	return types.NamespacedName{}, false, nil, fmt.Errorf("no matching ModelRoute found for model %s", model)
+	return nil, fmt.Errorf("no matching ModelRoute found for model %s", model)
+}
+
+// attachedToGateway checks if the ModelRoute serves the requests received through the gateway.
+func (s *store) attachedToGateway(mr *aiv1alpha1.ModelRoute, gatewayKey string) bool {
+	// Check parentRefs if specified
+	if len(mr.Spec.ParentRefs) > 0 {
+		// If gatewayKey is provided (not empty), check if ModelRoute matches the specific gateway.
+		// If ModelRoute has parentRefs but gatewayKey is empty, skip it.
+		return gatewayKey != "" && s.matchesSpecificGateway(mr, gatewayKey)
+	}
+	// If gatewayKey is specified, we only match ModelRoute with parentRefs.
+	// If gatewayKey is empty, ModelRoute without parentRefs can match
+	// (ModelRoute without parentRefs attaches to all Gateways in the same namespace)
+	return gatewayKey == ""
+}
+
+// GetModelRoutesForRequest returns the ModelRoutes serving the requests received through the gateway,
+// sorted by namespace and name.
+func (s *store) GetModelRoutesForRequest(gatewayKey string) []*aiv1alpha1.ModelRoute {
+	allRoutes := s.GetAllModelRoutes()
+	result := make([]*aiv1alpha1.ModelRoute, 0, len(allRoutes))
+	for _, mr := range allRoutes {
+		if s.attachedToGateway(mr, gatewayKey) {
+			result = append(result, mr)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
 }
 
 // matchesSpecificGateway checks if the ModelRoute matches a specific gateway
@@ -1434,6 +1504,17 @@ func (p *PodInfo) GetTTFT() float64 {
 	return p.TTFT
 }
 
+// GetTTFTQuantile returns the q-quantile of the time to first token of the pod in seconds, or 0
+// if the pod has not reported it yet.
+func (p *PodInfo) GetTTFTQuantile(q float64) float64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.TimeToFirstToken == nil {
+		return 0
+	}
+	return metrics.HistogramQuantile(q, p.TimeToFirstToken)
+}
+
 // Debug interface implementations
 
 // GetAllModelRoutes returns all ModelRoutes in the store
@@ -1626,6 +1707,58 @@ func (s *store) GetAllGateways() []*gatewayv1.Gateway {
 	return result
 }
 
+// Secret methods
+
+func (s *store) AddOrUpdateSecret(secret *corev1.Secret) error {
+	key := types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}
+
+	s.secretMutex.Lock()
+	_, exists := s.secrets[key]
+	s.secrets[key] = secret
+	s.secretMutex.Unlock()
+
+	klog.V(4).Infof("Added or updated Secret: %s", key)
+
+	eventType := EventAdd
+	if exists {
+		eventType = EventUpdate
+	}
+	// Trigger callback outside the lock to avoid potential deadlocks
+	s.triggerCallbacks("Secret", EventData{
+		EventType: eventType,
+		Secret:    key,
+	})
+
+	return nil
+}
+
+func (s *store) DeleteSecret(name types.NamespacedName) error {
+	s.secretMutex.Lock()
+	_, exists := s.secrets[name]
+	delete(s.secrets, name)
+	s.secretMutex.Unlock()
+
+	if !exists {
+		return nil
+	}
+	klog.V(4).Infof("Deleted Secret: %s", name)
+
+	// Trigger callback outside the lock to avoid potential deadlocks
+	s.triggerCallbacks("Secret", EventData{
+		EventType: EventDelete,
+		Secret:    name,
+	})
+
+	return nil
+}
+
+func (s *store) GetSecret(name types.NamespacedName) *corev1.Secret {
+	s.secretMutex.RLock()
+	defer s.secretMutex.RUnlock()
+
+	return s.secrets[name]
+}
+
 // InferencePool methods (using Gateway API Inference Extension)
 
 func (s *store) AddOrUpdateInferencePool(inferencePool *inferencev1.InferencePool) error {
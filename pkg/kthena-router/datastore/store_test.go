@@ -35,6 +35,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
 )
 
 // ptr is a helper function to get pointer to a value
@@ -1900,3 +1901,83 @@ func TestMatchModelServer_EmptyTargetModels_FallsThrough(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, types.NamespacedName{Namespace: "default", Name: "good-server"}, server)
 }
+
+func TestStoreGetModelRoutesForRequest(t *testing.T) {
+	s := New()
+	assert.NoError(t, s.AddOrUpdateGateway(&gatewayv1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "gw", Namespace: "default"},
+	}))
+	routes := []*aiv1alpha1.ModelRoute{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "b-default", Namespace: "default"},
+			Spec:       aiv1alpha1.ModelRouteSpec{ModelName: "model-b"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "a-default", Namespace: "default"},
+			Spec:       aiv1alpha1.ModelRouteSpec{LoraAdapters: []string{"lora-a"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "gw-route", Namespace: "default"},
+			Spec: aiv1alpha1.ModelRouteSpec{
+				ModelName:  "model-gw",
+				ParentRefs: []gatewayv1.ParentReference{{Name: "gw"}},
+			},
+		},
+	}
+	for _, mr := range routes {
+		assert.NoError(t, s.AddOrUpdateModelRoute(mr))
+	}
+
+	names := func(routes []*aiv1alpha1.ModelRoute) []string {
+		var result []string
+		for _, mr := range routes {
+			result = append(result, mr.Name)
+		}
+		return result
+	}
+	assert.Equal(t, []string{"a-default", "b-default"}, names(s.GetModelRoutesForRequest("")))
+	assert.Equal(t, []string{"gw-route"}, names(s.GetModelRoutesForRequest("default/gw")))
+	assert.Empty(t, s.GetModelRoutesForRequest("default/other"))
+}
+
+func TestMatchRoute_ReturnsRuleAndTarget(t *testing.T) {
+	s := New()
+	delay := metav1.Duration{Duration: time.Second}
+	mr := &aiv1alpha1.ModelRoute{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "route"},
+		Spec: aiv1alpha1.ModelRouteSpec{
+			ModelName: "my-model",
+			Rules: []*aiv1alpha1.Rule{
+				{
+					Name: "chat",
+					ModelMatch: &aiv1alpha1.ModelMatch{
+						Uri: &aiv1alpha1.StringMatch{Exact: ptr("/v1/chat/completions")},
+					},
+					TargetModels: []*aiv1alpha1.TargetModel{{ModelServerName: "chat-server"}},
+					Hedging:      &aiv1alpha1.Hedging{Delay: &delay},
+				},
+				{
+					Name:         "default",
+					TargetModels: []*aiv1alpha1.TargetModel{{ModelServerName: "default-server"}},
+				},
+			},
+		},
+	}
+	assert.NoError(t, s.AddOrUpdateModelRoute(mr))
+
+	match, err := s.MatchRoute("my-model", &http.Request{URL: &url.URL{Path: "/v1/chat/completions"}}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, types.NamespacedName{Namespace: "default", Name: "chat-server"}, match.ModelServerName)
+	assert.False(t, match.IsLora)
+	assert.Equal(t, "route", match.ModelRoute.Name)
+	assert.Equal(t, "chat", match.Rule.Name)
+	assert.Equal(t, "chat-server", match.Target.ModelServerName)
+	assert.Equal(t, time.Second, match.Rule.Hedging.Delay.Duration)
+
+	match, err = s.MatchRoute("my-model", &http.Request{URL: &url.URL{Path: "/v1/completions"}}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "default", match.Rule.Name)
+
+	_, err = s.MatchRoute("other-model", &http.Request{URL: &url.URL{Path: "/v1/completions"}}, "")
+	assert.Error(t, err)
+}
@@ -73,6 +73,7 @@ type PodResponse struct {
 	Metrics      *Metrics `json:"metrics,omitempty"`
 	Models       []string `json:"models"`
 	ModelServers []string `json:"modelServers"`
+	Health       *Health  `json:"health,omitempty"`
 }
 
 type PodInfo struct {
@@ -91,6 +92,16 @@ type Metrics struct {
 	TTFT              float64 `json:"ttft"`
 }
 
+// Health is the outlier detection state of a pod.
+type Health struct {
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	Requests            int    `json:"requests"`
+	Failures            int    `json:"failures"`
+	Ejections           int    `json:"ejections"`
+	EjectedUntil        string `json:"ejectedUntil,omitempty"`
+}
+
 type GatewayResponse struct {
 	Name      string                  `json:"name"`
 	Namespace string                  `json:"namespace"`
@@ -489,6 +500,19 @@ func (h *DebugHandler) convertPodInfoToResponse(namespacedName types.NamespacedN
 		TTFT:              podInfo.TTFT,
 	}
 
+	// Add outlier detection state
+	health := podInfo.GetHealth()
+	response.Health = &Health{
+		State:               string(health.State),
+		ConsecutiveFailures: health.ConsecutiveFailures,
+		Requests:            health.Requests,
+		Failures:            health.Failures,
+		Ejections:           health.Ejections,
+	}
+	if health.State != datastore.PodHealthy {
+		response.Health.EjectedUntil = health.EjectedUntil.UTC().Format("2006-01-02T15:04:05Z")
+	}
+
 	// Add pod info if details are requested
 	if includeDetails && podInfo.Pod != nil {
 		response.PodInfo = &PodInfo{
@@ -96,6 +96,14 @@ func (m *MockStore) MatchModelServer(modelName string, request *http.Request, ga
 	return args.Get(0).(types.NamespacedName), args.Bool(1), modelRoute, args.Error(3)
 }
 
+func (m *MockStore) MatchRoute(modelName string, request *http.Request, gatewayKey string) (*datastore.RouteMatch, error) {
+	args := m.Called(modelName, request, gatewayKey)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*datastore.RouteMatch), args.Error(1)
+}
+
 func (m *MockStore) AddOrUpdateModelRoute(mr *aiv1alpha1.ModelRoute) error {
 	args := m.Called(mr)
 	return args.Error(0)
@@ -180,6 +188,24 @@ func (m *MockStore) GetRequestWaitingQueueStats() []datastore.QueueStat {
 }
 
 // Debug interface methods
+func (m *MockStore) AddOrUpdateSecret(secret *corev1.Secret) error {
+	args := m.Called(secret)
+	return args.Error(0)
+}
+
+func (m *MockStore) DeleteSecret(name types.NamespacedName) error {
+	args := m.Called(name)
+	return args.Error(0)
+}
+
+func (m *MockStore) GetSecret(name types.NamespacedName) *corev1.Secret {
+	args := m.Called(name)
+	if args.Get(0) == nil {
+		return nil
+	}
+	return args.Get(0).(*corev1.Secret)
+}
+
 func (m *MockStore) GetAllModelRoutes() map[string]*aiv1alpha1.ModelRoute {
 	args := m.Called()
 	if args.Get(0) == nil {
@@ -307,6 +333,14 @@ func (m *MockStore) GetModelRoutesByGateway(gatewayKey string) []*aiv1alpha1.Mod
 	return args.Get(0).([]*aiv1alpha1.ModelRoute)
 }
 
+func (m *MockStore) GetModelRoutesForRequest(gatewayKey string) []*aiv1alpha1.ModelRoute {
+	args := m.Called(gatewayKey)
+	if args.Get(0) == nil {
+		return nil
+	}
+	return args.Get(0).([]*aiv1alpha1.ModelRoute)
+}
+
 func (m *MockStore) GetAllHTTPRoutes() []*gatewayv1.HTTPRoute {
 	args := m.Called()
 	if args.Get(0) == nil {
@@ -417,6 +451,42 @@ func TestGetModelRoute(t *testing.T) {
 	mockStore.AssertExpectations(t)
 }
 
+func TestListPods(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Pods get their outlier detection state from the store.
+	cfg := datastore.DefaultOutlierDetectionConfig()
+	cfg.ConsecutiveFailures = 1
+	store := datastore.New(datastore.WithOutlierDetectionConfig(cfg))
+	podName := types.NamespacedName{Namespace: "default", Name: "pod-1"}
+	require.NoError(t, store.AddOrUpdatePod(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "default"}}, nil))
+	podInfo := store.GetPodInfo(podName)
+	podInfo.RecordRequestResult(podInfo.StartRequest(), false)
+
+	mockStore := &MockStore{}
+	handler := NewDebugHandler(mockStore)
+	mockStore.On("GetAllPods").Return(map[types.NamespacedName]*datastore.PodInfo{podName: podInfo})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/debug/config_dump/pods", nil)
+	handler.ListPods(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Pods []PodResponse `json:"pods"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Pods, 1)
+	health := response.Pods[0].Health
+	require.NotNil(t, health)
+	assert.Equal(t, string(datastore.PodEjected), health.State)
+	assert.Equal(t, 1, health.Ejections)
+	assert.NotEmpty(t, health.EjectedUntil)
+
+	mockStore.AssertExpectations(t)
+}
+
 // TestDebugServerIntegration tests the debug server as a whole, including server startup and endpoint accessibility
 func TestDebugServerIntegration(t *testing.T) {
 	gin.SetMode(gin.TestMode)
@@ -0,0 +1,268 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"istio.io/istio/pkg/util/sets"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/common"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/datastore"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
+)
+
+// APIKeyLabel marks the Secrets holding an API key.
+const APIKeyLabel = "networking.serving.volcano.sh/api-key"
+
+// Keys of the data of API key Secrets
+const (
+	// APIKeySHA256Key is the hex encoded SHA-256 hash of the API key.
+	APIKeySHA256Key = "sha256"
+	// APIKeyOwnerKey is the user the API key identifies, the namespace/name of the Secret if empty.
+	// The owner of a Secret outside the namespace of the router is prefixed with its namespace.
+	APIKeyOwnerKey = "owner"
+	// APIKeyTenantKey is the tenant of the owner.
+	APIKeyTenantKey = "tenant"
+	// APIKeyModelsKey is the comma separated list of the models the API key may use.
+	APIKeyModelsKey = "models"
+	// APIKeyExpiresAtKey is the RFC 3339 time the API key expires at.
+	APIKeyExpiresAtKey = "expiresAt"
+	// APIKeyTierKey is the rate limit tier of the API key.
+	APIKeyTierKey = "tier"
+)
+
+// APIKey is the metadata of an API key.
+type APIKey struct {
+	Owner  string
+	Tenant string
+	// Models are the models the API key may use, any model if empty.
+	Models    []string
+	ExpiresAt time.Time
+	Tier      string
+
+	// namespace is the namespace of the Secret of the API key.
+	namespace string
+}
+
+// Expired returns whether the API key has expired at the time.
+func (k *APIKey) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && now.After(k.ExpiresAt)
+}
+
+// claims returns the identity of the API key as JWT claims, for authorization and rate limiting.
+func (k *APIKey) claims() map[string]interface{} {
+	claims := map[string]interface{}{"sub": k.Owner}
+	if k.Tenant != "" {
+		claims["tenant"] = k.Tenant
+	}
+	if k.Tier != "" {
+		claims["tier"] = k.Tier
+	}
+	return claims
+}
+
+// APIKeyAuthenticator validates API keys against the hashed keys of the labelled Secrets. Only the
+// Secrets in the namespace of the router or in the configured namespaces are accepted.
+type APIKeyAuthenticator struct {
+	enabled atomic.Bool
+	store   datastore.Store
+	// namespace is the namespace of the router
+	namespace string
+
+	mutex sync.RWMutex
+	// namespaces are the namespaces the Secrets are accepted from
+	namespaces sets.Set[string]
+	// keys are the API keys by hash, then by Secret. Several Secrets may hold the same hash, the key
+	// is only dropped once all of them are gone.
+	keys map[string]map[types.NamespacedName]*APIKey
+	// hashes are the hashes of the API keys by Secret
+	hashes map[types.NamespacedName]string
+}
+
+// NewAPIKeyAuthenticator creates an APIKeyAuthenticator following the Secrets of the store. The
+// Secrets are followed even if API key authentication is disabled, so that it can be enabled by
+// reloading the router configuration.
+func NewAPIKeyAuthenticator(routerConfig *conf.RouterConfiguration, store datastore.Store) *APIKeyAuthenticator {
+	a := &APIKeyAuthenticator{
+		store:     store,
+		namespace: routerNamespace(),
+		keys:      map[string]map[types.NamespacedName]*APIKey{},
+		hashes:    map[types.NamespacedName]string{},
+	}
+	var config conf.APIKeyConfig
+	if routerConfig != nil {
+		config = routerConfig.Auth.APIKey
+	}
+	a.SetEnabled(config.Enabled)
+	a.SetNamespaces(config.Namespaces)
+	if store != nil {
+		store.RegisterCallback("Secret", a.onSecretEvent)
+	}
+	return a
+}
+
+// IsEnabled returns whether API key authentication is enabled
+func (a *APIKeyAuthenticator) IsEnabled() bool {
+	return a != nil && a.enabled.Load()
+}
+
+// SetEnabled enables or disables API key authentication
+func (a *APIKeyAuthenticator) SetEnabled(enabled bool) {
+	if !enabled {
+		klog.V(4).Info("API key authentication disabled")
+	}
+	a.enabled.Store(enabled)
+}
+
+// SetNamespaces sets the namespaces the API key Secrets are accepted from, besides the namespace of
+// the router. The keys of the other namespaces are kept, but not accepted.
+func (a *APIKeyAuthenticator) SetNamespaces(namespaces []string) {
+	allowed := sets.New(namespaces...).Insert(a.namespace)
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.namespaces = allowed
+}
+
+// routerNamespace returns the namespace the router runs in.
+func routerNamespace() string {
+	if namespace := os.Getenv("POD_NAMESPACE"); namespace != "" {
+		return namespace
+	}
+	return "default"
+}
+
+// onSecretEvent reloads the API key of the Secret. The Secret is read from the store rather than
+// the event, since the callbacks of successive events may run out of order.
+func (a *APIKeyAuthenticator) onSecretEvent(data datastore.EventData) {
+	a.syncSecret(data.Secret, a.store.GetSecret(data.Secret))
+}
+
+func (a *APIKeyAuthenticator) syncSecret(name types.NamespacedName, secret *corev1.Secret) {
+	var hash string
+	var key *APIKey
+	if secret != nil && secret.Labels[APIKeyLabel] == "true" {
+		var err error
+		hash, key, err = a.parseAPIKeySecret(secret)
+		if err != nil {
+			klog.Errorf("invalid API key Secret %s: %v", name, err)
+		}
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if old, ok := a.hashes[name]; ok {
+		delete(a.keys[old], name)
+		if len(a.keys[old]) == 0 {
+			delete(a.keys, old)
+		}
+		delete(a.hashes, name)
+	}
+	if key != nil {
+		if a.keys[hash] == nil {
+			a.keys[hash] = map[types.NamespacedName]*APIKey{}
+		}
+		for other := range a.keys[hash] {
+			klog.Errorf("API key Secrets %s and %s hold the same key, it is only accepted as the key of the first Secret by name", name, other)
+		}
+		a.keys[hash][name] = key
+		a.hashes[name] = hash
+		klog.V(4).Infof("loaded API key of %s from Secret %s", key.Owner, name)
+	}
+}
+
+func (a *APIKeyAuthenticator) parseAPIKeySecret(secret *corev1.Secret) (string, *APIKey, error) {
+	hash := strings.ToLower(strings.TrimSpace(string(secret.Data[APIKeySHA256Key])))
+	if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+		return "", nil, fmt.Errorf("%s must be a hex encoded SHA-256 hash", APIKeySHA256Key)
+	}
+
+	key := &APIKey{
+		Owner:  strings.TrimSpace(string(secret.Data[APIKeyOwnerKey])),
+		Tenant: strings.TrimSpace(string(secret.Data[APIKeyTenantKey])),
+		Tier:   strings.TrimSpace(string(secret.Data[APIKeyTierKey])),
+
+		namespace: secret.Namespace,
+	}
+	switch {
+	case key.Owner == "":
+		key.Owner = fmt.Sprintf("%s/%s", secret.Namespace, secret.Name)
+	case secret.Namespace != a.namespace:
+		// The Secrets of other namespaces may not impersonate the users of the router namespace.
+		key.Owner = fmt.Sprintf("%s/%s", secret.Namespace, key.Owner)
+	}
+	for _, model := range strings.Split(string(secret.Data[APIKeyModelsKey]), ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			key.Models = append(key.Models, model)
+		}
+	}
+	if expiresAt := strings.TrimSpace(string(secret.Data[APIKeyExpiresAtKey])); expiresAt != "" {
+		t, err := time.Parse(time.RFC3339, expiresAt)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid %s: %w", APIKeyExpiresAtKey, err)
+		}
+		key.ExpiresAt = t
+	}
+	return hash, key, nil
+}
+
+// lookup returns the API key matching the token, or nil if there is none or its Secret is not in an
+// accepted namespace. Keys are looked up by hash, so the lookup time does not depend on how much of
+// a valid key the token matches. If several accepted Secrets hold the key, the first one by name
+// wins.
+func (a *APIKeyAuthenticator) lookup(token string) *APIKey {
+	if token == "" {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(token))
+	hash := hex.EncodeToString(sum[:])
+
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	var key *APIKey
+	var keyName string
+	for name, candidate := range a.keys[hash] {
+		if !a.namespaces.Contains(candidate.namespace) {
+			continue
+		}
+		if key == nil || name.String() < keyName {
+			key, keyName = candidate, name.String()
+		}
+	}
+	return key
+}
+
+// setAPIKeyIdentity sets the owner and the claims of the API key in the context, like the identity
+// of a JWT, as well as the models the key may use.
+func setAPIKeyIdentity(c *gin.Context, key *APIKey) {
+	c.Set(common.UserIdKey, key.Owner)
+	c.Set(common.ClaimsKey, key.claims())
+	if len(key.Models) > 0 {
+		c.Set(common.AllowedModelsKey, key.Models)
+	}
+}
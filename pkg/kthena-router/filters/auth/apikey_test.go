@@ -0,0 +1,223 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/common"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/datastore"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
+)
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func newAPIKeySecret(name, key string, data map[string]string) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{APIKeyLabel: "true"}},
+		Data:       map[string][]byte{APIKeySHA256Key: []byte(hashAPIKey(key))},
+	}
+	for k, v := range data {
+		secret.Data[k] = []byte(v)
+	}
+	return secret
+}
+
+func newAPIKeyAuthenticator(t *testing.T) (*APIKeyAuthenticator, datastore.Store) {
+	store := datastore.New()
+	apiKeys := NewAPIKeyAuthenticator(&conf.RouterConfiguration{Auth: conf.AuthenticationConfig{APIKey: conf.APIKeyConfig{Enabled: true}}}, store)
+	require.True(t, apiKeys.IsEnabled())
+	return apiKeys, store
+}
+
+func TestAPIKeyAuthenticator_Disabled(t *testing.T) {
+	apiKeys := NewAPIKeyAuthenticator(&conf.RouterConfiguration{}, datastore.New())
+	assert.False(t, apiKeys.IsEnabled())
+}
+
+func TestAPIKeyAuthenticator_Secrets(t *testing.T) {
+	apiKeys, store := newAPIKeyAuthenticator(t)
+
+	secret := newAPIKeySecret("key-alice", "sk-alice", map[string]string{
+		APIKeyOwnerKey:     "alice",
+		APIKeyTenantKey:    "team-a",
+		APIKeyModelsKey:    "deepseek-r1, llama-*",
+		APIKeyExpiresAtKey: "2099-01-01T00:00:00Z",
+		APIKeyTierKey:      "gold",
+	})
+	require.NoError(t, store.AddOrUpdateSecret(secret))
+	assert.Eventually(t, func() bool { return apiKeys.lookup("sk-alice") != nil }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, &APIKey{
+		Owner:     "alice",
+		Tenant:    "team-a",
+		Models:    []string{"deepseek-r1", "llama-*"},
+		ExpiresAt: time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC),
+		Tier:      "gold",
+		namespace: "default",
+	}, apiKeys.lookup("sk-alice"))
+	assert.Nil(t, apiKeys.lookup("sk-bob"))
+
+	// Rotating the key of the Secret replaces it.
+	require.NoError(t, store.AddOrUpdateSecret(newAPIKeySecret("key-alice", "sk-alice-2", nil)))
+	assert.Eventually(t, func() bool { return apiKeys.lookup("sk-alice-2") != nil }, time.Second, 10*time.Millisecond)
+	assert.Nil(t, apiKeys.lookup("sk-alice"))
+	// The owner defaults to the Secret.
+	assert.Equal(t, "default/key-alice", apiKeys.lookup("sk-alice-2").Owner)
+
+	require.NoError(t, store.DeleteSecret(types.NamespacedName{Namespace: "default", Name: "key-alice"}))
+	assert.Eventually(t, func() bool { return apiKeys.lookup("sk-alice-2") == nil }, time.Second, 10*time.Millisecond)
+}
+
+func TestAPIKeyAuthenticator_IgnoredSecrets(t *testing.T) {
+	apiKeys, _ := newAPIKeyAuthenticator(t)
+
+	unlabelled := newAPIKeySecret("unlabelled", "sk-unlabelled", nil)
+	unlabelled.Labels = nil
+	apiKeys.syncSecret(types.NamespacedName{Namespace: "default", Name: "unlabelled"}, unlabelled)
+	assert.Nil(t, apiKeys.lookup("sk-unlabelled"))
+
+	invalidHash := newAPIKeySecret("invalid-hash", "sk-invalid", map[string]string{APIKeySHA256Key: "sk-invalid"})
+	apiKeys.syncSecret(types.NamespacedName{Namespace: "default", Name: "invalid-hash"}, invalidHash)
+	assert.Nil(t, apiKeys.lookup("sk-invalid"))
+
+	invalidExpiry := newAPIKeySecret("invalid-expiry", "sk-expiry", map[string]string{APIKeyExpiresAtKey: "tomorrow"})
+	apiKeys.syncSecret(types.NamespacedName{Namespace: "default", Name: "invalid-expiry"}, invalidExpiry)
+	assert.Nil(t, apiKeys.lookup("sk-expiry"))
+
+	// Removing the label drops the key.
+	labelled := newAPIKeySecret("labelled", "sk-labelled", nil)
+	apiKeys.syncSecret(types.NamespacedName{Namespace: "default", Name: "labelled"}, labelled)
+	assert.NotNil(t, apiKeys.lookup("sk-labelled"))
+	labelled = labelled.DeepCopy()
+	labelled.Labels = nil
+	apiKeys.syncSecret(types.NamespacedName{Namespace: "default", Name: "labelled"}, labelled)
+	assert.Nil(t, apiKeys.lookup("sk-labelled"))
+}
+
+func TestAPIKeyAuthenticator_DuplicateHashes(t *testing.T) {
+	apiKeys, _ := newAPIKeyAuthenticator(t)
+
+	alice := types.NamespacedName{Namespace: "default", Name: "key-alice"}
+	bob := types.NamespacedName{Namespace: "default", Name: "key-bob"}
+	apiKeys.syncSecret(bob, newAPIKeySecret(bob.Name, "sk-shared", map[string]string{APIKeyOwnerKey: "bob"}))
+	apiKeys.syncSecret(alice, newAPIKeySecret(alice.Name, "sk-shared", map[string]string{APIKeyOwnerKey: "alice"}))
+	// The first Secret by name wins, whatever the order they are loaded in.
+	require.NotNil(t, apiKeys.lookup("sk-shared"))
+	assert.Equal(t, "alice", apiKeys.lookup("sk-shared").Owner)
+
+	// Rotating the key of one Secret keeps the key of the other.
+	apiKeys.syncSecret(alice, newAPIKeySecret(alice.Name, "sk-alice", map[string]string{APIKeyOwnerKey: "alice"}))
+	require.NotNil(t, apiKeys.lookup("sk-shared"))
+	assert.Equal(t, "bob", apiKeys.lookup("sk-shared").Owner)
+	require.NotNil(t, apiKeys.lookup("sk-alice"))
+
+	// Deleting one Secret keeps the key of the other.
+	apiKeys.syncSecret(alice, newAPIKeySecret(alice.Name, "sk-shared", map[string]string{APIKeyOwnerKey: "alice"}))
+	apiKeys.syncSecret(alice, nil)
+	require.NotNil(t, apiKeys.lookup("sk-shared"))
+	assert.Equal(t, "bob", apiKeys.lookup("sk-shared").Owner)
+
+	apiKeys.syncSecret(bob, nil)
+	assert.Nil(t, apiKeys.lookup("sk-shared"))
+	assert.Empty(t, apiKeys.keys)
+}
+
+func TestAPIKeyAuthenticator_Namespaces(t *testing.T) {
+	apiKeys, _ := newAPIKeyAuthenticator(t)
+
+	secret := newAPIKeySecret("key-alice", "sk-alice", map[string]string{APIKeyOwnerKey: "alice"})
+	secret.Namespace = "team-a"
+	apiKeys.syncSecret(types.NamespacedName{Namespace: "team-a", Name: "key-alice"}, secret)
+	assert.Nil(t, apiKeys.lookup("sk-alice"))
+
+	// The owner of a Secret outside the router namespace is scoped to its namespace.
+	apiKeys.SetNamespaces([]string{"team-a"})
+	require.NotNil(t, apiKeys.lookup("sk-alice"))
+	assert.Equal(t, "team-a/alice", apiKeys.lookup("sk-alice").Owner)
+
+	apiKeys.SetNamespaces(nil)
+	assert.Nil(t, apiKeys.lookup("sk-alice"))
+}
+
+func TestAuthenticate_APIKey(t *testing.T) {
+	apiKeys, _ := newAPIKeyAuthenticator(t)
+	apiKeys.syncSecret(types.NamespacedName{Namespace: "default", Name: "key-alice"}, newAPIKeySecret("key-alice", "sk-alice", map[string]string{
+		APIKeyOwnerKey:  "alice",
+		APIKeyTenantKey: "team-a",
+		APIKeyModelsKey: "deepseek-r1",
+		APIKeyTierKey:   "gold",
+	}))
+	apiKeys.syncSecret(types.NamespacedName{Namespace: "default", Name: "key-expired"}, newAPIKeySecret("key-expired", "sk-expired", map[string]string{
+		APIKeyExpiresAtKey: "2020-01-01T00:00:00Z",
+	}))
+
+	serve := func(jwtAuthenticator *JWTAuthenticator, token string) (*httptest.ResponseRecorder, *gin.Context) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+		if token != "" {
+			c.Request.Header.Set("Authorization", "Bearer "+token)
+		}
+		Authenticate(apiKeys, jwtAuthenticator)(c)
+		return w, c
+	}
+	jwtDisabled := &JWTAuthenticator{enabled: false}
+
+	w, c := serve(jwtDisabled, "sk-alice")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, c.IsAborted())
+	assert.Equal(t, "alice", c.GetString(common.UserIdKey))
+	claims, _ := c.Get(common.ClaimsKey)
+	assert.Equal(t, map[string]interface{}{"sub": "alice", "tenant": "team-a", "tier": "gold"}, claims)
+	models, _ := c.Get(common.AllowedModelsKey)
+	assert.Equal(t, []string{"deepseek-r1"}, models)
+
+	w, _ = serve(jwtDisabled, "sk-expired")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "API key expired")
+
+	w, _ = serve(jwtDisabled, "sk-unknown")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w, _ = serve(jwtDisabled, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// A token that is not an API key is validated as a JWT.
+	jwtEnabled := &JWTAuthenticator{enabled: true, issuers: map[string]*jwtIssuer{}}
+	w, c = serve(jwtEnabled, "not-an-api-key")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "failed to parse jwt")
+	assert.Empty(t, c.GetString(common.UserIdKey))
+
+	w, _ = serve(jwtEnabled, "sk-alice")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
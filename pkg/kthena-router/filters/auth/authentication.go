@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"reflect"
 	"strings"
 	"time"
 
@@ -32,6 +33,7 @@ import (
 	"k8s.io/klog/v2"
 
 	"github.com/volcano-sh/kthena/pkg/kthena-router/common"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/metrics"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
 )
 
@@ -49,56 +51,149 @@ func extractTokenFromHeader(req *http.Request) string {
 
 // JWTAuthenticator provides JWT token validation with automatic JWKS rotation support
 type JWTAuthenticator struct {
-	enabled bool         // Whether JWT authentication is enabled
-	rotator *JWKSRotator // JWKS rotator for automatic key updates
+	enabled bool // Whether JWT authentication is enabled
+	// issuers are the trusted issuers by iss claim
+	issuers map[string]*jwtIssuer
+}
+
+// jwtIssuer is a trusted issuer, with the rotator of its JWKS
+type jwtIssuer struct {
+	config  conf.JWTIssuerConfig
+	rotator *JWKSRotator
 }
 
 // NewJWTAuthenticator creates a new JWTAuthenticator with JWKS rotation support
 func NewJWTAuthenticator(routerConfig *conf.RouterConfiguration) *JWTAuthenticator {
-	if routerConfig == nil || routerConfig.Auth.JwksUri == "" {
-		klog.V(4).Info("JWKS URI not configured, authentication disabled")
-		return &JWTAuthenticator{enabled: false}
-	}
+	authenticator, _ := buildJWTAuthenticator(routerConfig, nil, false)
+	return authenticator
+}
 
-	// Create and configure the JWKS rotator
-	rotator := NewJWKSRotator(routerConfig.Auth)
-	if rotator != nil {
-		rotator.Start(context.TODO())
+// ReloadJWTAuthenticator creates the JWTAuthenticator of a reloaded router configuration. The
+// issuers whose configuration is unchanged keep the rotators of previous, so their JWKS are not
+// fetched again. An error is returned if the JWKS of a new issuer cannot be fetched, so that the
+// reload is rejected rather than failing the tokens of the issuer.
+func ReloadJWTAuthenticator(routerConfig *conf.RouterConfiguration, previous *JWTAuthenticator) (*JWTAuthenticator, error) {
+	return buildJWTAuthenticator(routerConfig, previous, true)
+}
+
+func buildJWTAuthenticator(routerConfig *conf.RouterConfiguration, previous *JWTAuthenticator, requireJwks bool) (*JWTAuthenticator, error) {
+	var configs []conf.JWTIssuerConfig
+	if routerConfig != nil {
+		configs = routerConfig.Auth.JWTIssuers()
+	}
+	if len(configs) == 0 {
+		klog.V(4).Info("JWKS URI not configured, authentication disabled")
+		return &JWTAuthenticator{enabled: false}, nil
 	}
 
-	return &JWTAuthenticator{
+	authenticator := &JWTAuthenticator{
 		enabled: true,
-		rotator: rotator,
+		issuers: make(map[string]*jwtIssuer, len(configs)),
 	}
+	for _, config := range configs {
+		if _, exists := authenticator.issuers[config.Issuer]; exists {
+			klog.Errorf("JWT issuer %q is configured more than once, ignoring JWKS URI %s", config.Issuer, config.JwksUri)
+			continue
+		}
+		if previous != nil {
+			if issuer, ok := previous.issuers[config.Issuer]; ok && reflect.DeepEqual(issuer.config, config) {
+				authenticator.issuers[config.Issuer] = issuer
+				continue
+			}
+		}
+		// Create and configure the JWKS rotator
+		rotator := NewJWKSRotator(config)
+		rotator.Start(context.TODO())
+		authenticator.issuers[config.Issuer] = &jwtIssuer{config: config, rotator: rotator}
+		if requireJwks && rotator.GetJwks() == nil {
+			authenticator.CloseExcept(previous)
+			return nil, fmt.Errorf("failed to fetch the JWKS of issuer %s from %s", config.Issuer, config.JwksUri)
+		}
+	}
+	return authenticator, nil
 }
 
 // Close gracefully closes the JWTAuthenticator and its resources
 func (j *JWTAuthenticator) Close() {
-	if j.rotator != nil {
-		j.rotator.Stop()
+	j.CloseExcept(nil)
+}
+
+// CloseExcept closes the resources of the JWTAuthenticator that are not shared with other, i.e. the
+// rotators of the issuers other did not reuse.
+func (j *JWTAuthenticator) CloseExcept(other *JWTAuthenticator) {
+	for iss, issuer := range j.issuers {
+		if other != nil && other.issuers[iss] == issuer {
+			continue
+		}
+		issuer.rotator.Stop()
 	}
 }
 
-// authenticate validates the token and returns the subject
-func (j *JWTAuthenticator) authenticate(tokenStr string) (string, error) {
+// authenticate validates the token with the JWKS of its issuer, and returns it with its issuer.
+// Failures are counted by issuer, where the issuers that are not trusted are counted as unknown.
+func (j *JWTAuthenticator) authenticate(tokenStr string) (jwt.Token, *jwtIssuer, error) {
+	// The issuer is read from the unverified token, to select the JWKS the token is verified with.
+	unverified, err := jwt.ParseInsecure([]byte(tokenStr))
+	if err != nil {
+		metrics.DefaultMetrics.RecordJWTValidationFailure("unknown", metrics.JWTFailureMalformed)
+		return nil, nil, fmt.Errorf("failed to parse jwt: %w", err)
+	}
+	iss, _ := unverified.Issuer()
+	issuer, ok := j.issuers[iss]
+	if !ok {
+		metrics.DefaultMetrics.RecordJWTValidationFailure("unknown", metrics.JWTFailureUnknownIssuer)
+		return nil, nil, fmt.Errorf("unknown issuer %q", iss)
+	}
+
 	// Get current JWKS from rotator
-	jwksValue := j.rotator.GetJwks()
-	if jwksValue.Jwks == nil {
-		return "", fmt.Errorf("no JWKS available for token validation")
+	jwksValue := issuer.rotator.GetJwks()
+	if jwksValue == nil || jwksValue.Jwks == nil {
+		metrics.DefaultMetrics.RecordJWTValidationFailure(iss, metrics.JWTFailureNoJWKS)
+		return nil, nil, fmt.Errorf("no JWKS available for token validation")
 	}
 
 	token, err := jwt.Parse([]byte(tokenStr), jwt.WithKeySet(jwksValue.Jwks, jws.WithInferAlgorithmFromKey(true)))
 	if err != nil {
-		return "", fmt.Errorf("failed to parse jwt: %w", err)
+		metrics.DefaultMetrics.RecordJWTValidationFailure(iss, metrics.JWTFailureSignature)
+		return nil, nil, fmt.Errorf("failed to parse jwt: %w", err)
 	}
 
 	// Validate the claims in the token
 	if err := j.validateClaims(token, jwksValue); err != nil {
-		return "", fmt.Errorf("failed to validate claims: %w", err)
+		metrics.DefaultMetrics.RecordJWTValidationFailure(iss, metrics.JWTFailureClaims)
+		return nil, nil, fmt.Errorf("failed to validate claims: %w", err)
 	}
 
+	return token, issuer, nil
+}
+
+// setIdentity sets the user and the claims of the validated token in the context, for
+// authorization, rate limiting and fairness. The user and the mapped claims are taken from the
+// claims configured for the issuer of the token.
+func setIdentity(c *gin.Context, token jwt.Token, config *conf.JWTIssuerConfig) {
 	sub, _ := token.Subject()
-	return sub, nil
+	c.Set(common.UserIdKey, sub)
+
+	data, err := json.Marshal(token)
+	if err != nil {
+		klog.Errorf("failed to marshal the claims of the token of %s: %v", sub, err)
+		return
+	}
+	claims := map[string]interface{}{}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		klog.Errorf("failed to unmarshal the claims of the token of %s: %v", sub, err)
+		return
+	}
+	for name, path := range config.ClaimMappings {
+		if value := lookupClaim(claims, path); value != nil {
+			claims[name] = value
+		}
+	}
+	if config.UserClaim != "" {
+		user, _ := lookupClaim(claims, config.UserClaim).(string)
+		c.Set(common.UserIdKey, user)
+	}
+	c.Set(common.ClaimsKey, claims)
 }
 
 func (j *JWTAuthenticator) validateClaims(token jwt.Token, jwks *Jwks) error {
@@ -293,12 +388,12 @@ func (j *JWTAuthenticator) ValidateToken(ctx context.Context, c *gin.Context, to
 		return fmt.Errorf("authorization header missing or empty")
 	}
 
-	sub, err := j.authenticate(token)
+	jwtToken, issuer, err := j.authenticate(token)
 	if err != nil {
 		return fmt.Errorf("authentication failed: %w", err)
 	}
 
-	c.Set(common.UserIdKey, sub)
+	setIdentity(c, jwtToken, &issuer.config)
 	return nil
 }
 
@@ -307,7 +402,8 @@ func (j *JWTAuthenticator) IsEnabled() bool {
 	return j.enabled
 }
 
-// Authenticate returns a Gin middleware for JWT token validation
+// Authenticate returns a Gin middleware for JWT token validation. It returns once the request is
+// authenticated rather than calling the next handlers, so that it can be timed by its callers.
 func (j *JWTAuthenticator) Authenticate() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if j.enabled {
@@ -318,13 +414,40 @@ func (j *JWTAuthenticator) Authenticate() gin.HandlerFunc {
 				return
 			}
 
-			sub, err := j.authenticate(token)
+			jwtToken, issuer, err := j.authenticate(token)
 			if err != nil {
 				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("Unauthorized: %v", err)})
 				return
 			}
-			c.Set(common.UserIdKey, sub)
+			setIdentity(c, jwtToken, &issuer.config)
+		}
+	}
+}
+
+// Authenticate returns a Gin middleware authenticating requests with an API key, or else with a
+// JWT. Without API key authentication, it is the middleware of the JWT authenticator.
+func Authenticate(apiKeys *APIKeyAuthenticator, jwtAuthenticator *JWTAuthenticator) gin.HandlerFunc {
+	jwtMiddleware := jwtAuthenticator.Authenticate()
+	return func(c *gin.Context) {
+		if !apiKeys.IsEnabled() {
+			jwtMiddleware(c)
+			return
+		}
+
+		token := extractTokenFromHeader(c.Request)
+		key := apiKeys.lookup(token)
+		switch {
+		case key != nil && key.Expired(time.Now()):
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: API key expired"})
+			return
+		case key != nil:
+			setAPIKeyIdentity(c, key)
+			return
+		case !jwtAuthenticator.IsEnabled():
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header missing or invalid API key"})
+			return
 		}
-		c.Next()
+		// The token may be a JWT.
+		jwtMiddleware(c)
 	}
 }
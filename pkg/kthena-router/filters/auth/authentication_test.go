@@ -18,14 +18,25 @@ package auth
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lestrrat-go/jwx/v3/jwa"
+	"github.com/lestrrat-go/jwx/v3/jwk"
 	"github.com/lestrrat-go/jwx/v3/jwt"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/volcano-sh/kthena/pkg/kthena-router/common"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/metrics"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
 )
 
@@ -179,6 +190,169 @@ func TestJWTAuthenticatorMiddleware(t *testing.T) {
 	})
 }
 
+func TestSetIdentity(t *testing.T) {
+	token, err := jwt.NewBuilder().
+		Subject("alice").
+		Claim("groups", []string{"team-a"}).
+		Claim("realm_access", map[string]interface{}{"roles": []string{"admin"}}).
+		Build()
+	assert.NoError(t, err)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	setIdentity(c, token, &conf.JWTIssuerConfig{})
+
+	assert.Equal(t, "alice", c.GetString(common.UserIdKey))
+	claims, _ := c.Get(common.ClaimsKey)
+	assert.Equal(t, map[string]interface{}{
+		"sub":          "alice",
+		"groups":       []interface{}{"team-a"},
+		"realm_access": map[string]interface{}{"roles": []interface{}{"admin"}},
+	}, claims)
+}
+
+// testIssuer is an issuer signing tokens with its own key, whose JWKS is served over HTTP.
+type testIssuer struct {
+	key    jwk.Key
+	server *httptest.Server
+}
+
+func newTestIssuer(t *testing.T, kid string) *testIssuer {
+	raw, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	key, err := jwk.Import(raw)
+	require.NoError(t, err)
+	require.NoError(t, key.Set(jwk.KeyIDKey, kid))
+	require.NoError(t, key.Set(jwk.AlgorithmKey, jwa.ES256()))
+
+	set := jwk.NewSet()
+	require.NoError(t, set.AddKey(key))
+	public, err := jwk.PublicSetOf(set)
+	require.NoError(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(public)
+	}))
+	t.Cleanup(server.Close)
+	return &testIssuer{key: key, server: server}
+}
+
+func (i *testIssuer) sign(t *testing.T, claims map[string]interface{}) string {
+	builder := jwt.NewBuilder().Expiration(time.Now().Add(time.Hour))
+	for name, value := range claims {
+		builder = builder.Claim(name, value)
+	}
+	token, err := builder.Build()
+	require.NoError(t, err)
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.ES256(), i.key))
+	require.NoError(t, err)
+	return string(signed)
+}
+
+func TestJWTAuthenticator_MultipleIssuers(t *testing.T) {
+	sso := newTestIssuer(t, "sso")
+	machines := newTestIssuer(t, "machines")
+	untrusted := newTestIssuer(t, "untrusted")
+
+	authenticator := NewJWTAuthenticator(&conf.RouterConfiguration{Auth: conf.AuthenticationConfig{
+		Issuer:  "https://sso.example.com",
+		JwksUri: sso.server.URL,
+		Issuers: []conf.JWTIssuerConfig{
+			{
+				Issuer:        "https://machines.example.com",
+				Audiences:     []string{"kthena"},
+				JwksUri:       machines.server.URL,
+				UserClaim:     "client_id",
+				ClaimMappings: map[string]string{"tenant": "org.id"},
+			},
+		},
+	}})
+	defer authenticator.Close()
+	require.Len(t, authenticator.issuers, 2)
+
+	serve := func(token string) (*httptest.ResponseRecorder, *gin.Context) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+		c.Request.Header.Set("Authorization", "Bearer "+token)
+		authenticator.Authenticate()(c)
+		return w, c
+	}
+
+	w, c := serve(sso.sign(t, map[string]interface{}{"iss": "https://sso.example.com", "sub": "alice"}))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "alice", c.GetString(common.UserIdKey))
+
+	w, c = serve(machines.sign(t, map[string]interface{}{
+		"iss":       "https://machines.example.com",
+		"aud":       "kthena",
+		"sub":       "svc-1234",
+		"client_id": "batch-job",
+		"org":       map[string]interface{}{"id": "team-a"},
+	}))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "batch-job", c.GetString(common.UserIdKey))
+	claims, _ := c.Get(common.ClaimsKey)
+	assert.Equal(t, "team-a", claims.(map[string]interface{})["tenant"])
+
+	// The audiences of the machine issuer apply to its tokens only.
+	before := testutil.ToFloat64(metrics.DefaultMetrics.JWTValidationFailuresTotal.WithLabelValues("https://machines.example.com", metrics.JWTFailureClaims))
+	w, _ = serve(machines.sign(t, map[string]interface{}{"iss": "https://machines.example.com", "aud": "other", "sub": "svc-1234"}))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, before+1, testutil.ToFloat64(metrics.DefaultMetrics.JWTValidationFailuresTotal.WithLabelValues("https://machines.example.com", metrics.JWTFailureClaims)))
+
+	// A token is verified with the keys of the issuer it claims.
+	before = testutil.ToFloat64(metrics.DefaultMetrics.JWTValidationFailuresTotal.WithLabelValues("https://sso.example.com", metrics.JWTFailureSignature))
+	w, _ = serve(untrusted.sign(t, map[string]interface{}{"iss": "https://sso.example.com", "sub": "mallory"}))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, before+1, testutil.ToFloat64(metrics.DefaultMetrics.JWTValidationFailuresTotal.WithLabelValues("https://sso.example.com", metrics.JWTFailureSignature)))
+
+	before = testutil.ToFloat64(metrics.DefaultMetrics.JWTValidationFailuresTotal.WithLabelValues("unknown", metrics.JWTFailureUnknownIssuer))
+	w, _ = serve(untrusted.sign(t, map[string]interface{}{"iss": "https://untrusted.example.com", "sub": "mallory"}))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "unknown issuer")
+	assert.Equal(t, before+1, testutil.ToFloat64(metrics.DefaultMetrics.JWTValidationFailuresTotal.WithLabelValues("unknown", metrics.JWTFailureUnknownIssuer)))
+}
+
+func TestReloadJWTAuthenticator(t *testing.T) {
+	sso := newTestIssuer(t, "sso")
+	machines := newTestIssuer(t, "machines")
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	previous := NewJWTAuthenticator(&conf.RouterConfiguration{Auth: conf.AuthenticationConfig{
+		Issuer:  "https://sso.example.com",
+		JwksUri: sso.server.URL,
+	}})
+
+	// The JWKS of a new issuer cannot be fetched, so the reload is rejected.
+	_, err := ReloadJWTAuthenticator(&conf.RouterConfiguration{Auth: conf.AuthenticationConfig{
+		Issuer:  "https://sso.example.com",
+		JwksUri: sso.server.URL,
+		Issuers: []conf.JWTIssuerConfig{{Issuer: "https://machines.example.com", JwksUri: unreachable.URL}},
+	}}, previous)
+	assert.ErrorContains(t, err, "failed to fetch the JWKS of issuer https://machines.example.com")
+
+	reloaded, err := ReloadJWTAuthenticator(&conf.RouterConfiguration{Auth: conf.AuthenticationConfig{
+		Issuer:  "https://sso.example.com",
+		JwksUri: sso.server.URL,
+		Issuers: []conf.JWTIssuerConfig{{Issuer: "https://machines.example.com", JwksUri: machines.server.URL}},
+	}}, previous)
+	require.NoError(t, err)
+	require.Len(t, reloaded.issuers, 2)
+	// The unchanged issuer keeps its rotator, which outlives the previous authenticator.
+	assert.Same(t, previous.issuers["https://sso.example.com"], reloaded.issuers["https://sso.example.com"])
+	previous.CloseExcept(reloaded)
+	defer reloaded.Close()
+	assert.NotNil(t, reloaded.issuers["https://machines.example.com"].rotator.GetJwks())
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	c.Request.Header.Set("Authorization", "Bearer "+sso.sign(t, map[string]interface{}{"iss": "https://sso.example.com", "sub": "alice"}))
+	reloaded.Authenticate()(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
 func TestValidateAudiences(t *testing.T) {
 	authenticator := &JWTAuthenticator{}
 	token := jwt.New()
@@ -17,8 +17,167 @@ limitations under the License.
 package auth
 
 import (
-	"github.com/gin-gonic/gin"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
+)
+
+// Actions of authorization rules
+const (
+	ActionAllow = "Allow"
+	ActionDeny  = "Deny"
 )
 
-func Authorize(ctx *gin.Context) {
+// Resource is what a request uses, as matched by authorization rules.
+type Resource struct {
+	// ModelRoute is the namespace/name of the ModelRoute serving the request, empty if the request
+	// is not served by a ModelRoute.
+	ModelRoute string
+	// Model is the base model of the request.
+	Model string
+	// LoraAdapter is the LoRA adapter of the request, if any.
+	LoraAdapter string
+}
+
+// Authorizer decides which models the callers may use from the claims of their JWT.
+type Authorizer struct {
+	allow []conf.AuthorizationRule
+	deny  []conf.AuthorizationRule
+}
+
+// NewAuthorizer creates an Authorizer with the rules of the configuration.
+func NewAuthorizer(config conf.AuthorizationConfig) (*Authorizer, error) {
+	a := &Authorizer{}
+	for i, rule := range config.Rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rules[%d]", i)
+		}
+		switch rule.Action {
+		case ActionAllow:
+			a.allow = append(a.allow, rule)
+		case ActionDeny:
+			a.deny = append(a.deny, rule)
+		default:
+			return nil, fmt.Errorf("invalid action %q of authorization rule %s, must be %s or %s", rule.Action, rule.Name, ActionAllow, ActionDeny)
+		}
+	}
+	return a, nil
+}
+
+// IsEnabled returns whether there are authorization rules
+func (a *Authorizer) IsEnabled() bool {
+	return a != nil && len(a.allow)+len(a.deny) > 0
+}
+
+// Authorize returns an error if the caller with the claims may not use the resource.
+func (a *Authorizer) Authorize(claims map[string]interface{}, resource Resource) error {
+	if !a.IsEnabled() {
+		return nil
+	}
+	for _, rule := range a.deny {
+		if matchRule(&rule, claims, resource) {
+			return fmt.Errorf("denied by authorization rule %s", rule.Name)
+		}
+	}
+	if len(a.allow) == 0 {
+		return nil
+	}
+	for _, rule := range a.allow {
+		if matchRule(&rule, claims, resource) {
+			return nil
+		}
+	}
+	return fmt.Errorf("not allowed by any authorization rule")
+}
+
+// AuthorizeModels returns an error if the resource is not one of the models, e.g. the models of an
+// API key. A base model covers its LoRA adapters.
+func AuthorizeModels(models []string, resource Resource) error {
+	if matchName(models, resource.Model) || matchName(models, resource.LoraAdapter) {
+		return nil
+	}
+	return fmt.Errorf("model not allowed")
+}
+
+func matchRule(rule *conf.AuthorizationRule, claims map[string]interface{}, resource Resource) bool {
+	for name, values := range rule.Claims {
+		if !matchClaim(lookupClaim(claims, name), values) {
+			return false
+		}
+	}
+	if len(rule.ModelRoutes) > 0 && !matchName(rule.ModelRoutes, resource.ModelRoute) {
+		return false
+	}
+	if len(rule.Models) > 0 && !matchName(rule.Models, resource.Model) {
+		return false
+	}
+	if len(rule.LoraAdapters) > 0 && (resource.LoraAdapter == "" || !matchName(rule.LoraAdapters, resource.LoraAdapter)) {
+		return false
+	}
+	return true
+}
+
+// lookupClaim returns the claim at the dotted path, or nil if there is none.
+func lookupClaim(claims map[string]interface{}, name string) interface{} {
+	if value, ok := claims[name]; ok {
+		return value
+	}
+	var value interface{} = claims
+	for _, key := range strings.Split(name, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
+
+// matchClaim returns whether the claim holds one of the values. A string claim holds the value it
+// equals, and each of its space separated values, like the scope claim of OAuth 2.0. A list claim
+// holds each of its elements.
+func matchClaim(claim interface{}, values []string) bool {
+	switch v := claim.(type) {
+	case nil:
+		return false
+	case string:
+		if slices.Contains(values, v) {
+			return true
+		}
+		for _, field := range strings.Fields(v) {
+			if slices.Contains(values, field) {
+				return true
+			}
+		}
+		return false
+	case []interface{}:
+		for _, elem := range v {
+			if matchClaim(elem, values) {
+				return true
+			}
+		}
+		return false
+	default:
+		return slices.Contains(values, fmt.Sprint(v))
+	}
+}
+
+// matchName returns whether the name matches one of the patterns, where a trailing * matches any
+// suffix.
+func matchName(patterns []string, name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if pattern == name {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,157 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
+)
+
+func TestNewAuthorizer(t *testing.T) {
+	authorizer, err := NewAuthorizer(conf.AuthorizationConfig{})
+	require.NoError(t, err)
+	assert.False(t, authorizer.IsEnabled())
+	assert.NoError(t, authorizer.Authorize(nil, Resource{Model: "test-model"}))
+
+	_, err = NewAuthorizer(conf.AuthorizationConfig{Rules: []conf.AuthorizationRule{{Action: "Audit"}}})
+	assert.EqualError(t, err, `invalid action "Audit" of authorization rule rules[0], must be Allow or Deny`)
+}
+
+func TestAuthorize(t *testing.T) {
+	authorizer, err := NewAuthorizer(conf.AuthorizationConfig{Rules: []conf.AuthorizationRule{
+		{
+			Name:   "team-a",
+			Action: ActionAllow,
+			Claims: map[string][]string{"groups": {"team-a"}},
+			Models: []string{"deepseek-*"},
+		},
+		{
+			Name:        "routes",
+			Action:      ActionAllow,
+			Claims:      map[string][]string{"scope": {"models:all"}},
+			ModelRoutes: []string{"default/llama"},
+		},
+		{
+			Name:         "secret-lora",
+			Action:       ActionDeny,
+			Claims:       map[string][]string{"realm_access.roles": {"guest"}},
+			LoraAdapters: []string{"secret-lora"},
+		},
+	}})
+	require.NoError(t, err)
+	assert.True(t, authorizer.IsEnabled())
+
+	teamA := map[string]interface{}{"sub": "alice", "groups": []interface{}{"team-b", "team-a"}}
+	scoped := map[string]interface{}{"sub": "bob", "scope": "openid models:all"}
+	guest := map[string]interface{}{
+		"sub":          "carol",
+		"groups":       []interface{}{"team-a"},
+		"realm_access": map[string]interface{}{"roles": []interface{}{"guest"}},
+	}
+
+	tests := []struct {
+		name     string
+		claims   map[string]interface{}
+		resource Resource
+		allowed  bool
+	}{
+		{
+			name:     "group allowed to model",
+			claims:   teamA,
+			resource: Resource{ModelRoute: "default/deepseek", Model: "deepseek-r1"},
+			allowed:  true,
+		},
+		{
+			name:     "group allowed to lora adapter of model",
+			claims:   teamA,
+			resource: Resource{ModelRoute: "default/deepseek", Model: "deepseek-r1", LoraAdapter: "secret-lora"},
+			allowed:  true,
+		},
+		{
+			name:     "group not allowed to model",
+			claims:   teamA,
+			resource: Resource{ModelRoute: "default/llama", Model: "llama-3"},
+			allowed:  false,
+		},
+		{
+			name:     "scope allowed to model route",
+			claims:   scoped,
+			resource: Resource{ModelRoute: "default/llama", Model: "llama-3"},
+			allowed:  true,
+		},
+		{
+			name:     "scope without model route",
+			claims:   scoped,
+			resource: Resource{Model: "llama-3"},
+			allowed:  false,
+		},
+		{
+			name:     "nested claim denied lora adapter",
+			claims:   guest,
+			resource: Resource{ModelRoute: "default/deepseek", Model: "deepseek-r1", LoraAdapter: "secret-lora"},
+			allowed:  false,
+		},
+		{
+			name:     "nested claim allowed base model",
+			claims:   guest,
+			resource: Resource{ModelRoute: "default/deepseek", Model: "deepseek-r1"},
+			allowed:  true,
+		},
+		{
+			name:     "unauthenticated",
+			claims:   nil,
+			resource: Resource{ModelRoute: "default/deepseek", Model: "deepseek-r1"},
+			allowed:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := authorizer.Authorize(tt.claims, tt.resource)
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestAuthorize_DenyOnly(t *testing.T) {
+	authorizer, err := NewAuthorizer(conf.AuthorizationConfig{Rules: []conf.AuthorizationRule{
+		{Name: "no-tenant-b", Action: ActionDeny, Claims: map[string][]string{"tenant": {"b"}}},
+	}})
+	require.NoError(t, err)
+
+	// Without Allow rules, anything not denied is allowed.
+	assert.NoError(t, authorizer.Authorize(map[string]interface{}{"tenant": "a"}, Resource{Model: "test-model"}))
+	assert.NoError(t, authorizer.Authorize(nil, Resource{Model: "test-model"}))
+	assert.EqualError(t, authorizer.Authorize(map[string]interface{}{"tenant": "b"}, Resource{Model: "test-model"}),
+		"denied by authorization rule no-tenant-b")
+}
+
+func TestAuthorizeModels(t *testing.T) {
+	models := []string{"deepseek-r1", "llama-*"}
+	assert.NoError(t, AuthorizeModels(models, Resource{Model: "deepseek-r1"}))
+	assert.NoError(t, AuthorizeModels(models, Resource{Model: "deepseek-r1", LoraAdapter: "sql-lora"}))
+	assert.NoError(t, AuthorizeModels(models, Resource{Model: "qwen", LoraAdapter: "llama-lora"}))
+	assert.Error(t, AuthorizeModels(models, Resource{Model: "qwen"}))
+}
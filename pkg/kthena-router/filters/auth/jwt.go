@@ -42,9 +42,9 @@ type Jwks struct {
 	ExpiredTime time.Duration
 }
 
-// JWKSRotator handles the rotation and caching of JWKS
+// JWKSRotator handles the rotation and caching of the JWKS of an issuer
 type JWKSRotator struct {
-	config          conf.AuthenticationConfig
+	config          conf.JWTIssuerConfig
 	refreshInterval time.Duration
 	stopCh          chan struct{}
 	mu              sync.RWMutex
@@ -52,10 +52,14 @@ type JWKSRotator struct {
 }
 
 // NewJWKSRotator creates a new JWKS rotator
-func NewJWKSRotator(config conf.AuthenticationConfig) *JWKSRotator {
+func NewJWKSRotator(config conf.JWTIssuerConfig) *JWKSRotator {
+	refreshInterval := defaultRefreshInterval
+	if config.RefreshInterval.Duration > 0 {
+		refreshInterval = config.RefreshInterval.Duration
+	}
 	return &JWKSRotator{
 		config:          config,
-		refreshInterval: defaultRefreshInterval,
+		refreshInterval: refreshInterval,
 		stopCh:          make(chan struct{}),
 	}
 }
@@ -108,19 +112,19 @@ func (jr *JWKSRotator) rotateJWKS() {
 	klog.V(4).Infof("Rotating JWKS from URI: %s", jr.config.JwksUri)
 
 	// Fetch new JWKS
-	newJwks := rebuildJwks(jr.config)
+	newJwks := rebuildJwks(jr.config, jr.refreshInterval)
 	if newJwks != nil {
 		jr.mu.Lock()
 		jr.jwks = newJwks
 		jr.mu.Unlock()
 		klog.V(4).Info("JWKS rotation completed successfully")
 	} else {
-		klog.Error("Failed to rotate JWKS")
+		klog.Errorf("Failed to rotate JWKS of issuer %s", jr.config.Issuer)
 	}
 }
 
 // rebuildJwks creates a new Jwks instance by fetching from the configured URI
-func rebuildJwks(config conf.AuthenticationConfig) *Jwks {
+func rebuildJwks(config conf.JWTIssuerConfig, refreshInterval time.Duration) *Jwks {
 	var keySet jwk.Set
 	var err error
 	for i := 0; i < maxRetryAttempts; i++ {
@@ -133,8 +137,8 @@ func rebuildJwks(config conf.AuthenticationConfig) *Jwks {
 				Audiences: config.Audiences,
 				Issuer:    config.Issuer,
 				Uri:       config.JwksUri,
-				// Default expiration time is set to 7 days
-				ExpiredTime: time.Hour * 24 * 7, // Default to 7 days
+				// The JWKS expires when it is fetched again
+				ExpiredTime: refreshInterval,
 			}
 		}
 	}
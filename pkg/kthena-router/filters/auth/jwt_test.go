@@ -57,10 +57,10 @@ func TestNewJWTAuthenticator(t *testing.T) {
 			assert.Equal(t, tt.expectEnabled, validator.IsEnabled())
 
 			if tt.expectRotator {
-				assert.NotNil(t, validator.rotator)
-				validator.rotator.Stop()
+				assert.NotEmpty(t, validator.issuers)
+				validator.Close()
 			} else {
-				assert.Nil(t, validator.rotator)
+				assert.Empty(t, validator.issuers)
 			}
 		})
 	}
@@ -69,19 +69,19 @@ func TestNewJWTAuthenticator(t *testing.T) {
 func TestRebuildJwks(t *testing.T) {
 	tests := []struct {
 		name      string
-		config    conf.AuthenticationConfig
+		config    conf.JWTIssuerConfig
 		expectNil bool
 	}{
 		{
 			name: "empty URI",
-			config: conf.AuthenticationConfig{
+			config: conf.JWTIssuerConfig{
 				JwksUri: "",
 			},
 			expectNil: true,
 		},
 		{
 			name: "invalid URI",
-			config: conf.AuthenticationConfig{
+			config: conf.JWTIssuerConfig{
 				JwksUri: "invalid-url",
 				Issuer:  "test-issuer",
 			},
@@ -89,7 +89,7 @@ func TestRebuildJwks(t *testing.T) {
 		},
 		{
 			name: "valid URI",
-			config: conf.AuthenticationConfig{
+			config: conf.JWTIssuerConfig{
 				JwksUri:   "https://raw.githubusercontent.com/istio/istio/release-1.27/security/tools/jwt/samples/jwks.json",
 				Issuer:    "test-issuer",
 				Audiences: []string{"test-audience"},
@@ -100,7 +100,7 @@ func TestRebuildJwks(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			jwks := rebuildJwks(tt.config)
+			jwks := rebuildJwks(tt.config, defaultRefreshInterval)
 
 			if tt.expectNil {
 				assert.Nil(t, jwks)
@@ -19,9 +19,12 @@ package ratelimit
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
 	"k8s.io/klog/v2"
 
 	networkingv1alpha1 "github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
@@ -77,9 +80,11 @@ type GlobalRateLimiter struct {
 	keyPrefix string
 	modelName string
 	tokenType string
-	limit     uint32
-	unit      networkingv1alpha1.RateLimitUnit
-	burst     int
+	// limitKey is the key of the requests sharing the bucket, if the rate limit is keyed
+	limitKey string
+	limit    uint32
+	unit     networkingv1alpha1.RateLimitUnit
+	burst    int
 }
 
 // NewGlobalRateLimiter creates a new GlobalRateLimiter instance
@@ -97,7 +102,14 @@ func NewGlobalRateLimiter(client *redis.Client, keyPrefix, modelName, tokenType
 
 // AllowN implements Limiter interface using token bucket algorithm
 func (g *GlobalRateLimiter) AllowN(now time.Time, n int) bool {
-	key := fmt.Sprintf("%s:%s:%s", g.keyPrefix, g.modelName, g.tokenType)
+	allowed, _ := g.TakeN(now, n)
+	return allowed
+}
+
+// TakeN implements Limiter interface using token bucket algorithm. The tokens left in the bucket
+// are returned by the same script run, so that they are known without querying Redis again.
+func (g *GlobalRateLimiter) TakeN(now time.Time, n int) (bool, float64) {
+	key := g.redisKey()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -146,13 +158,15 @@ func (g *GlobalRateLimiter) AllowN(now time.Time, n int) bool {
 			redis.call('hset', key, 'tokens', current_tokens, 'last_update', current_time)
 			redis.call('expire', key, expire_seconds)
 			
-			return 1 -- Return 1 to indicate request is allowed
+			-- Return 1 to indicate request is allowed, with the tokens left. Lua numbers are
+			-- truncated to integers in replies, so the tokens are returned as a string.
+			return {1, tostring(current_tokens)}
 		else
 			-- Insufficient tokens: reject request, but still update bucket state for time synchronization
 			redis.call('hset', key, 'tokens', current_tokens, 'last_update', current_time)
 			redis.call('expire', key, expire_seconds)
 			
-			return 0 -- Return 0 to indicate request is rate limited
+			return {0, tostring(current_tokens)} -- Return 0 to indicate request is rate limited
 		end
 	`
 
@@ -164,16 +178,46 @@ func (g *GlobalRateLimiter) AllowN(now time.Time, n int) bool {
 
 	if result.Err() != nil {
 		klog.Errorf("failed to execute token bucket lua script: %v", result.Err())
-		return false
+		return false, 0
 	}
 
-	allowed, ok := result.Val().(int64)
+	values, ok := result.Val().([]interface{})
+	if !ok || len(values) != 2 {
+		klog.Errorf("unexpected result from lua script: %v", result.Val())
+		return false, 0
+	}
+	allowed, ok := values[0].(int64)
 	if !ok {
-		klog.Errorf("unexpected result type from lua script: %T", result.Val())
-		return false
+		klog.Errorf("unexpected result type from lua script: %T", values[0])
+		return false, 0
 	}
+	remaining, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(remaining, 64)
+	if err != nil {
+		klog.Errorf("unexpected tokens from lua script: %v", values[1])
+	}
+
+	return allowed == 1, tokens
+}
+
+// redisKey returns the Redis key of the token bucket. The buckets of the keys of a keyed rate limit
+// are suffixed with the key.
+func (g *GlobalRateLimiter) redisKey() string {
+	key := fmt.Sprintf("%s:%s:%s", g.keyPrefix, g.modelName, g.tokenType)
+	if g.limitKey != "" {
+		key += ":" + g.limitKey
+	}
+	return key
+}
+
+// Burst returns the capacity of the token bucket
+func (g *GlobalRateLimiter) Burst() int {
+	return g.burst
+}
 
-	return allowed == 1
+// RefillDuration returns the time the token bucket takes to refill n tokens
+func (g *GlobalRateLimiter) RefillDuration(n float64) time.Duration {
+	return refillDuration(n, g.getRefillRate())
 }
 
 // getRefillRate calculates the token refill rate per second
@@ -222,7 +266,7 @@ func (g *GlobalRateLimiter) getExpireSeconds() int {
 
 // Tokens returns the estimated number of tokens currently available
 func (g *GlobalRateLimiter) Tokens() float64 {
-	key := fmt.Sprintf("%s:%s:%s", g.keyPrefix, g.modelName, g.tokenType)
+	key := g.redisKey()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -289,3 +333,92 @@ func (g *GlobalRateLimiter) Tokens() float64 {
 
 	return tokens
 }
+
+// concurrencyLeaseTTL bounds how long a slot of a GlobalConcurrencyLimiter is held when it is
+// never released, e.g. because the router instance holding it crashed.
+const concurrencyLeaseTTL = 30 * time.Minute
+
+// GlobalConcurrencyLimiter implements ConcurrencyLimiter interface using Redis
+//
+// The requests in flight are leases in a Redis sorted set, scored with the time they expire at.
+// A lease is removed when its request is done, and expired leases are dropped before slots are
+// counted, so that the slots of a crashed router instance are eventually freed.
+type GlobalConcurrencyLimiter struct {
+	client    *redis.Client
+	keyPrefix string
+	modelName string
+	// limitKey is the key of the requests sharing the slots, if the rate limit is keyed
+	limitKey string
+	max      uint32
+}
+
+// NewGlobalConcurrencyLimiter creates a new GlobalConcurrencyLimiter instance
+func NewGlobalConcurrencyLimiter(client *redis.Client, keyPrefix, modelName string, max uint32) *GlobalConcurrencyLimiter {
+	return &GlobalConcurrencyLimiter{
+		client:    client,
+		keyPrefix: keyPrefix,
+		modelName: modelName,
+		max:       max,
+	}
+}
+
+// redisKey returns the Redis key of the leases.
+func (g *GlobalConcurrencyLimiter) redisKey() string {
+	key := fmt.Sprintf("%s:%s:concurrency", g.keyPrefix, g.modelName)
+	if g.limitKey != "" {
+		key += ":" + g.limitKey
+	}
+	return key
+}
+
+// Acquire implements ConcurrencyLimiter interface
+func (g *GlobalConcurrencyLimiter) Acquire() (func(), bool) {
+	key := g.redisKey()
+	lease := uuid.New().String()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Drop the expired leases, and take a lease if there are fewer than max left.
+	luaScript := `
+		local key = KEYS[1]                           -- Redis key name of the sorted set of leases
+		local lease = ARGV[1]                         -- Unique id of the new lease
+		local max = tonumber(ARGV[2])                 -- Maximum number of leases
+		local ttl = tonumber(ARGV[3])                 -- Time to live of the new lease (seconds)
+		
+		local time_result = redis.call('time')
+		local current_time = tonumber(time_result[1]) + tonumber(time_result[2]) / 1000000
+		
+		redis.call('zremrangebyscore', key, '-inf', current_time)
+		if redis.call('zcard', key) >= max then
+			return 0
+		end
+		
+		redis.call('zadd', key, current_time + ttl, lease)
+		redis.call('expire', key, ttl)
+		return 1
+	`
+
+	ttl := int(concurrencyLeaseTTL.Seconds())
+	result := g.client.Eval(ctx, luaScript, []string{key}, lease, g.max, ttl)
+	if result.Err() != nil {
+		klog.Errorf("failed to execute concurrency lua script: %v", result.Err())
+		return func() {}, false
+	}
+	if allowed, ok := result.Val().(int64); !ok || allowed != 1 {
+		return func() {}, false
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			// The request may be done because its context was canceled, so the lease is removed
+			// with a context of its own.
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := g.client.ZRem(ctx, key, lease).Err(); err != nil {
+				klog.Errorf("failed to release concurrency lease of %s: %v", key, err)
+			}
+		})
+	}, true
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package ratelimit
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -64,16 +65,146 @@ func TestTokenRateLimiter_Global(t *testing.T) {
 
 	// Should allow multiple requests within limit
 	for i := 0; i < 3; i++ {
-		err := rl.RateLimit(model, prompt)
+		err := rl.RateLimit(model, "", prompt)
 		assert.NoError(t, err, "Request %d should be allowed", i)
 	}
 
 	// Should be rate limited after exceeding limit
-	err = rl.RateLimit(model, prompt)
+	err = rl.RateLimit(model, "", prompt)
 	assert.Error(t, err, "Should be rate limited after exceeding limit")
 	assert.IsType(t, &InputRateLimitExceededError{}, err)
 }
 
+// commandCounter counts the commands sent to Redis.
+type commandCounter struct {
+	count int
+}
+
+func (c *commandCounter) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	c.count++
+	return ctx, nil
+}
+
+func (c *commandCounter) AfterProcess(context.Context, redis.Cmder) error {
+	return nil
+}
+
+func (c *commandCounter) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	c.count += len(cmds)
+	return ctx, nil
+}
+
+func (c *commandCounter) AfterProcessPipeline(context.Context, []redis.Cmder) error {
+	return nil
+}
+
+func TestTokenRateLimiter_GlobalCheck(t *testing.T) {
+	mr, redisConfig := setupMiniRedis(t)
+	defer mr.Close()
+
+	rl := NewTokenRateLimiter()
+	model := "test-model"
+	tokens := uint32(60)
+	requests := uint32(10)
+	require.NoError(t, rl.AddOrUpdateLimiter(model, &networkingv1alpha1.RateLimit{
+		InputTokensPerUnit: &tokens,
+		RequestsPerUnit:    &requests,
+		Unit:               networkingv1alpha1.Minute,
+		Global:             &networkingv1alpha1.GlobalRateLimit{Redis: redisConfig},
+	}))
+
+	// The state of the limits comes from the checks, one script run per limit.
+	commands := &commandCounter{}
+	rl.redisClient.AddHook(commands)
+	status, err := rl.Check(model, "", "hello world")
+	require.NoError(t, err)
+	assert.Equal(t, 2, commands.count)
+	assert.Equal(t, 60, status.Tokens.Limit)
+	assert.Equal(t, 57, status.Tokens.Remaining)
+	assert.Equal(t, 9, status.Requests.Remaining)
+	assert.InDelta(t, 6*time.Second, status.Requests.Reset, float64(100*time.Millisecond))
+}
+
+func TestTokenRateLimiter_GlobalKeyed(t *testing.T) {
+	mr, redisConfig := setupMiniRedis(t)
+	defer mr.Close()
+
+	rl := NewTokenRateLimiter()
+	model := "test-model"
+	tokens := uint32(10)
+	err := rl.AddOrUpdateLimiter(model, &networkingv1alpha1.RateLimit{
+		InputTokensPerUnit:  &tokens,
+		OutputTokensPerUnit: &tokens,
+		Unit:                networkingv1alpha1.Minute,
+		Global:              &networkingv1alpha1.GlobalRateLimit{Redis: redisConfig},
+		Key:                 &networkingv1alpha1.RateLimitKey{Source: networkingv1alpha1.RateLimitKeySourceUser},
+	})
+	require.NoError(t, err)
+
+	// Each key has its own buckets in Redis.
+	rl.RecordOutputTokens(model, "alice", 10)
+	assert.IsType(t, &OutputRateLimitExceededError{}, rl.RateLimit(model, "alice", "hello world"))
+	assert.NoError(t, rl.RateLimit(model, "bob", "hello world"))
+
+	assert.True(t, mr.Exists("kthena:ratelimit:test-model:output:alice"))
+	assert.True(t, mr.Exists("kthena:ratelimit:test-model:input:bob"))
+	assert.False(t, mr.Exists("kthena:ratelimit:test-model:input"))
+}
+
+func TestTokenRateLimiter_GlobalRequestsAndConcurrency(t *testing.T) {
+	mr, redisConfig := setupMiniRedis(t)
+	defer mr.Close()
+
+	rl := NewTokenRateLimiter()
+	model := "test-model"
+	requests := uint32(3)
+	maxConcurrent := uint32(1)
+	err := rl.AddOrUpdateLimiter(model, &networkingv1alpha1.RateLimit{
+		RequestsPerUnit:       &requests,
+		MaxConcurrentRequests: &maxConcurrent,
+		Unit:                  networkingv1alpha1.Minute,
+		Global:                &networkingv1alpha1.GlobalRateLimit{Redis: redisConfig},
+	})
+	require.NoError(t, err)
+
+	release, err := rl.AcquireConcurrency(model, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kthena:ratelimit:test-model:concurrency"}, mr.Keys())
+	_, err = rl.AcquireConcurrency(model, "")
+	assert.IsType(t, &ConcurrencyLimitExceededError{}, err)
+	release()
+	release, err = rl.AcquireConcurrency(model, "")
+	require.NoError(t, err)
+	release()
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, rl.RateLimit(model, "", "a"))
+	}
+	assert.IsType(t, &RequestRateLimitExceededError{}, rl.RateLimit(model, "", "a"))
+}
+
+func TestGlobalConcurrencyLimiter_LeaseExpires(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	limiter := NewGlobalConcurrencyLimiter(client, "kthena:ratelimit", "test-model", 1)
+
+	now := time.Now()
+	mr.SetTime(now)
+	_, ok := limiter.Acquire()
+	require.True(t, ok)
+	_, ok = limiter.Acquire()
+	assert.False(t, ok)
+
+	// The slot of a request that was never released, e.g. by a crashed router, is freed once its
+	// lease expires.
+	mr.SetTime(now.Add(concurrencyLeaseTTL + time.Second))
+	_, ok = limiter.Acquire()
+	assert.True(t, ok)
+}
+
 func TestTokenRateLimiter_LocalVsGlobal(t *testing.T) {
 	mr, redisConfig := setupMiniRedis(t)
 	defer mr.Close()
@@ -108,18 +239,18 @@ func TestTokenRateLimiter_LocalVsGlobal(t *testing.T) {
 	require.NoError(t, err)
 
 	// Both should allow initial requests
-	err = rl.RateLimit(localModel, prompt)
+	err = rl.RateLimit(localModel, "", prompt)
 	assert.NoError(t, err)
 
-	err = rl.RateLimit(globalModel, prompt)
+	err = rl.RateLimit(globalModel, "", prompt)
 	assert.NoError(t, err)
 
 	// Use up local tokens
-	err = rl.RateLimit(localModel, prompt)
+	err = rl.RateLimit(localModel, "", prompt)
 	assert.Error(t, err, "Local model should be rate limited")
 
 	// Use up global tokens
-	err = rl.RateLimit(globalModel, prompt)
+	err = rl.RateLimit(globalModel, "", prompt)
 	assert.Error(t, err, "Global model should be rate limited")
 }
 
@@ -145,8 +276,8 @@ func TestTokenRateLimiter_OutputTokens(t *testing.T) {
 	require.NoError(t, err)
 
 	// Record output tokens (should not block since it's async)
-	rl.RecordOutputTokens(model, 25)
-	rl.RecordOutputTokens(model, 30) // Total: 55, over limit
+	rl.RecordOutputTokens(model, "", 25)
+	rl.RecordOutputTokens(model, "", 30) // Total: 55, over limit
 
 	// Give some time for async recording
 	time.Sleep(100 * time.Millisecond)
@@ -179,7 +310,7 @@ func TestTokenRateLimiter_GlobalDeleteLimiter(t *testing.T) {
 	require.NoError(t, err)
 
 	// Verify it works
-	err = rl.RateLimit(model, "test")
+	err = rl.RateLimit(model, "", "test")
 	assert.NoError(t, err)
 
 	// Delete the limiter
@@ -187,7 +318,7 @@ func TestTokenRateLimiter_GlobalDeleteLimiter(t *testing.T) {
 
 	// Should now allow unlimited requests (no limiter configured)
 	for i := 0; i < 10; i++ {
-		err = rl.RateLimit(model, "test")
+		err = rl.RateLimit(model, "", "test")
 		assert.NoError(t, err, "Request %d should be allowed after deletion", i)
 	}
 }
@@ -661,3 +792,14 @@ func TestGlobalRateLimiter_Tokens_AllModelsIsolated(t *testing.T) {
 		assert.True(t, mr.Exists(key), "Redis key should exist for %s", m.name)
 	}
 }
+
+func TestGlobalRateLimiter_BurstAndRefillDuration(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	limiter := newTestGlobalRateLimiter(t, mr, "model", "input", 120, networkingv1alpha1.Minute)
+	assert.Equal(t, 120, limiter.Burst())
+	assert.Equal(t, 30*time.Second, limiter.RefillDuration(60))
+	assert.Zero(t, limiter.RefillDuration(0))
+}
@@ -19,10 +19,14 @@ package ratelimit
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	lru "github.com/hashicorp/golang-lru/v2"
 	"golang.org/x/time/rate"
 	"k8s.io/klog/v2"
 
@@ -36,39 +40,131 @@ func (e *RateLimitExceededError) Error() string {
 	return "rate limit exceeded"
 }
 
-type InputRateLimitExceededError struct{}
+type InputRateLimitExceededError struct {
+	// RetryAfter is the time until the request may be retried
+	RetryAfter time.Duration
+}
 
 func (e *InputRateLimitExceededError) Error() string {
 	return "input token rate limit exceeded"
 }
 
-type OutputRateLimitExceededError struct{}
+// InputTokensExceedLimitError is returned for a request with more input tokens than the input token
+// limit allows at once, which would be rejected however long it waits.
+type InputTokensExceedLimitError struct {
+	Tokens int
+	Limit  int
+}
+
+func (e *InputTokensExceedLimitError) Error() string {
+	return fmt.Sprintf("request has %d input tokens, more than the input token rate limit of %d", e.Tokens, e.Limit)
+}
+
+type OutputRateLimitExceededError struct {
+	// RetryAfter is the time until the request may be retried
+	RetryAfter time.Duration
+}
 
 func (e *OutputRateLimitExceededError) Error() string {
 	return "output token rate limit exceeded"
 }
 
+type RequestRateLimitExceededError struct {
+	// RetryAfter is the time until the request may be retried
+	RetryAfter time.Duration
+}
+
+func (e *RequestRateLimitExceededError) Error() string {
+	return "request rate limit exceeded"
+}
+
+type ConcurrencyLimitExceededError struct{}
+
+func (e *ConcurrencyLimitExceededError) Error() string {
+	return "concurrent request limit exceeded"
+}
+
 // Limiter interface that both local and global rate limiters implement
 // Only includes methods that are actually used
 type Limiter interface {
 	// AllowN reports whether n tokens may be consumed and consumes them if so
 	AllowN(now time.Time, n int) bool
+	// TakeN is AllowN, and also returns the number of tokens left
+	TakeN(now time.Time, n int) (bool, float64)
 	// Tokens returns the number of tokens currently available
 	Tokens() float64
+	// Burst returns the capacity of the limiter, the maximum number of tokens available at once
+	Burst() int
+	// RefillDuration returns the time the limiter takes to refill n tokens
+	RefillDuration(n float64) time.Duration
+}
+
+// LimitStatus is the state of a limit of a key, as reported to clients in the rate limit headers
+type LimitStatus struct {
+	// Limit is the capacity of the limit
+	Limit int
+	// Remaining is the capacity left
+	Remaining int
+	// Reset is the time until the capacity is fully refilled
+	Reset time.Duration
 }
 
+// RateLimitStatus is the state of the token and request limits of the limit key of a request, as
+// seen by its rate limit check. A limit the model does not have, or that was not checked, is nil.
+type RateLimitStatus struct {
+	// Tokens is the input token limit, or the output token limit if there is no limit on input
+	// tokens.
+	Tokens   *LimitStatus
+	Requests *LimitStatus
+}
+
+// ConcurrencyLimiter limits the requests in flight of a key. The global concurrency limiters
+// implement it.
+type ConcurrencyLimiter interface {
+	// Acquire takes a slot for a request and reports whether one was free. The returned function
+	// frees the slot, and must be called once the request is done.
+	Acquire() (release func(), ok bool)
+}
+
+// maxLimitKeys bounds the limiters kept for the keys of a model, so that high-cardinality keys such
+// as user IDs cannot exhaust the memory of the router. The local budget of an evicted key starts
+// over when the key is seen again, while a global budget lives on in Redis.
+const maxLimitKeys = 10000
+
 // TokenRateLimiter provides rate limiting functionality for both input and output tokens
 type TokenRateLimiter struct {
 	mutex sync.RWMutex
 
-	// Unified rate limiters using Limiter interface
-	inputLimiter  map[string]Limiter
-	outputLimiter map[string]Limiter
+	// limits holds the rate limit of each model
+	limits map[string]*modelRateLimit
 
 	// Redis client for global rate limiting
 	redisClient *redis.Client
 
-	tokenizer tokenizer.Tokenizer
+	tokenizer *tokenizer.Manager
+}
+
+// modelRateLimit holds the rate limit of a model and the limiters of its keys. A rate limit
+// without a key has the limiters of the empty key only.
+type modelRateLimit struct {
+	model     string
+	ratelimit *networkingv1alpha1.RateLimit
+	// redisClient is set when the rate limit is global.
+	redisClient *redis.Client
+	limiters    *lru.Cache[string, *keyLimiters]
+	// concurrency limits the requests in flight of the keys when the rate limit is local.
+	concurrency *LocalConcurrencyLimiter
+	// unauthenticated is set once a request without verified claims has been warned about.
+	unauthenticated atomic.Bool
+}
+
+// keyLimiters holds the limiters of a rate limit key. Any of them may be nil.
+type keyLimiters struct {
+	input    Limiter
+	output   Limiter
+	requests Limiter
+	// concurrency is set when the rate limit is global.
+	concurrency ConcurrencyLimiter
 }
 
 // LocalLimiter wraps golang.org/x/time/rate.Limiter to implement our Limiter interface
@@ -88,63 +184,327 @@ func (l *LocalLimiter) Tokens() float64 {
 	return l.Limiter.Tokens()
 }
 
+// TakeN reports whether n tokens may be consumed and consumes them if so, and returns the number of
+// tokens left
+func (l *LocalLimiter) TakeN(now time.Time, n int) (bool, float64) {
+	allowed := l.Limiter.AllowN(now, n)
+	return allowed, l.Limiter.TokensAt(now)
+}
+
+// RefillDuration returns the time the limiter takes to refill n tokens
+func (l *LocalLimiter) RefillDuration(n float64) time.Duration {
+	return refillDuration(n, float64(l.Limit()))
+}
+
+// refillDuration returns the time a limiter refilled at rate tokens per second takes to refill n tokens.
+func refillDuration(n, rate float64) time.Duration {
+	if n <= 0 || rate <= 0 {
+		return 0
+	}
+	return time.Duration(n / rate * float64(time.Second))
+}
+
+// LocalConcurrencyLimiter limits the requests in flight of each key through this router instance.
+// Only the keys with requests in flight are tracked, so that their counts are not lost when the
+// other limiters of the key are evicted, nor when the rate limit is updated.
+type LocalConcurrencyLimiter struct {
+	mutex    sync.Mutex
+	inFlight map[string]int
+	max      int
+}
+
+// NewLocalConcurrencyLimiter creates a new LocalConcurrencyLimiter
+func NewLocalConcurrencyLimiter(max int) *LocalConcurrencyLimiter {
+	return &LocalConcurrencyLimiter{inFlight: map[string]int{}, max: max}
+}
+
+// SetMax updates the maximum of requests in flight of each key. The requests in flight beyond it
+// finish, but no new request of the key is admitted until the key is below the maximum.
+func (l *LocalConcurrencyLimiter) SetMax(max int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.max = max
+}
+
+// Acquire takes a slot for a request of the key and reports whether one was free. The returned
+// function frees the slot, and must be called once the request is done.
+func (l *LocalConcurrencyLimiter) Acquire(key string) (func(), bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.inFlight[key] >= l.max {
+		return func() {}, false
+	}
+	l.inFlight[key]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mutex.Lock()
+			defer l.mutex.Unlock()
+			if l.inFlight[key]--; l.inFlight[key] <= 0 {
+				delete(l.inFlight, key)
+			}
+		})
+	}, true
+}
+
 // NewTokenRateLimiter creates a new TokenRateLimiter instance
 func NewTokenRateLimiter() *TokenRateLimiter {
 	return &TokenRateLimiter{
-		inputLimiter:  make(map[string]Limiter),
-		outputLimiter: make(map[string]Limiter),
-		tokenizer:     tokenizer.NewSimpleEstimateTokenizer(),
+		limits:    make(map[string]*modelRateLimit),
+		tokenizer: tokenizer.DefaultManager,
 	}
 }
 
-// RateLimit checks if the request is within rate limits for both input and output tokens
-func (r *TokenRateLimiter) RateLimit(model, prompt string) error {
-	// Estimate input tokens
-	tokens, err := r.tokenizer.CalculateTokenNum(prompt)
-	if err != nil {
-		klog.Errorf("failed to calculate token number: %v", err)
-		tokens = len(prompt) / 4 // fallback estimation
-	}
-
+// LimitKey returns the key of a request to the model that its rate limit is enforced on, taken
+// from the headers, the verified claims or the body of the request. It is empty if the rate limit
+// of the model has no key, or the request does not carry one.
+func (r *TokenRateLimiter) LimitKey(model string, header http.Header, claims map[string]interface{}, body map[string]interface{}) string {
 	r.mutex.RLock()
-	inputLimiter, hasInputLimit := r.inputLimiter[model]
-	outputLimiter, hasOutputLimit := r.outputLimiter[model]
+	limit, exists := r.limits[model]
 	r.mutex.RUnlock()
+	if !exists || limit.ratelimit.Key == nil {
+		return ""
+	}
+
+	key := limit.ratelimit.Key
+	switch key.Source {
+	case networkingv1alpha1.RateLimitKeySourceHeader:
+		return header.Get(key.Header)
+	case networkingv1alpha1.RateLimitKeySourceJWTClaim:
+		claim := key.Claim
+		if claim == "" {
+			claim = "sub"
+		}
+		// The claims of the request are set once its credentials, a JWT or an API key, are
+		// verified. The claims of an unverified bearer token could be made up to get a new bucket on
+		// every request, so an unauthenticated request shares the bucket of the model.
+		if claims == nil {
+			if !limit.unauthenticated.Swap(true) {
+				klog.Warningf("rate limit of model %s is keyed on the %s claim, but requests are not authenticated; they share the rate limit of the model", model, claim)
+			}
+			return ""
+		}
+		switch v := claims[claim].(type) {
+		case string:
+			return v
+		case float64, bool:
+			return fmt.Sprint(v)
+		default:
+			return ""
+		}
+	case networkingv1alpha1.RateLimitKeySourceUser:
+		user, _ := body["user"].(string)
+		return user
+	default:
+		return ""
+	}
+}
+
+// RateLimit checks if the request is within the request rate limit and the rate limits for both
+// input and output tokens of the limit key of the request
+func (r *TokenRateLimiter) RateLimit(model, key, prompt string) error {
+	_, err := r.Check(model, key, prompt)
+	return err
+}
+
+// Check is RateLimit, and also returns the state of the limits of the key as seen by the check, for
+// the rate limit headers. The state comes with the checks, so that a global rate limit is not
+// queried again.
+func (r *TokenRateLimiter) Check(model, key, prompt string) (RateLimitStatus, error) {
+	var status RateLimitStatus
+	// Count input tokens with the tokenizer of the model
+	tokens := r.tokenizer.CountTokens(model, prompt)
+
+	limiters := r.limitersOf(model, key)
+	if limiters == nil {
+		return status, nil
+	}
+
+	// A request larger than the input token limit never fits in it, and must not consume the
+	// request budget either.
+	if limiters.input != nil && tokens > limiters.input.Burst() {
+		return status, &InputTokensExceedLimitError{Tokens: tokens, Limit: limiters.input.Burst()}
+	}
+
+	// Check request rate limit
+	if limiters.requests != nil {
+		allowed, remaining := limiters.requests.TakeN(time.Now(), 1)
+		status.Requests = limitStatus(limiters.requests, remaining)
+		if !allowed {
+			return status, &RequestRateLimitExceededError{RetryAfter: retryAfter(limiters.requests, 1, remaining)}
+		}
+	}
 
 	// Check input token rate limit
-	if hasInputLimit && !inputLimiter.AllowN(time.Now(), tokens) {
-		return &InputRateLimitExceededError{}
+	if limiters.input != nil {
+		allowed, remaining := limiters.input.TakeN(time.Now(), tokens)
+		status.Tokens = limitStatus(limiters.input, remaining)
+		if !allowed {
+			return status, &InputRateLimitExceededError{RetryAfter: retryAfter(limiters.input, tokens, remaining)}
+		}
 	}
 
 	// Check output token rate limit - we conservatively check if there's at least 1 token available
 	// This prevents starting requests that likely won't be able to complete
-	if hasOutputLimit && outputLimiter.Tokens() < 1.0 {
-		return &OutputRateLimitExceededError{}
+	if limiters.output != nil {
+		remaining := limiters.output.Tokens()
+		if status.Tokens == nil {
+			status.Tokens = limitStatus(limiters.output, remaining)
+		}
+		if remaining < 1.0 {
+			return status, &OutputRateLimitExceededError{RetryAfter: retryAfter(limiters.output, 1, remaining)}
+		}
 	}
 
-	return nil
+	return status, nil
+}
+
+// RecordOutputTokens records the actual output tokens consumed by a request of the limit key after
+// response generation
+func (r *TokenRateLimiter) RecordOutputTokens(model, key string, tokenCount int) {
+	limiters := r.limitersOf(model, key)
+	if limiters != nil && limiters.output != nil {
+		limiters.output.AllowN(time.Now(), tokenCount)
+	}
+}
+
+// limitStatus returns the state of the limiter with the remaining tokens.
+func limitStatus(limiter Limiter, remaining float64) *LimitStatus {
+	burst := limiter.Burst()
+	remaining = min(max(remaining, 0), float64(burst))
+	return &LimitStatus{
+		Limit:     burst,
+		Remaining: int(remaining),
+		Reset:     limiter.RefillDuration(float64(burst) - remaining),
+	}
+}
+
+// retryAfter returns the time until the limiter, with the remaining tokens, has the n tokens of a
+// rejected request, which are at most the capacity of the limiter.
+func retryAfter(limiter Limiter, n int, remaining float64) time.Duration {
+	needed := min(float64(n), float64(limiter.Burst()))
+	return limiter.RefillDuration(needed - max(remaining, 0))
 }
 
-// RecordOutputTokens records the actual output tokens consumed after response generation
-func (r *TokenRateLimiter) RecordOutputTokens(model string, tokenCount int) {
+// AcquireConcurrency takes a slot among the requests in flight of the limit key for the request.
+// The returned function frees the slot, and must be called once the request is done, whether it
+// succeeded, failed or the client went away.
+func (r *TokenRateLimiter) AcquireConcurrency(model, key string) (func(), error) {
 	r.mutex.RLock()
-	outputLimiter, exists := r.outputLimiter[model]
+	limit, exists := r.limits[model]
 	r.mutex.RUnlock()
+	if !exists {
+		return func() {}, nil
+	}
+
+	var release func()
+	var ok bool
+	if limit.concurrency != nil {
+		release, ok = limit.concurrency.Acquire(key)
+	} else {
+		limiters := r.limitersOf(model, key)
+		if limiters == nil || limiters.concurrency == nil {
+			return func() {}, nil
+		}
+		release, ok = limiters.concurrency.Acquire()
+	}
+	if !ok {
+		return func() {}, &ConcurrencyLimitExceededError{}
+	}
+	return release, nil
+}
+
+// limitersOf returns the limiters of the key of the model, or nil if the model has no rate limit.
+func (r *TokenRateLimiter) limitersOf(model, key string) *keyLimiters {
+	r.mutex.RLock()
+	limit, exists := r.limits[model]
+	r.mutex.RUnlock()
+	if !exists {
+		return nil
+	}
+
+	if limiters, ok := limit.limiters.Get(key); ok {
+		return limiters
+	}
+	limiters := limit.newKeyLimiters(key)
+	// Another request of the key may have added its limiters in the meantime.
+	if previous, ok, _ := limit.limiters.PeekOrAdd(key, limiters); ok {
+		return previous
+	}
+	return limiters
+}
 
-	if exists {
-		outputLimiter.AllowN(time.Now(), tokenCount)
+// newKeyLimiters creates the input and output limiters of a key.
+func (m *modelRateLimit) newKeyLimiters(key string) *keyLimiters {
+	ratelimit := m.ratelimit
+	limiters := &keyLimiters{}
+
+	if m.redisClient != nil {
+		// Create global rate limiters
+		if ratelimit.InputTokensPerUnit != nil {
+			limiter := NewGlobalRateLimiter(m.redisClient, "kthena:ratelimit", m.model, "input", *ratelimit.InputTokensPerUnit, ratelimit.Unit)
+			limiter.limitKey = key
+			limiters.input = limiter
+		}
+		if ratelimit.OutputTokensPerUnit != nil {
+			limiter := NewGlobalRateLimiter(m.redisClient, "kthena:ratelimit", m.model, "output", *ratelimit.OutputTokensPerUnit, ratelimit.Unit)
+			limiter.limitKey = key
+			limiters.output = limiter
+		}
+		if ratelimit.RequestsPerUnit != nil {
+			limiter := NewGlobalRateLimiter(m.redisClient, "kthena:ratelimit", m.model, "requests", *ratelimit.RequestsPerUnit, ratelimit.Unit)
+			limiter.limitKey = key
+			limiters.requests = limiter
+		}
+		if ratelimit.MaxConcurrentRequests != nil {
+			limiter := NewGlobalConcurrencyLimiter(m.redisClient, "kthena:ratelimit", m.model, *ratelimit.MaxConcurrentRequests)
+			limiter.limitKey = key
+			limiters.concurrency = limiter
+		}
+		return limiters
 	}
+
+	// Create local rate limiters
+	duration := getTimeUnitDuration(ratelimit.Unit)
+	if ratelimit.InputTokensPerUnit != nil {
+		limiters.input = NewLocalLimiter(
+			rate.Limit(float64(*ratelimit.InputTokensPerUnit)/duration.Seconds()),
+			int(*ratelimit.InputTokensPerUnit),
+		)
+	}
+	if ratelimit.OutputTokensPerUnit != nil {
+		limiters.output = NewLocalLimiter(
+			rate.Limit(float64(*ratelimit.OutputTokensPerUnit)/duration.Seconds()),
+			int(*ratelimit.OutputTokensPerUnit),
+		)
+	}
+	if ratelimit.RequestsPerUnit != nil {
+		limiters.requests = NewLocalLimiter(
+			rate.Limit(float64(*ratelimit.RequestsPerUnit)/duration.Seconds()),
+			int(*ratelimit.RequestsPerUnit),
+		)
+	}
+	return limiters
 }
 
-// AddOrUpdateLimiter adds or updates rate limiter for a model
+// AddOrUpdateLimiter adds or updates rate limiter for a model. The limiters of an unchanged rate
+// limit are kept, and so are the requests in flight of a local rate limit that changes.
 func (r *TokenRateLimiter) AddOrUpdateLimiter(model string, ratelimit *networkingv1alpha1.RateLimit) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	// Determine if we should use global or local rate limiting
-	useGlobal := ratelimit.Global != nil && ratelimit.Global.Redis != nil
+	previous := r.limits[model]
+	if previous != nil && reflect.DeepEqual(previous.ratelimit, ratelimit) {
+		return nil
+	}
+	limit := &modelRateLimit{
+		model:     model,
+		ratelimit: ratelimit,
+	}
 
-	if useGlobal {
+	// Determine if we should use global or local rate limiting
+	if ratelimit.Global != nil && ratelimit.Global.Redis != nil {
 		// Initialize Redis client if not already done
 		if r.redisClient == nil {
 			r.redisClient = redis.NewClient(&redis.Options{
@@ -158,47 +518,22 @@ func (r *TokenRateLimiter) AddOrUpdateLimiter(model string, ratelimit *networkin
 				return fmt.Errorf("failed to connect to redis: %w", err)
 			}
 		}
-
-		// Create global rate limiters
-		if ratelimit.InputTokensPerUnit != nil {
-			r.inputLimiter[model] = NewGlobalRateLimiter(
-				r.redisClient,
-				"kthena:ratelimit",
-				model,
-				"input",
-				*ratelimit.InputTokensPerUnit,
-				ratelimit.Unit,
-			)
-		}
-
-		if ratelimit.OutputTokensPerUnit != nil {
-			r.outputLimiter[model] = NewGlobalRateLimiter(
-				r.redisClient,
-				"kthena:ratelimit",
-				model,
-				"output",
-				*ratelimit.OutputTokensPerUnit,
-				ratelimit.Unit,
-			)
-		}
-	} else {
-		// Create local rate limiters
-		duration := getTimeUnitDuration(ratelimit.Unit)
-
-		if ratelimit.InputTokensPerUnit != nil {
-			r.inputLimiter[model] = NewLocalLimiter(
-				rate.Limit(float64(*ratelimit.InputTokensPerUnit)/duration.Seconds()),
-				int(*ratelimit.InputTokensPerUnit),
-			)
+		limit.redisClient = r.redisClient
+	} else if ratelimit.MaxConcurrentRequests != nil {
+		if previous != nil && previous.concurrency != nil {
+			limit.concurrency = previous.concurrency
+			limit.concurrency.SetMax(int(*ratelimit.MaxConcurrentRequests))
+		} else {
+			limit.concurrency = NewLocalConcurrencyLimiter(int(*ratelimit.MaxConcurrentRequests))
 		}
+	}
 
-		if ratelimit.OutputTokensPerUnit != nil {
-			r.outputLimiter[model] = NewLocalLimiter(
-				rate.Limit(float64(*ratelimit.OutputTokensPerUnit)/duration.Seconds()),
-				int(*ratelimit.OutputTokensPerUnit),
-			)
-		}
+	limiters, err := lru.New[string, *keyLimiters](maxLimitKeys)
+	if err != nil {
+		return fmt.Errorf("failed to create limiters cache: %w", err)
 	}
+	limit.limiters = limiters
+	r.limits[model] = limit
 
 	return nil
 }
@@ -208,8 +543,7 @@ func (r *TokenRateLimiter) DeleteLimiter(model string) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	delete(r.inputLimiter, model)
-	delete(r.outputLimiter, model)
+	delete(r.limits, model)
 }
 
 func getTimeUnitDuration(unit networkingv1alpha1.RateLimitUnit) time.Duration {
@@ -17,9 +17,16 @@ limitations under the License.
 package ratelimit
 
 import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
 	networkingv1alpha1 "github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
 )
 
@@ -37,14 +44,14 @@ func TestTokenRateLimiter_Basic(t *testing.T) {
 
 	// Should allow up to 10 tokens immediately
 	for i := 0; i < 3; i++ {
-		err := rl.RateLimit(model, prompt)
+		err := rl.RateLimit(model, "", prompt)
 		if err != nil {
 			t.Fatalf("unexpected error on allowed request: %v, %d", err, i)
 		}
 	}
 
 	// 4th request should be rate limited
-	err := rl.RateLimit(model, prompt)
+	err := rl.RateLimit(model, "", prompt)
 	if err == nil {
 		t.Fatalf("expected rate limit error, got nil")
 	}
@@ -56,7 +63,7 @@ func TestTokenRateLimiter_Basic(t *testing.T) {
 func TestTokenRateLimiter_NoLimiter(t *testing.T) {
 	rl := NewTokenRateLimiter()
 	// No limiter added, should always allow
-	err := rl.RateLimit("unknown-model", "test")
+	err := rl.RateLimit("unknown-model", "", "test")
 	if err != nil {
 		t.Fatalf("expected nil error for unknown model, got %v", err)
 	}
@@ -76,13 +83,13 @@ func TestTokenRateLimiter_ResetAfterTime(t *testing.T) {
 
 	// Use up tokens
 	for i := 0; i < 3; i++ {
-		err := rl.RateLimit(model, prompt)
+		err := rl.RateLimit(model, "", prompt)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 	}
 	// Should be rate limited now
-	err := rl.RateLimit(model, prompt)
+	err := rl.RateLimit(model, "", prompt)
 	if err == nil {
 		t.Fatalf("expected rate limit error, got nil")
 	}
@@ -92,7 +99,7 @@ func TestTokenRateLimiter_ResetAfterTime(t *testing.T) {
 
 	// Wait for refill
 	time.Sleep(1100 * time.Millisecond)
-	err = rl.RateLimit(model, prompt)
+	err = rl.RateLimit(model, "", prompt)
 	if err != nil {
 		t.Fatalf("expected nil after refill, got %v", err)
 	}
@@ -110,12 +117,12 @@ func TestTokenRateLimiter_OutputTokenRecording(t *testing.T) {
 	})
 
 	// Record output tokens - this should not block/error
-	rl.RecordOutputTokens(model, 5)
-	rl.RecordOutputTokens(model, 3)
-	rl.RecordOutputTokens(model, 2) // Total: 10 tokens consumed
+	rl.RecordOutputTokens(model, "", 5)
+	rl.RecordOutputTokens(model, "", 3)
+	rl.RecordOutputTokens(model, "", 2) // Total: 10 tokens consumed
 
 	// Recording more tokens should still work (just consumes from the bucket)
-	rl.RecordOutputTokens(model, 1)
+	rl.RecordOutputTokens(model, "", 1)
 }
 
 func TestTokenRateLimiter_CombinedInputOutput(t *testing.T) {
@@ -133,15 +140,15 @@ func TestTokenRateLimiter_CombinedInputOutput(t *testing.T) {
 	})
 
 	// First request should be allowed
-	err := rl.RateLimit(model, prompt)
+	err := rl.RateLimit(model, "", prompt)
 	if err != nil {
 		t.Fatalf("unexpected error on first request: %v", err)
 	}
 	// Record output tokens used
-	rl.RecordOutputTokens(model, 2)
+	rl.RecordOutputTokens(model, "", 2)
 
 	// Second request should be rate limited due to input token exhaustion
-	err = rl.RateLimit(model, prompt)
+	err = rl.RateLimit(model, "", prompt)
 	if err == nil {
 		t.Fatalf("expected rate limit error after exhausting input tokens")
 	}
@@ -153,7 +160,7 @@ func TestTokenRateLimiter_CombinedInputOutput(t *testing.T) {
 func TestTokenRateLimiter_OutputNoLimiter(t *testing.T) {
 	rl := NewTokenRateLimiter()
 	// No limiter added, should not error when recording output tokens
-	rl.RecordOutputTokens("unknown-model", 100)
+	rl.RecordOutputTokens("unknown-model", "", 100)
 	// RecordOutputTokens doesn't return error, just silently does nothing
 }
 
@@ -171,12 +178,12 @@ func TestTokenRateLimiter_DeleteLimiter(t *testing.T) {
 	})
 
 	// Verify limiter exists and restricts
-	err := rl.RateLimit(model, "hello world") // ~3 tokens
+	err := rl.RateLimit(model, "", "hello world") // ~3 tokens
 	if err != nil {
 		t.Fatalf("first request should be allowed: %v", err)
 	}
 
-	err = rl.RateLimit(model, "hello world") // Should be rate limited
+	err = rl.RateLimit(model, "", "hello world") // Should be rate limited
 	if err == nil {
 		t.Fatalf("expected rate limit error")
 	}
@@ -186,14 +193,14 @@ func TestTokenRateLimiter_DeleteLimiter(t *testing.T) {
 
 	// Should now be unrestricted
 	for i := 0; i < 10; i++ {
-		err = rl.RateLimit(model, "hello world")
+		err = rl.RateLimit(model, "", "hello world")
 		if err != nil {
 			t.Fatalf("expected nil after deletion, got %v", err)
 		}
 	}
 
 	// Recording output tokens should work without error
-	rl.RecordOutputTokens(model, 100)
+	rl.RecordOutputTokens(model, "", 100)
 }
 
 func TestTokenRateLimiter_OutputRateLimit(t *testing.T) {
@@ -209,16 +216,16 @@ func TestTokenRateLimiter_OutputRateLimit(t *testing.T) {
 	})
 
 	// First request should be allowed (has 5 tokens available)
-	err := rl.RateLimit(model, prompt)
+	err := rl.RateLimit(model, "", prompt)
 	if err != nil {
 		t.Fatalf("first request should be allowed: %v", err)
 	}
 
 	// Consume most tokens
-	rl.RecordOutputTokens(model, 5)
+	rl.RecordOutputTokens(model, "", 5)
 
 	// Next request should be blocked due to insufficient output tokens
-	err = rl.RateLimit(model, prompt)
+	err = rl.RateLimit(model, "", prompt)
 	if err == nil {
 		t.Fatalf("expected output rate limit error")
 	}
@@ -241,9 +248,15 @@ func TestTokenRateLimiter_InputAndOutputErrors(t *testing.T) {
 		Unit:               unit,
 	})
 
-	err := rl.RateLimit(model+"-input", longPrompt)
-	if err == nil {
-		t.Fatalf("expected input rate limit error")
+	// A prompt larger than the limit is rejected for good.
+	err := rl.RateLimit(model+"-input", "", longPrompt)
+	if _, ok := err.(*InputTokensExceedLimitError); !ok {
+		t.Fatalf("expected InputTokensExceedLimitError, got %T: %v", err, err)
+	}
+
+	// One that fits is rejected until the budget is refilled.
+	for err = nil; err == nil; {
+		err = rl.RateLimit(model+"-input", "", "hello world")
 	}
 	if _, ok := err.(*InputRateLimitExceededError); !ok {
 		t.Fatalf("expected InputRateLimitExceededError, got %T: %v", err, err)
@@ -256,19 +269,19 @@ func TestTokenRateLimiter_InputAndOutputErrors(t *testing.T) {
 	})
 
 	// First make a successful request to establish the limiter
-	err = rl.RateLimit(model+"-output", "short")
+	err = rl.RateLimit(model+"-output", "", "short")
 	if err != nil {
 		t.Fatalf("first request should succeed: %v", err)
 	}
 
 	// Consume all available output tokens
-	rl.RecordOutputTokens(model+"-output", 10) // Consume all 10 tokens
+	rl.RecordOutputTokens(model+"-output", "", 10) // Consume all 10 tokens
 
 	// Wait a bit for the tokens to be recorded
 	time.Sleep(10 * time.Millisecond)
 
 	// Next request should be blocked due to insufficient output tokens (< 1 token available)
-	err = rl.RateLimit(model+"-output", "short") // Short prompt to avoid input limit
+	err = rl.RateLimit(model+"-output", "", "short") // Short prompt to avoid input limit
 	if err == nil {
 		t.Fatalf("expected output rate limit error")
 	}
@@ -276,3 +289,332 @@ func TestTokenRateLimiter_InputAndOutputErrors(t *testing.T) {
 		t.Fatalf("expected OutputRateLimitExceededError, got %T: %v", err, err)
 	}
 }
+
+func TestTokenRateLimiter_Keyed(t *testing.T) {
+	rl := NewTokenRateLimiter()
+	model := "test-model"
+	inputTokens := uint32(10)
+	outputTokens := uint32(5)
+	err := rl.AddOrUpdateLimiter(model, &networkingv1alpha1.RateLimit{
+		InputTokensPerUnit:  &inputTokens,
+		OutputTokensPerUnit: &outputTokens,
+		Unit:                networkingv1alpha1.Minute,
+		Key:                 &networkingv1alpha1.RateLimitKey{Source: networkingv1alpha1.RateLimitKeySourceHeader, Header: "x-user-id"},
+	})
+	require.NoError(t, err)
+
+	// alice uses up her input budget, which leaves the budget of bob untouched.
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, rl.RateLimit(model, "alice", "hello world"))
+	}
+	assert.IsType(t, &InputRateLimitExceededError{}, rl.RateLimit(model, "alice", "hello world"))
+	assert.NoError(t, rl.RateLimit(model, "bob", "hello world"))
+
+	// The same goes for the output budget.
+	rl.RecordOutputTokens(model, "bob", 5)
+	assert.IsType(t, &OutputRateLimitExceededError{}, rl.RateLimit(model, "bob", "hello world"))
+	assert.NoError(t, rl.RateLimit(model, "carol", "hello world"))
+
+	// Requests without a key share a budget of their own.
+	assert.NoError(t, rl.RateLimit(model, "", "hello world"))
+}
+
+func TestTokenRateLimiter_KeyedBounded(t *testing.T) {
+	rl := NewTokenRateLimiter()
+	model := "test-model"
+	tokens := uint32(10)
+	require.NoError(t, rl.AddOrUpdateLimiter(model, &networkingv1alpha1.RateLimit{
+		InputTokensPerUnit: &tokens,
+		Unit:               networkingv1alpha1.Minute,
+		Key:                &networkingv1alpha1.RateLimitKey{Source: networkingv1alpha1.RateLimitKeySourceUser},
+	}))
+
+	for i := 0; i < maxLimitKeys+100; i++ {
+		assert.NoError(t, rl.RateLimit(model, fmt.Sprintf("user-%d", i), "hello world"))
+	}
+	assert.Equal(t, maxLimitKeys, rl.limits[model].limiters.Len())
+}
+
+func TestTokenRateLimiter_LimitKey(t *testing.T) {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"alice","tenant":"team-a"}`))
+	header := http.Header{}
+	header.Set("Authorization", "Bearer header."+payload+".signature")
+	header.Set("X-User-Id", "user-1")
+	body := map[string]interface{}{"model": "test-model", "user": "user-2"}
+
+	tests := []struct {
+		name   string
+		key    *networkingv1alpha1.RateLimitKey
+		claims map[string]interface{}
+		want   string
+	}{
+		{
+			name: "no key",
+			want: "",
+		},
+		{
+			name: "header",
+			key:  &networkingv1alpha1.RateLimitKey{Source: networkingv1alpha1.RateLimitKeySourceHeader, Header: "x-user-id"},
+			want: "user-1",
+		},
+		{
+			name: "missing header",
+			key:  &networkingv1alpha1.RateLimitKey{Source: networkingv1alpha1.RateLimitKeySourceHeader, Header: "x-tenant"},
+			want: "",
+		},
+		{
+			// The claims of the forged bearer token are not verified, so the request shares the
+			// bucket of the model.
+			name: "unverified jwt claim",
+			key:  &networkingv1alpha1.RateLimitKey{Source: networkingv1alpha1.RateLimitKeySourceJWTClaim},
+			want: "",
+		},
+		{
+			name: "unverified custom jwt claim",
+			key:  &networkingv1alpha1.RateLimitKey{Source: networkingv1alpha1.RateLimitKeySourceJWTClaim, Claim: "tenant"},
+			want: "",
+		},
+		{
+			name:   "verified claims",
+			key:    &networkingv1alpha1.RateLimitKey{Source: networkingv1alpha1.RateLimitKeySourceJWTClaim},
+			claims: map[string]interface{}{"sub": "bob", "tier": "gold"},
+			want:   "bob",
+		},
+		{
+			name:   "verified claim",
+			key:    &networkingv1alpha1.RateLimitKey{Source: networkingv1alpha1.RateLimitKeySourceJWTClaim, Claim: "tier"},
+			claims: map[string]interface{}{"sub": "bob", "tier": "gold"},
+			want:   "gold",
+		},
+		{
+			name:   "missing verified claim",
+			key:    &networkingv1alpha1.RateLimitKey{Source: networkingv1alpha1.RateLimitKeySourceJWTClaim, Claim: "tenant"},
+			claims: map[string]interface{}{"sub": "bob"},
+			want:   "",
+		},
+		{
+			name: "user field",
+			key:  &networkingv1alpha1.RateLimitKey{Source: networkingv1alpha1.RateLimitKeySourceUser},
+			want: "user-2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := NewTokenRateLimiter()
+			tokens := uint32(10)
+			require.NoError(t, rl.AddOrUpdateLimiter("test-model", &networkingv1alpha1.RateLimit{
+				InputTokensPerUnit: &tokens,
+				Unit:               networkingv1alpha1.Second,
+				Key:                tt.key,
+			}))
+			assert.Equal(t, tt.want, rl.LimitKey("test-model", header, tt.claims, body))
+		})
+	}
+	// A model without a rate limit has no key.
+	assert.Empty(t, NewTokenRateLimiter().LimitKey("test-model", header, nil, body))
+}
+
+func TestTokenRateLimiter_ForgedJWTClaim(t *testing.T) {
+	rl := NewTokenRateLimiter()
+	tokens := uint32(10)
+	require.NoError(t, rl.AddOrUpdateLimiter("test-model", &networkingv1alpha1.RateLimit{
+		InputTokensPerUnit: &tokens,
+		Unit:               networkingv1alpha1.Minute,
+		Key:                &networkingv1alpha1.RateLimitKey{Source: networkingv1alpha1.RateLimitKeySourceJWTClaim},
+	}))
+
+	// Every request carries a self-made token with a new subject, which must not get a new bucket.
+	// "hello world" is estimated as 3 tokens, so the bucket of the model admits 3 requests.
+	for i := 0; i < 4; i++ {
+		payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub":"user-%d"}`, i)))
+		header := http.Header{}
+		header.Set("Authorization", "Bearer header."+payload+".signature")
+		key := rl.LimitKey("test-model", header, nil, nil)
+		assert.Empty(t, key)
+		err := rl.RateLimit("test-model", key, "hello world")
+		if i < 3 {
+			assert.NoError(t, err)
+		} else {
+			assert.IsType(t, &InputRateLimitExceededError{}, err)
+		}
+	}
+	assert.Equal(t, 1, rl.limits["test-model"].limiters.Len())
+}
+
+func TestTokenRateLimiter_RequestsPerUnit(t *testing.T) {
+	rl := NewTokenRateLimiter()
+	model := "test-model"
+	requests := uint32(2)
+	require.NoError(t, rl.AddOrUpdateLimiter(model, &networkingv1alpha1.RateLimit{
+		RequestsPerUnit: &requests,
+		Unit:            networkingv1alpha1.Minute,
+	}))
+
+	// Tiny requests are limited by their number, not their tokens.
+	assert.NoError(t, rl.RateLimit(model, "", "a"))
+	assert.NoError(t, rl.RateLimit(model, "", "a"))
+	assert.IsType(t, &RequestRateLimitExceededError{}, rl.RateLimit(model, "", "a"))
+}
+
+func TestTokenRateLimiter_MaxConcurrentRequests(t *testing.T) {
+	rl := NewTokenRateLimiter()
+	model := "test-model"
+	maxConcurrent := uint32(2)
+	require.NoError(t, rl.AddOrUpdateLimiter(model, &networkingv1alpha1.RateLimit{
+		MaxConcurrentRequests: &maxConcurrent,
+		Unit:                  networkingv1alpha1.Second,
+		Key:                   &networkingv1alpha1.RateLimitKey{Source: networkingv1alpha1.RateLimitKeySourceUser},
+	}))
+
+	release1, err := rl.AcquireConcurrency(model, "alice")
+	require.NoError(t, err)
+	release2, err := rl.AcquireConcurrency(model, "alice")
+	require.NoError(t, err)
+	_, err = rl.AcquireConcurrency(model, "alice")
+	assert.IsType(t, &ConcurrencyLimitExceededError{}, err)
+
+	// Other keys have slots of their own.
+	release3, err := rl.AcquireConcurrency(model, "bob")
+	require.NoError(t, err)
+	release3()
+
+	// Releasing a slot twice frees it only once.
+	release1()
+	release1()
+	release4, err := rl.AcquireConcurrency(model, "alice")
+	require.NoError(t, err)
+	_, err = rl.AcquireConcurrency(model, "alice")
+	assert.IsType(t, &ConcurrencyLimitExceededError{}, err)
+	release2()
+	release4()
+
+	// A model without a rate limit has no concurrency limit.
+	release, err := rl.AcquireConcurrency("unknown-model", "")
+	assert.NoError(t, err)
+	release()
+}
+
+func TestTokenRateLimiter_MaxConcurrentRequestsUpdated(t *testing.T) {
+	rl := NewTokenRateLimiter()
+	model := "test-model"
+	maxConcurrent := uint32(2)
+	tokens := uint32(10)
+	ratelimit := &networkingv1alpha1.RateLimit{
+		MaxConcurrentRequests: &maxConcurrent,
+		InputTokensPerUnit:    &tokens,
+		Unit:                  networkingv1alpha1.Minute,
+		Key:                   &networkingv1alpha1.RateLimitKey{Source: networkingv1alpha1.RateLimitKeySourceUser},
+	}
+	require.NoError(t, rl.AddOrUpdateLimiter(model, ratelimit))
+
+	release1, err := rl.AcquireConcurrency(model, "alice")
+	require.NoError(t, err)
+	release2, err := rl.AcquireConcurrency(model, "alice")
+	require.NoError(t, err)
+	assert.NoError(t, rl.RateLimit(model, "alice", "hello world"))
+
+	// An unchanged rate limit keeps the limiters of the keys.
+	require.NoError(t, rl.AddOrUpdateLimiter(model, ratelimit.DeepCopy()))
+	status, err := rl.Check(model, "alice", "hello")
+	require.NoError(t, err)
+	assert.Less(t, status.Tokens.Remaining, int(tokens)-2)
+
+	// The requests in flight are kept when the rate limit changes, and when the other limiters of
+	// the key are evicted.
+	updatedMaxConcurrent := uint32(3)
+	updated := ratelimit.DeepCopy()
+	updated.MaxConcurrentRequests = &updatedMaxConcurrent
+	require.NoError(t, rl.AddOrUpdateLimiter(model, updated))
+	for i := 0; i < maxLimitKeys+100; i++ {
+		assert.NoError(t, rl.RateLimit(model, fmt.Sprintf("user-%d", i), "hello"))
+	}
+	release3, err := rl.AcquireConcurrency(model, "alice")
+	require.NoError(t, err)
+	_, err = rl.AcquireConcurrency(model, "alice")
+	assert.IsType(t, &ConcurrencyLimitExceededError{}, err)
+
+	release1()
+	release2()
+	release3()
+	assert.Empty(t, rl.limits[model].concurrency.inFlight)
+}
+
+func TestTokenRateLimiter_Check(t *testing.T) {
+	rl := NewTokenRateLimiter()
+	model := "test-model"
+	inputTokens := uint32(60)
+	outputTokens := uint32(100)
+	requests := uint32(10)
+	require.NoError(t, rl.AddOrUpdateLimiter(model, &networkingv1alpha1.RateLimit{
+		InputTokensPerUnit:  &inputTokens,
+		OutputTokensPerUnit: &outputTokens,
+		RequestsPerUnit:     &requests,
+		Unit:                networkingv1alpha1.Minute,
+	}))
+
+	// "hello world" is 3 tokens, refilled at 1 token per second.
+	status, err := rl.Check(model, "", "hello world")
+	require.NoError(t, err)
+	assert.Equal(t, 60, status.Tokens.Limit)
+	assert.Equal(t, 57, status.Tokens.Remaining)
+	assert.InDelta(t, 3*time.Second, status.Tokens.Reset, float64(100*time.Millisecond))
+	assert.Equal(t, 10, status.Requests.Limit)
+	assert.Equal(t, 9, status.Requests.Remaining)
+	assert.InDelta(t, 6*time.Second, status.Requests.Reset, float64(100*time.Millisecond))
+
+	// A request larger than the input token limit checks no limit.
+	status, err = rl.Check(model, "", strings.Repeat("hello world ", 30))
+	assert.IsType(t, &InputTokensExceedLimitError{}, err)
+	assert.Nil(t, status.Tokens)
+	assert.Nil(t, status.Requests)
+
+	// Without an input token limit, the token limit is the output token limit.
+	require.NoError(t, rl.AddOrUpdateLimiter(model, &networkingv1alpha1.RateLimit{
+		OutputTokensPerUnit: &outputTokens,
+		Unit:                networkingv1alpha1.Minute,
+	}))
+	status, err = rl.Check(model, "", "hello world")
+	require.NoError(t, err)
+	assert.Equal(t, &LimitStatus{Limit: 100, Remaining: 100, Reset: 0}, status.Tokens)
+	assert.Nil(t, status.Requests)
+
+	status, err = rl.Check("unknown-model", "", "hello world")
+	require.NoError(t, err)
+	assert.Nil(t, status.Tokens)
+	assert.Nil(t, status.Requests)
+}
+
+func TestTokenRateLimiter_RetryAfter(t *testing.T) {
+	rl := NewTokenRateLimiter()
+	model := "test-model"
+	inputTokens := uint32(60)
+	outputTokens := uint32(60)
+	require.NoError(t, rl.AddOrUpdateLimiter(model, &networkingv1alpha1.RateLimit{
+		InputTokensPerUnit:  &inputTokens,
+		OutputTokensPerUnit: &outputTokens,
+		Unit:                networkingv1alpha1.Minute,
+	}))
+
+	// Input tokens are refilled at 1 token per second: the 3 tokens of "hello world" are available
+	// again in about 3 seconds.
+	for i := 0; i < 20; i++ {
+		require.NoError(t, rl.RateLimit(model, "", "hello world"))
+	}
+	err := rl.RateLimit(model, "", "hello world")
+	var inputErr *InputRateLimitExceededError
+	require.ErrorAs(t, err, &inputErr)
+	assert.Greater(t, inputErr.RetryAfter, time.Duration(0))
+	assert.LessOrEqual(t, inputErr.RetryAfter, 3*time.Second)
+
+	rl = NewTokenRateLimiter()
+	require.NoError(t, rl.AddOrUpdateLimiter(model, &networkingv1alpha1.RateLimit{
+		OutputTokensPerUnit: &outputTokens,
+		Unit:                networkingv1alpha1.Minute,
+	}))
+	rl.RecordOutputTokens(model, "", 60)
+	err = rl.RateLimit(model, "", "hello world")
+	var outputErr *OutputRateLimitExceededError
+	require.ErrorAs(t, err, &outputErr)
+	assert.InDelta(t, time.Second, outputErr.RetryAfter, float64(100*time.Millisecond))
+}
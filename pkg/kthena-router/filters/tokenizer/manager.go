@@ -0,0 +1,160 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tokenizer
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/cespare/xxhash"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"k8s.io/klog/v2"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
+)
+
+const (
+	TypeEstimate = "estimate"
+	TypeTikToken = "tiktoken"
+	TypeRemote   = "remote"
+
+	defaultCacheSize = 10000
+)
+
+// DefaultManager is the Manager shared by rate limiting, metrics and the scheduler plugins of the
+// router, so that a prompt is counted once.
+var DefaultManager = NewManager()
+
+// Manager counts the tokens of prompts with the tokenizer configured for their model, and caches
+// the counts by prompt hash. Counts recorded by others, e.g. the scheduler plugins tokenizing the
+// prompt with the inference engine, are served from the same cache.
+type Manager struct {
+	mutex      sync.RWMutex
+	fallback   Tokenizer
+	defaults   Tokenizer
+	tokenizers map[string]Tokenizer
+	counts     *lru.Cache[uint64, int]
+}
+
+// NewManager creates a Manager estimating the tokens of every model.
+func NewManager() *Manager {
+	counts, _ := lru.New[uint64, int](defaultCacheSize)
+	estimate := NewSimpleEstimateTokenizer()
+	return &Manager{
+		fallback:   estimate,
+		defaults:   estimate,
+		tokenizers: map[string]Tokenizer{},
+		counts:     counts,
+	}
+}
+
+// Configure replaces the tokenizers of the manager, and drops the cached counts.
+func (m *Manager) Configure(config *conf.TokenizerConfiguration) error {
+	defaults, err := m.newTokenizer("", config.Default)
+	if err != nil {
+		return fmt.Errorf("invalid default tokenizer: %w", err)
+	}
+	tokenizers := make(map[string]Tokenizer, len(config.Models))
+	for _, model := range config.Models {
+		if model.Model == "" {
+			return fmt.Errorf("tokenizer without a model")
+		}
+		tok, err := m.newTokenizer(model.Model, model.TokenizerSpec)
+		if err != nil {
+			return fmt.Errorf("invalid tokenizer of model %s: %w", model.Model, err)
+		}
+		tokenizers[model.Model] = tok
+	}
+	size := config.CacheSize
+	if size <= 0 {
+		size = defaultCacheSize
+	}
+	counts, err := lru.New[uint64, int](size)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.defaults = defaults
+	m.tokenizers = tokenizers
+	m.counts = counts
+	return nil
+}
+
+func (m *Manager) newTokenizer(model string, spec conf.TokenizerSpec) (Tokenizer, error) {
+	switch spec.Type {
+	case "", TypeEstimate:
+		return m.fallback, nil
+	case TypeTikToken:
+		return NewTickToken(spec.Encoding)
+	case TypeRemote:
+		if spec.Endpoint == "" {
+			return nil, fmt.Errorf("remote tokenizer without an endpoint")
+		}
+		return NewRemoteTokenizer(spec.Endpoint, model)
+	default:
+		return nil, fmt.Errorf("unknown tokenizer type %q", spec.Type)
+	}
+}
+
+// CountTokens returns the number of tokens of the prompt of a request to the model. It falls back
+// to an estimate when the tokenizer of the model fails, e.g. when the inference engine is down.
+func (m *Manager) CountTokens(model, prompt string) int {
+	if prompt == "" {
+		return 0
+	}
+	key := promptHash(model, prompt)
+
+	m.mutex.RLock()
+	tok, ok := m.tokenizers[model]
+	if !ok {
+		tok = m.defaults
+	}
+	counts := m.counts
+	m.mutex.RUnlock()
+
+	if count, ok := counts.Get(key); ok {
+		return count
+	}
+	count, err := tok.CalculateTokenNum(prompt)
+	if err != nil {
+		klog.Errorf("failed to calculate token number of model %s: %v", model, err)
+		// The estimate is not cached, so that the prompt is counted again once the tokenizer is back.
+		count, _ = m.fallback.CalculateTokenNum(prompt)
+		return count
+	}
+	counts.Add(key, count)
+	return count
+}
+
+// RecordTokens caches the number of tokens of the prompt of a request to the model, as counted by
+// the exact tokenizer of the model.
+func (m *Manager) RecordTokens(model, prompt string, count int) {
+	m.mutex.RLock()
+	counts := m.counts
+	m.mutex.RUnlock()
+	counts.Add(promptHash(model, prompt), count)
+}
+
+func promptHash(model, prompt string) uint64 {
+	h := xxhash.New()
+	_, _ = h.Write([]byte(model))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(prompt))
+	return h.Sum64()
+}
@@ -0,0 +1,138 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tokenizer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
+)
+
+// newTokenizeServer serves a /tokenize endpoint counting 7 tokens for any prompt.
+func newTokenizeServer(t *testing.T, calls *atomic.Int32, status *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if code := status.Load(); code != 0 {
+			w.WriteHeader(int(code))
+			return
+		}
+		assert.Equal(t, "/tokenize", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"count":  7,
+			"tokens": []int{1, 2, 3, 4, 5, 6, 7},
+		})
+	}))
+}
+
+func TestManager_Estimate(t *testing.T) {
+	manager := NewManager()
+	assert.Equal(t, 0, manager.CountTokens("test-model", ""))
+	assert.Equal(t, 3, manager.CountTokens("test-model", "hello world"))
+}
+
+func TestManager_TikToken(t *testing.T) {
+	manager := NewManager()
+	require.NoError(t, manager.Configure(&conf.TokenizerConfiguration{
+		Models: []conf.ModelTokenizer{{Model: "gpt", TokenizerSpec: conf.TokenizerSpec{Type: TypeTikToken}}},
+	}))
+
+	// "hello world" is 2 tokens with cl100k_base, and is estimated as 3 for other models.
+	assert.Equal(t, 2, manager.CountTokens("gpt", "hello world"))
+	assert.Equal(t, 3, manager.CountTokens("other", "hello world"))
+}
+
+func TestManager_Remote(t *testing.T) {
+	var calls, status atomic.Int32
+	server := newTokenizeServer(t, &calls, &status)
+	defer server.Close()
+
+	manager := NewManager()
+	require.NoError(t, manager.Configure(&conf.TokenizerConfiguration{
+		Default: conf.TokenizerSpec{Type: TypeRemote, Endpoint: server.URL},
+	}))
+
+	assert.Equal(t, 7, manager.CountTokens("test-model", "hello world"))
+	// The count is served from the cache.
+	assert.Equal(t, 7, manager.CountTokens("test-model", "hello world"))
+	assert.Equal(t, int32(1), calls.Load())
+
+	// The prompt is estimated while the engine fails, without calling it again until the backoff
+	// expires, and counted again once it is back.
+	status.Store(http.StatusInternalServerError)
+	assert.Equal(t, 2, manager.CountTokens("test-model", "hello"))
+	failedCalls := calls.Load()
+	assert.Equal(t, 2, manager.CountTokens("test-model", "hello"))
+	assert.Equal(t, failedCalls, calls.Load())
+	status.Store(0)
+	assert.Equal(t, 2, manager.CountTokens("test-model", "hello"))
+	manager.defaults.(*RemoteTokenizer).unavailableUntil.Store(0)
+	assert.Equal(t, 7, manager.CountTokens("test-model", "hello"))
+}
+
+func TestManager_RecordTokens(t *testing.T) {
+	manager := NewManager()
+	manager.RecordTokens("test-model", "hello world", 42)
+
+	assert.Equal(t, 42, manager.CountTokens("test-model", "hello world"))
+	// The counts are per model.
+	assert.Equal(t, 3, manager.CountTokens("other", "hello world"))
+
+	// Configuring the manager drops the cached counts.
+	require.NoError(t, manager.Configure(&conf.TokenizerConfiguration{CacheSize: 10}))
+	assert.Equal(t, 3, manager.CountTokens("test-model", "hello world"))
+}
+
+func TestManager_ConfigureErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config conf.TokenizerConfiguration
+	}{
+		{
+			name:   "unknown type",
+			config: conf.TokenizerConfiguration{Default: conf.TokenizerSpec{Type: "sentencepiece"}},
+		},
+		{
+			name:   "unknown encoding",
+			config: conf.TokenizerConfiguration{Default: conf.TokenizerSpec{Type: TypeTikToken, Encoding: "unknown"}},
+		},
+		{
+			name:   "remote without endpoint",
+			config: conf.TokenizerConfiguration{Default: conf.TokenizerSpec{Type: TypeRemote}},
+		},
+		{
+			name:   "model without name",
+			config: conf.TokenizerConfiguration{Models: []conf.ModelTokenizer{{TokenizerSpec: conf.TokenizerSpec{Type: TypeTikToken}}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager()
+			assert.Error(t, manager.Configure(&tt.config))
+			// The manager keeps estimating.
+			assert.Equal(t, 3, manager.CountTokens("test-model", "hello world"))
+		})
+	}
+}
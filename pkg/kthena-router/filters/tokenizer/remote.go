@@ -0,0 +1,84 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tokenizer
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/tokenization"
+)
+
+// remoteTokenizeTimeout bounds the time a prompt spends being counted by the inference engine,
+// since it is counted before the request is scheduled.
+const remoteTokenizeTimeout = 2 * time.Second
+
+// remoteUnavailableBackoff is the time prompts are not sent to the inference engine after a failed
+// call, so that requests fall back to an estimate immediately while the engine is down.
+const remoteUnavailableBackoff = 5 * time.Second
+
+// RemoteTokenizer counts tokens with the /tokenize endpoint of the inference engine serving the
+// model, which applies the exact tokenizer of the model.
+type RemoteTokenizer struct {
+	tokenizer tokenization.ExtendedTokenizer
+	// unavailableUntil is the unix nano time until which the engine is not called after a failure.
+	unavailableUntil atomic.Int64
+}
+
+// NewRemoteTokenizer creates a RemoteTokenizer for the model served at the endpoint.
+func NewRemoteTokenizer(endpoint, model string) (*RemoteTokenizer, error) {
+	tok, err := tokenization.NewRemoteTokenizer(tokenization.RemoteTokenizerConfig{
+		Engine:           "vllm",
+		Endpoint:         endpoint,
+		Model:            model,
+		AddSpecialTokens: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	extended, ok := tok.(tokenization.ExtendedTokenizer)
+	if !ok {
+		return nil, fmt.Errorf("remote tokenizer of %s does not support tokenize options", endpoint)
+	}
+	return &RemoteTokenizer{tokenizer: extended}, nil
+}
+
+func (r *RemoteTokenizer) CalculateTokenNum(prompt string) (int, error) {
+	if until := r.unavailableUntil.Load(); until != 0 && time.Now().UnixNano() < until {
+		return 0, fmt.Errorf("remote tokenizer unavailable until %s", time.Unix(0, until).Format(time.RFC3339))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTokenizeTimeout)
+	defer cancel()
+
+	result, err := r.tokenizer.TokenizeWithOptions(ctx, tokenization.TokenizeInput{
+		Type:             tokenization.CompletionInput,
+		Text:             prompt,
+		AddSpecialTokens: true,
+	})
+	if err != nil {
+		r.unavailableUntil.Store(time.Now().Add(remoteUnavailableBackoff).UnixNano())
+		return 0, err
+	}
+	r.unavailableUntil.Store(0)
+	if result.Count > 0 {
+		return result.Count, nil
+	}
+	return len(result.Tokens), nil
+}
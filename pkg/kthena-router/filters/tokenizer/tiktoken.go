@@ -23,13 +23,24 @@ import (
 
 const encodingName = "cl100k_base"
 
-type TickToken struct{}
+// TickToken counts tokens with a tiktoken encoding, loaded from the encodings embedded in the binary.
+type TickToken struct {
+	encoding *tiktoken.Tiktoken
+}
 
-func (t *TickToken) CalculateTokenNum(prompt string) (int, error) {
+// NewTickToken creates a TickToken with the encoding, cl100k_base if it is empty.
+func NewTickToken(encoding string) (*TickToken, error) {
+	if encoding == "" {
+		encoding = encodingName
+	}
 	tiktoken.SetBpeLoader(tiktokenloader.NewOfflineLoader())
-	encoding, err := tiktoken.GetEncoding(encodingName)
+	tke, err := tiktoken.GetEncoding(encoding)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return len(encoding.Encode(prompt, nil, nil)), nil
+	return &TickToken{encoding: tke}, nil
+}
+
+func (t *TickToken) CalculateTokenNum(prompt string) (int, error) {
+	return len(t.encoding.Encode(prompt, nil, nil)), nil
 }
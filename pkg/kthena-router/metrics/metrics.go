@@ -36,6 +36,11 @@ const (
 	LabelModelRoute  = "model_route"
 	LabelModelServer = "model_server"
 	LabelUserID      = "user_id"
+	LabelPod         = "pod"
+	LabelWinner      = "winner"
+	LabelIssuer      = "issuer"
+	LabelReason      = "reason"
+	LabelResult      = "result"
 
 	// Token type values
 	TokenTypeInput  = "input"
@@ -46,9 +51,26 @@ const (
 	PluginTypeScore  = "score"
 
 	// Limit type values
-	LimitTypeInputTokens  = "input_tokens"
-	LimitTypeOutputTokens = "output_tokens"
-	LimitTypeRequests     = "requests"
+	LimitTypeInputTokens        = "input_tokens"
+	LimitTypeOutputTokens       = "output_tokens"
+	LimitTypeRequests           = "requests"
+	LimitTypeConcurrentRequests = "concurrent_requests"
+
+	// JWT validation failure reasons
+	JWTFailureMalformed     = "malformed"
+	JWTFailureUnknownIssuer = "unknown_issuer"
+	JWTFailureNoJWKS        = "no_jwks"
+	JWTFailureSignature     = "invalid_signature"
+	JWTFailureClaims        = "invalid_claims"
+
+	// Config reload result values
+	ConfigReloadSuccess = "success"
+	ConfigReloadFailure = "failure"
+
+	// Hedging winner values
+	HedgingWinnerPrimary = "primary"
+	HedgingWinnerHedge   = "hedge"
+	HedgingWinnerNone    = "none"
 )
 
 // Metrics holds all Prometheus metrics for the kthena-router
@@ -70,6 +92,30 @@ type Metrics struct {
 	// Rate limiting metrics
 	RateLimitExceeded prometheus.CounterVec
 
+	// Authentication metrics
+	JWTValidationFailuresTotal prometheus.CounterVec
+
+	// Router configuration reload metrics
+	ConfigReloadsTotal        prometheus.CounterVec
+	ConfigLastReloadTimestamp prometheus.GaugeVec
+
+	// Upstream attempt metrics
+	UpstreamAttemptsTotal prometheus.CounterVec
+	UpstreamRetriesTotal  prometheus.CounterVec
+
+	// Outlier detection metrics
+	PodEjectionsTotal prometheus.CounterVec
+	PodEjected        prometheus.GaugeVec
+
+	// Request hedging metrics
+	HedgedRequestsTotal    prometheus.CounterVec
+	CancelledRequestsTotal prometheus.CounterVec
+
+	// Traffic mirroring metrics
+	MirrorRequestsTotal   prometheus.CounterVec
+	MirrorRequestDuration prometheus.HistogramVec
+	MirrorTokensTotal     prometheus.CounterVec
+
 	// Request and scheduling metrics
 	ActiveDownstreamRequests prometheus.GaugeVec
 	ActiveUpstreamRequests   prometheus.GaugeVec
@@ -147,6 +193,103 @@ func NewMetrics() *Metrics {
 			[]string{LabelModel, LabelLimitType, LabelPath},
 		),
 
+		UpstreamAttemptsTotal: *promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kthena_router_upstream_attempts_total",
+				Help: "Total number of attempts to send requests to backend pods, by upstream status code",
+			},
+			[]string{LabelModelServer, LabelModelRoute, LabelStatusCode},
+		),
+
+		UpstreamRetriesTotal: *promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kthena_router_upstream_retries_total",
+				Help: "Total number of upstream attempts that were retries of a failed attempt",
+			},
+			[]string{LabelModelServer, LabelModelRoute},
+		),
+
+		JWTValidationFailuresTotal: *promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kthena_router_jwt_validation_failures_total",
+				Help: "Total number of JWTs rejected by the router, by issuer and reason",
+			},
+			[]string{LabelIssuer, LabelReason},
+		),
+
+		ConfigReloadsTotal: *promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kthena_router_config_reloads_total",
+				Help: "Total number of reloads of the router configuration, by result",
+			},
+			[]string{LabelResult},
+		),
+
+		ConfigLastReloadTimestamp: *promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kthena_router_config_last_reload_timestamp_seconds",
+				Help: "Unix time of the last reload of the router configuration, by result",
+			},
+			[]string{LabelResult},
+		),
+
+		PodEjectionsTotal: *promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kthena_router_pod_ejections_total",
+				Help: "Total number of times pods were ejected from scheduling by outlier detection",
+			},
+			[]string{LabelPod},
+		),
+
+		PodEjected: *promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kthena_router_pod_ejected",
+				Help: "Whether the pod is ejected from scheduling by outlier detection, including its half-open trial period",
+			},
+			[]string{LabelPod},
+		),
+
+		HedgedRequestsTotal: *promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kthena_router_hedged_requests_total",
+				Help: "Total number of hedged requests sent to a second pod, by the attempt whose response was returned",
+			},
+			[]string{LabelModelServer, LabelModelRoute, LabelWinner},
+		),
+
+		CancelledRequestsTotal: *promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kthena_router_hedging_cancelled_requests_total",
+				Help: "Total number of upstream requests cancelled because the other attempt of a hedged request answered first",
+			},
+			[]string{LabelModelServer, LabelModelRoute},
+		),
+
+		MirrorRequestsTotal: *promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kthena_router_mirror_requests_total",
+				Help: "Total number of requests mirrored to shadow model servers, by shadow status code",
+			},
+			[]string{LabelModelServer, LabelModelRoute, LabelStatusCode},
+		),
+
+		MirrorRequestDuration: *promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "kthena_router_mirror_request_duration_seconds",
+				Help:    "Latency distribution of the requests mirrored to shadow model servers",
+				Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+			},
+			[]string{LabelModelServer, LabelModelRoute, LabelStatusCode},
+		),
+
+		MirrorTokensTotal: *promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kthena_router_mirror_tokens_total",
+				Help: "Total tokens processed/generated by shadow model servers",
+			},
+			[]string{LabelModelServer, LabelModelRoute, LabelTokenType},
+		),
+
 		ActiveDownstreamRequests: *promauto.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "kthena_router_active_downstream_requests",
@@ -253,11 +396,76 @@ func (m *Metrics) RecordRateLimitExceeded(model, limitType, path string) {
 	m.RateLimitExceeded.WithLabelValues(model, limitType, path).Inc()
 }
 
+// RecordJWTValidationFailure records a JWT of the issuer rejected for the reason
+func (m *Metrics) RecordJWTValidationFailure(issuer, reason string) {
+	m.JWTValidationFailuresTotal.WithLabelValues(issuer, reason).Inc()
+}
+
+// RecordConfigReload records a reload of the router configuration with the result
+func (m *Metrics) RecordConfigReload(result string) {
+	m.ConfigReloadsTotal.WithLabelValues(result).Inc()
+	m.ConfigLastReloadTimestamp.WithLabelValues(result).SetToCurrentTime()
+}
+
 // RecordSchedulerPluginDuration records the processing time for a specific scheduler plugin
 func (m *Metrics) RecordSchedulerPluginDuration(model, pluginName, pluginType string, duration time.Duration) {
 	m.SchedulerPluginDuration.WithLabelValues(model, pluginName, pluginType).Observe(duration.Seconds())
 }
 
+// RecordUpstreamAttempt records a single attempt to send a request to a backend pod.
+// statusCode is the upstream HTTP status code, or an error kind when no response was received.
+func (m *Metrics) RecordUpstreamAttempt(modelServer, modelRoute, statusCode string, retry bool) {
+	m.UpstreamAttemptsTotal.WithLabelValues(modelServer, modelRoute, statusCode).Inc()
+	if retry {
+		m.UpstreamRetriesTotal.WithLabelValues(modelServer, modelRoute).Inc()
+	}
+}
+
+// RecordPodEjection records the ejection of a pod by outlier detection.
+func (m *Metrics) RecordPodEjection(pod string) {
+	m.PodEjectionsTotal.WithLabelValues(pod).Inc()
+	m.PodEjected.WithLabelValues(pod).Set(1)
+}
+
+// RecordPodRestored records that an ejected pod passed its trial requests.
+func (m *Metrics) RecordPodRestored(pod string) {
+	m.PodEjected.WithLabelValues(pod).Set(0)
+}
+
+// DeletePod deletes the outlier detection series of a deleted pod.
+func (m *Metrics) DeletePod(pod string) {
+	m.PodEjectionsTotal.DeleteLabelValues(pod)
+	m.PodEjected.DeleteLabelValues(pod)
+}
+
+// RecordHedgedRequest records a hedged request, and which of the two attempts answered first.
+func (m *Metrics) RecordHedgedRequest(modelServer, modelRoute, winner string) {
+	m.HedgedRequestsTotal.WithLabelValues(modelServer, modelRoute, winner).Inc()
+}
+
+// RecordCancelledRequest records an upstream request cancelled because the other attempt of a
+// hedged request answered first.
+func (m *Metrics) RecordCancelledRequest(modelServer, modelRoute string) {
+	m.CancelledRequestsTotal.WithLabelValues(modelServer, modelRoute).Inc()
+}
+
+// RecordMirrorRequest records a request mirrored to a shadow model server. statusCode is the
+// status code of the shadow, or an error kind when no response was received.
+func (m *Metrics) RecordMirrorRequest(modelServer, modelRoute, statusCode string, duration time.Duration) {
+	m.MirrorRequestsTotal.WithLabelValues(modelServer, modelRoute, statusCode).Inc()
+	m.MirrorRequestDuration.WithLabelValues(modelServer, modelRoute, statusCode).Observe(duration.Seconds())
+}
+
+// RecordMirrorTokens records the tokens of a request mirrored to a shadow model server.
+func (m *Metrics) RecordMirrorTokens(modelServer, modelRoute string, inputTokens, outputTokens int) {
+	if inputTokens > 0 {
+		m.MirrorTokensTotal.WithLabelValues(modelServer, modelRoute, TokenTypeInput).Add(float64(inputTokens))
+	}
+	if outputTokens > 0 {
+		m.MirrorTokensTotal.WithLabelValues(modelServer, modelRoute, TokenTypeOutput).Add(float64(outputTokens))
+	}
+}
+
 // SetActiveDownstreamRequests sets the current number of active downstream requests
 func (m *Metrics) SetActiveDownstreamRequests(model string, count float64) {
 	m.ActiveDownstreamRequests.WithLabelValues(model).Set(count)
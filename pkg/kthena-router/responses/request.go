@@ -0,0 +1,312 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package responses
+
+import (
+	"fmt"
+)
+
+// responsesFields are the fields of a Responses request that do not exist in chat completions.
+// The other fields, such as temperature or stream, are passed through to the chat completion.
+var responsesFields = []string{
+	"input", "instructions", "previous_response_id", "store", "max_output_tokens", "tools",
+	"tool_choice", "text", "reasoning", "metadata", "truncation", "include", "background", "prompt",
+}
+
+// Request holds the fields of a Responses request that shape the response.
+type Request struct {
+	Model              string
+	Instructions       string
+	PreviousResponseID string
+	// Store tells whether the response is kept for later requests, true by default.
+	Store    bool
+	Stream   bool
+	Metadata map[string]string
+}
+
+// ParseRequest reads the fields of a Responses request that shape the response.
+func ParseRequest(body map[string]interface{}) (*Request, error) {
+	req := &Request{Store: true}
+	req.Model, _ = body["model"].(string)
+	if v, ok := body["instructions"]; ok && v != nil {
+		instructions, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("instructions is not a string")
+		}
+		req.Instructions = instructions
+	}
+	if v, ok := body["previous_response_id"]; ok && v != nil {
+		id, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("previous_response_id is not a string")
+		}
+		req.PreviousResponseID = id
+	}
+	if v, ok := body["store"].(bool); ok {
+		req.Store = v
+	}
+	req.Stream, _ = body["stream"].(bool)
+	if metadata, ok := body["metadata"].(map[string]interface{}); ok {
+		req.Metadata = make(map[string]string, len(metadata))
+		for k, v := range metadata {
+			req.Metadata[k] = fmt.Sprint(v)
+		}
+	}
+	return req, nil
+}
+
+// ToChatCompletion translates the body of a Responses request into the body of a chat completions request.
+// history are the messages of the conversation up to the previous response, if any.
+// It also returns the messages to store with the response, which exclude the instructions.
+func ToChatCompletion(body map[string]interface{}, req *Request, history []map[string]interface{}) (map[string]interface{}, []map[string]interface{}, error) {
+	input, ok := body["input"]
+	if !ok {
+		return nil, nil, fmt.Errorf("input not found in request body")
+	}
+	inputMessages, err := inputToMessages(input)
+	if err != nil {
+		return nil, nil, err
+	}
+	conversation := make([]map[string]interface{}, 0, len(history)+len(inputMessages))
+	conversation = append(conversation, history...)
+	conversation = append(conversation, inputMessages...)
+
+	chat := make(map[string]interface{}, len(body))
+	for k, v := range body {
+		chat[k] = v
+	}
+	for _, field := range responsesFields {
+		delete(chat, field)
+	}
+
+	// The messages are kept as generic values, the way a decoded JSON request body holds them.
+	messages := make([]interface{}, 0, len(conversation)+1)
+	if req.Instructions != "" {
+		messages = append(messages, map[string]interface{}{"role": "system", "content": req.Instructions})
+	}
+	for _, msg := range conversation {
+		messages = append(messages, msg)
+	}
+	chat["messages"] = messages
+
+	if req.Stream {
+		// The usage of the last chunk is needed for the final event of the response.
+		chat["stream_options"] = map[string]interface{}{"include_usage": true}
+	}
+	if v, ok := body["max_output_tokens"]; ok && v != nil {
+		chat["max_tokens"] = v
+	}
+	if tools, ok := body["tools"].([]interface{}); ok && len(tools) > 0 {
+		chatTools, err := convertTools(tools)
+		if err != nil {
+			return nil, nil, err
+		}
+		chat["tools"] = chatTools
+	}
+	if v, ok := body["tool_choice"]; ok && v != nil {
+		chat["tool_choice"] = convertToolChoice(v)
+	}
+	if text, ok := body["text"].(map[string]interface{}); ok {
+		if format, ok := text["format"].(map[string]interface{}); ok {
+			if responseFormat := convertTextFormat(format); responseFormat != nil {
+				chat["response_format"] = responseFormat
+			}
+		}
+	}
+	return chat, conversation, nil
+}
+
+// inputToMessages translates the input of a Responses request, a string or a list of items, into chat messages.
+func inputToMessages(input interface{}) ([]map[string]interface{}, error) {
+	switch v := input.(type) {
+	case string:
+		return []map[string]interface{}{{"role": "user", "content": v}}, nil
+	case []interface{}:
+		messages := make([]map[string]interface{}, 0, len(v))
+		for i, item := range v {
+			itemMap, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("input item %d is not an object", i)
+			}
+			itemType, _ := itemMap["type"].(string)
+			switch itemType {
+			case "", ItemTypeMessage:
+				msg, err := convertInputMessage(itemMap)
+				if err != nil {
+					return nil, fmt.Errorf("input item %d: %w", i, err)
+				}
+				messages = append(messages, msg)
+			case ItemTypeFunctionCall:
+				toolCall := map[string]interface{}{
+					"id":   itemMap["call_id"],
+					"type": "function",
+					"function": map[string]interface{}{
+						"name":      itemMap["name"],
+						"arguments": itemMap["arguments"],
+					},
+				}
+				// Consecutive function calls are the tool calls of a single assistant message.
+				if n := len(messages); n > 0 && messages[n-1]["role"] == "assistant" && messages[n-1]["tool_calls"] != nil {
+					messages[n-1]["tool_calls"] = append(messages[n-1]["tool_calls"].([]interface{}), toolCall)
+					continue
+				}
+				messages = append(messages, map[string]interface{}{
+					"role":       "assistant",
+					"tool_calls": []interface{}{toolCall},
+				})
+			case ItemTypeFunctionCallOutput:
+				messages = append(messages, map[string]interface{}{
+					"role":         "tool",
+					"tool_call_id": itemMap["call_id"],
+					"content":      itemMap["output"],
+				})
+			case "reasoning":
+				// Reasoning items are produced by the model and are not sent back to it.
+				continue
+			default:
+				return nil, fmt.Errorf("unsupported input item type %q", itemType)
+			}
+		}
+		return messages, nil
+	default:
+		return nil, fmt.Errorf("input is neither a string nor a list")
+	}
+}
+
+func convertInputMessage(item map[string]interface{}) (map[string]interface{}, error) {
+	role, ok := item["role"].(string)
+	if !ok {
+		return nil, fmt.Errorf("message has no role")
+	}
+	if role == "developer" {
+		role = "system"
+	}
+
+	switch content := item["content"].(type) {
+	case string:
+		return map[string]interface{}{"role": role, "content": content}, nil
+	case []interface{}:
+		parts := make([]interface{}, 0, len(content))
+		for _, part := range content {
+			partMap, ok := part.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("content part is not an object")
+			}
+			switch partType, _ := partMap["type"].(string); partType {
+			case "input_text", "output_text":
+				parts = append(parts, map[string]interface{}{"type": "text", "text": partMap["text"]})
+			case "input_image":
+				imageURL, ok := partMap["image_url"].(string)
+				if !ok {
+					return nil, fmt.Errorf("only input_image with image_url is supported")
+				}
+				image := map[string]interface{}{"url": imageURL}
+				if detail, ok := partMap["detail"]; ok {
+					image["detail"] = detail
+				}
+				parts = append(parts, map[string]interface{}{"type": "image_url", "image_url": image})
+			default:
+				return nil, fmt.Errorf("unsupported content part type %q", partType)
+			}
+		}
+		return map[string]interface{}{"role": role, "content": parts}, nil
+	default:
+		return nil, fmt.Errorf("message content is neither a string nor a list")
+	}
+}
+
+// convertTools translates the function tools of a Responses request into chat completion tools.
+func convertTools(tools []interface{}) ([]interface{}, error) {
+	chatTools := make([]interface{}, 0, len(tools))
+	for _, tool := range tools {
+		toolMap, ok := tool.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("tool is not an object")
+		}
+		if toolType, _ := toolMap["type"].(string); toolType != "function" {
+			return nil, fmt.Errorf("unsupported tool type %q", toolType)
+		}
+		function := make(map[string]interface{}, len(toolMap))
+		for _, key := range []string{"name", "description", "parameters", "strict"} {
+			if v, ok := toolMap[key]; ok {
+				function[key] = v
+			}
+		}
+		chatTools = append(chatTools, map[string]interface{}{"type": "function", "function": function})
+	}
+	return chatTools, nil
+}
+
+// convertToolChoice translates {"type": "function", "name": "..."} into its chat completion form.
+// The string choices, such as "auto", are the same in both APIs.
+func convertToolChoice(choice interface{}) interface{} {
+	choiceMap, ok := choice.(map[string]interface{})
+	if !ok || choiceMap["type"] != "function" {
+		return choice
+	}
+	return map[string]interface{}{
+		"type":     "function",
+		"function": map[string]interface{}{"name": choiceMap["name"]},
+	}
+}
+
+// convertTextFormat translates the text format of a Responses request into a chat completion response format.
+func convertTextFormat(format map[string]interface{}) interface{} {
+	switch format["type"] {
+	case "json_object":
+		return map[string]interface{}{"type": "json_object"}
+	case "json_schema":
+		schema := make(map[string]interface{}, len(format))
+		for _, key := range []string{"name", "description", "schema", "strict"} {
+			if v, ok := format[key]; ok {
+				schema[key] = v
+			}
+		}
+		return map[string]interface{}{"type": "json_schema", "json_schema": schema}
+	default:
+		return nil
+	}
+}
+
+// outputToMessages translates the output of a response into the chat messages of the assistant.
+func outputToMessages(output []OutputItem) []map[string]interface{} {
+	var messages []map[string]interface{}
+	var toolCalls []interface{}
+	for _, item := range output {
+		switch item.Type {
+		case ItemTypeMessage:
+			text := ""
+			for _, part := range item.Content {
+				text += part.Text
+			}
+			messages = append(messages, map[string]interface{}{"role": "assistant", "content": text})
+		case ItemTypeFunctionCall:
+			toolCalls = append(toolCalls, map[string]interface{}{
+				"id":   item.CallID,
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":      item.Name,
+					"arguments": item.Arguments,
+				},
+			})
+		}
+	}
+	if len(toolCalls) > 0 {
+		messages = append(messages, map[string]interface{}{"role": "assistant", "tool_calls": toolCalls})
+	}
+	return messages
+}
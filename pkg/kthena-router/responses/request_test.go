@@ -0,0 +1,165 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package responses
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeBody(t *testing.T, body string) map[string]interface{} {
+	var m map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(body), &m))
+	return m
+}
+
+func TestParseRequest(t *testing.T) {
+	req, err := ParseRequest(decodeBody(t, `{"model": "m", "input": "hi"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, &Request{Model: "m", Store: true}, req)
+
+	req, err = ParseRequest(decodeBody(t, `{"model": "m", "instructions": "be brief", "previous_response_id": "resp_1",
+		"store": false, "stream": true, "metadata": {"team": "a"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, &Request{
+		Model:              "m",
+		Instructions:       "be brief",
+		PreviousResponseID: "resp_1",
+		Store:              false,
+		Stream:             true,
+		Metadata:           map[string]string{"team": "a"},
+	}, req)
+
+	_, err = ParseRequest(decodeBody(t, `{"model": "m", "instructions": 1}`))
+	assert.Error(t, err)
+}
+
+func TestToChatCompletion(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		history  []map[string]interface{}
+		want     string
+		wantConv int
+		wantErr  bool
+	}{
+		{
+			name:     "string input with instructions",
+			body:     `{"model": "m", "input": "hi", "instructions": "be brief", "max_output_tokens": 16, "temperature": 0.5, "store": true}`,
+			want:     `{"model": "m", "temperature": 0.5, "max_tokens": 16, "messages": [{"role": "system", "content": "be brief"}, {"role": "user", "content": "hi"}]}`,
+			wantConv: 1,
+		},
+		{
+			name:    "history is prepended",
+			body:    `{"model": "m", "input": "and now?"}`,
+			history: []map[string]interface{}{{"role": "user", "content": "hi"}, {"role": "assistant", "content": "hello"}},
+			want: `{"model": "m", "messages": [{"role": "user", "content": "hi"}, {"role": "assistant", "content": "hello"},
+				{"role": "user", "content": "and now?"}]}`,
+			wantConv: 3,
+		},
+		{
+			name: "message items with parts and function calls",
+			body: `{"model": "m", "input": [
+				{"role": "developer", "content": "use tools"},
+				{"type": "message", "role": "user", "content": [{"type": "input_text", "text": "what is this"},
+					{"type": "input_image", "image_url": "https://example.com/a.png", "detail": "low"}]},
+				{"type": "function_call", "call_id": "call_1", "name": "lookup", "arguments": "{}"},
+				{"type": "function_call", "call_id": "call_2", "name": "search", "arguments": "{\"q\":1}"},
+				{"type": "function_call_output", "call_id": "call_1", "output": "a cat"}
+			]}`,
+			want: `{"model": "m", "messages": [
+				{"role": "system", "content": "use tools"},
+				{"role": "user", "content": [{"type": "text", "text": "what is this"},
+					{"type": "image_url", "image_url": {"url": "https://example.com/a.png", "detail": "low"}}]},
+				{"role": "assistant", "tool_calls": [
+					{"id": "call_1", "type": "function", "function": {"name": "lookup", "arguments": "{}"}},
+					{"id": "call_2", "type": "function", "function": {"name": "search", "arguments": "{\"q\":1}"}}]},
+				{"role": "tool", "tool_call_id": "call_1", "content": "a cat"}]}`,
+			wantConv: 4,
+		},
+		{
+			name:     "streaming requests include usage",
+			body:     `{"model": "m", "input": "hi", "stream": true}`,
+			want:     `{"model": "m", "stream": true, "stream_options": {"include_usage": true}, "messages": [{"role": "user", "content": "hi"}]}`,
+			wantConv: 1,
+		},
+		{
+			name: "tools, tool choice and text format",
+			body: `{"model": "m", "input": "hi",
+				"tools": [{"type": "function", "name": "lookup", "description": "look up", "parameters": {"type": "object"}}],
+				"tool_choice": {"type": "function", "name": "lookup"},
+				"text": {"format": {"type": "json_schema", "name": "answer", "schema": {"type": "object"}, "strict": true}}}`,
+			want: `{"model": "m", "messages": [{"role": "user", "content": "hi"}],
+				"tools": [{"type": "function", "function": {"name": "lookup", "description": "look up", "parameters": {"type": "object"}}}],
+				"tool_choice": {"type": "function", "function": {"name": "lookup"}},
+				"response_format": {"type": "json_schema", "json_schema": {"name": "answer", "schema": {"type": "object"}, "strict": true}}}`,
+			wantConv: 1,
+		},
+		{
+			name:    "missing input",
+			body:    `{"model": "m"}`,
+			wantErr: true,
+		},
+		{
+			name:    "built-in tools are not supported",
+			body:    `{"model": "m", "input": "hi", "tools": [{"type": "web_search"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "unsupported item",
+			body:    `{"model": "m", "input": [{"type": "item_reference", "id": "msg_1"}]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := decodeBody(t, tt.body)
+			req, err := ParseRequest(body)
+			assert.NoError(t, err)
+			chat, conversation, err := ToChatCompletion(body, req, tt.history)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			got, _ := json.Marshal(chat)
+			assert.JSONEq(t, tt.want, string(got))
+			assert.Len(t, conversation, tt.wantConv)
+		})
+	}
+}
+
+func TestStoredResponseHistory(t *testing.T) {
+	stored := &StoredResponse{
+		Messages: []map[string]interface{}{{"role": "user", "content": "hi"}},
+		Response: &Response{
+			Output: []OutputItem{
+				{Type: ItemTypeMessage, Content: []ContentPart{textPart("let me check")}},
+				{Type: ItemTypeFunctionCall, CallID: "call_1", Name: "lookup", Arguments: "{}"},
+			},
+		},
+	}
+	got, _ := json.Marshal(stored.History())
+	assert.JSONEq(t, `[
+		{"role": "user", "content": "hi"},
+		{"role": "assistant", "content": "let me check"},
+		{"role": "assistant", "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "lookup", "arguments": "{}"}}]}
+	]`, string(got))
+}
@@ -0,0 +1,166 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package responses
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/redis/go-redis/v9"
+	"k8s.io/klog/v2"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/utils"
+)
+
+const (
+	StoreTypeMemory = "memory"
+	StoreTypeRedis  = "redis"
+
+	defaultStoreTTL        = 24 * time.Hour
+	defaultStoreMaxEntries = 10000
+
+	redisKeyPrefix = "kthena:responses:"
+)
+
+// ErrNotFound is returned by a Store when the response does not exist or has expired.
+var ErrNotFound = errors.New("response not found")
+
+// Store keeps the stored responses, so that later requests can refer to them by id.
+type Store interface {
+	Get(ctx context.Context, id string) (*StoredResponse, error)
+	Put(ctx context.Context, stored *StoredResponse) error
+	Delete(ctx context.Context, id string) error
+}
+
+// NewStoreFromEnv creates the Store configured by the environment variables:
+//   - RESPONSES_STORE_TYPE: "memory" (default) or "redis". The redis store uses REDIS_HOST,
+//     REDIS_PORT and REDIS_PASSWORD, and falls back to memory if redis is not reachable.
+//   - RESPONSES_STORE_TTL: how long the responses are kept, 24h by default.
+//   - RESPONSES_STORE_MAX_ENTRIES: the maximum number of responses kept in memory, 10000 by default.
+func NewStoreFromEnv() Store {
+	ttl := defaultStoreTTL
+	if v := os.Getenv("RESPONSES_STORE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			ttl = d
+		} else {
+			klog.Warningf("invalid RESPONSES_STORE_TTL %q, using %s", v, defaultStoreTTL)
+		}
+	}
+
+	if os.Getenv("RESPONSES_STORE_TYPE") == StoreTypeRedis {
+		if client := utils.TryGetRedisClient(); client != nil {
+			return NewRedisStore(client, ttl)
+		}
+		klog.Warning("redis is not available, responses are stored in memory")
+	}
+
+	maxEntries := defaultStoreMaxEntries
+	if v := os.Getenv("RESPONSES_STORE_MAX_ENTRIES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			maxEntries = n
+		} else {
+			klog.Warningf("invalid RESPONSES_STORE_MAX_ENTRIES %q, using %d", v, defaultStoreMaxEntries)
+		}
+	}
+	return NewMemoryStore(maxEntries, ttl)
+}
+
+type memoryStore struct {
+	cache *expirable.LRU[string, *StoredResponse]
+}
+
+// NewMemoryStore returns a Store keeping up to maxEntries responses in memory for ttl.
+// The least recently used responses are evicted first.
+func NewMemoryStore(maxEntries int, ttl time.Duration) Store {
+	return &memoryStore{
+		cache: expirable.NewLRU[string, *StoredResponse](maxEntries, nil, ttl),
+	}
+}
+
+func (m *memoryStore) Get(_ context.Context, id string) (*StoredResponse, error) {
+	stored, ok := m.cache.Get(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return stored, nil
+}
+
+func (m *memoryStore) Put(_ context.Context, stored *StoredResponse) error {
+	m.cache.Add(stored.Response.ID, stored)
+	return nil
+}
+
+func (m *memoryStore) Delete(_ context.Context, id string) error {
+	if !m.cache.Remove(id) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type redisStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewRedisStore returns a Store keeping the responses in redis for ttl, so that they are shared
+// by all the router replicas.
+func NewRedisStore(client *redis.Client, ttl time.Duration) Store {
+	return &redisStore{client: client, ttl: ttl}
+}
+
+func (r *redisStore) Get(ctx context.Context, id string) (*StoredResponse, error) {
+	data, err := r.client.Get(ctx, redisKeyPrefix+id).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get response %s from redis: %w", id, err)
+	}
+	stored := &StoredResponse{}
+	if err := json.Unmarshal(data, stored); err != nil {
+		return nil, fmt.Errorf("failed to decode response %s: %w", id, err)
+	}
+	return stored, nil
+}
+
+func (r *redisStore) Put(ctx context.Context, stored *StoredResponse) error {
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to encode response %s: %w", stored.Response.ID, err)
+	}
+	if err := r.client.Set(ctx, redisKeyPrefix+stored.Response.ID, data, r.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to put response %s to redis: %w", stored.Response.ID, err)
+	}
+	return nil
+}
+
+func (r *redisStore) Delete(ctx context.Context, id string) error {
+	deleted, err := r.client.Del(ctx, redisKeyPrefix+id).Result()
+	if err != nil {
+		return fmt.Errorf("failed to delete response %s from redis: %w", id, err)
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
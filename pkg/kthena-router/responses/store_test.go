@@ -0,0 +1,81 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package responses
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+	stored := &StoredResponse{
+		Response: &Response{ID: "resp_1", Object: "response", Status: StatusCompleted, Output: []OutputItem{}},
+		Messages: []map[string]interface{}{{"role": "user", "content": "hi"}},
+	}
+
+	_, err := store.Get(ctx, "resp_1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, store.Put(ctx, stored))
+	got, err := store.Get(ctx, "resp_1")
+	assert.NoError(t, err)
+	assert.Equal(t, stored, got)
+
+	assert.NoError(t, store.Delete(ctx, "resp_1"))
+	_, err = store.Get(ctx, "resp_1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.Delete(ctx, "resp_1"), ErrNotFound)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore(10, time.Hour))
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(1, time.Hour)
+	assert.NoError(t, store.Put(ctx, &StoredResponse{Response: &Response{ID: "resp_1"}}))
+	assert.NoError(t, store.Put(ctx, &StoredResponse{Response: &Response{ID: "resp_2"}}))
+
+	_, err := store.Get(ctx, "resp_1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = store.Get(ctx, "resp_2")
+	assert.NoError(t, err)
+}
+
+func TestRedisStore(t *testing.T) {
+	mr, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer mr.Close()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	testStore(t, NewRedisStore(client, time.Hour))
+
+	// Responses expire after the ttl.
+	store := NewRedisStore(client, time.Minute)
+	assert.NoError(t, store.Put(context.Background(), &StoredResponse{Response: &Response{ID: "resp_2"}}))
+	mr.FastForward(2 * time.Minute)
+	_, err = store.Get(context.Background(), "resp_2")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,142 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package responses implements the OpenAI Responses API (/v1/responses) on top of the
+// chat completions API served by the inference engines. Requests are translated into chat
+// completions, the results are translated back into responses, and the responses are kept
+// in a Store so that later requests can continue the conversation with previous_response_id.
+package responses
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	StatusInProgress = "in_progress"
+	StatusCompleted  = "completed"
+	StatusIncomplete = "incomplete"
+
+	ItemTypeMessage            = "message"
+	ItemTypeFunctionCall       = "function_call"
+	ItemTypeFunctionCallOutput = "function_call_output"
+)
+
+// Response is a response object of the Responses API.
+type Response struct {
+	ID                 string             `json:"id"`
+	Object             string             `json:"object"`
+	CreatedAt          int64              `json:"created_at"`
+	Status             string             `json:"status"`
+	Model              string             `json:"model"`
+	Instructions       string             `json:"instructions,omitempty"`
+	PreviousResponseID string             `json:"previous_response_id,omitempty"`
+	Output             []OutputItem       `json:"output"`
+	IncompleteDetails  *IncompleteDetails `json:"incomplete_details,omitempty"`
+	Usage              *Usage             `json:"usage,omitempty"`
+	Metadata           map[string]string  `json:"metadata,omitempty"`
+	Store              bool               `json:"store"`
+}
+
+// OutputItem is an item of the response output, either an assistant message or a function call.
+type OutputItem struct {
+	Type    string        `json:"type"`
+	ID      string        `json:"id"`
+	Status  string        `json:"status"`
+	Role    string        `json:"role,omitempty"`
+	Content []ContentPart `json:"content,omitempty"`
+
+	// Fields of function calls.
+	CallID    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+// ContentPart is a part of an output message.
+type ContentPart struct {
+	Type        string        `json:"type"`
+	Text        string        `json:"text"`
+	Annotations []interface{} `json:"annotations"`
+}
+
+// IncompleteDetails explains why a response is incomplete.
+type IncompleteDetails struct {
+	Reason string `json:"reason"`
+}
+
+// Usage is the token usage of a response.
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// StoredResponse is a response kept by the Store.
+type StoredResponse struct {
+	Response *Response `json:"response"`
+	// Messages are the chat messages sent to the model, including those of the previous
+	// responses but not the instructions, which are not carried over to later responses.
+	Messages []map[string]interface{} `json:"messages"`
+	// Owner is the user that created the response, empty if the request was not authenticated.
+	// Only the owner may retrieve, delete or continue the response.
+	Owner string `json:"owner,omitempty"`
+}
+
+// History returns the chat messages of the conversation up to and including the response.
+func (s *StoredResponse) History() []map[string]interface{} {
+	history := make([]map[string]interface{}, 0, len(s.Messages)+len(s.Response.Output))
+	history = append(history, s.Messages...)
+	return append(history, outputToMessages(s.Response.Output)...)
+}
+
+// chatCompletion is the part of a chat completion response, or of a streamed chunk, that is
+// translated into a response.
+type chatCompletion struct {
+	Choices []chatChoice `json:"choices"`
+	Usage   *chatUsage   `json:"usage"`
+}
+
+type chatChoice struct {
+	Index        int         `json:"index"`
+	Message      chatMessage `json:"message"`
+	Delta        chatMessage `json:"delta"`
+	FinishReason *string     `json:"finish_reason"`
+}
+
+type chatMessage struct {
+	Content   *string        `json:"content"`
+	ToolCalls []chatToolCall `json:"tool_calls"`
+}
+
+type chatToolCall struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type chatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func newID(prefix string) string {
+	return prefix + "_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+}
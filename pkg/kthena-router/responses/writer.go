@@ -0,0 +1,392 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package responses
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog/v2"
+)
+
+// Writer translates the chat completion written by the proxy into a response of the Responses API.
+// Non-streaming completions are buffered and translated on Close. Streaming completions are
+// translated chunk by chunk into Responses events. Error responses are written unchanged.
+type Writer struct {
+	gin.ResponseWriter
+
+	stream bool
+	// onComplete is called with the completed response, e.g. to store it.
+	onComplete func(*Response)
+
+	response *Response
+	decided  bool
+	// passthrough is set when the upstream response is an error, which is not translated.
+	passthrough bool
+	buf         bytes.Buffer
+
+	// Streaming state.
+	started      bool
+	done         bool
+	seq          int
+	finishReason string
+	textItem     *streamItem
+	toolItems    map[int]*streamItem
+}
+
+type streamItem struct {
+	outputIndex int
+	item        OutputItem
+	text        bytes.Buffer
+}
+
+// NewWriter returns a Writer wrapping w for the Responses request req.
+func NewWriter(w gin.ResponseWriter, req *Request, onComplete func(*Response)) *Writer {
+	return &Writer{
+		ResponseWriter: w,
+		stream:         req.Stream,
+		onComplete:     onComplete,
+		response: &Response{
+			ID:                 newID("resp"),
+			Object:             "response",
+			CreatedAt:          time.Now().Unix(),
+			Status:             StatusInProgress,
+			Model:              req.Model,
+			Instructions:       req.Instructions,
+			PreviousResponseID: req.PreviousResponseID,
+			Output:             []OutputItem{},
+			Metadata:           req.Metadata,
+			Store:              req.Store,
+		},
+		toolItems: make(map[int]*streamItem),
+	}
+}
+
+// ResponseID returns the id of the response being written.
+func (w *Writer) ResponseID() string {
+	return w.response.ID
+}
+
+func (w *Writer) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		w.passthrough = w.Status() >= http.StatusBadRequest
+		if !w.passthrough {
+			w.Header().Del("Content-Length")
+			if w.stream {
+				w.Header().Set("Content-Type", "text/event-stream")
+			} else {
+				w.Header().Set("Content-Type", "application/json")
+			}
+		}
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.buf.Write(data)
+	if w.stream {
+		w.processLines()
+	}
+	return len(data), nil
+}
+
+func (w *Writer) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Close writes the translated non-streaming response, or completes a stream that ended
+// without a final chunk.
+func (w *Writer) Close() {
+	if !w.decided || w.passthrough {
+		return
+	}
+	if w.stream {
+		if w.started && !w.done {
+			w.finishStream()
+		}
+		return
+	}
+
+	var completion chatCompletion
+	if err := json.Unmarshal(w.buf.Bytes(), &completion); err != nil {
+		klog.Errorf("failed to parse chat completion: %v", err)
+		w.ResponseWriter.WriteHeader(http.StatusBadGateway)
+		_, _ = w.ResponseWriter.Write([]byte(`"invalid response from model server"`))
+		return
+	}
+	w.buildResponse(&completion)
+	data, _ := json.Marshal(w.response)
+	_, _ = w.ResponseWriter.Write(data)
+	if w.onComplete != nil {
+		w.onComplete(w.response)
+	}
+}
+
+// buildResponse fills the response with the output of a non-streaming chat completion.
+func (w *Writer) buildResponse(completion *chatCompletion) {
+	if len(completion.Choices) > 0 {
+		choice := completion.Choices[0]
+		if choice.Message.Content != nil && *choice.Message.Content != "" {
+			w.response.Output = append(w.response.Output, OutputItem{
+				Type:    ItemTypeMessage,
+				ID:      newID("msg"),
+				Status:  StatusCompleted,
+				Role:    "assistant",
+				Content: []ContentPart{textPart(*choice.Message.Content)},
+			})
+		}
+		for _, toolCall := range choice.Message.ToolCalls {
+			w.response.Output = append(w.response.Output, OutputItem{
+				Type:      ItemTypeFunctionCall,
+				ID:        newID("fc"),
+				Status:    StatusCompleted,
+				CallID:    toolCall.ID,
+				Name:      toolCall.Function.Name,
+				Arguments: toolCall.Function.Arguments,
+			})
+		}
+		if choice.FinishReason != nil {
+			w.finishReason = *choice.FinishReason
+		}
+	}
+	w.setUsage(completion.Usage)
+	w.setStatus()
+}
+
+func (w *Writer) setUsage(usage *chatUsage) {
+	if usage == nil {
+		return
+	}
+	w.response.Usage = &Usage{
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+		TotalTokens:  usage.TotalTokens,
+	}
+}
+
+func (w *Writer) setStatus() {
+	w.response.Status = StatusCompleted
+	if w.finishReason == "length" {
+		w.response.Status = StatusIncomplete
+		w.response.IncompleteDetails = &IncompleteDetails{Reason: "max_output_tokens"}
+	}
+}
+
+// processLines translates the complete server-sent event lines in the buffer.
+func (w *Writer) processLines() {
+	for {
+		line, err := w.buf.ReadBytes('\n')
+		if err != nil {
+			// Keep the incomplete line until the rest of it is written.
+			rest := append([]byte(nil), line...)
+			w.buf.Reset()
+			w.buf.Write(rest)
+			return
+		}
+		line = bytes.TrimSpace(line)
+		data, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			continue
+		}
+		data = bytes.TrimSpace(data)
+		if bytes.Equal(data, []byte("[DONE]")) {
+			if w.started && !w.done {
+				w.finishStream()
+			}
+			continue
+		}
+		var chunk chatCompletion
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			klog.V(4).Infof("skip invalid chat completion chunk: %v", err)
+			continue
+		}
+		w.processChunk(&chunk)
+	}
+}
+
+func (w *Writer) processChunk(chunk *chatCompletion) {
+	if !w.started {
+		w.started = true
+		w.emit("response.created", map[string]interface{}{"response": w.response})
+		w.emit("response.in_progress", map[string]interface{}{"response": w.response})
+	}
+
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 {
+			continue
+		}
+		if choice.Delta.Content != nil && *choice.Delta.Content != "" {
+			w.appendText(*choice.Delta.Content)
+		}
+		for _, toolCall := range choice.Delta.ToolCalls {
+			w.appendToolCall(toolCall)
+		}
+		if choice.FinishReason != nil {
+			w.finishReason = *choice.FinishReason
+		}
+	}
+	w.setUsage(chunk.Usage)
+}
+
+func (w *Writer) appendText(delta string) {
+	if w.textItem == nil {
+		w.textItem = &streamItem{
+			outputIndex: w.nextOutputIndex(),
+			item:        OutputItem{Type: ItemTypeMessage, ID: newID("msg"), Status: StatusInProgress, Role: "assistant"},
+		}
+		w.emit("response.output_item.added", map[string]interface{}{
+			"output_index": w.textItem.outputIndex,
+			"item": map[string]interface{}{
+				"type":    ItemTypeMessage,
+				"id":      w.textItem.item.ID,
+				"status":  StatusInProgress,
+				"role":    "assistant",
+				"content": []ContentPart{},
+			},
+		})
+		w.emit("response.content_part.added", map[string]interface{}{
+			"item_id":       w.textItem.item.ID,
+			"output_index":  w.textItem.outputIndex,
+			"content_index": 0,
+			"part":          textPart(""),
+		})
+	}
+	w.textItem.text.WriteString(delta)
+	w.emit("response.output_text.delta", map[string]interface{}{
+		"item_id":       w.textItem.item.ID,
+		"output_index":  w.textItem.outputIndex,
+		"content_index": 0,
+		"delta":         delta,
+	})
+}
+
+func (w *Writer) appendToolCall(toolCall chatToolCall) {
+	item, ok := w.toolItems[toolCall.Index]
+	if !ok {
+		item = &streamItem{
+			outputIndex: w.nextOutputIndex(),
+			item: OutputItem{
+				Type:   ItemTypeFunctionCall,
+				ID:     newID("fc"),
+				Status: StatusInProgress,
+				CallID: toolCall.ID,
+				Name:   toolCall.Function.Name,
+			},
+		}
+		w.toolItems[toolCall.Index] = item
+		w.emit("response.output_item.added", map[string]interface{}{
+			"output_index": item.outputIndex,
+			"item":         item.item,
+		})
+	}
+	if toolCall.Function.Arguments != "" {
+		item.text.WriteString(toolCall.Function.Arguments)
+		w.emit("response.function_call_arguments.delta", map[string]interface{}{
+			"item_id":      item.item.ID,
+			"output_index": item.outputIndex,
+			"delta":        toolCall.Function.Arguments,
+		})
+	}
+}
+
+func (w *Writer) nextOutputIndex() int {
+	n := len(w.toolItems)
+	if w.textItem != nil {
+		n++
+	}
+	return n
+}
+
+// finishStream closes the output items and sends the final event of the response.
+func (w *Writer) finishStream() {
+	w.done = true
+
+	items := make([]*streamItem, 0, len(w.toolItems)+1)
+	if w.textItem != nil {
+		items = append(items, w.textItem)
+	}
+	for _, item := range w.toolItems {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].outputIndex < items[j].outputIndex })
+
+	for _, item := range items {
+		item.item.Status = StatusCompleted
+		if item.item.Type == ItemTypeMessage {
+			text := item.text.String()
+			part := textPart(text)
+			item.item.Content = []ContentPart{part}
+			w.emit("response.output_text.done", map[string]interface{}{
+				"item_id":       item.item.ID,
+				"output_index":  item.outputIndex,
+				"content_index": 0,
+				"text":          text,
+			})
+			w.emit("response.content_part.done", map[string]interface{}{
+				"item_id":       item.item.ID,
+				"output_index":  item.outputIndex,
+				"content_index": 0,
+				"part":          part,
+			})
+		} else {
+			item.item.Arguments = item.text.String()
+			w.emit("response.function_call_arguments.done", map[string]interface{}{
+				"item_id":      item.item.ID,
+				"output_index": item.outputIndex,
+				"arguments":    item.item.Arguments,
+			})
+		}
+		w.emit("response.output_item.done", map[string]interface{}{
+			"output_index": item.outputIndex,
+			"item":         item.item,
+		})
+		w.response.Output = append(w.response.Output, item.item)
+	}
+
+	w.setStatus()
+	event := "response.completed"
+	if w.response.Status == StatusIncomplete {
+		event = "response.incomplete"
+	}
+	w.emit(event, map[string]interface{}{"response": w.response})
+	if w.onComplete != nil {
+		w.onComplete(w.response)
+	}
+}
+
+// emit writes a server-sent event of the Responses API.
+func (w *Writer) emit(eventType string, payload map[string]interface{}) {
+	payload["type"] = eventType
+	payload["sequence_number"] = w.seq
+	w.seq++
+	data, err := json.Marshal(payload)
+	if err != nil {
+		klog.Errorf("failed to marshal %s event: %v", eventType, err)
+		return
+	}
+	_, _ = fmt.Fprintf(w.ResponseWriter, "event: %s\ndata: %s\n\n", eventType, data)
+}
+
+func textPart(text string) ContentPart {
+	return ContentPart{Type: "output_text", Text: text, Annotations: []interface{}{}}
+}
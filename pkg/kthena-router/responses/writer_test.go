@@ -0,0 +1,175 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package responses
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestWriter(stream bool) (*httptest.ResponseRecorder, *Writer, *[]*Response) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	completed := &[]*Response{}
+	writer := NewWriter(c.Writer, &Request{Model: "m", Stream: stream, Store: true}, func(resp *Response) {
+		*completed = append(*completed, resp)
+	})
+	return w, writer, completed
+}
+
+func TestWriterNonStreaming(t *testing.T) {
+	w, writer, completed := newTestWriter(false)
+	writer.Header().Set("Content-Length", "1000")
+	writer.WriteHeader(http.StatusOK)
+	_, _ = writer.Write([]byte(`{"id": "chatcmpl-1", "choices": [{"index": 0, "message": {"role": "assistant", "content": "hello",`))
+	_, _ = writer.Write([]byte(`"tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "lookup", "arguments": "{}"}}]},
+		"finish_reason": "tool_calls"}], "usage": {"prompt_tokens": 3, "completion_tokens": 5, "total_tokens": 8}}`))
+	writer.Close()
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Length"))
+	var resp Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, writer.ResponseID(), resp.ID)
+	assert.Equal(t, "response", resp.Object)
+	assert.Equal(t, StatusCompleted, resp.Status)
+	assert.Equal(t, &Usage{InputTokens: 3, OutputTokens: 5, TotalTokens: 8}, resp.Usage)
+	assert.Len(t, resp.Output, 2)
+	assert.Equal(t, "hello", resp.Output[0].Content[0].Text)
+	assert.Equal(t, ItemTypeFunctionCall, resp.Output[1].Type)
+	assert.Equal(t, "call_1", resp.Output[1].CallID)
+	assert.Len(t, *completed, 1)
+}
+
+func TestWriterNonStreamingIncomplete(t *testing.T) {
+	w, writer, _ := newTestWriter(false)
+	_, _ = writer.Write([]byte(`{"choices": [{"index": 0, "message": {"content": "trunc"}, "finish_reason": "length"}]}`))
+	writer.Close()
+
+	var resp Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, StatusIncomplete, resp.Status)
+	assert.Equal(t, &IncompleteDetails{Reason: "max_output_tokens"}, resp.IncompleteDetails)
+}
+
+func TestWriterPassesErrorsThrough(t *testing.T) {
+	w, writer, completed := newTestWriter(false)
+	writer.WriteHeader(http.StatusTooManyRequests)
+	_, _ = writer.Write([]byte(`"rate limited"`))
+	writer.Close()
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, `"rate limited"`, w.Body.String())
+	assert.Empty(t, *completed)
+}
+
+type sseEvent struct {
+	name string
+	data map[string]interface{}
+}
+
+func parseEvents(t *testing.T, body string) []sseEvent {
+	var events []sseEvent
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	var name string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if v, ok := strings.CutPrefix(line, "event: "); ok {
+			name = v
+		}
+		if v, ok := strings.CutPrefix(line, "data: "); ok {
+			var data map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(v), &data))
+			assert.Equal(t, name, data["type"])
+			events = append(events, sseEvent{name: name, data: data})
+		}
+	}
+	return events
+}
+
+func TestWriterStreaming(t *testing.T) {
+	w, writer, completed := newTestWriter(true)
+	chunks := strings.Join([]string{
+		`data: {"choices": [{"index": 0, "delta": {"role": "assistant", "content": ""}}]}`,
+		`data: {"choices": [{"index": 0, "delta": {"content": "Hel"}}]}`,
+		`data: {"choices": [{"index": 0, "delta": {"content": "lo"}}]}`,
+		`data: {"choices": [{"index": 0, "delta": {"tool_calls": [{"index": 0, "id": "call_1", "function": {"name": "lookup", "arguments": "{\"q\""}}]}}]}`,
+		`data: {"choices": [{"index": 0, "delta": {"tool_calls": [{"index": 0, "function": {"arguments": ":1}"}}]}, "finish_reason": "tool_calls"}]}`,
+		`data: {"choices": [], "usage": {"prompt_tokens": 3, "completion_tokens": 5, "total_tokens": 8}}`,
+		`data: [DONE]`,
+	}, "\n\n") + "\n\n"
+	// Write in small pieces, which split the lines.
+	for i := 0; i < len(chunks); i += 7 {
+		end := min(i+7, len(chunks))
+		_, _ = writer.Write([]byte(chunks[i:end]))
+	}
+	writer.Close()
+
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	events := parseEvents(t, w.Body.String())
+	var names []string
+	for _, event := range events {
+		names = append(names, event.name)
+	}
+	assert.Equal(t, []string{
+		"response.created",
+		"response.in_progress",
+		"response.output_item.added",
+		"response.content_part.added",
+		"response.output_text.delta",
+		"response.output_text.delta",
+		"response.output_item.added",
+		"response.function_call_arguments.delta",
+		"response.function_call_arguments.delta",
+		"response.output_text.done",
+		"response.content_part.done",
+		"response.output_item.done",
+		"response.function_call_arguments.done",
+		"response.output_item.done",
+		"response.completed",
+	}, names)
+	for i, event := range events {
+		assert.Equal(t, float64(i), event.data["sequence_number"])
+	}
+	assert.Equal(t, "Hello", events[9].data["text"])
+	assert.Equal(t, `{"q":1}`, events[12].data["arguments"])
+
+	assert.Len(t, *completed, 1)
+	resp := (*completed)[0]
+	assert.Equal(t, StatusCompleted, resp.Status)
+	assert.Len(t, resp.Output, 2)
+	assert.Equal(t, "Hello", resp.Output[0].Content[0].Text)
+	assert.Equal(t, `{"q":1}`, resp.Output[1].Arguments)
+	assert.Equal(t, 8, resp.Usage.TotalTokens)
+}
+
+func TestWriterStreamingWithoutDone(t *testing.T) {
+	w, writer, completed := newTestWriter(true)
+	_, _ = writer.Write([]byte("data: {\"choices\": [{\"index\": 0, \"delta\": {\"content\": \"hi\"}, \"finish_reason\": \"length\"}]}\n\n"))
+	writer.Close()
+
+	events := parseEvents(t, w.Body.String())
+	assert.Equal(t, "response.incomplete", events[len(events)-1].name)
+	assert.Len(t, *completed, 1)
+}
@@ -0,0 +1,56 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/accesslog"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/anthropic"
+)
+
+const messagesPath = "/v1/messages"
+
+// isMessagesRequest reports whether the request creates a message with the Anthropic Messages API.
+func isMessagesRequest(req *http.Request) bool {
+	return req.Method == http.MethodPost && strings.TrimSuffix(req.URL.Path, "/") == messagesPath
+}
+
+// translateMessagesRequest turns a Messages API request into a chat completions request. The writer
+// of the context is replaced by an anthropic.Writer, which translates the chat completion back into
+// a message. The returned writer must be closed once the request is proxied.
+func translateMessagesRequest(c *gin.Context, modelRequest ModelRequest) (ModelRequest, *anthropic.Writer, error) {
+	model, _ := modelRequest["model"].(string)
+	stream, _ := modelRequest["stream"].(bool)
+	writer := anthropic.NewWriter(c.Writer, model, stream)
+	c.Writer = writer
+
+	chatRequest, err := anthropic.ToChatCompletion(modelRequest)
+	if err != nil {
+		accesslog.SetError(c, "request_parsing", err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		writer.Close()
+		return nil, nil, err
+	}
+
+	c.Request.URL.Path = strings.TrimSuffix(strings.TrimSuffix(c.Request.URL.Path, "/"), "/messages") + "/chat/completions"
+	c.Request.URL.RawPath = ""
+	return chatRequest, writer, nil
+}
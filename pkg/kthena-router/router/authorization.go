@@ -0,0 +1,100 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog/v2"
+
+	"github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/accesslog"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/common"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/filters/auth"
+)
+
+// claimsOf returns the claims of the JWT of the request, or nil if it was not authenticated.
+func claimsOf(c *gin.Context) map[string]interface{} {
+	if v, exists := c.Get(common.ClaimsKey); exists {
+		if claims, ok := v.(map[string]interface{}); ok {
+			return claims
+		}
+	}
+	return nil
+}
+
+// allowedModelsOf returns the models the credentials of the request may use, e.g. the models of its
+// API key, or nil if they may use any model.
+func allowedModelsOf(c *gin.Context) []string {
+	if v, exists := c.Get(common.AllowedModelsKey); exists {
+		if models, ok := v.([]string); ok {
+			return models
+		}
+	}
+	return nil
+}
+
+// authorizationResource returns the resource of a request to the model served by the ModelRoute,
+// or by no ModelRoute if mr is nil.
+func authorizationResource(mr *v1alpha1.ModelRoute, model string, isLora bool) auth.Resource {
+	if mr == nil {
+		return auth.Resource{Model: model}
+	}
+	resource := auth.Resource{ModelRoute: fmt.Sprintf("%s/%s", mr.Namespace, mr.Name), Model: model}
+	if isLora {
+		resource.Model = mr.Spec.ModelName
+		resource.LoraAdapter = model
+	}
+	return resource
+}
+
+// authorize checks that the caller may use the model of the request, through the ModelRoute the
+// request matches. Denied requests are answered with 403.
+func (r *Router) authorize(c *gin.Context, modelName string) bool {
+	if !r.runtimeConfigOf(c).authorizer.IsEnabled() && allowedModelsOf(c) == nil {
+		return true
+	}
+	// A request that matches no ModelRoute is authorized on its model alone, and is answered with
+	// 404 afterwards unless it matches an HTTPRoute.
+	var resource auth.Resource
+	if match, err := r.store.MatchRoute(modelName, c.Request, c.GetString(GatewayKey)); err == nil {
+		resource = authorizationResource(match.ModelRoute, modelName, match.IsLora)
+	} else {
+		resource = authorizationResource(nil, modelName, false)
+	}
+
+	if err := r.checkAccess(c, resource); err != nil {
+		klog.V(4).Infof("request of %s to model %s is forbidden: %v", c.GetString(common.UserIdKey), modelName, err)
+		accesslog.SetError(c, "authorization", err.Error())
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Forbidden: %v", err)})
+		return false
+	}
+	return true
+}
+
+// checkAccess returns an error if the caller may not use the resource, either because its
+// credentials are restricted to other models or because of the authorization rules.
+func (r *Router) checkAccess(c *gin.Context, resource auth.Resource) error {
+	if models := allowedModelsOf(c); models != nil {
+		if err := auth.AuthorizeModels(models, resource); err != nil {
+			return err
+		}
+	}
+	return r.runtimeConfigOf(c).authorizer.Authorize(claimsOf(c), resource)
+}
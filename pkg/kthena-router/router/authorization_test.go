@@ -0,0 +1,170 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	aiv1alpha1 "github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/accesslog"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/common"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/filters/auth"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
+)
+
+// setupAuthorizationTest sets up the route of setupTrafficPolicyTest, with the LoRA adapter
+// sql-lora, and the rules allowing team-a to use test-model but not sql-lora.
+func setupAuthorizationTest(t *testing.T) (*Router, *httptest.Server) {
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"id":"response-id"}`)
+	})
+	router, backend := setupTrafficPolicyTest(t, backendHandler, nil)
+
+	modelRoute := router.store.GetModelRoute("default/mr-1").DeepCopy()
+	modelRoute.Spec.LoraAdapters = []string{"sql-lora"}
+	require.NoError(t, router.store.AddOrUpdateModelRoute(modelRoute))
+
+	authorizer, err := auth.NewAuthorizer(conf.AuthorizationConfig{Rules: []conf.AuthorizationRule{
+		{Name: "team-a", Action: auth.ActionAllow, Claims: map[string][]string{"groups": {"team-a"}}, ModelRoutes: []string{"default/mr-1"}},
+		{Name: "no-lora", Action: auth.ActionDeny, LoraAdapters: []string{"sql-*"}},
+	}})
+	require.NoError(t, err)
+	config := *router.config.Load()
+	config.authorizer = authorizer
+	router.config.Store(&config)
+	return router, backend
+}
+
+// serveAuthorizedRequest serves a request to the model by the caller with the groups, and returns
+// its access log.
+func serveAuthorizedRequest(router *Router, method, path, model string, groups ...string) (*httptest.ResponseRecorder, *accesslog.AccessLogContext) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest(method, path, bytes.NewBufferString(fmt.Sprintf(`{"model": %q, "prompt": "hello"}`, model)))
+	c.Request.Header.Set("Content-Type", "application/json")
+	if len(groups) > 0 {
+		claims := map[string]interface{}{"sub": "alice", "groups": []interface{}{}}
+		for _, group := range groups {
+			claims["groups"] = append(claims["groups"].([]interface{}), group)
+		}
+		c.Set(common.UserIdKey, "alice")
+		c.Set(common.ClaimsKey, claims)
+	}
+	accessCtx := accesslog.NewAccessLogContext("request-id", method, path, "HTTP/1.1", model)
+	c.Set(accesslog.AccessLogContextKey, accessCtx)
+	router.HandlerFunc()(c)
+	return w, accessCtx
+}
+
+func TestRouter_Authorization(t *testing.T) {
+	router, backend := setupAuthorizationTest(t)
+	defer backend.Close()
+
+	w, _ := serveAuthorizedRequest(router, http.MethodPost, "/v1/completions", "test-model", "team-a")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	tests := []struct {
+		name   string
+		model  string
+		groups []string
+	}{
+		{name: "other group", model: "test-model", groups: []string{"team-b"}},
+		{name: "unauthenticated", model: "test-model"},
+		{name: "denied lora adapter", model: "sql-lora", groups: []string{"team-a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, accessCtx := serveAuthorizedRequest(router, http.MethodPost, "/v1/completions", tt.model, tt.groups...)
+			assert.Equal(t, http.StatusForbidden, w.Code)
+			if assert.NotNil(t, accessCtx.Error) {
+				assert.Equal(t, "authorization", accessCtx.Error.Type)
+			}
+			assert.Empty(t, accessCtx.SelectedPod)
+		})
+	}
+}
+
+func TestRouter_AuthorizationModels(t *testing.T) {
+	router, backend := setupAuthorizationTest(t)
+	defer backend.Close()
+	otherRoute := &aiv1alpha1.ModelRoute{
+		ObjectMeta: v1.ObjectMeta{Name: "mr-other", Namespace: "default"},
+		Spec: aiv1alpha1.ModelRouteSpec{
+			ModelName: "other-model",
+			Rules: []*aiv1alpha1.Rule{
+				{TargetModels: []*aiv1alpha1.TargetModel{{ModelServerName: "ms-1"}}},
+			},
+		},
+	}
+	require.NoError(t, router.store.AddOrUpdateModelRoute(otherRoute))
+
+	// Only the models the caller may use are listed.
+	w, _ := serveAuthorizedRequest(router, http.MethodGet, "/v1/models", "", "team-a")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list ModelList
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	if assert.Len(t, list.Data, 1) {
+		assert.Equal(t, "test-model", list.Data[0].ID)
+	}
+
+	w, _ = serveAuthorizedRequest(router, http.MethodGet, "/v1/models", "", "team-b")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Empty(t, list.Data)
+
+	w, _ = serveAuthorizedRequest(router, http.MethodGet, "/v1/models/sql-lora", "", "team-a")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRouter_AllowedModels(t *testing.T) {
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"id":"response-id"}`)
+	})
+	// No authorization rules, only the models of the API key.
+	router, backend := setupTrafficPolicyTest(t, backendHandler, nil)
+	defer backend.Close()
+
+	serve := func(method, path, model string, allowed ...string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(method, path, bytes.NewBufferString(fmt.Sprintf(`{"model": %q, "prompt": "hello"}`, model)))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Set(common.UserIdKey, "alice")
+		c.Set(common.AllowedModelsKey, allowed)
+		router.HandlerFunc()(c)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/v1/completions", "test-model", "test-*").Code)
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "/v1/completions", "test-model", "other-model").Code)
+
+	w := serve(http.MethodGet, "/v1/models", "", "other-model")
+	var list ModelList
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Empty(t, list.Data)
+}
@@ -0,0 +1,297 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/accesslog"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/filters/auth"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/metrics"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/tracing"
+)
+
+const (
+	// configReloadInterval is how often the router configuration file is checked for changes.
+	configReloadInterval = 10 * time.Second
+	// runtimeConfigKey holds the *runtimeConfig of the request in the gin context.
+	runtimeConfigKey = "runtimeConfig"
+	// tracerShutdownDelay is how long a tracer provider replaced by a reload is kept exporting the
+	// spans of the requests in flight.
+	tracerShutdownDelay = 30 * time.Second
+	// tracerShutdownTimeout bounds the export of the last spans of a tracer provider.
+	tracerShutdownTimeout = 10 * time.Second
+	// accessLoggerCloseDelay is how long an access logger replaced by a reload is kept logging the
+	// requests in flight. It is longer than tracerShutdownDelay, since the requests are logged once
+	// their responses, which may stream for minutes, are complete.
+	accessLoggerCloseDelay = 5 * time.Minute
+)
+
+// runtimeConfig holds the components built from the router configuration. They are swapped
+// together when the configuration is reloaded, and a request keeps using the ones it started with.
+type runtimeConfig struct {
+	// checksum is the sha256 checksum of the configuration file.
+	checksum      string
+	issuers       []conf.JWTIssuerConfig
+	scheduler     scheduler.Scheduler
+	authenticator *auth.JWTAuthenticator
+	authorizer    *auth.Authorizer
+	authenticate  gin.HandlerFunc
+	tracing       conf.TracingConfiguration
+	tracer        *tracing.Provider
+	accessLog     conf.AccessLogConfiguration
+	accessLogger  accesslog.AccessLogger
+}
+
+// ConfigStatus is the status of the router configuration and of its reloads.
+type ConfigStatus struct {
+	Path string `json:"path"`
+	// Checksum is the sha256 checksum of the configuration in use.
+	Checksum string    `json:"checksum"`
+	LoadedAt time.Time `json:"loadedAt"`
+	// Reloads and Failures count the successful and rejected reloads.
+	Reloads     int                  `json:"reloads"`
+	Failures    int                  `json:"failures"`
+	LastFailure *ConfigReloadFailure `json:"lastFailure,omitempty"`
+}
+
+// ConfigReloadFailure is a configuration that was rejected, while the previous one kept serving.
+type ConfigReloadFailure struct {
+	Checksum string    `json:"checksum,omitempty"`
+	Time     time.Time `json:"time"`
+	Error    string    `json:"error"`
+}
+
+func checksumOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// loadConfig builds the components of the router configuration file, reusing those of previous
+// whose configuration is unchanged. Nothing is swapped in if the configuration is invalid.
+func (r *Router) loadConfig(data []byte, previous *runtimeConfig) (*runtimeConfig, *conf.RouterConfiguration, error) {
+	routerConfig, err := conf.ParseRouterConfigData(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	authorizer, err := auth.NewAuthorizer(routerConfig.Auth.Authorization)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to configure authorization: %w", err)
+	}
+	var previousScheduler scheduler.Scheduler
+	if previous != nil {
+		previousScheduler = previous.scheduler
+	}
+	sched, err := scheduler.BuildScheduler(r.store, routerConfig, previousScheduler)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load scheduler: %w", err)
+	}
+	// The exporter of unchanged tracing is kept, so that no span is lost.
+	var tracer *tracing.Provider
+	if previous != nil && reflect.DeepEqual(previous.tracing, routerConfig.Tracing) {
+		tracer = previous.tracer
+	} else if tracer, err = tracing.NewProvider(&routerConfig.Tracing); err != nil {
+		return nil, nil, fmt.Errorf("failed to configure tracing: %w", err)
+	}
+	// So are the sinks of an unchanged access log, which may have entries to send.
+	var accessLogger accesslog.AccessLogger
+	if previous != nil && reflect.DeepEqual(previous.accessLog, routerConfig.AccessLog) {
+		accessLogger = previous.accessLogger
+	} else if accessLogger, err = accesslog.New(&routerConfig.AccessLog); err != nil {
+		if previous == nil || tracer != previous.tracer {
+			_ = tracer.Shutdown(context.Background())
+		}
+		return nil, nil, fmt.Errorf("failed to configure access log: %w", err)
+	}
+	// The JWKS of unchanged issuers are not fetched again, and a reload is rejected if the JWKS of
+	// a new issuer cannot be fetched.
+	issuers := routerConfig.Auth.JWTIssuers()
+	var authenticator *auth.JWTAuthenticator
+	switch {
+	case previous == nil:
+		authenticator = auth.NewJWTAuthenticator(routerConfig)
+	case reflect.DeepEqual(previous.issuers, issuers):
+		authenticator = previous.authenticator
+	default:
+		if authenticator, err = auth.ReloadJWTAuthenticator(routerConfig, previous.authenticator); err != nil {
+			if tracer != previous.tracer {
+				_ = tracer.Shutdown(context.Background())
+			}
+			if accessLogger != previous.accessLogger {
+				_ = accessLogger.Close()
+			}
+			return nil, nil, fmt.Errorf("failed to configure authentication: %w", err)
+		}
+	}
+	// The tokenizers are configured last, because they take effect right away.
+	if err := r.tokenizers.Configure(&routerConfig.Tokenizer); err != nil {
+		if previous == nil || tracer != previous.tracer {
+			_ = tracer.Shutdown(context.Background())
+		}
+		if previous == nil || accessLogger != previous.accessLogger {
+			_ = accessLogger.Close()
+		}
+		if previous == nil {
+			authenticator.Close()
+		} else if authenticator != previous.authenticator {
+			authenticator.CloseExcept(previous.authenticator)
+		}
+		return nil, nil, fmt.Errorf("failed to configure tokenizers: %w", err)
+	}
+
+	return &runtimeConfig{
+		checksum:      checksumOf(data),
+		issuers:       issuers,
+		scheduler:     sched,
+		authenticator: authenticator,
+		authorizer:    authorizer,
+		authenticate:  auth.Authenticate(r.apiKeys, authenticator),
+		tracing:       routerConfig.Tracing,
+		tracer:        tracer,
+		accessLog:     routerConfig.AccessLog,
+		accessLogger:  accessLogger,
+	}, routerConfig, nil
+}
+
+// runtimeConfigOf returns the runtime configuration of the request, which is the current one when
+// the request is first seen.
+func (r *Router) runtimeConfigOf(c *gin.Context) *runtimeConfig {
+	if v, exists := c.Get(runtimeConfigKey); exists {
+		if config, ok := v.(*runtimeConfig); ok {
+			return config
+		}
+	}
+	config := r.config.Load()
+	c.Set(runtimeConfigKey, config)
+	return config
+}
+
+// reloadConfig reloads the router configuration if the file has changed. An invalid configuration
+// is rejected, and the previous one keeps serving.
+func (r *Router) reloadConfig() error {
+	r.reloadMutex.Lock()
+	defer r.reloadMutex.Unlock()
+
+	data, err := os.ReadFile(r.configPath)
+	if err != nil {
+		return r.rejectConfig("", fmt.Errorf("failed to read config file %s: %w", r.configPath, err))
+	}
+	checksum := checksumOf(data)
+	current := r.config.Load()
+	if checksum == current.checksum {
+		return nil
+	}
+	// A rejected configuration is not tried again until the file changes.
+	if failure := r.configStatus.LastFailure; failure != nil && failure.Checksum == checksum {
+		return nil
+	}
+
+	next, routerConfig, err := r.loadConfig(data, current)
+	if err != nil {
+		return r.rejectConfig(checksum, err)
+	}
+	r.config.Store(next)
+	r.apiKeys.SetEnabled(routerConfig.Auth.APIKey.Enabled)
+	r.apiKeys.SetNamespaces(routerConfig.Auth.APIKey.Namespaces)
+	if next.authenticator != current.authenticator {
+		// The rotators of the issuers the new authenticator reuses keep running.
+		current.authenticator.CloseExcept(next.authenticator)
+	}
+	if next.tracer != current.tracer {
+		go shutdownTracer(current.tracer)
+	}
+	if next.accessLogger != current.accessLogger {
+		go closeAccessLogger(current.accessLogger)
+	}
+
+	klog.Infof("reloaded router config %s (sha256 %s)", r.configPath, checksum)
+	r.metrics.RecordConfigReload(metrics.ConfigReloadSuccess)
+	r.configStatus.Checksum = checksum
+	r.configStatus.LoadedAt = time.Now()
+	r.configStatus.Reloads++
+	return nil
+}
+
+// shutdownTracer shuts down a tracer provider replaced by a reload, once the requests in flight
+// have had time to end their spans.
+func shutdownTracer(tracer *tracing.Provider) {
+	time.Sleep(tracerShutdownDelay)
+	ctx, cancel := context.WithTimeout(context.Background(), tracerShutdownTimeout)
+	defer cancel()
+	if err := tracer.Shutdown(ctx); err != nil {
+		klog.Errorf("failed to shut down the tracer provider: %v", err)
+	}
+}
+
+// closeAccessLogger closes an access logger replaced by a reload, once the requests in flight
+// have had time to be logged.
+func closeAccessLogger(logger accesslog.AccessLogger) {
+	time.Sleep(accessLoggerCloseDelay)
+	if err := logger.Close(); err != nil {
+		klog.Errorf("failed to close the access logger: %v", err)
+	}
+}
+
+func (r *Router) rejectConfig(checksum string, err error) error {
+	klog.Errorf("rejected router config %s, keeping the previous one: %v", r.configPath, err)
+	r.metrics.RecordConfigReload(metrics.ConfigReloadFailure)
+	r.configStatus.Failures++
+	r.configStatus.LastFailure = &ConfigReloadFailure{Checksum: checksum, Time: time.Now(), Error: err.Error()}
+	return err
+}
+
+// WatchConfig reloads the router configuration whenever its file changes, until ctx is done. The
+// file is polled rather than watched, since a ConfigMap volume is updated by swapping the symlink
+// of its directory.
+func (r *Router) WatchConfig(ctx context.Context) {
+	wait.Until(func() {
+		_ = r.reloadConfig()
+	}, configReloadInterval, ctx.Done())
+}
+
+// ConfigStatus returns the status of the router configuration and of its reloads.
+func (r *Router) ConfigStatus() ConfigStatus {
+	r.reloadMutex.Lock()
+	defer r.reloadMutex.Unlock()
+	status := r.configStatus
+	if status.LastFailure != nil {
+		failure := *status.LastFailure
+		status.LastFailure = &failure
+	}
+	return status
+}
+
+// ConfigStatusHandler returns the handler of the debug endpoint of the router configuration status.
+func (r *Router) ConfigStatusHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, r.ConfigStatus())
+	}
+}
@@ -0,0 +1,216 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/datastore"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/metrics"
+)
+
+const reloadedConfig = `
+scheduler:
+  plugins:
+    Filter:
+      enabled:
+        - least-request
+    Score:
+      enabled:
+        - name: random
+          weight: 1
+auth:
+  apiKey:
+    enabled: true
+`
+
+// setupConfigReloadTest creates a router with a copy of the test router configuration, and
+// returns the path of the copy.
+func setupConfigReloadTest(t *testing.T) (*Router, string) {
+	data, err := os.ReadFile("../scheduler/testdata/configmap.yaml")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "routerConfiguration.yaml")
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+	return NewRouter(datastore.New(), path), path
+}
+
+func TestRouter_ReloadConfig(t *testing.T) {
+	router, path := setupConfigReloadTest(t)
+	successes := testutil.ToFloat64(router.metrics.ConfigReloadsTotal.WithLabelValues(metrics.ConfigReloadSuccess))
+
+	// An unchanged file is not reloaded.
+	initial := router.config.Load()
+	require.NoError(t, router.reloadConfig())
+	assert.Same(t, initial, router.config.Load())
+	assert.False(t, router.apiKeys.IsEnabled())
+
+	// A request in flight keeps the configuration it started with.
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	assert.Same(t, initial, router.runtimeConfigOf(c))
+
+	require.NoError(t, os.WriteFile(path, []byte(reloadedConfig), 0o644))
+	require.NoError(t, router.reloadConfig())
+
+	reloaded := router.config.Load()
+	assert.NotSame(t, initial, reloaded)
+	assert.NotSame(t, initial.scheduler, reloaded.scheduler)
+	// The tracing is unchanged, so is its tracer provider.
+	assert.Same(t, initial.tracer, reloaded.tracer)
+	assert.Same(t, initial.accessLogger, reloaded.accessLogger)
+	assert.Same(t, initial, router.runtimeConfigOf(c))
+	assert.True(t, router.apiKeys.IsEnabled())
+	assert.Equal(t, successes+1, testutil.ToFloat64(router.metrics.ConfigReloadsTotal.WithLabelValues(metrics.ConfigReloadSuccess)))
+
+	status := router.ConfigStatus()
+	assert.Equal(t, path, status.Path)
+	assert.Equal(t, reloaded.checksum, status.Checksum)
+	assert.Equal(t, 1, status.Reloads)
+	assert.Zero(t, status.Failures)
+}
+
+func TestRouter_ReloadConfig_Invalid(t *testing.T) {
+	router, path := setupConfigReloadTest(t)
+	failures := testutil.ToFloat64(router.metrics.ConfigReloadsTotal.WithLabelValues(metrics.ConfigReloadFailure))
+	initial := router.config.Load()
+
+	invalid := []byte(`
+scheduler:
+  plugins:
+    Score:
+      enabled:
+        - name: no-such-plugin
+          weight: 1
+`)
+	require.NoError(t, os.WriteFile(path, invalid, 0o644))
+	assert.ErrorContains(t, router.reloadConfig(), "unknown score plugin no-such-plugin")
+	// The previous configuration keeps serving.
+	assert.Same(t, initial, router.config.Load())
+	assert.Equal(t, failures+1, testutil.ToFloat64(router.metrics.ConfigReloadsTotal.WithLabelValues(metrics.ConfigReloadFailure)))
+
+	// The rejected configuration is not tried again until the file changes.
+	require.NoError(t, router.reloadConfig())
+	assert.Equal(t, failures+1, testutil.ToFloat64(router.metrics.ConfigReloadsTotal.WithLabelValues(metrics.ConfigReloadFailure)))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	router.ConfigStatusHandler()(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var status ConfigStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, initial.checksum, status.Checksum)
+	assert.Equal(t, 1, status.Failures)
+	if assert.NotNil(t, status.LastFailure) {
+		assert.Equal(t, checksumOf(invalid), status.LastFailure.Checksum)
+		assert.Contains(t, status.LastFailure.Error, "no-such-plugin")
+	}
+
+	// A valid configuration is loaded again.
+	require.NoError(t, os.WriteFile(path, []byte(reloadedConfig), 0o644))
+	require.NoError(t, router.reloadConfig())
+	assert.NotSame(t, initial, router.config.Load())
+}
+
+func TestRouter_ReloadConfig_Tracing(t *testing.T) {
+	router, path := setupConfigReloadTest(t)
+	initial := router.config.Load()
+	assert.False(t, initial.tracer.Enabled())
+
+	invalid := reloadedConfig + `
+tracing:
+  enabled: true
+  samplingRatio: 2
+`
+	require.NoError(t, os.WriteFile(path, []byte(invalid), 0o644))
+	assert.ErrorContains(t, router.reloadConfig(), "samplingRatio 2 is not between 0 and 1")
+	assert.Same(t, initial, router.config.Load())
+
+	enabled := reloadedConfig + `
+tracing:
+  enabled: true
+  endpoint: otel-collector:4317
+  insecure: true
+  samplingRatio: 0.5
+`
+	require.NoError(t, os.WriteFile(path, []byte(enabled), 0o644))
+	require.NoError(t, router.reloadConfig())
+	reloaded := router.config.Load()
+	assert.True(t, reloaded.tracer.Enabled())
+	assert.Equal(t, "otel-collector:4317", reloaded.tracing.Endpoint)
+}
+
+func TestRouter_ReloadConfig_AccessLog(t *testing.T) {
+	router, path := setupConfigReloadTest(t)
+	initial := router.config.Load()
+
+	invalid := reloadedConfig + `
+accessLog:
+  sinks:
+    - name: audit
+      type: file
+`
+	require.NoError(t, os.WriteFile(path, []byte(invalid), 0o644))
+	assert.ErrorContains(t, router.reloadConfig(), "invalid access log sink audit: file.path is required")
+	assert.Same(t, initial, router.config.Load())
+
+	logPath := filepath.Join(t.TempDir(), "access.log")
+	valid := reloadedConfig + `
+accessLog:
+  headers: [x-user-id]
+  sinks:
+    - name: audit
+      type: file
+      format: json
+      file:
+        path: ` + logPath + `
+        maxSizeMB: 10
+      filter:
+        errorsOnly: true
+`
+	require.NoError(t, os.WriteFile(path, []byte(valid), 0o644))
+	require.NoError(t, router.reloadConfig())
+	reloaded := router.config.Load()
+	assert.NotSame(t, initial.accessLogger, reloaded.accessLogger)
+	assert.Equal(t, []string{"x-user-id"}, reloaded.accessLog.Headers)
+	assert.True(t, reloaded.accessLog.Sinks[0].Filter.ErrorsOnly)
+	require.NoError(t, reloaded.accessLogger.Close())
+}
+
+func TestRouter_ReloadConfig_UnreachableJWKS(t *testing.T) {
+	router, path := setupConfigReloadTest(t)
+	initial := router.config.Load()
+	jwks := httptest.NewServer(http.NotFoundHandler())
+	jwks.Close()
+
+	require.NoError(t, os.WriteFile(path, []byte(reloadedConfig+`
+  issuer: "https://sso.example.com"
+  jwksUri: "`+jwks.URL+`"
+`), 0o644))
+	assert.ErrorContains(t, router.reloadConfig(), "failed to fetch the JWKS of issuer https://sso.example.com")
+	// The previous configuration, and its authenticator, keep serving.
+	assert.Same(t, initial, router.config.Load())
+	assert.False(t, router.apiKeys.IsEnabled())
+}
@@ -0,0 +1,103 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/framework"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/utils"
+)
+
+// defaultExplainPath is the path of the requests explained by the schedule debug endpoint.
+const defaultExplainPath = "/v1/chat/completions"
+
+// ScheduleExplanation is the response of the schedule debug endpoint.
+type ScheduleExplanation struct {
+	Model       string `json:"model"`
+	ModelRoute  string `json:"modelRoute"`
+	Rule        string `json:"rule,omitempty"`
+	ModelServer string `json:"modelServer"`
+	IsLora      bool   `json:"isLora"`
+	// ScheduledModel is the model the pods are scheduled for, which is the model name of the target
+	// model if it has one.
+	ScheduledModel string `json:"scheduledModel"`
+
+	*scheduler.Explanation
+}
+
+// ExplainHandler returns the handler of the schedule debug endpoint. The request in the body is
+// matched with its headers like the router does, and the pods of the matched model server are
+// filtered and scored, without proxying the request. The path of the request is given by the path
+// query parameter, /v1/chat/completions by default, and its Gateway by the gateway parameter.
+func (r *Router) ExplainHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		modelRequest, err := ParseModelRequest(c)
+		if err != nil {
+			return
+		}
+		modelName := modelRequest["model"].(string)
+
+		req := c.Request.Clone(c.Request.Context())
+		req.URL.Path = c.DefaultQuery("path", defaultExplainPath)
+		match, err := r.store.MatchRoute(modelName, req, c.Query("gateway"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("no route matched: %v", err)})
+			return
+		}
+		pods, modelServer, err := r.getPodsAndServer(match.ModelServerName)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		prompt, err := utils.ParseEndpointPrompt(utils.GetEndpointType(req.URL.Path), modelRequest)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "prompt not found"})
+			return
+		}
+
+		scheduledModel := modelName
+		if match.Target != nil && match.Target.ModelName != nil {
+			scheduledModel = *match.Target.ModelName
+		}
+		ctx := &framework.Context{
+			Model:           scheduledModel,
+			Prompt:          prompt,
+			MediaHashes:     utils.GetMediaHashes(prompt),
+			Headers:         req.Header,
+			Body:            modelRequest,
+			ModelServerName: match.ModelServerName,
+		}
+		if modelServer.Spec.WorkloadSelector != nil {
+			ctx.PDGroup = modelServer.Spec.WorkloadSelector.PDGroup
+		}
+
+		c.JSON(http.StatusOK, ScheduleExplanation{
+			Model:          modelName,
+			ModelRoute:     fmt.Sprintf("%s/%s", match.ModelRoute.Namespace, match.ModelRoute.Name),
+			Rule:           match.Rule.Name,
+			ModelServer:    match.ModelServerName.String(),
+			IsLora:         match.IsLora,
+			ScheduledModel: scheduledModel,
+			Explanation:    r.runtimeConfigOf(c).scheduler.Explain(ctx, pods),
+		})
+	}
+}
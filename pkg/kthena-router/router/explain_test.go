@@ -0,0 +1,73 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveExplainRequest(router *Router, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("POST", "/debug/schedule", bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	router.ExplainHandler()(c)
+	return w
+}
+
+func TestRouter_Explain(t *testing.T) {
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the explained request must not be proxied")
+	})
+	router, backend := setupTrafficPolicyTest(t, backendHandler, nil)
+	defer backend.Close()
+
+	w := serveExplainRequest(router, `{"model": "test-model", "messages": [{"role": "user", "content": "hello"}]}`)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var explanation ScheduleExplanation
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &explanation))
+	assert.Equal(t, "test-model", explanation.Model)
+	assert.Equal(t, "default/mr-1", explanation.ModelRoute)
+	assert.Equal(t, "default/ms-1", explanation.ModelServer)
+	assert.Equal(t, "test-model", explanation.ScheduledModel)
+	require.NotNil(t, explanation.Explanation)
+	assert.Empty(t, explanation.Error)
+	assert.NotEmpty(t, explanation.Filters)
+	assert.NotEmpty(t, explanation.Scores)
+	if assert.Len(t, explanation.Ranking, 1) {
+		assert.Equal(t, "default/pod-1", explanation.Ranking[0].Pod)
+	}
+}
+
+func TestRouter_Explain_NoRoute(t *testing.T) {
+	router, backend := setupTrafficPolicyTest(t, http.NotFoundHandler(), nil)
+	defer backend.Close()
+
+	w := serveExplainRequest(router, `{"model": "unknown-model", "messages": [{"role": "user", "content": "hello"}]}`)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "no route matched")
+}
@@ -0,0 +1,193 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	"github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/accesslog"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/connectors"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/datastore"
+)
+
+// fallbackChainKey holds the *fallbackChain of the request in the gin context.
+const fallbackChainKey = "fallbackChain"
+
+// fallbackChain tracks the fallback targets of the ModelRoute rule serving a request.
+type fallbackChain struct {
+	targets []*v1alpha1.FallbackTarget
+	// next is the index of the next fallback target to try.
+	next int
+}
+
+// fallbackError is returned when a target failed to serve the request, and the request is handed
+// over to the next fallback target. Nothing has been written downstream.
+type fallbackError struct {
+	trigger v1alpha1.FallbackTrigger
+	err     error
+}
+
+func (e *fallbackError) Error() string {
+	return fmt.Sprintf("falling back on %s: %v", e.trigger, e.err)
+}
+
+func (e *fallbackError) Unwrap() error {
+	return e.err
+}
+
+func getFallbackChain(c *gin.Context) *fallbackChain {
+	if v, exists := c.Get(fallbackChainKey); exists {
+		if chain, ok := v.(*fallbackChain); ok {
+			return chain
+		}
+	}
+	return nil
+}
+
+// isFallback returns whether the request is being served by a fallback target.
+func isFallback(c *gin.Context) bool {
+	chain := getFallbackChain(c)
+	return chain != nil && chain.next > 0
+}
+
+// fallsBack returns whether the failure of the current target hands the request over to the next
+// fallback target. It never does once the response has been started downstream.
+func fallsBack(c *gin.Context, trigger v1alpha1.FallbackTrigger) bool {
+	chain := getFallbackChain(c)
+	if chain == nil || chain.next >= len(chain.targets) || c.IsAborted() || c.Writer.Written() {
+		return false
+	}
+	on := chain.targets[chain.next].On
+	return len(on) == 0 || slices.Contains(on, trigger)
+}
+
+// fallbackTrigger returns the trigger matching the error of the last upstream attempt, or false if
+// the error is not one that a fallback may recover from, e.g. the client went away.
+func fallbackTrigger(c *gin.Context, err error) (v1alpha1.FallbackTrigger, bool) {
+	if err == nil {
+		return "", false
+	}
+	// The request context expires with the timeout of the traffic policy of the model server.
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+		return v1alpha1.FallbackOnTimeout, true
+	}
+	if errors.Is(err, context.Canceled) || c.Request.Context().Err() != nil {
+		return "", false
+	}
+	var statusErr *connectors.UpstreamStatusError
+	if errors.As(err, &statusErr) {
+		switch {
+		case statusErr.StatusCode == http.StatusTooManyRequests:
+			return v1alpha1.FallbackOnRateLimited, true
+		case statusErr.StatusCode >= http.StatusInternalServerError:
+			return v1alpha1.FallbackOn5xx, true
+		default:
+			return "", false
+		}
+	}
+	// A connection error.
+	return v1alpha1.FallbackOn5xx, true
+}
+
+// serveRouteMatch serves the request with the target model selected by the matched rule, and then
+// with each of the fallback targets of the rule in turn, as long as the failure of the previous
+// target is one the next fallback is configured for.
+func (r *Router) serveRouteMatch(c *gin.Context, modelRequest ModelRequest, match *datastore.RouteMatch) {
+	modelName := modelRequest["model"].(string)
+	chain := &fallbackChain{targets: match.Rule.Fallbacks}
+	c.Set(fallbackChainKey, chain)
+
+	// The headers are modified once for all the targets, before scheduling.
+	if modifier := match.Rule.RequestHeaderModifier; modifier != nil {
+		modifyHeaders(c.Request.Header, modifier)
+	}
+	if modifier := match.Rule.ResponseHeaderModifier; modifier != nil {
+		c.Writer = newHeaderModifyingWriter(c.Writer, modifier)
+	}
+	// Each target may change the request, e.g. with the timeout of its traffic policy.
+	originalReq := c.Request
+
+	modelServerName, isLora, target := match.ModelServerName, match.IsLora, match.Target
+	for {
+		err := r.serveModelServer(c, modelRequest, modelName, modelServerName, isLora, target, match.ModelRoute)
+		var fallbackErr *fallbackError
+		if !errors.As(err, &fallbackErr) {
+			return
+		}
+
+		fallback := chain.targets[chain.next]
+		chain.next++
+		klog.V(4).Infof("model server %s failed to serve the request (%v), falling back to %s", modelServerName, fallbackErr, fallback.ModelServerName)
+		accesslog.SetFallback(c, chain.next)
+		c.Request = originalReq
+		modelServerName = types.NamespacedName{Namespace: match.ModelRoute.Namespace, Name: fallback.ModelServerName}
+		// A fallback model server serves its own model, not the lora adapter or the model name of
+		// the target model.
+		isLora, target = false, nil
+	}
+}
+
+// serveModelServer serves the request with the pods of the model server, which is the target
+// model of the rule or one of its fallbacks if target is nil. It returns a *fallbackError if the
+// request is to be served by the next fallback target.
+func (r *Router) serveModelServer(
+	c *gin.Context,
+	modelRequest ModelRequest,
+	modelName string,
+	modelServerName types.NamespacedName,
+	isLora bool,
+	target *v1alpha1.TargetModel,
+	modelRoute *v1alpha1.ModelRoute,
+) error {
+	// step 3: Find pods and model server details
+	klog.V(4).Infof("modelServer is %v, is_lora: %v", modelServerName, isLora)
+
+	pods, modelServer, err := r.getPodsAndServer(modelServerName)
+	if err != nil || len(pods) == 0 {
+		if fallsBack(c, v1alpha1.FallbackOnNoEndpoints) {
+			return &fallbackError{trigger: v1alpha1.FallbackOnNoEndpoints, err: err}
+		}
+		klog.Errorf("failed to get pods and model server: %v, %v", modelServerName, err)
+		accesslog.SetError(c, "pod_discovery", fmt.Sprintf("can't find model server: %v", modelServerName))
+		c.AbortWithStatusJSON(http.StatusNotFound, fmt.Sprintf("can't find model server: %v", modelServerName))
+		return nil
+	}
+
+	// The model name of the target model is the model of the request from now on, so that the
+	// scheduler plugins hash the prompt and match the lora adapters of the pods with it.
+	scheduledModel := modelName
+	switch {
+	case target != nil && target.ModelName != nil:
+		scheduledModel = *target.ModelName
+		modelRequest["model"] = scheduledModel
+	case modelServer.Spec.Model != nil && !isLora:
+		modelRequest["model"] = *modelServer.Spec.Model
+	default:
+		modelRequest["model"] = modelName
+	}
+
+	return r.scheduleAndProxy(c, modelRequest, scheduledModel, pods, modelServer, modelServerName, modelRoute, modelServer.Spec.WorkloadPort.Port)
+}
@@ -0,0 +1,244 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"istio.io/istio/pkg/util/sets"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	aiv1alpha1 "github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/accesslog"
+)
+
+// setupFallbackTest sets up the route of setupTrafficPolicyTest, whose rule falls back to the model
+// server ms-fallback served by fallbackHandler.
+func setupFallbackTest(t *testing.T, backendHandler, fallbackHandler http.Handler, policy *aiv1alpha1.TrafficPolicy, on ...aiv1alpha1.FallbackTrigger) (*Router, *httptest.Server, *httptest.Server) {
+	router, backend := setupTrafficPolicyTest(t, backendHandler, policy)
+	fallback := httptest.NewServer(fallbackHandler)
+
+	fallbackURL, _ := url.Parse(fallback.URL)
+	fallbackPort, _ := strconv.Atoi(fallbackURL.Port())
+	modelServer := &aiv1alpha1.ModelServer{
+		ObjectMeta: v1.ObjectMeta{Name: "ms-fallback", Namespace: "default"},
+		Spec: aiv1alpha1.ModelServerSpec{
+			Model:           func(s string) *string { return &s }("test-model-small"),
+			WorkloadPort:    aiv1alpha1.WorkloadPort{Port: int32(fallbackPort)},
+			InferenceEngine: "vLLM",
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{Name: "fallback-pod-1", Namespace: "default"},
+		Status:     corev1.PodStatus{PodIP: fallbackURL.Hostname(), Phase: corev1.PodRunning},
+	}
+	router.store.AddOrUpdateModelServer(modelServer, sets.New(types.NamespacedName{Name: "fallback-pod-1", Namespace: "default"}))
+	router.store.AddOrUpdatePod(pod, []*aiv1alpha1.ModelServer{modelServer})
+
+	modelRoute := router.store.GetModelRoute("default/mr-1").DeepCopy()
+	modelRoute.Spec.Rules[0].Fallbacks = []*aiv1alpha1.FallbackTarget{{ModelServerName: "ms-fallback", On: on}}
+	router.store.AddOrUpdateModelRoute(modelRoute)
+
+	return router, backend, fallback
+}
+
+// serveFallbackRequest serves a request like serveTrafficPolicyRequest, and returns its access log.
+func serveFallbackRequest(router *Router) (*httptest.ResponseRecorder, *accesslog.AccessLogContext) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model": "test-model", "prompt": "hello"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	accessCtx := accesslog.NewAccessLogContext("request-id", "POST", "/v1/chat/completions", "HTTP/1.1", "test-model")
+	c.Set(accesslog.AccessLogContextKey, accessCtx)
+	router.HandlerFunc()(c)
+	return w, accessCtx
+}
+
+// fallbackHandler answers with the model of the request.
+func newFallbackHandler(calls *atomic.Int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var body map[string]interface{}
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &body)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"id":"fallback","model":%q}`, body["model"])
+	})
+}
+
+func TestRouter_Fallback_On5xx(t *testing.T) {
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	var fallbackCalls atomic.Int32
+	router, backend, fallback := setupFallbackTest(t, backendHandler, newFallbackHandler(&fallbackCalls), nil, aiv1alpha1.FallbackOn5xx)
+	defer backend.Close()
+	defer fallback.Close()
+
+	w, accessCtx := serveFallbackRequest(router)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"model":"test-model-small"`)
+	assert.Equal(t, int32(1), fallbackCalls.Load())
+	assert.Equal(t, 1, accessCtx.Fallback)
+	assert.Equal(t, "default/ms-fallback", accessCtx.ModelServer)
+	assert.Equal(t, "fallback-pod-1", accessCtx.SelectedPod)
+	if assert.Len(t, accessCtx.UpstreamAttempts, 2) {
+		assert.Equal(t, http.StatusServiceUnavailable, accessCtx.UpstreamAttempts[0].StatusCode)
+		assert.Equal(t, http.StatusOK, accessCtx.UpstreamAttempts[1].StatusCode)
+	}
+}
+
+func TestRouter_Fallback_NoEndpoints(t *testing.T) {
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	var fallbackCalls atomic.Int32
+	router, backend, fallback := setupFallbackTest(t, backendHandler, newFallbackHandler(&fallbackCalls), nil)
+	defer backend.Close()
+	defer fallback.Close()
+
+	// The target model has no pod left.
+	router.store.DeletePod(types.NamespacedName{Namespace: "default", Name: "pod-1"})
+
+	w, accessCtx := serveFallbackRequest(router)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int32(1), fallbackCalls.Load())
+	assert.Equal(t, 1, accessCtx.Fallback)
+}
+
+func TestRouter_Fallback_OnTimeout(t *testing.T) {
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		<-r.Context().Done()
+	})
+	var fallbackCalls atomic.Int32
+	router, backend, fallback := setupFallbackTest(t, backendHandler, newFallbackHandler(&fallbackCalls),
+		&aiv1alpha1.TrafficPolicy{Timeout: &v1.Duration{Duration: 50 * time.Millisecond}},
+		aiv1alpha1.FallbackOnTimeout)
+	defer backend.Close()
+	defer fallback.Close()
+
+	w, accessCtx := serveFallbackRequest(router)
+
+	// The timeout of the target model does not apply to the fallback.
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int32(1), fallbackCalls.Load())
+	assert.Equal(t, 1, accessCtx.Fallback)
+}
+
+func TestRouter_Fallback_TriggerNotConfigured(t *testing.T) {
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	var fallbackCalls atomic.Int32
+	router, backend, fallback := setupFallbackTest(t, backendHandler, newFallbackHandler(&fallbackCalls), nil,
+		aiv1alpha1.FallbackOn5xx, aiv1alpha1.FallbackOnTimeout)
+	defer backend.Close()
+	defer fallback.Close()
+
+	w, accessCtx := serveFallbackRequest(router)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Zero(t, fallbackCalls.Load())
+	assert.Zero(t, accessCtx.Fallback)
+}
+
+func TestRouter_Fallback_OnRateLimited(t *testing.T) {
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	var fallbackCalls atomic.Int32
+	router, backend, fallback := setupFallbackTest(t, backendHandler, newFallbackHandler(&fallbackCalls), nil, aiv1alpha1.FallbackOnRateLimited)
+	defer backend.Close()
+	defer fallback.Close()
+
+	w, _ := serveFallbackRequest(router)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int32(1), fallbackCalls.Load())
+}
+
+func TestRouter_Fallback_ChainExhausted(t *testing.T) {
+	failing := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	router, backend, fallback := setupFallbackTest(t, failing, failing, nil)
+	defer backend.Close()
+	defer fallback.Close()
+
+	w, accessCtx := serveFallbackRequest(router)
+
+	// The error of the last fallback is returned.
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, 1, accessCtx.Fallback)
+	assert.Len(t, accessCtx.UpstreamAttempts, 2)
+}
+
+func TestRouter_Fallback_PDDisaggregated(t *testing.T) {
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	router, store, backend := setupTestRouter(t, backendHandler)
+	defer backend.Close()
+	setupPDModelServer(store, backend)
+
+	var fallbackCalls atomic.Int32
+	fallback := httptest.NewServer(newFallbackHandler(&fallbackCalls))
+	defer fallback.Close()
+	fallbackURL, _ := url.Parse(fallback.URL)
+	fallbackPort, _ := strconv.Atoi(fallbackURL.Port())
+	modelServer := &aiv1alpha1.ModelServer{
+		ObjectMeta: v1.ObjectMeta{Name: "ms-fallback", Namespace: "default"},
+		Spec: aiv1alpha1.ModelServerSpec{
+			Model:           func(s string) *string { return &s }("test-model-small"),
+			WorkloadPort:    aiv1alpha1.WorkloadPort{Port: int32(fallbackPort)},
+			InferenceEngine: "vLLM",
+		},
+	}
+	store.AddOrUpdateModelServer(modelServer, sets.New(types.NamespacedName{Name: "fallback-pod-1", Namespace: "default"}))
+	store.AddOrUpdatePod(&corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{Name: "fallback-pod-1", Namespace: "default"},
+		Status:     corev1.PodStatus{PodIP: fallbackURL.Hostname(), Phase: corev1.PodRunning},
+	}, []*aiv1alpha1.ModelServer{modelServer})
+	modelRoute := store.GetModelRoute("default/mr-1").DeepCopy()
+	modelRoute.Spec.Rules[0].Fallbacks = []*aiv1alpha1.FallbackTarget{{ModelServerName: "ms-fallback"}}
+	store.AddOrUpdateModelRoute(modelRoute)
+
+	w, accessCtx := serveFallbackRequest(router)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"model":"test-model-small"`)
+	assert.Equal(t, int32(1), fallbackCalls.Load())
+	assert.Equal(t, 1, accessCtx.Fallback)
+	assert.Equal(t, "default/ms-fallback", accessCtx.ModelServer)
+}
@@ -0,0 +1,86 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
+)
+
+// modifyHeaders removes, sets and then adds the headers of the modifier.
+func modifyHeaders(header http.Header, modifier *v1alpha1.HeaderModifier) {
+	for _, name := range modifier.Remove {
+		header.Del(name)
+	}
+	for _, h := range modifier.Set {
+		header.Set(h.Name, h.Value)
+	}
+	for _, h := range modifier.Add {
+		header.Add(h.Name, h.Value)
+	}
+}
+
+// headerModifyingWriter modifies the headers of the response right before they are written
+// downstream, so that the headers of the model server are modified as well as the router's own.
+type headerModifyingWriter struct {
+	gin.ResponseWriter
+
+	modifier *v1alpha1.HeaderModifier
+	modified bool
+}
+
+func newHeaderModifyingWriter(w gin.ResponseWriter, modifier *v1alpha1.HeaderModifier) *headerModifyingWriter {
+	return &headerModifyingWriter{ResponseWriter: w, modifier: modifier}
+}
+
+func (w *headerModifyingWriter) modify() {
+	if w.modified {
+		return
+	}
+	w.modified = true
+	modifyHeaders(w.Header(), w.modifier)
+}
+
+// WriteHeader modifies the headers when the status is set, since the proxy copies the headers of
+// the model server before setting the status, and the status may be the last thing written.
+func (w *headerModifyingWriter) WriteHeader(code int) {
+	w.modify()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *headerModifyingWriter) WriteHeaderNow() {
+	w.modify()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *headerModifyingWriter) Write(data []byte) (int, error) {
+	w.modify()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *headerModifyingWriter) WriteString(s string) (int, error) {
+	w.modify()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *headerModifyingWriter) Flush() {
+	w.modify()
+	w.ResponseWriter.Flush()
+}
@@ -0,0 +1,115 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	aiv1alpha1 "github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
+)
+
+func TestModifyHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Internal", "secret")
+	header.Set("X-Tenant", "spoofed")
+	header.Set("X-Trace", "a")
+
+	modifyHeaders(header, &aiv1alpha1.HeaderModifier{
+		Remove: []string{"x-internal", "X-Missing"},
+		Set:    []aiv1alpha1.HTTPHeader{{Name: "x-tenant", Value: "team-a"}},
+		Add:    []aiv1alpha1.HTTPHeader{{Name: "x-trace", Value: "b"}, {Name: "x-new", Value: "c"}},
+	})
+
+	assert.Empty(t, header.Values("X-Internal"))
+	assert.Equal(t, []string{"team-a"}, header.Values("X-Tenant"))
+	assert.Equal(t, []string{"a", "b"}, header.Values("X-Trace"))
+	assert.Equal(t, []string{"c"}, header.Values("X-New"))
+}
+
+func TestRouter_HeaderModifiers(t *testing.T) {
+	received := make(chan http.Header, 1)
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+		w.Header().Set("X-Internal-Debug", "pod-1")
+		w.Header().Set("X-Served-By", "vllm")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"id":"response-id"}`)
+	})
+	router, backend := setupTrafficPolicyTest(t, backendHandler, nil)
+	defer backend.Close()
+
+	modelRoute := router.store.GetModelRoute("default/mr-1").DeepCopy()
+	modelRoute.Spec.Rules[0].RequestHeaderModifier = &aiv1alpha1.HeaderModifier{
+		Set:    []aiv1alpha1.HTTPHeader{{Name: "X-Tenant", Value: "team-a"}},
+		Remove: []string{"X-Internal-Token"},
+	}
+	modelRoute.Spec.Rules[0].ResponseHeaderModifier = &aiv1alpha1.HeaderModifier{
+		Remove: []string{"X-Internal-Debug"},
+		Add:    []aiv1alpha1.HTTPHeader{{Name: "X-Gateway", Value: "kthena"}},
+	}
+	router.store.AddOrUpdateModelRoute(modelRoute)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model": "test-model", "prompt": "hello"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Request.Header.Set("X-Tenant", "spoofed")
+	c.Request.Header.Set("X-Internal-Token", "secret")
+	router.HandlerFunc()(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	header := <-received
+	assert.Equal(t, "team-a", header.Get("X-Tenant"))
+	assert.Empty(t, header.Get("X-Internal-Token"))
+
+	assert.Empty(t, w.Header().Get("X-Internal-Debug"))
+	assert.Equal(t, "vllm", w.Header().Get("X-Served-By"))
+	assert.Equal(t, "kthena", w.Header().Get("X-Gateway"))
+}
+
+func TestRouter_TargetModelName(t *testing.T) {
+	models := make(chan string, 1)
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &body)
+		models <- fmt.Sprint(body["model"])
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"id":"response-id"}`)
+	})
+	router, backend := setupTrafficPolicyTest(t, backendHandler, nil)
+	defer backend.Close()
+
+	modelRoute := router.store.GetModelRoute("default/mr-1").DeepCopy()
+	modelRoute.Spec.Rules[0].TargetModels[0].ModelName = func(s string) *string { return &s }("qwen3-32b-instruct")
+	router.store.AddOrUpdateModelRoute(modelRoute)
+
+	w := serveTrafficPolicyRequest(router)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	// The model name of the target model takes precedence over the model of the ModelServer.
+	assert.Equal(t, "qwen3-32b-instruct", <-models)
+}
@@ -0,0 +1,245 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/klog/v2"
+
+	"github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/handlers"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/metrics"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/framework"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/tracing"
+)
+
+const (
+	defaultMaxHedgedPercent = 10
+	// maxHedgingBurst is the number of hedged requests a rule may save up while its requests are fast.
+	maxHedgingBurst = 10.0
+)
+
+// hedgingBudget caps the extra load of hedging on a rule. Every request of the rule earns
+// MaxHedgedPercent/100 of a token, and every hedged request spends a whole token.
+type hedgingBudget struct {
+	mutex  sync.Mutex
+	tokens float64
+}
+
+func (b *hedgingBudget) deposit(percent int32) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.tokens = min(b.tokens+float64(percent)/100, maxHedgingBurst)
+}
+
+func (b *hedgingBudget) withdraw() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// hedging is the resolved Hedging of the rule matched by a request.
+type hedging struct {
+	delay  time.Duration
+	budget *hedgingBudget
+}
+
+// getHedging returns the hedging of the request, or nil if it must not be hedged. Only
+// non-streaming requests are hedged, since a stream can not be switched to another pod once
+// it started, and only when there is a second pod to send the hedged request to.
+func (r *Router) getHedging(c *gin.Context, ctx *framework.Context, stream bool) *hedging {
+	match := getRouteMatch(c)
+	if match == nil || match.Rule == nil || match.Rule.Hedging == nil || stream || len(ctx.BestPods) < 2 {
+		return nil
+	}
+	spec := match.Rule.Hedging
+
+	percent := int32(defaultMaxHedgedPercent)
+	if spec.MaxHedgedPercent != nil {
+		percent = *spec.MaxHedgedPercent
+	}
+	budget := r.getHedgingBudget(match.ModelRoute, match.Rule)
+	budget.deposit(percent)
+
+	delay := hedgingDelay(spec, ctx)
+	if delay <= 0 {
+		return nil
+	}
+	return &hedging{delay: delay, budget: budget}
+}
+
+// hedgingDelay returns the time to wait for the first pod before sending the hedged request, or 0
+// if the percentile of the time to first token of the pod is not known yet.
+func hedgingDelay(spec *v1alpha1.Hedging, ctx *framework.Context) time.Duration {
+	if spec.Delay != nil {
+		return spec.Delay.Duration
+	}
+	if spec.DelayPercentile != nil {
+		seconds := ctx.BestPods[0].GetTTFTQuantile(float64(*spec.DelayPercentile) / 100)
+		return time.Duration(seconds * float64(time.Second))
+	}
+	return 0
+}
+
+// getHedgingBudget returns the budget of the rule, which is kept across updates of the ModelRoute.
+func (r *Router) getHedgingBudget(modelRoute *v1alpha1.ModelRoute, rule *v1alpha1.Rule) *hedgingBudget {
+	index := 0
+	for i := range modelRoute.Spec.Rules {
+		if modelRoute.Spec.Rules[i] == rule {
+			index = i
+			break
+		}
+	}
+	key := fmt.Sprintf("%s/%s/%d", modelRoute.Namespace, modelRoute.Name, index)
+	budget, _ := r.hedgingBudgets.LoadOrStore(key, &hedgingBudget{})
+	return budget.(*hedgingBudget)
+}
+
+// hedgedAttempt is one of the two upstream requests of a hedged request.
+type hedgedAttempt struct {
+	index  int
+	start  time.Time
+	trial  bool
+	cancel context.CancelFunc
+	span   trace.Span
+	resp   *http.Response
+	err    error
+}
+
+// proxyHedged sends the request to the first pod, and to the second pod as well if the first one
+// has not answered after the hedging delay. The first successful response is returned downstream
+// and the other request is cancelled. It returns the index of the pod that served the request,
+// and whether the hedged request was sent.
+func (r *Router) proxyHedged(
+	c *gin.Context,
+	req *http.Request,
+	ctx *framework.Context,
+	port int32,
+	h *hedging,
+	onUsage func(u handlers.OpenAIResponse),
+	modelServerName, modelRouteName string,
+) (int, bool, error) {
+	results := make(chan *hedgedAttempt, 2)
+	send := func(index int) *hedgedAttempt {
+		podInfo := ctx.BestPods[index]
+		attemptCtx, cancel := context.WithCancel(req.Context())
+		attemptCtx, span := tracing.Start(attemptCtx, tracing.SpanUpstream, trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(tracing.PodKey.String(podInfo.Pod.Name), tracing.AttemptKey.Int(0), tracing.HedgedKey.Bool(index > 0)))
+		// Each attempt has its own copy of the request, whose body is rewound by doRequest.
+		attemptReq := req.Clone(attemptCtx)
+		a := &hedgedAttempt{index: index, start: time.Now(), cancel: cancel, span: span}
+		a.trial = podInfo.StartRequest()
+		r.metrics.IncActiveUpstreamRequests(modelServerName, modelRouteName)
+		go func() {
+			a.resp, a.err = doRequest(attemptReq, podInfo.Pod.Status.PodIP, port)
+			results <- a
+		}()
+		return a
+	}
+	// finish records a completed attempt. A hedged request is not a retry, so both attempts are
+	// recorded as first attempts.
+	finish := func(a *hedgedAttempt) {
+		podInfo := ctx.BestPods[a.index]
+		tracing.End(a.span, a.err)
+		r.metrics.DecActiveUpstreamRequests(modelServerName, modelRouteName)
+		r.recordUpstreamAttempt(c, modelServerName, modelRouteName, podInfo.Pod.Name, 0, a.start, a.err)
+		r.recordPodHealth(podInfo, a.trial, a.err)
+	}
+
+	primary := send(0)
+	var hedge, winner *hedgedAttempt
+	var lastErr error
+	pending := 1
+
+	timer := time.NewTimer(h.delay)
+	defer timer.Stop()
+	timerC := timer.C
+	for winner == nil && pending > 0 {
+		select {
+		case <-timerC:
+			timerC = nil
+			if !h.budget.withdraw() {
+				klog.V(4).Infof("hedging budget of %s exhausted", modelRouteName)
+				continue
+			}
+			hedge = send(1)
+			pending++
+		case a := <-results:
+			pending--
+			if a.err == nil {
+				winner = a
+				continue
+			}
+			a.cancel()
+			finish(a)
+			klog.Errorf("pod request error: %v", a.err)
+			lastErr = fmt.Errorf("decode request error: %w", a.err)
+			// A request failing before the hedging delay is retried like any other request.
+			if hedge == nil {
+				return a.index, false, lastErr
+			}
+		}
+	}
+
+	if hedge != nil {
+		result := metrics.HedgingWinnerNone
+		switch winner {
+		case primary:
+			result = metrics.HedgingWinnerPrimary
+		case hedge:
+			result = metrics.HedgingWinnerHedge
+		}
+		r.metrics.RecordHedgedRequest(modelServerName, modelRouteName, result)
+	}
+	if winner == nil {
+		return primary.index, hedge != nil, lastErr
+	}
+
+	// The slower attempt is cancelled before the response is written, so that its pod stops working on it.
+	var loser *hedgedAttempt
+	if pending > 0 {
+		loser = primary
+		if winner == primary {
+			loser = hedge
+		}
+		loser.cancel()
+		r.metrics.RecordCancelledRequest(modelServerName, modelRouteName)
+	}
+
+	err := writeResponse(c, winner.resp, false, onUsage)
+	winner.cancel()
+	finish(winner)
+	if loser != nil {
+		<-results
+		if loser.resp != nil {
+			loser.resp.Body.Close()
+		}
+		finish(loser)
+	}
+	return winner.index, hedge != nil, err
+}
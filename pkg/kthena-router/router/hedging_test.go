@@ -0,0 +1,170 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"istio.io/istio/pkg/util/sets"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	aiv1alpha1 "github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/metrics"
+)
+
+// setupHedgingTest sets up a model server with two pods served by the same backend, and a route
+// whose rule has the given hedging.
+func setupHedgingTest(t *testing.T, backendHandler http.Handler, hedging *aiv1alpha1.Hedging) (*Router, *httptest.Server) {
+	router, backend := setupTrafficPolicyTest(t, backendHandler, nil)
+
+	backendURL, _ := url.Parse(backend.URL)
+	modelServer := router.store.GetModelServer(types.NamespacedName{Namespace: "default", Name: "ms-1"})
+	pod2 := &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{Name: "pod-2", Namespace: "default"},
+		Status:     corev1.PodStatus{PodIP: backendURL.Hostname(), Phase: corev1.PodRunning},
+	}
+	router.store.AddOrUpdateModelServer(modelServer, sets.New(
+		types.NamespacedName{Name: "pod-1", Namespace: "default"},
+		types.NamespacedName{Name: "pod-2", Namespace: "default"},
+	))
+	router.store.AddOrUpdatePod(pod2, []*aiv1alpha1.ModelServer{modelServer})
+
+	modelRoute := router.store.GetModelRoute("default/mr-1").DeepCopy()
+	modelRoute.Spec.Rules[0].Hedging = hedging
+	router.store.AddOrUpdateModelRoute(modelRoute)
+
+	return router, backend
+}
+
+func hedgedRequests(winner string) float64 {
+	return testutil.ToFloat64(metrics.DefaultMetrics.HedgedRequestsTotal.WithLabelValues("default/ms-1", "default/mr-1", winner))
+}
+
+func cancelledRequests() float64 {
+	return testutil.ToFloat64(metrics.DefaultMetrics.CancelledRequestsTotal.WithLabelValues("default/ms-1", "default/mr-1"))
+}
+
+func TestHedgingBudget(t *testing.T) {
+	budget := &hedgingBudget{}
+	assert.False(t, budget.withdraw())
+
+	budget.deposit(50)
+	assert.False(t, budget.withdraw())
+	budget.deposit(50)
+	assert.True(t, budget.withdraw())
+	assert.False(t, budget.withdraw())
+
+	// The budget saved up while the requests are fast is capped.
+	for i := 0; i < 100; i++ {
+		budget.deposit(100)
+	}
+	hedged := 0
+	for budget.withdraw() {
+		hedged++
+	}
+	assert.Equal(t, int(maxHedgingBurst), hedged)
+}
+
+func TestRouter_Hedging_SlowPod(t *testing.T) {
+	var calls atomic.Int32
+	var cancelled atomic.Bool
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The request body is read, so that the server notices when the client goes away.
+		_, _ = io.ReadAll(r.Body)
+		if calls.Add(1) == 1 {
+			// The first pod does not answer until the request is cancelled.
+			<-r.Context().Done()
+			cancelled.Store(true)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"id":"hedged-response"}`)
+	})
+	router, backend := setupHedgingTest(t, backendHandler, &aiv1alpha1.Hedging{
+		Delay:            &v1.Duration{Duration: 10 * time.Millisecond},
+		MaxHedgedPercent: func(i int32) *int32 { return &i }(100),
+	})
+	defer backend.Close()
+
+	hedgeWins, cancels := hedgedRequests(metrics.HedgingWinnerHedge), cancelledRequests()
+	w := serveTrafficPolicyRequest(router)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"hedged-response"`)
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Eventually(t, cancelled.Load, time.Second, 10*time.Millisecond)
+	assert.Equal(t, hedgeWins+1, hedgedRequests(metrics.HedgingWinnerHedge))
+	assert.Equal(t, cancels+1, cancelledRequests())
+}
+
+func TestRouter_Hedging_FastPod(t *testing.T) {
+	var calls atomic.Int32
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"id":"response-id"}`)
+	})
+	router, backend := setupHedgingTest(t, backendHandler, &aiv1alpha1.Hedging{
+		Delay:            &v1.Duration{Duration: time.Second},
+		MaxHedgedPercent: func(i int32) *int32 { return &i }(100),
+	})
+	defer backend.Close()
+
+	w := serveTrafficPolicyRequest(router)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"response-id"`)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestRouter_Hedging_Budget(t *testing.T) {
+	var calls atomic.Int32
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		calls.Add(1)
+		select {
+		case <-time.After(50 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"id":"response-id"}`)
+	})
+	router, backend := setupHedgingTest(t, backendHandler, &aiv1alpha1.Hedging{
+		Delay:            &v1.Duration{Duration: 5 * time.Millisecond},
+		MaxHedgedPercent: func(i int32) *int32 { return &i }(50),
+	})
+	defer backend.Close()
+
+	// Only one of two requests may be hedged.
+	for i := 0; i < 2; i++ {
+		w := serveTrafficPolicyRequest(router)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	assert.Equal(t, int32(3), calls.Load())
+}
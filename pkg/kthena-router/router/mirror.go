@@ -0,0 +1,241 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/common"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/connectors"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/datastore"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/handlers"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/framework"
+)
+
+const (
+	// maxInflightMirrors caps the mirrored requests in flight. Requests are not mirrored while the
+	// shadow model servers are that far behind, so that mirroring never holds up the router.
+	maxInflightMirrors = 256
+	// defaultMirrorTimeout bounds a mirrored request when the shadow model server has no timeout.
+	defaultMirrorTimeout = 5 * time.Minute
+)
+
+// mirroredRequest is the copy of a request sent to a shadow model server. It holds everything the
+// mirror needs, because the gin context must not be used once the request has been served.
+type mirroredRequest struct {
+	modelServerName types.NamespacedName
+	modelRouteName  string
+	isLora          bool
+	model           string
+	prompt          common.ChatMessage
+	mediaHashes     []uint64
+	method          string
+	path            string
+	rawQuery        string
+	header          http.Header
+	body            ModelRequest
+	stream          bool
+	requestedAt     time.Time
+	// scheduler is the scheduler of the configuration the primary request started with.
+	scheduler scheduler.Scheduler
+}
+
+// mirror sends a copy of the request to the shadow model server of the matched rule, if the
+// request is sampled. The copy is scheduled and sent in the background, and its response is
+// discarded once its latency, status and token usage are recorded.
+func (r *Router) mirror(c *gin.Context, match *datastore.RouteMatch, ctx *framework.Context, modelRequest ModelRequest) {
+	spec := match.Rule.Mirror
+	percent := int32(100)
+	if spec.Percent != nil {
+		percent = *spec.Percent
+	}
+	if rand.Int32N(100) >= percent {
+		return
+	}
+
+	m := &mirroredRequest{
+		modelServerName: types.NamespacedName{Namespace: match.ModelRoute.Namespace, Name: spec.ModelServerName},
+		modelRouteName:  fmt.Sprintf("%s/%s", match.ModelRoute.Namespace, match.ModelRoute.Name),
+		isLora:          match.IsLora,
+		model:           ctx.Model,
+		prompt:          ctx.Prompt,
+		mediaHashes:     ctx.MediaHashes,
+		method:          c.Request.Method,
+		path:            c.Request.URL.Path,
+		rawQuery:        c.Request.URL.RawQuery,
+		header:          c.Request.Header.Clone(),
+		// The primary request adds fields to the body while it is proxied, but does not change
+		// the nested values, so that a shallow copy is enough.
+		body:        maps.Clone(modelRequest),
+		stream:      isStreaming(modelRequest),
+		requestedAt: time.Now(),
+		scheduler:   r.runtimeConfigOf(c).scheduler,
+	}
+
+	select {
+	case r.mirrorSlots <- struct{}{}:
+	default:
+		klog.V(4).Infof("too many mirrored requests in flight, not mirroring to %s", m.modelServerName)
+		r.metrics.RecordMirrorRequest(m.modelServerName.String(), m.modelRouteName, "dropped", 0)
+		return
+	}
+	go func() {
+		defer func() { <-r.mirrorSlots }()
+		r.sendMirror(m)
+	}()
+}
+
+// sendMirror schedules the mirrored request among the pods of the shadow model server, sends it
+// and records its result.
+func (r *Router) sendMirror(m *mirroredRequest) {
+	modelServerName := m.modelServerName.String()
+	status, inputTokens, outputTokens, err := r.doMirror(m)
+	if err != nil {
+		klog.V(4).Infof("mirrored request to %s failed: %v", modelServerName, err)
+	}
+	r.metrics.RecordMirrorRequest(modelServerName, m.modelRouteName, status, time.Since(m.requestedAt))
+	r.metrics.RecordMirrorTokens(modelServerName, m.modelRouteName, inputTokens, outputTokens)
+}
+
+// doMirror returns the status of the mirrored request, the status code of the shadow or an error
+// kind, and its token usage.
+func (r *Router) doMirror(m *mirroredRequest) (string, int, int, error) {
+	pods, modelServer, err := r.getPodsAndServer(m.modelServerName)
+	if err != nil {
+		return "no_pods", 0, 0, err
+	}
+	if modelServer.Spec.WorkloadSelector != nil && modelServer.Spec.WorkloadSelector.PDGroup != nil {
+		return "unsupported", 0, 0, fmt.Errorf("mirroring to PD disaggregated model server %s is not supported", m.modelServerName)
+	}
+
+	ctx := &framework.Context{
+		Model:           m.model,
+		Prompt:          m.prompt,
+		MediaHashes:     m.mediaHashes,
+		Headers:         m.header,
+		Body:            m.body,
+		ModelServerName: m.modelServerName,
+	}
+	if err := m.scheduler.Schedule(ctx, pods); err != nil || len(ctx.BestPods) == 0 {
+		return "scheduling", 0, 0, fmt.Errorf("can't schedule mirrored request: %v", err)
+	}
+
+	if model := modelServer.Spec.Model; model != nil && !m.isLora {
+		m.body["model"] = *model
+	} else {
+		m.body["model"] = m.model
+	}
+	if m.stream {
+		m.body["stream_options"] = map[string]interface{}{"include_usage": true}
+	}
+	body, err := json.Marshal(m.body)
+	if err != nil {
+		return "error", 0, 0, err
+	}
+
+	upstream, err := r.upstreams.Get(modelServer)
+	if err != nil {
+		return "error", 0, 0, err
+	}
+	timeout := defaultMirrorTimeout
+	if policy := newTrafficPolicy(modelServer); policy.timeout > 0 {
+		timeout = policy.timeout
+	}
+	reqCtx, cancel := context.WithTimeout(connectors.WithUpstream(context.Background(), upstream), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, m.method, m.path, bytes.NewReader(body))
+	if err != nil {
+		return "error", 0, 0, err
+	}
+	req.URL.RawQuery = m.rawQuery
+	req.Header = m.header
+	req.Header.Del("Content-Length")
+
+	pod := ctx.BestPods[0]
+	resp, err := doRequest(req, pod.Pod.Status.PodIP, modelServer.Spec.WorkloadPort.Port)
+	if err != nil {
+		var statusErr *connectors.UpstreamStatusError
+		switch {
+		case errors.As(err, &statusErr):
+			return strconv.Itoa(statusErr.StatusCode), 0, 0, err
+		case errors.Is(err, context.DeadlineExceeded):
+			return "timeout", 0, 0, err
+		default:
+			return "error", 0, 0, err
+		}
+	}
+	defer resp.Body.Close()
+
+	usage, err := readMirrorResponse(resp.Body, m.stream)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return "timeout", 0, 0, err
+		}
+		return "error", 0, 0, err
+	}
+	m.scheduler.RunPostHooks(ctx, 0)
+	return strconv.Itoa(resp.StatusCode), usage.PromptTokens, usage.CompletionTokens, nil
+}
+
+// readMirrorResponse reads the response of the shadow to its end, and returns its token usage.
+func readMirrorResponse(body io.Reader, stream bool) (handlers.Usage, error) {
+	if !stream {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return handlers.Usage{}, err
+		}
+		parsed, _ := handlers.ParseOpenAIResponseBody(data)
+		if parsed == nil {
+			return handlers.Usage{}, nil
+		}
+		return parsed.Usage, nil
+	}
+
+	var usage handlers.Usage
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if parsed := handlers.ParseStreamRespForUsage(string(line)); parsed.Usage.CompletionTokens > 0 {
+				usage = parsed.Usage
+			}
+		}
+		if err == io.EOF {
+			return usage, nil
+		}
+		if err != nil {
+			return usage, err
+		}
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"istio.io/istio/pkg/util/sets"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	aiv1alpha1 "github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/metrics"
+)
+
+// setupMirrorTest sets up the route of setupTrafficPolicyTest, whose rule mirrors the requests to
+// the shadow model server ms-shadow served by shadowHandler.
+func setupMirrorTest(t *testing.T, backendHandler, shadowHandler http.Handler, mirror *aiv1alpha1.Mirror) (*Router, *httptest.Server, *httptest.Server) {
+	router, backend := setupTrafficPolicyTest(t, backendHandler, nil)
+	shadow := httptest.NewServer(shadowHandler)
+
+	shadowURL, _ := url.Parse(shadow.URL)
+	shadowPort, _ := strconv.Atoi(shadowURL.Port())
+	modelServer := &aiv1alpha1.ModelServer{
+		ObjectMeta: v1.ObjectMeta{Name: "ms-shadow", Namespace: "default"},
+		Spec: aiv1alpha1.ModelServerSpec{
+			Model:           func(s string) *string { return &s }("test-model-candidate"),
+			WorkloadPort:    aiv1alpha1.WorkloadPort{Port: int32(shadowPort)},
+			InferenceEngine: "vLLM",
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{Name: "shadow-pod-1", Namespace: "default"},
+		Status:     corev1.PodStatus{PodIP: shadowURL.Hostname(), Phase: corev1.PodRunning},
+	}
+	router.store.AddOrUpdateModelServer(modelServer, sets.New(types.NamespacedName{Name: "shadow-pod-1", Namespace: "default"}))
+	router.store.AddOrUpdatePod(pod, []*aiv1alpha1.ModelServer{modelServer})
+
+	modelRoute := router.store.GetModelRoute("default/mr-1").DeepCopy()
+	modelRoute.Spec.Rules[0].Mirror = mirror
+	router.store.AddOrUpdateModelRoute(modelRoute)
+
+	return router, backend, shadow
+}
+
+func mirrorRequests(status string) float64 {
+	return testutil.ToFloat64(metrics.DefaultMetrics.MirrorRequestsTotal.WithLabelValues("default/ms-shadow", "default/mr-1", status))
+}
+
+func mirrorTokens(tokenType string) float64 {
+	return testutil.ToFloat64(metrics.DefaultMetrics.MirrorTokensTotal.WithLabelValues("default/ms-shadow", "default/mr-1", tokenType))
+}
+
+func TestRouter_Mirror(t *testing.T) {
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"id":"primary"}`)
+	})
+	shadowBodies := make(chan map[string]interface{}, 1)
+	shadowHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &body)
+		shadowBodies <- body
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"id":"shadow","usage":{"prompt_tokens":3,"completion_tokens":7,"total_tokens":10}}`)
+	})
+	router, backend, shadow := setupMirrorTest(t, backendHandler, shadowHandler, &aiv1alpha1.Mirror{ModelServerName: "ms-shadow"})
+	defer backend.Close()
+	defer shadow.Close()
+
+	requests, outputTokens := mirrorRequests("200"), mirrorTokens(metrics.TokenTypeOutput)
+	w := serveTrafficPolicyRequest(router)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"primary"`)
+	select {
+	case body := <-shadowBodies:
+		assert.Equal(t, "test-model-candidate", body["model"])
+		assert.Equal(t, "hello", body["prompt"])
+	case <-time.After(time.Second):
+		t.Fatal("the request was not mirrored")
+	}
+	assert.Eventually(t, func() bool {
+		return mirrorRequests("200") == requests+1 && mirrorTokens(metrics.TokenTypeOutput) == outputTokens+7
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRouter_Mirror_SlowShadow(t *testing.T) {
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"id":"primary"}`)
+	})
+	release := make(chan struct{})
+	shadowHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	router, backend, shadow := setupMirrorTest(t, backendHandler, shadowHandler, &aiv1alpha1.Mirror{ModelServerName: "ms-shadow"})
+	defer backend.Close()
+	defer shadow.Close()
+
+	failures := mirrorRequests("503")
+	start := time.Now()
+	w := serveTrafficPolicyRequest(router)
+
+	// The client does not wait for the shadow.
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Less(t, time.Since(start), time.Second)
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		return mirrorRequests("503") == failures+1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRouter_Mirror_NotSampled(t *testing.T) {
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"id":"primary"}`)
+	})
+	var shadowCalls atomic.Int32
+	shadowHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		shadowCalls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	})
+	router, backend, shadow := setupMirrorTest(t, backendHandler, shadowHandler, &aiv1alpha1.Mirror{
+		ModelServerName: "ms-shadow",
+		Percent:         func(i int32) *int32 { return &i }(0),
+	})
+	defer backend.Close()
+	defer shadow.Close()
+
+	for i := 0; i < 10; i++ {
+		w := serveTrafficPolicyRequest(router)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(0), shadowCalls.Load())
+}
@@ -0,0 +1,150 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/accesslog"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/filters/auth"
+)
+
+// EnablePodModelsInModelList adds the models reported by the pods behind the routes to /v1/models.
+var EnablePodModelsInModelList = getEnvBool("ENABLE_POD_MODELS_IN_MODEL_LIST", false)
+
+const modelsPath = "/v1/models"
+
+// ModelCard describes a model in the response of /v1/models, following the OpenAI models API.
+type ModelCard struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+	// Parent is the base model of a LoRA adapter.
+	Parent string `json:"parent,omitempty"`
+}
+
+// ModelList is the response of /v1/models.
+type ModelList struct {
+	Object string      `json:"object"`
+	Data   []ModelCard `json:"data"`
+}
+
+// isModelsRequest reports whether the request lists models or retrieves a model,
+// which the router answers itself instead of proxying to a model server.
+func isModelsRequest(req *http.Request) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	return path == modelsPath || strings.HasPrefix(path, modelsPath+"/")
+}
+
+// handleModels serves GET /v1/models and GET /v1/models/{model} from the ModelRoutes
+// reachable through the gateway of the request.
+func (r *Router) handleModels(c *gin.Context) {
+	models := r.listModels(c.GetString(GatewayKey), func(resource auth.Resource) bool {
+		return r.checkAccess(c, resource) == nil
+	})
+
+	path := strings.TrimSuffix(c.Request.URL.Path, "/")
+	if path == modelsPath {
+		c.JSON(http.StatusOK, ModelList{Object: "list", Data: models})
+		return
+	}
+
+	model := strings.TrimPrefix(path, modelsPath+"/")
+	accesslog.SetModelName(c, model)
+	for _, card := range models {
+		if card.ID == model {
+			c.JSON(http.StatusOK, card)
+			return
+		}
+	}
+	accesslog.SetError(c, "model_not_found", "model not found")
+	c.AbortWithStatusJSON(http.StatusNotFound, "model not found")
+}
+
+// listModels returns the models and LoRA adapters of the ModelRoutes attached to the gateway that
+// the caller is allowed to use. A model exposed by several ModelRoutes is reported once, with the
+// metadata of the oldest route.
+func (r *Router) listModels(gatewayKey string, allowed func(auth.Resource) bool) []ModelCard {
+	models := []ModelCard{}
+	index := make(map[string]int)
+	add := func(card ModelCard) {
+		if i, ok := index[card.ID]; ok {
+			if card.Created < models[i].Created {
+				models[i] = card
+			}
+			return
+		}
+		index[card.ID] = len(models)
+		models = append(models, card)
+	}
+
+	routes := r.store.GetModelRoutesForRequest(gatewayKey)
+	for _, mr := range routes {
+		created := mr.CreationTimestamp.Unix()
+		if mr.Spec.ModelName != "" && allowed(authorizationResource(mr, mr.Spec.ModelName, false)) {
+			add(ModelCard{ID: mr.Spec.ModelName, Object: "model", Created: created, OwnedBy: mr.Namespace})
+		}
+		for _, lora := range mr.Spec.LoraAdapters {
+			if allowed(authorizationResource(mr, lora, true)) {
+				add(ModelCard{ID: lora, Object: "model", Created: created, OwnedBy: mr.Namespace, Parent: mr.Spec.ModelName})
+			}
+		}
+	}
+
+	if !EnablePodModelsInModelList {
+		return models
+	}
+
+	for _, mr := range routes {
+		for _, rule := range mr.Spec.Rules {
+			if rule == nil {
+				continue
+			}
+			for _, target := range rule.TargetModels {
+				msName := types.NamespacedName{Namespace: mr.Namespace, Name: target.ModelServerName}
+				modelServer := r.store.GetModelServer(msName)
+				if modelServer == nil {
+					continue
+				}
+				pods, err := r.store.GetPodsByModelServer(msName)
+				if err != nil {
+					continue
+				}
+				for _, pod := range pods {
+					podModels := pod.GetModelsList()
+					sort.Strings(podModels)
+					for _, model := range podModels {
+						if _, ok := index[model]; ok || !allowed(authorizationResource(mr, model, false)) {
+							continue
+						}
+						add(ModelCard{ID: model, Object: "model", Created: modelServer.CreationTimestamp.Unix(), OwnedBy: mr.Namespace})
+					}
+				}
+			}
+		}
+	}
+	return models
+}
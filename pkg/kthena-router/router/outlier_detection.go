@@ -0,0 +1,70 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"k8s.io/klog/v2"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/connectors"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/datastore"
+)
+
+// recordPodHealth records the result of an upstream attempt in the health of the pod, which ejects
+// the pod from scheduling after too many failures. trial is the result of pod.StartRequest.
+func (r *Router) recordPodHealth(pod *datastore.PodInfo, trial bool, err error) {
+	state, changed := pod.RecordRequestResult(trial, !isPodFailure(err))
+	if !changed {
+		return
+	}
+	podName := pod.Pod.Namespace + "/" + pod.Pod.Name
+	switch state {
+	case datastore.PodEjected:
+		health := pod.GetHealth()
+		klog.Warningf("pod %s ejected until %s after failed requests: %v", podName, health.EjectedUntil.Format("15:04:05"), err)
+		r.metrics.RecordPodEjection(podName)
+	case datastore.PodHealthy:
+		klog.Infof("pod %s restored after successful trial requests", podName)
+		r.metrics.RecordPodRestored(podName)
+	}
+}
+
+// splitPDError returns the errors of the prefill and the decode pod of a PD disaggregated attempt.
+// A failure is accounted to the decode pod, which serves the response, unless it comes from the
+// prefill request.
+func splitPDError(err error) (prefillErr, decodeErr error) {
+	if connectors.FailedPhase(err) == "prefill" {
+		return err, nil
+	}
+	return nil, err
+}
+
+// isPodFailure reports whether an upstream attempt failed because of the pod: a 5xx response, a
+// connection error or a timeout. 4xx responses and requests cancelled by the client are not failures.
+func isPodFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var statusErr *connectors.UpstreamStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/connectors"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/datastore"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/metrics"
+)
+
+func TestIsPodFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "success", err: nil, want: false},
+		{name: "connect error", err: errors.New("connection refused"), want: true},
+		{name: "timeout", err: context.DeadlineExceeded, want: true},
+		{name: "cancelled by client", err: fmt.Errorf("decode request error: %w", context.Canceled), want: false},
+		{name: "5xx", err: &connectors.UpstreamStatusError{Phase: "decode", StatusCode: 502}, want: true},
+		{name: "wrapped 4xx", err: fmt.Errorf("decode request error: %w", &connectors.UpstreamStatusError{Phase: "decode", StatusCode: 429}), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isPodFailure(tt.err))
+		})
+	}
+}
+
+func TestRouter_OutlierDetection_EjectsFailingPod(t *testing.T) {
+	router, backend := setupTrafficPolicyTest(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}), nil)
+	defer backend.Close()
+
+	podInfo := router.store.GetPodInfo(types.NamespacedName{Namespace: "default", Name: "pod-1"})
+	consecutiveFailures := datastore.DefaultOutlierDetectionConfig().ConsecutiveFailures
+	for i := 0; i < consecutiveFailures-1; i++ {
+		serveTrafficPolicyRequest(router)
+	}
+	assert.False(t, podInfo.IsEjected())
+	assert.Equal(t, consecutiveFailures-1, podInfo.GetHealth().ConsecutiveFailures)
+
+	serveTrafficPolicyRequest(router)
+	assert.True(t, podInfo.IsEjected())
+	health := podInfo.GetHealth()
+	assert.Equal(t, datastore.PodEjected, health.State)
+	assert.Equal(t, 1, health.Ejections)
+	assert.True(t, hasPodEjectedSeries("default/pod-1"))
+
+	// The series of the pod are deleted with the pod.
+	assert.NoError(t, router.store.DeletePod(types.NamespacedName{Namespace: "default", Name: "pod-1"}))
+	assert.Eventually(t, func() bool { return !hasPodEjectedSeries("default/pod-1") }, time.Second, 10*time.Millisecond)
+}
+
+func hasPodEjectedSeries(pod string) bool {
+	ch := make(chan prometheus.Metric, 100)
+	metrics.DefaultMetrics.PodEjected.Collect(ch)
+	close(ch)
+	for m := range ch {
+		var series dto.Metric
+		_ = m.Write(&series)
+		for _, label := range series.GetLabel() {
+			if label.GetValue() == pod {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestSplitPDError(t *testing.T) {
+	prefillErr := &connectors.UpstreamStatusError{Phase: "prefill", StatusCode: 503}
+	decodeErr := &connectors.UpstreamPhaseError{Phase: "decode", Err: errors.New("connection refused")}
+	tests := []struct {
+		name        string
+		err         error
+		wantPrefill error
+		wantDecode  error
+	}{
+		{name: "success"},
+		{name: "prefill", err: prefillErr, wantPrefill: prefillErr},
+		{name: "wrapped prefill", err: &connectors.UpstreamPhaseError{Phase: "prefill", Err: context.DeadlineExceeded}, wantPrefill: &connectors.UpstreamPhaseError{Phase: "prefill", Err: context.DeadlineExceeded}},
+		{name: "decode", err: decodeErr, wantDecode: decodeErr},
+		{name: "unknown phase", err: errors.New("connection reset"), wantDecode: errors.New("connection reset")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefill, decode := splitPDError(tt.err)
+			assert.Equal(t, tt.wantPrefill, prefill)
+			assert.Equal(t, tt.wantDecode, decode)
+		})
+	}
+}
+
+func TestRouter_OutlierDetection_PDDisaggregated(t *testing.T) {
+	// The prefill requests are the ones without stream.
+	router, store, backend := setupTestRouter(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if _, ok := body["stream"]; !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+	setupPDModelServer(store, backend)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model": "test-model", "prompt": "hello", "stream": true}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	router.HandlerFunc()(c)
+
+	prefill := store.GetPodInfo(types.NamespacedName{Namespace: "default", Name: "prefill-pod-1"})
+	decode := store.GetPodInfo(types.NamespacedName{Namespace: "default", Name: "decode-pod-1"})
+	assert.Equal(t, 1, prefill.GetHealth().ConsecutiveFailures)
+	assert.Equal(t, 0, decode.GetHealth().ConsecutiveFailures)
+}
@@ -0,0 +1,51 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/filters/ratelimit"
+)
+
+// setRateLimitHeaders sets the OpenAI-compatible x-ratelimit-* headers of the token and request
+// limits of the limit key of the request, as seen by its rate limit check, so that clients can pace
+// themselves. Nothing is set for a limit the model does not have, or that was not checked.
+func setRateLimitHeaders(c *gin.Context, status ratelimit.RateLimitStatus) {
+	setLimitHeaders(c, "tokens", status.Tokens)
+	setLimitHeaders(c, "requests", status.Requests)
+}
+
+func setLimitHeaders(c *gin.Context, kind string, status *ratelimit.LimitStatus) {
+	if status == nil {
+		return
+	}
+	c.Header("x-ratelimit-limit-"+kind, strconv.Itoa(status.Limit))
+	c.Header("x-ratelimit-remaining-"+kind, strconv.Itoa(status.Remaining))
+	// e.g. 6m0s or 20ms, as OpenAI does
+	c.Header("x-ratelimit-reset-"+kind, status.Reset.Round(time.Millisecond).String())
+}
+
+// setRetryAfter sets the Retry-After header of a rejected request, in whole seconds.
+func setRetryAfter(c *gin.Context, retryAfter time.Duration) {
+	seconds := max(int(math.Ceil(retryAfter.Seconds())), 1)
+	c.Header("Retry-After", strconv.Itoa(seconds))
+}
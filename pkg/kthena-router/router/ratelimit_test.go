@@ -0,0 +1,211 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	aiv1alpha1 "github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/metrics"
+)
+
+// serveRateLimitedRequest serves a request like serveTrafficPolicyRequest, on behalf of a user.
+func serveRateLimitedRequest(router *Router, user string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model": "test-model", "prompt": "hello"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Request.Header.Set("X-User-Id", user)
+	router.HandlerFunc()(c)
+	return w
+}
+
+func TestRouter_RateLimitKeyedOnHeader(t *testing.T) {
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"id":"response-id","usage":{"prompt_tokens":1,"completion_tokens":5,"total_tokens":6}}`)
+	})
+	router, backend := setupTrafficPolicyTest(t, backendHandler, nil)
+	defer backend.Close()
+
+	outputTokens := uint32(5)
+	require.NoError(t, router.loadRateLimiter.AddOrUpdateLimiter("test-model", &aiv1alpha1.RateLimit{
+		OutputTokensPerUnit: &outputTokens,
+		Unit:                aiv1alpha1.Hour,
+		Key:                 &aiv1alpha1.RateLimitKey{Source: aiv1alpha1.RateLimitKeySourceHeader, Header: "x-user-id"},
+	}))
+
+	// The output tokens of the first response use up the budget of alice only.
+	assert.Equal(t, http.StatusOK, serveRateLimitedRequest(router, "alice").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serveRateLimitedRequest(router, "alice").Code)
+	assert.Equal(t, http.StatusOK, serveRateLimitedRequest(router, "bob").Code)
+}
+
+func rateLimitExceeded(limitType string) float64 {
+	return testutil.ToFloat64(metrics.DefaultMetrics.RateLimitExceeded.WithLabelValues("test-model", limitType, "/v1/chat/completions"))
+}
+
+func TestRouter_RequestsPerUnit(t *testing.T) {
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"id":"response-id"}`)
+	})
+	router, backend := setupTrafficPolicyTest(t, backendHandler, nil)
+	defer backend.Close()
+
+	requests := uint32(1)
+	require.NoError(t, router.loadRateLimiter.AddOrUpdateLimiter("test-model", &aiv1alpha1.RateLimit{
+		RequestsPerUnit: &requests,
+		Unit:            aiv1alpha1.Hour,
+	}))
+
+	before := rateLimitExceeded(metrics.LimitTypeRequests)
+	assert.Equal(t, http.StatusOK, serveTrafficPolicyRequest(router).Code)
+	w := serveTrafficPolicyRequest(router)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), "request rate limit exceeded")
+	assert.Equal(t, before+1, rateLimitExceeded(metrics.LimitTypeRequests))
+}
+
+func TestRouter_MaxConcurrentRequests(t *testing.T) {
+	arrived := make(chan struct{}, 1)
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		arrived <- struct{}{}
+		// Hold the request open until the client goes away.
+		<-r.Context().Done()
+	})
+	router, backend := setupTrafficPolicyTest(t, backendHandler, nil)
+	defer backend.Close()
+
+	maxConcurrent := uint32(1)
+	require.NoError(t, router.loadRateLimiter.AddOrUpdateLimiter("test-model", &aiv1alpha1.RateLimit{
+		MaxConcurrentRequests: &maxConcurrent,
+		Unit:                  aiv1alpha1.Second,
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequestWithContext(ctx, "POST", "/v1/chat/completions", bytes.NewBufferString(`{"model": "test-model", "prompt": "hello", "stream": true}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+		router.HandlerFunc()(c)
+	}()
+	<-arrived
+
+	before := rateLimitExceeded(metrics.LimitTypeConcurrentRequests)
+	w := serveTrafficPolicyRequest(router)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), "concurrent request limit exceeded")
+	assert.Equal(t, before+1, rateLimitExceeded(metrics.LimitTypeConcurrentRequests))
+
+	// The slot of the stream is released once its client disconnects.
+	cancel()
+	<-done
+	release, err := router.loadRateLimiter.AcquireConcurrency("test-model", "")
+	assert.NoError(t, err)
+	release()
+}
+
+func TestRouter_RateLimitHeaders(t *testing.T) {
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"id":"response-id"}`)
+	})
+	router, backend := setupTrafficPolicyTest(t, backendHandler, nil)
+	defer backend.Close()
+
+	inputTokens := uint32(600)
+	requests := uint32(1)
+	require.NoError(t, router.loadRateLimiter.AddOrUpdateLimiter("test-model", &aiv1alpha1.RateLimit{
+		InputTokensPerUnit: &inputTokens,
+		RequestsPerUnit:    &requests,
+		Unit:               aiv1alpha1.Minute,
+	}))
+
+	// A successful response reports the limits after the request.
+	w := serveTrafficPolicyRequest(router)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "600", w.Header().Get("x-ratelimit-limit-tokens"))
+	assert.NotEmpty(t, w.Header().Get("x-ratelimit-remaining-tokens"))
+	assert.NotEmpty(t, w.Header().Get("x-ratelimit-reset-tokens"))
+	assert.Equal(t, "1", w.Header().Get("x-ratelimit-limit-requests"))
+	assert.Equal(t, "0", w.Header().Get("x-ratelimit-remaining-requests"))
+	assert.Empty(t, w.Header().Get("Retry-After"))
+
+	// The request limit is refilled at 1 request per minute.
+	w = serveTrafficPolicyRequest(router)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("x-ratelimit-remaining-requests"))
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	assert.InDelta(t, 60, retryAfter, 1)
+}
+
+func TestRouter_InputTokensExceedLimit(t *testing.T) {
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("backend should not be called for a request larger than the limit")
+	})
+	router, backend := setupTrafficPolicyTest(t, backendHandler, nil)
+	defer backend.Close()
+
+	inputTokens := uint32(1)
+	require.NoError(t, router.loadRateLimiter.AddOrUpdateLimiter("test-model", &aiv1alpha1.RateLimit{
+		InputTokensPerUnit: &inputTokens,
+		Unit:               aiv1alpha1.Minute,
+	}))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model": "test-model", "prompt": "hello world, how are you doing today?"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	router.HandlerFunc()(c)
+
+	// Retrying would not help, so the request is not answered with 429.
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), "more than the input token rate limit of 1")
+	assert.Empty(t, w.Header().Get("Retry-After"))
+}
+
+func TestRouter_NoRateLimitHeaders(t *testing.T) {
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"id":"response-id"}`)
+	})
+	router, backend := setupTrafficPolicyTest(t, backendHandler, nil)
+	defer backend.Close()
+
+	w := serveTrafficPolicyRequest(router)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("x-ratelimit-limit-tokens"))
+	assert.Empty(t, w.Header().Get("x-ratelimit-limit-requests"))
+}
@@ -0,0 +1,143 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog/v2"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/accesslog"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/common"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/responses"
+)
+
+const responsesPath = "/v1/responses"
+
+// isResponsesRequest reports whether the request creates a response with the Responses API.
+func isResponsesRequest(req *http.Request) bool {
+	return req.Method == http.MethodPost && strings.TrimSuffix(req.URL.Path, "/") == responsesPath
+}
+
+// isStoredResponseRequest reports whether the request retrieves or deletes a stored response,
+// which the router answers itself from the response store.
+func isStoredResponseRequest(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodDelete {
+		return false
+	}
+	return strings.HasPrefix(req.URL.Path, responsesPath+"/")
+}
+
+// handleStoredResponse serves GET and DELETE /v1/responses/{id}. The caller must own the response
+// and may use its model.
+func (r *Router) handleStoredResponse(c *gin.Context) {
+	id := strings.TrimSuffix(strings.TrimPrefix(c.Request.URL.Path, responsesPath+"/"), "/")
+	stored, err := r.getStoredResponse(c, id)
+	if err != nil {
+		abortWithStoreError(c, id, err)
+		return
+	}
+	if !r.authorize(c, stored.Response.Model) {
+		return
+	}
+
+	if c.Request.Method == http.MethodDelete {
+		if err := r.responseStore.Delete(c.Request.Context(), id); err != nil {
+			abortWithStoreError(c, id, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"id": id, "object": "response", "deleted": true})
+		return
+	}
+	c.JSON(http.StatusOK, stored.Response)
+}
+
+// getStoredResponse returns the stored response if the caller owns it. The responses of other
+// users are reported as not found, so that their ids cannot be probed.
+func (r *Router) getStoredResponse(c *gin.Context, id string) (*responses.StoredResponse, error) {
+	stored, err := r.responseStore.Get(c.Request.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if stored.Owner != c.GetString(common.UserIdKey) {
+		klog.V(4).Infof("response %s of %q is not visible to %q", id, stored.Owner, c.GetString(common.UserIdKey))
+		return nil, responses.ErrNotFound
+	}
+	return stored, nil
+}
+
+// translateResponsesRequest turns a Responses API request into a chat completions request.
+// The conversation of previous_response_id is loaded from the response store, and the writer of
+// the context is replaced by a responses.Writer, which translates the chat completion back and
+// stores the response. The returned writer must be closed once the request is proxied.
+func (r *Router) translateResponsesRequest(c *gin.Context, modelRequest ModelRequest) (ModelRequest, *responses.Writer, error) {
+	req, err := responses.ParseRequest(modelRequest)
+	if err != nil {
+		accesslog.SetError(c, "request_parsing", err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return nil, nil, err
+	}
+
+	var history []map[string]interface{}
+	if req.PreviousResponseID != "" {
+		previous, err := r.getStoredResponse(c, req.PreviousResponseID)
+		if err != nil {
+			abortWithStoreError(c, req.PreviousResponseID, err)
+			return nil, nil, err
+		}
+		history = previous.History()
+	}
+
+	chatRequest, messages, err := responses.ToChatCompletion(modelRequest, req, history)
+	if err != nil {
+		accesslog.SetError(c, "request_parsing", err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return nil, nil, err
+	}
+
+	c.Request.URL.Path = strings.TrimSuffix(strings.TrimSuffix(c.Request.URL.Path, "/"), "/responses") + "/chat/completions"
+	c.Request.URL.RawPath = ""
+
+	owner := c.GetString(common.UserIdKey)
+	writer := responses.NewWriter(c.Writer, req, func(resp *responses.Response) {
+		if !req.Store {
+			return
+		}
+		stored := &responses.StoredResponse{Response: resp, Messages: messages, Owner: owner}
+		if err := r.responseStore.Put(context.Background(), stored); err != nil {
+			klog.Errorf("failed to store response %s: %v", resp.ID, err)
+		}
+	})
+	c.Writer = writer
+	return chatRequest, writer, nil
+}
+
+func abortWithStoreError(c *gin.Context, id string, err error) {
+	if errors.Is(err, responses.ErrNotFound) {
+		accesslog.SetError(c, "response_not_found", err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound, fmt.Sprintf("response %s not found", id))
+		return
+	}
+	klog.Errorf("failed to access response store: %v", err)
+	accesslog.SetError(c, "response_store", err.Error())
+	c.AbortWithStatusJSON(http.StatusInternalServerError, "failed to access response store")
+}
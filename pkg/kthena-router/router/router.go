@@ -30,16 +30,22 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/v2"
 	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
 
 	"github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/accesslog"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/anthropic"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/common"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/connectors"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/datastore"
@@ -48,15 +54,19 @@ import (
 	"github.com/volcano-sh/kthena/pkg/kthena-router/filters/tokenizer"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/handlers"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/metrics"
-	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/responses"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/framework"
-	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/tracing"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/utils"
 )
 
 const (
 	// Context keys for gin context
 	GatewayKey = "gatewayKey"
+	// routeMatchKey holds the *datastore.RouteMatch of the ModelRoute serving the request.
+	routeMatchKey = "routeMatch"
+	// rateLimitKeyKey holds the key of the request that the rate limit of its model is enforced on.
+	rateLimitKeyKey = "rateLimitKey"
 )
 
 func getEnvBool(key string, fallback bool) bool {
@@ -71,21 +81,34 @@ func getEnvBool(key string, fallback bool) bool {
 var EnableFairnessScheduling = getEnvBool("ENABLE_FAIRNESS_SCHEDULING", false)
 
 type Router struct {
-	scheduler       scheduler.Scheduler
-	authenticator   *auth.JWTAuthenticator
+	// config holds the *runtimeConfig of the router configuration at configPath
+	config          atomic.Pointer[runtimeConfig]
+	configPath      string
+	apiKeys         *auth.APIKeyAuthenticator
 	store           datastore.Store
 	loadRateLimiter *ratelimit.TokenRateLimiter
-	accessLogger    accesslog.AccessLogger
 	metrics         *metrics.Metrics
-	tokenizer       tokenizer.Tokenizer
+	tokenizers      *tokenizer.Manager
 
 	// KV Connector management
 	connectorFactory *connectors.Factory
+	// upstreams holds the TLS transports of the model servers accessed over https
+	upstreams *upstreamCache
+	// responseStore keeps the responses of the Responses API
+	responseStore responses.Store
+	// hedgingBudgets holds the *hedgingBudget of the ModelRoute rules with hedging
+	hedgingBudgets sync.Map
+	// mirrorSlots limits the mirrored requests in flight
+	mirrorSlots chan struct{}
 
 	// Fairness scheduling configuration
 	fairnessTimeout  time.Duration
 	tokenWeight      float64 // Weight for token-based priority (default 1.0)
 	requestNumWeight float64 // Weight for request-count-based priority (default 0.0)
+
+	// reloadMutex serializes the reloads of the router configuration and guards configStatus
+	reloadMutex  sync.Mutex
+	configStatus ConfigStatus
 }
 
 func NewRouter(store datastore.Store, routerConfigPath string) *Router {
@@ -95,9 +118,6 @@ func NewRouter(store datastore.Store, routerConfigPath string) *Router {
 	// Use global metrics instance
 	metricsInstance := metrics.DefaultMetrics
 
-	// Initialize tokenizer
-	tokenizerInstance := tokenizer.NewSimpleEstimateTokenizer()
-
 	store.RegisterCallback("ModelRoute", func(data datastore.EventData) {
 		switch data.EventType {
 		case datastore.EventAdd, datastore.EventUpdate:
@@ -117,55 +137,47 @@ func NewRouter(store datastore.Store, routerConfigPath string) *Router {
 		}
 	})
 
-	routerConfig, err := conf.ParseRouterConfig(routerConfigPath)
-	if err != nil {
-		klog.Fatalf("failed to parse router config: %v", err)
-	}
-
-	// Initialize access logger with configuration from environment variables
-	accessLogConfig := &accesslog.AccessLoggerConfig{
-		Enabled: true,
-		Format:  accesslog.FormatText,
-		Output:  "stdout",
-	}
-
-	// Read access log configuration from environment variables
-	if enabled := os.Getenv("ACCESS_LOG_ENABLED"); enabled != "" {
-		if enabledBool, err := strconv.ParseBool(enabled); err == nil {
-			accessLogConfig.Enabled = enabledBool
-		}
-	}
-
-	if format := os.Getenv("ACCESS_LOG_FORMAT"); format != "" {
-		if format == "json" {
-			accessLogConfig.Format = accesslog.FormatJSON
-		} else if format == "text" {
-			accessLogConfig.Format = accesslog.FormatText
-		}
-	}
-
-	if output := os.Getenv("ACCESS_LOG_OUTPUT"); output != "" {
-		accessLogConfig.Output = output
-	}
-
-	accessLogger, err := accesslog.NewAccessLogger(accessLogConfig)
-	if err != nil {
-		klog.Fatalf("failed to create access logger: %v", err)
-	}
-
-	return &Router{
+	// The tokenizers are shared with the rate limiter and the scheduler plugins
+	router := &Router{
 		store:            store,
-		scheduler:        scheduler.NewScheduler(store, routerConfig),
-		authenticator:    auth.NewJWTAuthenticator(routerConfig),
+		configPath:       routerConfigPath,
+		apiKeys:          auth.NewAPIKeyAuthenticator(nil, store),
 		loadRateLimiter:  loadRateLimiter,
-		accessLogger:     accessLogger,
 		metrics:          metricsInstance,
-		tokenizer:        tokenizerInstance,
+		tokenizers:       tokenizer.DefaultManager,
 		connectorFactory: connectors.NewDefaultFactory(),
+		upstreams:        newUpstreamCache(store),
+		responseStore:    responses.NewStoreFromEnv(),
+		mirrorSlots:      make(chan struct{}, maxInflightMirrors),
 		fairnessTimeout:  parseFairnessTimeout(),
 		tokenWeight:      parseEnvFloat("FAIRNESS_PRIORITY_TOKEN_WEIGHT", 1.0),
 		requestNumWeight: parseEnvFloat("FAIRNESS_PRIORITY_REQUEST_NUM_WEIGHT", 0.0),
 	}
+
+	store.RegisterCallback("Pod", func(data datastore.EventData) {
+		if data.EventType == datastore.EventDelete {
+			router.metrics.DeletePod(data.Pod.Namespace + "/" + data.Pod.Name)
+		}
+	})
+	store.RegisterCallback("ModelServer", func(data datastore.EventData) {
+		if data.EventType == datastore.EventDelete {
+			router.upstreams.Delete(data.ModelServer)
+		}
+	})
+
+	data, err := os.ReadFile(routerConfigPath)
+	if err != nil {
+		klog.Fatalf("failed to read router config: %v", err)
+	}
+	config, routerConfig, err := router.loadConfig(data, nil)
+	if err != nil {
+		klog.Fatalf("failed to load router config: %v", err)
+	}
+	router.config.Store(config)
+	router.apiKeys.SetEnabled(routerConfig.Auth.APIKey.Enabled)
+	router.apiKeys.SetNamespaces(routerConfig.Auth.APIKey.Namespaces)
+	router.configStatus = ConfigStatus{Path: routerConfigPath, Checksum: config.checksum, LoadedAt: time.Now()}
+	return router
 }
 
 const defaultFairnessTimeout = 60 * time.Second
@@ -203,12 +215,43 @@ type ModelRequest map[string]interface{}
 
 func (r *Router) HandlerFunc() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if isModelsRequest(c.Request) {
+			r.handleModels(c)
+			return
+		}
+		if isStoredResponseRequest(c.Request) {
+			r.handleStoredResponse(c)
+			return
+		}
+
 		// Step 1: Parse and validate request
+		_, parseSpan := tracing.Start(c.Request.Context(), tracing.SpanParseRequest)
 		modelRequest, err := ParseModelRequest(c)
+		tracing.End(parseSpan, err)
 		if err != nil {
 			accesslog.SetError(c, "request_parsing", err.Error())
 			return
 		}
+		accesslog.SetRequestBody(c, modelRequest)
+
+		// Responses API requests are served with chat completions.
+		if isResponsesRequest(c.Request) {
+			var writer *responses.Writer
+			modelRequest, writer, err = r.translateResponsesRequest(c, modelRequest)
+			if err != nil {
+				return
+			}
+			defer writer.Close()
+		}
+		// So are Anthropic Messages API requests.
+		if isMessagesRequest(c.Request) {
+			var writer *anthropic.Writer
+			modelRequest, writer, err = translateMessagesRequest(c, modelRequest)
+			if err != nil {
+				return
+			}
+			defer writer.Close()
+		}
 
 		// step 2: Detection of rate limit
 		modelName := modelRequest["model"].(string)
@@ -238,8 +281,14 @@ func (r *Router) HandlerFunc() gin.HandlerFunc {
 			}
 		}()
 
-		prompt, err := utils.ParsePrompt(modelRequest)
+		if !r.authorize(c, modelName) {
+			c.Set("finishReason", "authorization")
+			return
+		}
+
+		prompt, err := utils.ParseEndpointPrompt(utils.GetEndpointType(c.Request.URL.Path), modelRequest)
 		if err != nil {
+			klog.V(4).Infof("failed to parse prompt: %v", err)
 			accesslog.SetError(c, "prompt_parsing", "prompt not found")
 			c.AbortWithStatusJSON(http.StatusNotFound, "prompt not found")
 			c.Set("finishReason", "prompt_parsing")
@@ -247,12 +296,8 @@ func (r *Router) HandlerFunc() gin.HandlerFunc {
 		}
 		promptStr := utils.GetPromptString(prompt)
 
-		// Calculate input tokens for metrics using tokenizer
-		inputTokens, err := r.tokenizer.CalculateTokenNum(promptStr)
-		if err != nil {
-			klog.Errorf("failed to calculate token number: %v", err)
-			inputTokens = len(promptStr) / 4 // fallback estimation
-		}
+		// Count input tokens for metrics with the tokenizer of the model
+		inputTokens := r.tokenizers.CountTokens(modelName, promptStr)
 
 		// Calculate and set input tokens for access log
 		accesslog.SetTokenCounts(c, inputTokens, 0)
@@ -264,29 +309,62 @@ func (r *Router) HandlerFunc() gin.HandlerFunc {
 		metricsRecorder.RecordInputTokens(inputTokens)
 
 		// Apply rate limiting using the unified rate limiter
-		if err := r.loadRateLimiter.RateLimit(modelName, promptStr); err != nil {
+		_, rateLimitSpan := tracing.Start(c.Request.Context(), tracing.SpanRateLimit,
+			trace.WithAttributes(semconv.GenAIUsageInputTokens(inputTokens)))
+		limitKey := r.loadRateLimiter.LimitKey(modelName, c.Request.Header, claimsOf(c), modelRequest)
+		c.Set(rateLimitKeyKey, limitKey)
+		// The concurrency slot is held until the request is done, which is when the handler returns,
+		// whether the response was streamed, failed or the client went away.
+		release, err := r.loadRateLimiter.AcquireConcurrency(modelName, limitKey)
+		defer release()
+		var rateLimitStatus ratelimit.RateLimitStatus
+		if err == nil {
+			rateLimitStatus, err = r.loadRateLimiter.Check(modelName, limitKey, promptStr)
+		}
+		tracing.End(rateLimitSpan, err)
+		setRateLimitHeaders(c, rateLimitStatus)
+		if err != nil {
 			var errorMsg string
 			var errorType string
-			var tokenType string
-			switch err.(type) {
+			var limitType string
+			status := http.StatusTooManyRequests
+			switch e := err.(type) {
+			case *ratelimit.InputTokensExceedLimitError:
+				// Retrying would not help.
+				errorMsg = e.Error()
+				errorType = "input_tokens_exceed_limit"
+				limitType = metrics.LimitTypeInputTokens
+				status = http.StatusRequestEntityTooLarge
 			case *ratelimit.InputRateLimitExceededError:
 				errorMsg = "input token rate limit exceeded"
 				errorType = "input_rate_limit"
-				tokenType = metrics.LimitTypeInputTokens
+				limitType = metrics.LimitTypeInputTokens
+				setRetryAfter(c, e.RetryAfter)
 			case *ratelimit.OutputRateLimitExceededError:
 				errorMsg = "output token rate limit exceeded"
 				errorType = "output_rate_limit"
-				tokenType = metrics.LimitTypeOutputTokens
+				limitType = metrics.LimitTypeOutputTokens
+				setRetryAfter(c, e.RetryAfter)
+			case *ratelimit.RequestRateLimitExceededError:
+				errorMsg = "request rate limit exceeded"
+				errorType = "request_rate_limit"
+				limitType = metrics.LimitTypeRequests
+				setRetryAfter(c, e.RetryAfter)
+			case *ratelimit.ConcurrencyLimitExceededError:
+				errorMsg = "concurrent request limit exceeded"
+				errorType = "concurrency_limit"
+				limitType = metrics.LimitTypeConcurrentRequests
 			default:
-				errorMsg = "token usage exceeds rate limit"
+				errorMsg = "rate limit exceeded"
 				errorType = "rate_limit"
-				tokenType = metrics.LimitTypeRequests
 			}
 			accesslog.SetError(c, errorType, errorMsg)
 
 			// Record rate limit exceeded
-			metricsRecorder.RecordRateLimitExceeded(tokenType)
-			c.AbortWithStatusJSON(http.StatusTooManyRequests, errorMsg)
+			if limitType != "" {
+				metricsRecorder.RecordRateLimitExceeded(limitType)
+			}
+			c.AbortWithStatusJSON(status, errorMsg)
 			c.Set("finishReason", "rate_limit")
 			return
 		}
@@ -322,7 +400,6 @@ func (r *Router) doLoadbalance(c *gin.Context, modelRequest ModelRequest) {
 	var port int32
 	var modelServerName types.NamespacedName
 	var modelRoute *v1alpha1.ModelRoute
-	var modelServer *v1alpha1.ModelServer
 
 	// Get gateway key from context if available (set by Gateway listener)
 	var gatewayKey string
@@ -335,33 +412,19 @@ func (r *Router) doLoadbalance(c *gin.Context, modelRequest ModelRequest) {
 		accesslog.SetGatewayAPIInfo(c, gatewayKey, "", "")
 	}
 
-	var isLora bool
-	var err error
 	// Try to match ModelRoute first
-	modelServerName, isLora, modelRoute, err = r.store.MatchModelServer(modelName, c.Request, gatewayKey)
+	match, err := r.store.MatchRoute(modelName, c.Request, gatewayKey)
 	if err != nil {
 		accesslog.SetError(c, "model_server_matching", fmt.Sprintf("can't find corresponding model server: %v", err))
+	} else {
+		modelServerName, modelRoute = match.ModelServerName, match.ModelRoute
 	}
 
 	if err == nil && strings.HasPrefix(c.Request.URL.Path, "/v1/") {
-		// Regular ModelServer request
-		// step 3: Find pods and model server details
-		klog.V(4).Infof("modelServer is %v, is_lora: %v", modelServerName, isLora)
-
-		pods, modelServer, err = r.getPodsAndServer(modelServerName)
-		if err != nil || len(pods) == 0 {
-			klog.Errorf("failed to get pods and model server: %v, %v", modelServerName, err)
-			accesslog.SetError(c, "pod_discovery", fmt.Sprintf("can't find model server: %v", modelServerName))
-			c.AbortWithStatusJSON(http.StatusNotFound, fmt.Sprintf("can't find model server: %v", modelServerName))
-			return
-		}
-
-		model := modelServer.Spec.Model
-		if model != nil && !isLora {
-			modelRequest["model"] = *model
-		}
-
-		port = modelServer.Spec.WorkloadPort.Port
+		c.Set(routeMatchKey, match)
+		// Regular ModelServer request, served by the target model of the rule or its fallbacks
+		r.serveRouteMatch(c, modelRequest, match)
+		return
 	} else if matched, inferencePoolName := r.handleHTTPRoute(c, gatewayKey); matched {
 		// If ModelRoute is not matched, try to match HTTPRoute
 
@@ -401,12 +464,28 @@ func (r *Router) doLoadbalance(c *gin.Context, modelRequest ModelRequest) {
 		return
 	}
 
+	_ = r.scheduleAndProxy(c, modelRequest, modelName, pods, nil, modelServerName, modelRoute, port)
+}
+
+// scheduleAndProxy schedules the request among the given pods and proxies it to the best ones.
+// Failures are written downstream, except when the request is handed over to the next fallback
+// target of the ModelRoute rule, in which case a *fallbackError is returned and nothing is written.
+func (r *Router) scheduleAndProxy(
+	c *gin.Context,
+	modelRequest ModelRequest,
+	modelName string,
+	pods []*datastore.PodInfo,
+	modelServer *v1alpha1.ModelServer,
+	modelServerName types.NamespacedName,
+	modelRoute *v1alpha1.ModelRoute,
+	port int32,
+) error {
 	// Common scheduling logic for both ModelServer and InferencePool
-	prompt, err := utils.ParsePrompt(modelRequest)
+	prompt, err := utils.ParseEndpointPrompt(utils.GetEndpointType(c.Request.URL.Path), modelRequest)
 	if err != nil {
 		accesslog.SetError(c, "prompt_parsing", "prompt not found")
 		c.AbortWithStatusJSON(http.StatusNotFound, "prompt not found")
-		return
+		return nil
 	}
 
 	// Get metrics recorder from gin context
@@ -426,16 +505,35 @@ func (r *Router) doLoadbalance(c *gin.Context, modelRequest ModelRequest) {
 	ctx := &framework.Context{
 		Model:           modelName,
 		Prompt:          prompt,
+		MediaHashes:     utils.GetMediaHashes(prompt),
+		Headers:         c.Request.Header,
+		Body:            modelRequest,
 		ModelServerName: modelServerName,
 		PDGroup:         pdGroup,
 		MetricsRecorder: metricsRecorder,
 	}
 
-	err = r.scheduler.Schedule(ctx, pods)
+	var scheduleSpan trace.Span
+	ctx.TraceContext, scheduleSpan = tracing.Start(c.Request.Context(), tracing.SpanSchedule, trace.WithAttributes(
+		tracing.ModelServerKey.String(modelServerName.String()),
+		tracing.CandidatePodsKey.Int(len(pods)),
+	))
+	err = r.runtimeConfigOf(c).scheduler.Schedule(ctx, pods)
+	scheduleSpan.SetAttributes(tracing.SchedulerProfileKey.String(ctx.Profile))
+	if len(ctx.BestPods) > 0 && ctx.BestPods[0].Pod != nil {
+		scheduleSpan.SetAttributes(tracing.PodKey.String(ctx.BestPods[0].Pod.Name))
+	}
+	if len(ctx.DecodePods) > 0 && ctx.DecodePods[0].Pod != nil {
+		scheduleSpan.SetAttributes(tracing.PodKey.String(ctx.DecodePods[0].Pod.Name))
+	}
+	tracing.End(scheduleSpan, err)
 	if err != nil {
+		if fallsBack(c, v1alpha1.FallbackOnNoEndpoints) {
+			return &fallbackError{trigger: v1alpha1.FallbackOnNoEndpoints, err: err}
+		}
 		accesslog.SetError(c, "scheduling", fmt.Sprintf("can't schedule to target pod: %v", err))
 		c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("can't schedule to target pod: %v", err))
-		return
+		return nil
 	}
 
 	// Set complete request routing information in access log
@@ -455,12 +553,25 @@ func (r *Router) doLoadbalance(c *gin.Context, modelRequest ModelRequest) {
 		accesslog.SetRequestRouting(c, modelRouteName, modelServerFullName, "")
 	}
 
+	// The shadow traffic is sent before the request is proxied, which changes the request body.
+	// A request served by a fallback target has already been mirrored.
+	if match := getRouteMatch(c); match != nil && match.Rule != nil && match.Rule.Mirror != nil && !isFallback(c) {
+		r.mirror(c, match, ctx, modelRequest)
+	}
+
 	req := c.Request
 	if err := r.proxyModelEndpoint(c, req, ctx, modelRequest, port); err != nil {
+		var fallbackErr *fallbackError
+		if errors.As(err, &fallbackErr) {
+			return err
+		}
 		klog.Errorf("request failed reqID: %s: %v", c.Request.Header.Get("x-request-id"), err)
 		accesslog.SetError(c, "proxy", "request processing failed")
-		c.AbortWithStatusJSON(http.StatusInternalServerError, "request processing failed")
+		if !c.IsAborted() {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, "request processing failed")
+		}
 	}
+	return nil
 }
 
 func ParseModelRequest(c *gin.Context) (ModelRequest, error) {
@@ -689,26 +800,108 @@ func (r *Router) proxy(
 		}
 	}
 
-	for i := 0; i < len(ctx.BestPods); i++ {
+	policy := r.getTrafficPolicy(ctx.ModelServerName)
+	attempts := policy.maxAttempts(len(ctx.BestPods))
+	hedging := r.getHedging(c, ctx, stream)
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if !isRetryableError(c, lastErr) {
+				break
+			}
+			if err := waitForRetry(req.Context(), policy.backoff(attempt)); err != nil {
+				lastErr = err
+				break
+			}
+		}
+		if attempt == 0 && hedging != nil {
+			i, hedged, err := r.proxyHedged(c, req, ctx, port, hedging, onUsage, modelServerName, modelRouteName)
+			if err != nil {
+				lastErr = err
+				// The hedged request counts as an attempt, so that retries go on with the next pod.
+				if hedged {
+					attempt++
+				}
+				continue
+			}
+			accesslog.SetSelectedPod(c, ctx.BestPods[i].Pod.Name)
+			r.runtimeConfigOf(c).scheduler.RunPostHooks(ctx, i)
+			return nil
+		}
+		i := attempt % len(ctx.BestPods)
+		podInfo := ctx.BestPods[i]
+		pod := podInfo.Pod
+
 		// Increment upstream request count with both modelServer and modelRoute
 		r.metrics.IncActiveUpstreamRequests(modelServerName, modelRouteName)
 
 		// Request dispatched to the pod.
-		err := proxyRequest(c, req, ctx.BestPods[i].Pod.Status.PodIP, port, stream, onUsage)
+		attemptCtx, span := tracing.Start(req.Context(), tracing.SpanUpstream, trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(tracing.PodKey.String(pod.Name), tracing.AttemptKey.Int(attempt)))
+		start := time.Now()
+		trial := podInfo.StartRequest()
+		err := proxyRequest(c, req.WithContext(attemptCtx), pod.Status.PodIP, port, stream, onUsage)
+		tracing.End(span, err)
 
 		// Decrement upstream request count when request completes
 		r.metrics.DecActiveUpstreamRequests(modelServerName, modelRouteName)
+		r.recordUpstreamAttempt(c, modelServerName, modelRouteName, pod.Name, attempt, start, err)
+		r.recordPodHealth(podInfo, trial, err)
 
 		if err != nil {
 			klog.Errorf(" pod request error: %v", err)
+			lastErr = err
 			continue
 		}
+		accesslog.SetSelectedPod(c, pod.Name)
 		// record in prefix cache
-		r.scheduler.RunPostHooks(ctx, i)
+		r.runtimeConfigOf(c).scheduler.RunPostHooks(ctx, i)
 		return nil
 	}
-	c.AbortWithStatusJSON(http.StatusNotFound, "request to all pods failed")
-	return fmt.Errorf("request to all pods failed")
+	if trigger, ok := fallbackTrigger(c, lastErr); ok && fallsBack(c, trigger) {
+		return &fallbackError{trigger: trigger, err: lastErr}
+	}
+	abortWithUpstreamError(c, lastErr, http.StatusNotFound, "request to all pods failed")
+	if lastErr == nil {
+		return fmt.Errorf("request to all pods failed")
+	}
+	return fmt.Errorf("request to all pods failed: %w", lastErr)
+}
+
+// requestedModel returns the model of the client request, which the rate limits and the fairness
+// queues are keyed on. It differs from the scheduled model when the target model renames it.
+func requestedModel(c *gin.Context, ctx *framework.Context) string {
+	if model := c.GetString("model"); model != "" {
+		return model
+	}
+	return ctx.Model
+}
+
+// requestUser returns the user the token usage of the request is accounted to: the authenticated
+// user, or the userId of the request body if the request was not authenticated. The user field of
+// chat completions, which carries the metadata.user_id of Messages API requests, is used otherwise.
+func requestUser(c *gin.Context, modelRequest ModelRequest) string {
+	if user, exists := c.Get(common.UserIdKey); exists {
+		userID, _ := user.(string)
+		return userID
+	}
+	if userID, ok := modelRequest["userId"].(string); ok && userID != "" {
+		return userID
+	}
+	userID, _ := modelRequest["user"].(string)
+	return userID
+}
+
+// getRouteMatch returns the ModelRoute rule matched by the request, or nil if the request is not
+// served through a ModelRoute.
+func getRouteMatch(c *gin.Context) *datastore.RouteMatch {
+	if v, exists := c.Get(routeMatchKey); exists {
+		if match, ok := v.(*datastore.RouteMatch); ok {
+			return match
+		}
+	}
+	return nil
 }
 
 func (r *Router) proxyModelEndpoint(
@@ -721,6 +914,28 @@ func (r *Router) proxyModelEndpoint(
 	// Mark start of upstream processing
 	accesslog.MarkUpstreamStart(c)
 
+	// Model servers serving https are accessed with their own TLS transport, both by the
+	// aggregated proxy and by the kv connectors.
+	if modelServer := r.getModelServerByName(ctx.ModelServerName); modelServer != nil {
+		upstream, err := r.upstreams.Get(modelServer)
+		if err != nil {
+			klog.Errorf("failed to get upstream of model server %s: %v", ctx.ModelServerName, err)
+			accesslog.SetError(c, "upstream_tls", err.Error())
+			c.AbortWithStatusJSON(http.StatusBadGateway, "failed to connect to model server")
+			return fmt.Errorf("failed to get upstream: %w", err)
+		}
+		req = req.WithContext(connectors.WithUpstream(req.Context(), upstream))
+		c.Request = req
+	}
+
+	// The timeout of the traffic policy covers all upstream attempts including retries.
+	if policy := r.getTrafficPolicy(ctx.ModelServerName); policy.timeout > 0 {
+		timeoutCtx, cancel := context.WithTimeout(req.Context(), policy.timeout)
+		defer cancel()
+		req = req.WithContext(timeoutCtx)
+		c.Request = req
+	}
+
 	// Get metrics recorder from context
 	var metricsRecorder *metrics.RequestMetricsRecorder
 	if recorder, exists := c.Get("metricsRecorder"); exists {
@@ -734,18 +949,16 @@ func (r *Router) proxyModelEndpoint(
 		decodeRequest := connectors.BuildDecodeRequest(c, req, modelRequest)
 		// build request
 		stream := isStreaming(modelRequest)
-		userID := ""
-		if v, ok := modelRequest["userId"].(string); ok {
-			userID = v
-		}
-		modelName := ctx.Model
+		userID := requestUser(c, modelRequest)
+		modelName := requestedModel(c, ctx)
+		limitKey := c.GetString(rateLimitKeyKey)
 		err := r.proxy(c, decodeRequest, ctx, stream, port, func(resp handlers.OpenAIResponse) {
 			if resp.Usage.TotalTokens <= 0 {
 				return
 			}
 			// Record output tokens for rate limiting
 			if r.loadRateLimiter != nil {
-				r.loadRateLimiter.RecordOutputTokens(modelName, resp.Usage.CompletionTokens)
+				r.loadRateLimiter.RecordOutputTokens(modelName, limitKey, resp.Usage.CompletionTokens)
 			}
 			// Update access log with output tokens
 			if accessCtx := accesslog.GetAccessLogContext(c); accessCtx != nil {
@@ -796,11 +1009,28 @@ func (r *Router) GetModelServer(modelName string, req *http.Request) (*v1alpha1.
 }
 
 func (r *Router) Auth() gin.HandlerFunc {
-	return r.authenticator.Authenticate()
+	return func(c *gin.Context) {
+		_, span := tracing.Start(c.Request.Context(), tracing.SpanAuthenticate)
+		defer span.End()
+		r.runtimeConfigOf(c).authenticate(c)
+		if c.IsAborted() {
+			span.SetStatus(codes.Error, http.StatusText(c.Writer.Status()))
+		}
+	}
 }
 
+// AccessLog logs the requests with the access logger of their runtime configuration.
 func (r *Router) AccessLog() gin.HandlerFunc {
-	return accesslog.AccessLogMiddleware(r.accessLogger)
+	return func(c *gin.Context) {
+		accesslog.AccessLogMiddleware(r.runtimeConfigOf(c).accessLogger)(c)
+	}
+}
+
+// Shutdown exports the spans and sends the access log entries that are not sent yet, when the
+// router stops.
+func (r *Router) Shutdown(ctx context.Context) error {
+	config := r.config.Load()
+	return errors.Join(config.tracer.Shutdown(ctx), config.accessLogger.Close())
 }
 
 // proxyRequest proxies the request to the model server pods, returns response to downstream.
@@ -816,6 +1046,16 @@ func proxyRequest(
 	if err != nil {
 		return fmt.Errorf("decode request error: %w", err)
 	}
+	return writeResponse(c, resp, stream, onUsage)
+}
+
+// writeResponse writes the response of the model server downstream, and closes its body.
+func writeResponse(
+	c *gin.Context,
+	resp *http.Response,
+	stream bool,
+	onUsage func(u handlers.OpenAIResponse),
+) error {
 	for k, vv := range resp.Header {
 		for _, v := range vv {
 			c.Header(k, v)
@@ -889,16 +1129,19 @@ func doRequest(
 	port int32,
 ) (*http.Response, error) {
 	// step 1: change request URL to prefill pod URL.
-	req.URL.Host = fmt.Sprintf("%s:%d", podIP, port)
+	connectors.SetUpstreamURL(req, fmt.Sprintf("%s:%d", podIP, port))
 
-	// step 2: use http.Transport to do request to prefill pod.
-	transport := http.DefaultTransport
-	resp, err := transport.RoundTrip(req)
+	// step 2: use the transport of the model server to do request to prefill pod.
+	if err := connectors.RewindRequestBody(req); err != nil {
+		return nil, err
+	}
+	resp, err := connectors.RoundTrip(req)
 	if err != nil {
 		return nil, err
 	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("http resp error, http code is %d", resp.StatusCode)
+		resp.Body.Close()
+		return nil, &connectors.UpstreamStatusError{Phase: "decode", StatusCode: resp.StatusCode}
 	}
 	return resp, nil
 }
@@ -970,15 +1213,32 @@ func (r *Router) proxyToPDDisaggregated(
 	}
 
 	// Try multiple prefill/decode pairs
-	maxRetry := len(ctx.DecodePods)
-	if len(ctx.PrefillPods) < maxRetry {
-		maxRetry = len(ctx.PrefillPods)
+	maxPairs := len(ctx.DecodePods)
+	if len(ctx.PrefillPods) < maxPairs {
+		maxPairs = len(ctx.PrefillPods)
+	}
+	pairs := make([]int, 0, maxPairs)
+	for i := 0; i < maxPairs; i++ {
+		if ctx.PrefillPods[i] != nil && ctx.DecodePods[i] != nil {
+			pairs = append(pairs, i)
+		}
 	}
 
-	for i := 0; i < maxRetry; i++ {
-		if ctx.PrefillPods[i] == nil || ctx.DecodePods[i] == nil {
-			continue
+	policy := r.getTrafficPolicy(ctx.ModelServerName)
+	attempts := policy.maxAttempts(len(pairs))
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if !isRetryableError(c, lastErr) {
+				break
+			}
+			if err := waitForRetry(c.Request.Context(), policy.backoff(attempt)); err != nil {
+				lastErr = err
+				break
+			}
 		}
+		i := pairs[attempt%len(pairs)]
 
 		// Build addresses for prefill and decode pods
 		prefillAddr := fmt.Sprintf("%s:%d", ctx.PrefillPods[i].Pod.Status.PodIP, port)
@@ -986,18 +1246,40 @@ func (r *Router) proxyToPDDisaggregated(
 
 		klog.V(4).Infof("Attempting PD disaggregated request: prefill=%s, decode=%s", prefillAddr, decodeAddr)
 
-		// Execute the PD disaggregated proxy operation
+		// Execute the PD disaggregated proxy operation. The connector sends the prefill and decode
+		// requests in the span of the attempt.
+		attemptCtx, span := tracing.Start(req.Context(), tracing.SpanUpstream, trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				tracing.PodKey.String(ctx.DecodePods[i].Pod.Name),
+				tracing.PrefillPodKey.String(ctx.PrefillPods[i].Pod.Name),
+				tracing.AttemptKey.Int(attempt),
+			))
+		c.Request = req.WithContext(attemptCtx)
+		start := time.Now()
+		prefillTrial := ctx.PrefillPods[i].StartRequest()
+		decodeTrial := ctx.DecodePods[i].StartRequest()
 		outputTokens, err := kvConnector.Proxy(c, modelRequest, prefillAddr, decodeAddr)
+		c.Request = req
+		if outputTokens > 0 {
+			span.SetAttributes(semconv.GenAIUsageOutputTokens(outputTokens))
+		}
+		tracing.End(span, err)
+		r.recordUpstreamAttempt(c, modelServerName, modelRouteName, ctx.DecodePods[i].Pod.Name, attempt, start, err)
+		prefillErr, decodeErr := splitPDError(err)
+		r.recordPodHealth(ctx.PrefillPods[i], prefillTrial, prefillErr)
+		r.recordPodHealth(ctx.DecodePods[i], decodeTrial, decodeErr)
 
 		if err != nil {
 			klog.Errorf("proxy failed for prefill pod %s, decode pod %s: %v",
 				ctx.PrefillPods[i].Pod.Name, ctx.DecodePods[i].Pod.Name, err)
+			lastErr = err
 			continue
 		}
+		accesslog.SetSelectedPod(c, ctx.DecodePods[i].Pod.Name)
 
 		// Record output tokens for rate limiting
 		if outputTokens > 0 && r.loadRateLimiter != nil {
-			r.loadRateLimiter.RecordOutputTokens(ctx.Model, outputTokens)
+			r.loadRateLimiter.RecordOutputTokens(requestedModel(c, ctx), c.GetString(rateLimitKeyKey), outputTokens)
 		}
 
 		// Record output token metrics
@@ -1006,7 +1288,7 @@ func (r *Router) proxyToPDDisaggregated(
 		}
 
 		// Record successful operation in cache
-		r.scheduler.RunPostHooks(ctx, i)
+		r.runtimeConfigOf(c).scheduler.RunPostHooks(ctx, i)
 
 		klog.V(4).Infof("kv connector run successful for prefill pod %s, decode pod %s, output tokens: %d",
 			ctx.PrefillPods[i].Pod.Name, ctx.DecodePods[i].Pod.Name, outputTokens)
@@ -1014,8 +1296,14 @@ func (r *Router) proxyToPDDisaggregated(
 		return nil
 	}
 
-	c.AbortWithStatusJSON(http.StatusInternalServerError, "all prefill/decode attempts failed")
-	return fmt.Errorf("all prefill/decode attempts failed")
+	if trigger, ok := fallbackTrigger(c, lastErr); ok && fallsBack(c, trigger) {
+		return &fallbackError{trigger: trigger, err: lastErr}
+	}
+	abortWithUpstreamError(c, lastErr, http.StatusInternalServerError, "all prefill/decode attempts failed")
+	if lastErr == nil {
+		return fmt.Errorf("all prefill/decode attempts failed")
+	}
+	return fmt.Errorf("all prefill/decode attempts failed: %w", lastErr)
 }
 
 // handleFairnessScheduling handles the fairness scheduling flow for requests
@@ -1046,19 +1334,25 @@ func (r *Router) handleFairnessScheduling(c *gin.Context, modelRequest ModelRequ
 		CancelCh:    reqCtx.Done(),
 	}
 
+	_, queueSpan := tracing.Start(c.Request.Context(), tracing.SpanFairnessQueue, trace.WithAttributes(
+		tracing.FairnessPriorityKey.Float64(pri),
+	))
 	if err := r.store.Enqueue(queueReq); err != nil {
+		tracing.End(queueSpan, err)
 		c.AbortWithStatusJSON(http.StatusInternalServerError, fmt.Sprintf("failed to enqueue request: %v", err))
 		return fmt.Errorf("failed to enqueue request: %v", err)
 	}
 
 	select {
 	case <-queueReq.NotifyChan:
+		queueSpan.End()
 		if queueReq.Release != nil {
 			defer queueReq.Release()
 		}
 		r.doLoadbalance(c, modelRequest)
 		return nil
 	case <-reqCtx.Done():
+		tracing.End(queueSpan, reqCtx.Err())
 		if queueReq.Release != nil {
 			queueReq.Release()
 		}
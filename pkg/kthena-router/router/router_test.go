@@ -26,11 +26,13 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"istio.io/istio/pkg/util/sets"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -39,8 +41,12 @@ import (
 
 	aiv1alpha1 "github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/accesslog"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/anthropic"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/common"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/connectors"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/datastore"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/responses"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
 )
 
 func TestMain(m *testing.M) {
@@ -131,39 +137,46 @@ func TestRouter_HandlerFunc_AggregatedMode(t *testing.T) {
 	assert.Contains(t, w.Body.String(), `"id":"response-id"`)
 }
 
-func TestRouter_HandlerFunc_DisaggregatedMode(t *testing.T) {
-	// 1. Setup backend mock server
-	prefillReqs := 0
-	decodeReqs := 0
-	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		body, _ := io.ReadAll(r.Body)
-		var reqBody ModelRequest
-		json.Unmarshal(body, &reqBody)
+func TestRouter_HandlerFunc_NonGenerationEndpoints(t *testing.T) {
+	tests := []struct {
+		path string
+		body string
+	}{
+		{path: "/v1/embeddings", body: `{"model": "test-model", "input": ["hello", "world"]}`},
+		{path: "/v1/rerank", body: `{"model": "test-model", "query": "hello", "documents": ["world"]}`},
+		{path: "/v1/score", body: `{"model": "test-model", "text_1": "hello", "text_2": "world"}`},
+	}
 
-		// Check if this is a prefill request (stream key removed) or decode request (stream key present)
-		if _, hasStream := reqBody["stream"]; !hasStream {
-			// Prefill request - stream key is deleted
-			prefillReqs++
-			assert.Equal(t, "test-model-base", reqBody["model"])
-			w.WriteHeader(http.StatusOK)
-			fmt.Fprint(w, `{"id":"prefill-resp"}`)
-		} else {
-			// Decode request - stream key is present
-			decodeReqs++
-			assert.Equal(t, "test-model-base", reqBody["model"])
-			w.Header().Set("Content-Type", "text/event-stream")
-			w.WriteHeader(http.StatusOK)
-			fmt.Fprint(w, `data: {"id":"decode-resp"}`)
-		}
-	})
-	router, store, backend := setupTestRouter(t, backendHandler)
-	defer backend.Close()
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, tt.path, r.URL.Path)
+				w.WriteHeader(http.StatusOK)
+				fmt.Fprint(w, `{"id":"response-id"}`)
+			})
+			router, backend := setupTrafficPolicyTest(t, backendHandler, nil)
+			defer backend.Close()
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest("POST", tt.path, bytes.NewBufferString(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			router.HandlerFunc()(c)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), `"id":"response-id"`)
+		})
+	}
+}
 
+// setupPDModelServer adds the PD disaggregated model server ms-1 with a prefill and a decode pod
+// served by backend, and the ModelRoute mr-1 of test-model to it.
+func setupPDModelServer(store datastore.Store, backend *httptest.Server) {
 	backendURL, _ := url.Parse(backend.URL)
 	backendIP := backendURL.Hostname()
 	backendPort, _ := strconv.Atoi(backendURL.Port())
 
-	// 2. Populate store
 	modelServer := &aiv1alpha1.ModelServer{
 		ObjectMeta: v1.ObjectMeta{Name: "ms-1", Namespace: "default"},
 		Spec: aiv1alpha1.ModelServerSpec{
@@ -217,6 +230,38 @@ func TestRouter_HandlerFunc_DisaggregatedMode(t *testing.T) {
 	store.AddOrUpdatePod(decodePod, []*aiv1alpha1.ModelServer{modelServer})
 	store.AddOrUpdatePod(prefillPod, []*aiv1alpha1.ModelServer{modelServer})
 	store.AddOrUpdateModelRoute(modelRoute)
+}
+
+func TestRouter_HandlerFunc_DisaggregatedMode(t *testing.T) {
+	// 1. Setup backend mock server
+	prefillReqs := 0
+	decodeReqs := 0
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var reqBody ModelRequest
+		json.Unmarshal(body, &reqBody)
+
+		// Check if this is a prefill request (stream key removed) or decode request (stream key present)
+		if _, hasStream := reqBody["stream"]; !hasStream {
+			// Prefill request - stream key is deleted
+			prefillReqs++
+			assert.Equal(t, "test-model-base", reqBody["model"])
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, `{"id":"prefill-resp"}`)
+		} else {
+			// Decode request - stream key is present
+			decodeReqs++
+			assert.Equal(t, "test-model-base", reqBody["model"])
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, `data: {"id":"decode-resp"}`)
+		}
+	})
+	router, store, backend := setupTestRouter(t, backendHandler)
+	defer backend.Close()
+
+	// 2. Populate store
+	setupPDModelServer(store, backend)
 
 	// 3. Create request
 	w := connectors.CreateTestResponseRecorder()
@@ -315,134 +360,73 @@ func TestRouter_HandlerFunc_ScheduleFailure(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "can't schedule to target pod")
 }
 
-func TestAccessLogConfigurationFromEnv(t *testing.T) {
-	// Save original environment variables
-	originalEnabled := os.Getenv("ACCESS_LOG_ENABLED")
-	originalFormat := os.Getenv("ACCESS_LOG_FORMAT")
-	originalOutput := os.Getenv("ACCESS_LOG_OUTPUT")
-
-	// Clean up after test
-	defer func() {
-		os.Setenv("ACCESS_LOG_ENABLED", originalEnabled)
-		os.Setenv("ACCESS_LOG_FORMAT", originalFormat)
-		os.Setenv("ACCESS_LOG_OUTPUT", originalOutput)
-	}()
-
+func TestAccessLogConfiguration(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "access.log")
 	tests := []struct {
-		name            string
-		envEnabled      string
-		envFormat       string
-		envOutput       string
-		expectedEnabled bool
-		expectedFormat  accesslog.LogFormat
-		expectedOutput  string
+		name           string
+		config         string
+		expectedSinks  int
+		expectedFormat string
 	}{
 		{
-			name:            "default configuration",
-			envEnabled:      "",
-			envFormat:       "",
-			envOutput:       "",
-			expectedEnabled: true,
-			expectedFormat:  accesslog.FormatText,
-			expectedOutput:  "stdout",
+			name:          "default configuration",
+			config:        ``,
+			expectedSinks: 0,
 		},
 		{
-			name:            "JSON format configuration",
-			envEnabled:      "true",
-			envFormat:       "json",
-			envOutput:       "stdout",
-			expectedEnabled: true,
-			expectedFormat:  accesslog.FormatJSON,
-			expectedOutput:  "stdout",
+			name: "JSON format configuration",
+			config: `
+accessLog:
+  sinks:
+    - type: stdout
+      format: json
+`,
+			expectedSinks:  1,
+			expectedFormat: "json",
 		},
 		{
-			name:            "text format with file output",
-			envEnabled:      "true",
-			envFormat:       "text",
-			envOutput:       "/tmp/access.log",
-			expectedEnabled: true,
-			expectedFormat:  accesslog.FormatText,
-			expectedOutput:  "/tmp/access.log",
+			name: "text format with file output",
+			config: `
+accessLog:
+  sinks:
+    - type: file
+      format: text
+      file:
+        path: ` + logPath + `
+`,
+			expectedSinks:  1,
+			expectedFormat: "text",
 		},
 		{
-			name:            "disabled access log",
-			envEnabled:      "false",
-			envFormat:       "json",
-			envOutput:       "stdout",
-			expectedEnabled: false,
-			expectedFormat:  accesslog.FormatJSON,
-			expectedOutput:  "stdout",
+			name: "disabled access log",
+			config: `
+accessLog:
+  enabled: false
+`,
+			expectedSinks: 0,
 		},
 		{
-			name:            "stderr output",
-			envEnabled:      "true",
-			envFormat:       "text",
-			envOutput:       "stderr",
-			expectedEnabled: true,
-			expectedFormat:  accesslog.FormatText,
-			expectedOutput:  "stderr",
+			name: "stderr output",
+			config: `
+accessLog:
+  sinks:
+    - type: stderr
+`,
+			expectedSinks: 1,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Handle file output case - create a temporary file
-			envOutput := tt.envOutput
-			var tempFile *os.File
-			if tt.envOutput == "/tmp/access.log" {
-				var err error
-				tempFile, err = os.CreateTemp("", "access_log_test_*.log")
-				assert.NoError(t, err)
-				envOutput = tempFile.Name()
-				defer func() {
-					tempFile.Close()
-					os.Remove(tempFile.Name())
-				}()
-			}
-
-			// Set environment variables
-			os.Setenv("ACCESS_LOG_ENABLED", tt.envEnabled)
-			os.Setenv("ACCESS_LOG_FORMAT", tt.envFormat)
-			os.Setenv("ACCESS_LOG_OUTPUT", envOutput)
-
-			// Create access log configuration (simulating the logic in NewRouter)
-			accessLogConfig := &accesslog.AccessLoggerConfig{
-				Enabled: true,
-				Format:  accesslog.FormatText,
-				Output:  "stdout",
-			}
-
-			// Apply environment variable overrides
-			if enabled := os.Getenv("ACCESS_LOG_ENABLED"); enabled != "" {
-				if enabledBool, err := parseBool(enabled); err == nil {
-					accessLogConfig.Enabled = enabledBool
-				}
-			}
-
-			if format := os.Getenv("ACCESS_LOG_FORMAT"); format != "" {
-				if format == "json" {
-					accessLogConfig.Format = accesslog.FormatJSON
-				} else if format == "text" {
-					accessLogConfig.Format = accesslog.FormatText
-				}
-			}
-
-			if output := os.Getenv("ACCESS_LOG_OUTPUT"); output != "" {
-				accessLogConfig.Output = output
-			}
-
-			// Verify configuration
-			assert.Equal(t, tt.expectedEnabled, accessLogConfig.Enabled)
-			assert.Equal(t, tt.expectedFormat, accessLogConfig.Format)
-			// For file output, we check that the config output matches the actual temp file path
-			if tt.envOutput == "/tmp/access.log" {
-				assert.Equal(t, envOutput, accessLogConfig.Output)
-			} else {
-				assert.Equal(t, tt.expectedOutput, accessLogConfig.Output)
+			routerConfig, err := conf.ParseRouterConfigData([]byte(tt.config))
+			require.NoError(t, err)
+			require.Len(t, routerConfig.AccessLog.Sinks, tt.expectedSinks)
+			if tt.expectedSinks > 0 {
+				assert.Equal(t, tt.expectedFormat, routerConfig.AccessLog.Sinks[0].Format)
 			}
 
 			// Test that logger can be created with this configuration
-			logger, err := accesslog.NewAccessLogger(accessLogConfig)
+			logger, err := accesslog.New(&routerConfig.AccessLog)
 			assert.NoError(t, err)
 			assert.NotNil(t, logger)
 
@@ -452,13 +436,246 @@ func TestAccessLogConfigurationFromEnv(t *testing.T) {
 	}
 }
 
-// Helper function to parse boolean (same logic as strconv.ParseBool but simpler for test)
-func parseBool(str string) (bool, error) {
-	switch str {
-	case "1", "t", "T", "true", "TRUE", "True":
-		return true, nil
-	case "0", "f", "F", "false", "FALSE", "False":
-		return false, nil
+func TestRouter_AccessLogRequest(t *testing.T) {
+	router, path := setupConfigReloadTest(t)
+	logPath := filepath.Join(t.TempDir(), "access.log")
+	config := `
+accessLog:
+  headers: [authorization, x-user-id]
+  bodyFields: [model, temperature, metadata]
+  redact:
+    bodyFields: [metadata.email]
+  sinks:
+    - type: file
+      format: json
+      file:
+        path: ` + logPath + `
+`
+	require.NoError(t, os.WriteFile(path, []byte(config), 0o644))
+	require.NoError(t, router.reloadConfig())
+
+	engine := gin.New()
+	engine.Use(router.AccessLog())
+	engine.POST("/v1/chat/completions", router.HandlerFunc())
+	body := `{"model": "no-such-model", "temperature": 0.5, "messages": [], "metadata": {"email": "alice@example.com"}}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-User-Id", "alice")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	require.NoError(t, router.config.Load().accessLogger.Close())
+
+	data, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+	var entry accesslog.AccessLogEntry
+	require.NoError(t, json.Unmarshal(data, &entry))
+	assert.Equal(t, w.Code, entry.StatusCode)
+	assert.Equal(t, map[string]string{"authorization": accesslog.RedactedValue, "x-user-id": "alice"}, entry.RequestHeaders)
+	assert.Equal(t, map[string]interface{}{
+		"model":       "no-such-model",
+		"temperature": 0.5,
+		"metadata":    map[string]interface{}{"email": accesslog.RedactedValue},
+	}, entry.RequestBody)
+}
+
+func TestRouter_HandlerFunc_Models(t *testing.T) {
+	router, _ := setupTrafficPolicyTest(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("models requests should not be proxied")
+	}), nil)
+	loraRoute := &aiv1alpha1.ModelRoute{
+		ObjectMeta: v1.ObjectMeta{Name: "mr-lora", Namespace: "team-a", CreationTimestamp: v1.Unix(100, 0)},
+		Spec: aiv1alpha1.ModelRouteSpec{
+			ModelName:    "test-model",
+			LoraAdapters: []string{"sql-lora"},
+			Rules: []*aiv1alpha1.Rule{
+				{TargetModels: []*aiv1alpha1.TargetModel{{ModelServerName: "ms-1"}}},
+			},
+		},
+	}
+	assert.NoError(t, router.store.AddOrUpdateModelRoute(loraRoute))
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodGet, path, nil)
+		router.HandlerFunc()(c)
+		return w
+	}
+
+	w := serve("/v1/models")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list ModelList
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, "list", list.Object)
+	assert.ElementsMatch(t, []ModelCard{
+		{ID: "test-model", Object: "model", Created: v1.Time{}.Unix(), OwnedBy: "default"},
+		{ID: "sql-lora", Object: "model", Created: 100, OwnedBy: "team-a", Parent: "test-model"},
+	}, list.Data)
+
+	w = serve("/v1/models/sql-lora")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var card ModelCard
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &card))
+	assert.Equal(t, "sql-lora", card.ID)
+
+	w = serve("/v1/models/unknown")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Models reported by the pods are only listed when enabled.
+	router.store.GetPodInfo(types.NamespacedName{Namespace: "default", Name: "pod-1"}).UpdateModels([]string{"test-model-base", "sql-lora"})
+	EnablePodModelsInModelList = true
+	defer func() { EnablePodModelsInModelList = false }()
+	w = serve("/v1/models")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list.Data, 3)
+	assert.Contains(t, list.Data, ModelCard{ID: "test-model-base", Object: "model", Created: v1.Time{}.Unix(), OwnedBy: "default"})
+}
+
+func TestRouter_HandlerFunc_Responses(t *testing.T) {
+	var lastMessages []interface{}
+	router, _ := setupTrafficPolicyTest(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.NotContains(t, body, "input")
+		lastMessages = body["messages"].([]interface{})
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id": "chatcmpl-1", "choices": [{"index": 0, "message": {"role": "assistant", "content": "answer %d"}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 1, "completion_tokens": 2, "total_tokens": 3}}`, len(lastMessages))
+	}), nil)
+
+	serveAs := func(user, method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(method, path, bytes.NewBufferString(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		if user != "" {
+			c.Set(common.UserIdKey, user)
+		}
+		router.HandlerFunc()(c)
+		return w
+	}
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		return serveAs("alice", method, path, body)
+	}
+
+	w := serve(http.MethodPost, "/v1/responses", `{"model": "test-model", "input": "hello", "instructions": "be brief"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var first responses.Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &first))
+	assert.Equal(t, "answer 2", first.Output[0].Content[0].Text)
+	assert.Len(t, lastMessages, 2)
+
+	// The previous response rebuilds the conversation, without its instructions.
+	w = serve(http.MethodPost, "/v1/responses", fmt.Sprintf(`{"model": "test-model", "input": "more", "previous_response_id": %q}`, first.ID))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, lastMessages, 3)
+	assert.Equal(t, map[string]interface{}{"role": "assistant", "content": "answer 2"}, lastMessages[1])
+
+	w = serve(http.MethodPost, "/v1/responses", `{"model": "test-model", "input": "more", "previous_response_id": "resp_unknown"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serve(http.MethodGet, "/v1/responses/"+first.ID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var retrieved responses.Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &retrieved))
+	assert.Equal(t, first.ID, retrieved.ID)
+
+	// The responses of other users are not found, and those of models the credentials may not use are forbidden.
+	w = serveAs("bob", http.MethodGet, "/v1/responses/"+first.ID, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serveAs("", http.MethodGet, "/v1/responses/"+first.ID, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serveAs("bob", http.MethodDelete, "/v1/responses/"+first.ID, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest(http.MethodGet, "/v1/responses/"+first.ID, nil)
+	c.Set(common.UserIdKey, "alice")
+	c.Set(common.AllowedModelsKey, []string{"other-model"})
+	router.HandlerFunc()(c)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = serveAs("bob", http.MethodPost, "/v1/responses", fmt.Sprintf(`{"model": "test-model", "input": "more", "previous_response_id": %q}`, first.ID))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serve(http.MethodDelete, "/v1/responses/"+first.ID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serve(http.MethodGet, "/v1/responses/"+first.ID, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRouter_HandlerFunc_Messages(t *testing.T) {
+	router, _ := setupTrafficPolicyTest(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.NotContains(t, body, "system")
+		assert.Equal(t, map[string]interface{}{"role": "system", "content": "be brief"}, body["messages"].([]interface{})[0])
+		if stream, _ := body["stream"].(bool); stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"choices\": [{\"index\": 0, \"delta\": {\"content\": \"hi\"}, \"finish_reason\": \"stop\"}]}\n\n")
+			fmt.Fprint(w, "data: {\"choices\": [], \"usage\": {\"prompt_tokens\": 4, \"completion_tokens\": 6, \"total_tokens\": 10}}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id": "chatcmpl-1", "choices": [{"index": 0, "message": {"role": "assistant", "content": "hi"}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 4, "completion_tokens": 6, "total_tokens": 10}}`)
+	}), nil)
+
+	serve := func(body string) *connectors.TestResponseRecorder {
+		w := connectors.CreateTestResponseRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/v1/messages", bytes.NewBufferString(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		router.HandlerFunc()(c)
+		return w
+	}
+
+	w := serve(`{"model": "test-model", "max_tokens": 16, "system": "be brief", "messages": [{"role": "user", "content": "hello"}]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var msg anthropic.Message
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &msg))
+	assert.Equal(t, "hi", *msg.Content[0].Text)
+	assert.Equal(t, anthropic.StopReasonEndTurn, *msg.StopReason)
+	assert.Equal(t, anthropic.Usage{InputTokens: 4, OutputTokens: 6}, msg.Usage)
+
+	// The usage of streamed messages still reaches the token tracker.
+	w = serve(`{"model": "test-model", "max_tokens": 16, "stream": true, "userId": "alice", "system": "be brief",
+		"messages": [{"role": "user", "content": "hello"}]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "event: message_start")
+	assert.Contains(t, w.Body.String(), `"usage":{"input_tokens":4,"output_tokens":6}`)
+	assert.Contains(t, w.Body.String(), "event: message_stop")
+	tokens, err := router.store.GetTokenCount("alice", "test-model")
+	assert.NoError(t, err)
+	assert.Greater(t, tokens, float64(0))
+
+	w = serve(`{"model": "test-model", "messages": [{"role": "system", "content": "hello"}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"type": "error", "error": {"type": "invalid_request_error", "message": "message 0: unsupported role \"system\""}}`, w.Body.String())
+}
+
+func TestRequestUser(t *testing.T) {
+	tests := []struct {
+		name         string
+		user         string
+		modelRequest ModelRequest
+		want         string
+	}{
+		{name: "authenticated", user: "alice", modelRequest: ModelRequest{"userId": "bob"}, want: "alice"},
+		{name: "unauthenticated", modelRequest: ModelRequest{"userId": "bob"}, want: "bob"},
+		{name: "chat completions user", modelRequest: ModelRequest{"user": "carol"}, want: "carol"},
+		{name: "authenticated with chat completions user", user: "alice", modelRequest: ModelRequest{"user": "carol"}, want: "alice"},
+		{name: "anonymous", modelRequest: ModelRequest{}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			if tt.user != "" {
+				c.Set(common.UserIdKey, tt.user)
+			}
+			assert.Equal(t, tt.want, requestUser(c, tt.modelRequest))
+		})
 	}
-	return false, &strconv.NumError{Func: "ParseBool", Num: str, Err: strconv.ErrSyntax}
 }
@@ -0,0 +1,85 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/accesslog"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/tracing"
+)
+
+// Tracing returns the middleware starting the span of a request, as a child of the trace context
+// of its traceparent header. The span carries the routing of the request and its token counts
+// once it is served, as recorded in the access log.
+func (r *Router) Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tracer := r.runtimeConfigOf(c).tracer.Tracer(tracing.InstrumentationName)
+		ctx := tracing.Extract(c.Request.Context(), c.Request.Header)
+		ctx, span := tracer.Start(ctx, c.Request.Method+" "+c.Request.URL.Path,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.URLPath(c.Request.URL.Path),
+			))
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if accessCtx := accesslog.GetAccessLogContext(c); accessCtx != nil {
+			setRoutingAttributes(span, accessCtx)
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+func setRoutingAttributes(span trace.Span, accessCtx *accesslog.AccessLogContext) {
+	if accessCtx.ModelName != "" {
+		span.SetAttributes(semconv.GenAIRequestModel(accessCtx.ModelName))
+	}
+	if accessCtx.ModelRoute != "" {
+		span.SetAttributes(tracing.ModelRouteKey.String(accessCtx.ModelRoute))
+	}
+	if accessCtx.ModelServer != "" {
+		span.SetAttributes(tracing.ModelServerKey.String(accessCtx.ModelServer))
+	}
+	if accessCtx.SelectedPod != "" {
+		span.SetAttributes(tracing.PodKey.String(accessCtx.SelectedPod))
+	}
+	if accessCtx.Fallback > 0 {
+		span.SetAttributes(tracing.FallbackKey.Int(accessCtx.Fallback))
+	}
+	if accessCtx.InputTokens > 0 {
+		span.SetAttributes(semconv.GenAIUsageInputTokens(accessCtx.InputTokens))
+	}
+	if accessCtx.OutputTokens > 0 {
+		span.SetAttributes(semconv.GenAIUsageOutputTokens(accessCtx.OutputTokens))
+	}
+	if accessCtx.Error != nil {
+		span.SetAttributes(tracing.ErrorTypeKey.String(accessCtx.Error.Type))
+	}
+}
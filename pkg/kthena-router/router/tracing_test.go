@@ -0,0 +1,166 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/tracing"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// setupTracingTest sets up the route of setupTrafficPolicyTest, with the spans of the router
+// recorded by the returned recorder, and the traceparent headers of the upstream requests sent to
+// the returned channel.
+func setupTracingTest(t *testing.T) (*Router, *httptest.Server, *tracetest.SpanRecorder, chan string) {
+	traceparents := make(chan string, 1)
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents <- r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"id":"response-id","usage":{"prompt_tokens":3,"completion_tokens":7,"total_tokens":10}}`)
+	})
+	router, backend := setupTrafficPolicyTest(t, backendHandler, nil)
+
+	recorder := tracetest.NewSpanRecorder()
+	config := *router.config.Load()
+	config.tracer = &tracing.Provider{TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))}
+	router.config.Store(&config)
+	return router, backend, recorder, traceparents
+}
+
+func serveTracedRequest(router *Router, traceparent string) *httptest.ResponseRecorder {
+	engine := gin.New()
+	engine.Use(router.Tracing(), router.AccessLog(), router.Auth())
+	engine.Any("/v1/*path", router.HandlerFunc())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model": "test-model", "prompt": "hello"}`))
+	req.Header.Set("Content-Type", "application/json")
+	if traceparent != "" {
+		req.Header.Set("traceparent", traceparent)
+	}
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func spansByName(spans []sdktrace.ReadOnlySpan) map[string]sdktrace.ReadOnlySpan {
+	byName := make(map[string]sdktrace.ReadOnlySpan, len(spans))
+	for _, span := range spans {
+		byName[span.Name()] = span
+	}
+	return byName
+}
+
+func attributesOf(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestRouter_Tracing(t *testing.T) {
+	router, backend, recorder, traceparents := setupTracingTest(t)
+	defer backend.Close()
+
+	w := serveTracedRequest(router, testTraceparent)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	spans := spansByName(recorder.Ended())
+	root, ok := spans["POST /v1/chat/completions"]
+	require.True(t, ok)
+	// The span of the request is a child of the span of the client.
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", root.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", root.Parent().SpanID().String())
+	assert.True(t, root.Parent().IsRemote())
+	assert.Equal(t, trace.SpanKindServer, root.SpanKind())
+
+	attrs := attributesOf(root)
+	assert.Equal(t, "test-model", attrs["gen_ai.request.model"].AsString())
+	assert.Equal(t, "default/mr-1", attrs[tracing.ModelRouteKey].AsString())
+	assert.Equal(t, "default/ms-1", attrs[tracing.ModelServerKey].AsString())
+	assert.Equal(t, "pod-1", attrs[tracing.PodKey].AsString())
+	assert.Equal(t, int64(7), attrs["gen_ai.usage.output_tokens"].AsInt64())
+	assert.Equal(t, int64(http.StatusOK), attrs["http.response.status_code"].AsInt64())
+
+	for _, name := range []string{tracing.SpanAuthenticate, tracing.SpanParseRequest, tracing.SpanRateLimit, tracing.SpanSchedule, tracing.SpanUpstream} {
+		span, ok := spans[name]
+		if assert.True(t, ok, "span %s", name) {
+			assert.Equal(t, root.SpanContext().SpanID(), span.Parent().SpanID(), "parent of span %s", name)
+		}
+	}
+
+	// The plugins are children of the span of the scheduling.
+	schedule := spans[tracing.SpanSchedule]
+	plugins := 0
+	for name, span := range spans {
+		if strings.HasPrefix(name, "filter/") || strings.HasPrefix(name, "score/") {
+			plugins++
+			assert.Equal(t, schedule.SpanContext().SpanID(), span.Parent().SpanID(), "parent of span %s", name)
+		}
+	}
+	assert.NotZero(t, plugins)
+
+	// The upstream request is a child of the span of the upstream attempt.
+	upstream := spans[tracing.SpanUpstream]
+	assert.Equal(t, "pod-1", attributesOf(upstream)[tracing.PodKey].AsString())
+	expected := fmt.Sprintf("00-%s-%s-01", upstream.SpanContext().TraceID(), upstream.SpanContext().SpanID())
+	assert.Equal(t, expected, <-traceparents)
+}
+
+func TestRouter_Tracing_NewTrace(t *testing.T) {
+	router, backend, recorder, traceparents := setupTracingTest(t)
+	defer backend.Close()
+
+	w := serveTracedRequest(router, "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	root := spansByName(recorder.Ended())["POST /v1/chat/completions"]
+	require.NotNil(t, root)
+	assert.False(t, root.Parent().IsValid())
+	assert.Contains(t, <-traceparents, root.SpanContext().TraceID().String())
+}
+
+func TestRouter_Tracing_Disabled(t *testing.T) {
+	traceparents := make(chan string, 1)
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents <- r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"id":"response-id"}`)
+	})
+	router, backend := setupTrafficPolicyTest(t, backendHandler, nil)
+	defer backend.Close()
+
+	w := serveTracedRequest(router, testTraceparent)
+	require.Equal(t, http.StatusOK, w.Code)
+	// The trace context of the client is still propagated to the model server.
+	assert.Equal(t, testTraceparent, <-traceparents)
+}
@@ -0,0 +1,205 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/accesslog"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/connectors"
+)
+
+const (
+	defaultRetryInterval = 100 * time.Millisecond
+	// maxRetryBackoff caps the exponential backoff between two retries.
+	maxRetryBackoff = 10 * time.Second
+)
+
+// trafficPolicy is the resolved ModelServer TrafficPolicy applied when proxying a request.
+type trafficPolicy struct {
+	// timeout is the deadline of the whole request including all retries, 0 means no timeout.
+	timeout time.Duration
+	// retry is false when no retry policy is configured.
+	retry         bool
+	retryAttempts int
+	retryInterval time.Duration
+}
+
+func newTrafficPolicy(modelServer *v1alpha1.ModelServer) trafficPolicy {
+	var policy trafficPolicy
+	if modelServer == nil || modelServer.Spec.TrafficPolicy == nil {
+		return policy
+	}
+
+	tp := modelServer.Spec.TrafficPolicy
+	if tp.Timeout != nil && tp.Timeout.Duration > 0 {
+		policy.timeout = tp.Timeout.Duration
+	}
+	if tp.Retry != nil {
+		policy.retry = true
+		policy.retryAttempts = int(tp.Retry.Attempts)
+		if policy.retryAttempts < 0 {
+			policy.retryAttempts = 0
+		}
+		policy.retryInterval = defaultRetryInterval
+		if tp.Retry.RetryInterval != nil && tp.Retry.RetryInterval.Duration > 0 {
+			policy.retryInterval = tp.Retry.RetryInterval.Duration
+		}
+	}
+	return policy
+}
+
+// getTrafficPolicy returns the traffic policy of the given model server.
+// InferencePool backends have no model server and get the zero policy.
+func (r *Router) getTrafficPolicy(modelServerName types.NamespacedName) trafficPolicy {
+	return newTrafficPolicy(r.getModelServerByName(modelServerName))
+}
+
+// getModelServerByName returns the model server, or nil for InferencePool backends which have none.
+func (r *Router) getModelServerByName(modelServerName types.NamespacedName) *v1alpha1.ModelServer {
+	if modelServerName.Name == "" {
+		return nil
+	}
+	return r.store.GetModelServer(modelServerName)
+}
+
+// maxAttempts returns how many upstream attempts may be made for a request with the given number of candidates.
+// Without a retry policy every candidate is tried once. With a retry policy the first attempt is followed by at
+// most retryAttempts retries, cycling through the candidates.
+func (p trafficPolicy) maxAttempts(candidates int) int {
+	if candidates == 0 {
+		return 0
+	}
+	if !p.retry {
+		return candidates
+	}
+	return p.retryAttempts + 1
+}
+
+// backoff returns how long to wait before the n-th retry (starting from 1).
+// The wait starts at retryInterval and doubles on every retry, up to maxRetryBackoff.
+// Without a retry policy the next candidate is tried immediately.
+func (p trafficPolicy) backoff(n int) time.Duration {
+	if !p.retry || n <= 0 {
+		return 0
+	}
+	d := p.retryInterval
+	for i := 1; i < n; i++ {
+		d *= 2
+		if d >= maxRetryBackoff {
+			return maxRetryBackoff
+		}
+	}
+	return d
+}
+
+// waitForRetry waits for d, and returns the context error if the request is cancelled or times out meanwhile.
+func waitForRetry(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// isRetryableError reports whether a failed upstream attempt can be retried.
+// Only failures that happened before anything was written downstream are retried, i.e. connection errors,
+// 5xx responses and errors before the first streamed byte. 4xx responses are not retried, because another pod
+// would reject the request the same way, and neither are requests that timed out or whose client went away.
+func isRetryableError(c *gin.Context, err error) bool {
+	if err == nil || c.Writer.Written() {
+		return false
+	}
+	if c.Request.Context().Err() != nil || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var statusErr *connectors.UpstreamStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// upstreamStatus returns the status of an upstream attempt used in metrics and the access log:
+// the HTTP status code of the response, or an error kind when no response was received.
+func upstreamStatus(c *gin.Context, err error) (string, int) {
+	if err == nil {
+		return strconv.Itoa(c.Writer.Status()), c.Writer.Status()
+	}
+	var statusErr *connectors.UpstreamStatusError
+	if errors.As(err, &statusErr) {
+		return strconv.Itoa(statusErr.StatusCode), statusErr.StatusCode
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+		return "timeout", 0
+	}
+	if errors.Is(err, context.Canceled) {
+		return "cancelled", 0
+	}
+	return "error", 0
+}
+
+// recordUpstreamAttempt records an upstream attempt in metrics and the access log.
+func (r *Router) recordUpstreamAttempt(c *gin.Context, modelServerName, modelRouteName, pod string, attempt int, start time.Time, err error) {
+	status, statusCode := upstreamStatus(c, err)
+	r.metrics.RecordUpstreamAttempt(modelServerName, modelRouteName, status, attempt > 0)
+
+	entry := accesslog.UpstreamAttempt{
+		Pod:        pod,
+		StatusCode: statusCode,
+		Duration:   time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	accesslog.AddUpstreamAttempt(c, entry)
+}
+
+// abortWithUpstreamError responds to the client after the request could not be served by any upstream attempt.
+// A request that ran out of time gets 504, an upstream 4xx is returned with its status code and anything else gets
+// defaultCode. Nothing is written if the response has already started streaming.
+func abortWithUpstreamError(c *gin.Context, err error, defaultCode int, msg string) {
+	if c.Writer.Written() {
+		c.Abort()
+		return
+	}
+	if errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) {
+		accesslog.SetError(c, "upstream_timeout", "request timed out")
+		c.AbortWithStatusJSON(http.StatusGatewayTimeout, "request timed out")
+		return
+	}
+	var statusErr *connectors.UpstreamStatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode >= http.StatusBadRequest && statusErr.StatusCode < http.StatusInternalServerError {
+		c.AbortWithStatusJSON(statusErr.StatusCode, msg)
+		return
+	}
+	c.AbortWithStatusJSON(defaultCode, msg)
+}
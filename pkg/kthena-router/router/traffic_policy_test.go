@@ -0,0 +1,192 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"istio.io/istio/pkg/util/sets"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	aiv1alpha1 "github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/connectors"
+)
+
+func TestTrafficPolicyBackoff(t *testing.T) {
+	policy := newTrafficPolicy(&aiv1alpha1.ModelServer{
+		Spec: aiv1alpha1.ModelServerSpec{
+			TrafficPolicy: &aiv1alpha1.TrafficPolicy{
+				Retry: &aiv1alpha1.Retry{Attempts: 3},
+			},
+		},
+	})
+
+	assert.Equal(t, 4, policy.maxAttempts(1))
+	assert.Equal(t, 100*time.Millisecond, policy.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, policy.backoff(2))
+	assert.Equal(t, 400*time.Millisecond, policy.backoff(3))
+	assert.Equal(t, maxRetryBackoff, policy.backoff(20))
+
+	// Without a retry policy every candidate is tried once without waiting.
+	noRetry := newTrafficPolicy(nil)
+	assert.Equal(t, 3, noRetry.maxAttempts(3))
+	assert.Equal(t, time.Duration(0), noRetry.backoff(1))
+}
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "connect error", err: errors.New("connection refused"), want: true},
+		{name: "5xx", err: &connectors.UpstreamStatusError{Phase: "decode", StatusCode: 503}, want: true},
+		{name: "4xx", err: &connectors.UpstreamStatusError{Phase: "decode", StatusCode: 400}, want: false},
+		{name: "wrapped 4xx", err: fmt.Errorf("decode request error: %w", &connectors.UpstreamStatusError{Phase: "decode", StatusCode: 429}), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request, _ = http.NewRequest("POST", "/v1/completions", nil)
+			assert.Equal(t, tt.want, isRetryableError(c, tt.err))
+		})
+	}
+}
+
+// setupTrafficPolicyTest registers a single aggregated model server with the given traffic policy.
+func setupTrafficPolicyTest(t *testing.T, backendHandler http.Handler, policy *aiv1alpha1.TrafficPolicy) (*Router, *httptest.Server) {
+	router, store, backend := setupTestRouter(t, backendHandler)
+
+	backendURL, _ := url.Parse(backend.URL)
+	backendPort, _ := strconv.Atoi(backendURL.Port())
+
+	modelServer := &aiv1alpha1.ModelServer{
+		ObjectMeta: v1.ObjectMeta{Name: "ms-1", Namespace: "default"},
+		Spec: aiv1alpha1.ModelServerSpec{
+			Model:           func(s string) *string { return &s }("test-model-base"),
+			WorkloadPort:    aiv1alpha1.WorkloadPort{Port: int32(backendPort)},
+			InferenceEngine: "vLLM",
+			TrafficPolicy:   policy,
+		},
+	}
+	pod1 := &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{Name: "pod-1", Namespace: "default"},
+		Status:     corev1.PodStatus{PodIP: backendURL.Hostname(), Phase: corev1.PodRunning},
+	}
+	modelRoute := &aiv1alpha1.ModelRoute{
+		ObjectMeta: v1.ObjectMeta{Name: "mr-1", Namespace: "default"},
+		Spec: aiv1alpha1.ModelRouteSpec{
+			ModelName: "test-model",
+			Rules: []*aiv1alpha1.Rule{
+				{
+					TargetModels: []*aiv1alpha1.TargetModel{
+						{ModelServerName: "ms-1"},
+					},
+				},
+			},
+		},
+	}
+
+	store.AddOrUpdateModelServer(modelServer, sets.New(types.NamespacedName{Name: "pod-1", Namespace: "default"}))
+	store.AddOrUpdatePod(pod1, []*aiv1alpha1.ModelServer{modelServer})
+	store.AddOrUpdateModelRoute(modelRoute)
+
+	return router, backend
+}
+
+func serveTrafficPolicyRequest(router *Router) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model": "test-model", "prompt": "hello"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	router.HandlerFunc()(c)
+	return w
+}
+
+func TestRouter_TrafficPolicy_RetryOn5xx(t *testing.T) {
+	var calls atomic.Int32
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"id":"response-id"}`)
+	})
+	router, backend := setupTrafficPolicyTest(t, backendHandler, &aiv1alpha1.TrafficPolicy{
+		Retry: &aiv1alpha1.Retry{Attempts: 2, RetryInterval: &v1.Duration{Duration: time.Millisecond}},
+	})
+	defer backend.Close()
+
+	w := serveTrafficPolicyRequest(router)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"response-id"`)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestRouter_TrafficPolicy_NoRetryOn4xx(t *testing.T) {
+	var calls atomic.Int32
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	router, backend := setupTrafficPolicyTest(t, backendHandler, &aiv1alpha1.TrafficPolicy{
+		Retry: &aiv1alpha1.Retry{Attempts: 2, RetryInterval: &v1.Duration{Duration: time.Millisecond}},
+	})
+	defer backend.Close()
+
+	w := serveTrafficPolicyRequest(router)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestRouter_TrafficPolicy_Timeout(t *testing.T) {
+	done := make(chan struct{})
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	})
+	router, backend := setupTrafficPolicyTest(t, backendHandler, &aiv1alpha1.TrafficPolicy{
+		Timeout: &v1.Duration{Duration: 50 * time.Millisecond},
+		Retry:   &aiv1alpha1.Retry{Attempts: 3},
+	})
+	defer backend.Close()
+	defer close(done)
+
+	start := time.Now()
+	w := serveTrafficPolicyRequest(router)
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
@@ -0,0 +1,174 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	"github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/connectors"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/datastore"
+)
+
+const (
+	protocolHTTPS = "https"
+	// caBundleKey is the key of the CA bundle in the Secret referenced by WorkloadTLS.CASecretName.
+	caBundleKey = "ca.crt"
+)
+
+// upstreamCache caches the upstreams of the model servers accessed over https.
+// Building a TLS transport parses certificates and drops the connection pool, so it is only
+// rebuilt when the ModelServer or one of its Secrets changes.
+type upstreamCache struct {
+	store datastore.Store
+
+	mutex   sync.Mutex
+	entries map[types.NamespacedName]*upstreamEntry
+}
+
+type upstreamEntry struct {
+	// version is derived from the resource versions of the ModelServer and the referenced Secrets.
+	version  string
+	upstream *connectors.Upstream
+}
+
+func newUpstreamCache(store datastore.Store) *upstreamCache {
+	return &upstreamCache{
+		store:   store,
+		entries: make(map[types.NamespacedName]*upstreamEntry),
+	}
+}
+
+// Get returns the upstream used to connect to the pods of the model server.
+// Model servers using plain http share the default upstream.
+func (u *upstreamCache) Get(modelServer *v1alpha1.ModelServer) (*connectors.Upstream, error) {
+	if modelServer == nil || modelServer.Spec.WorkloadPort.Protocol != protocolHTTPS {
+		return connectors.DefaultUpstream(), nil
+	}
+
+	name := types.NamespacedName{Namespace: modelServer.Namespace, Name: modelServer.Name}
+	tlsSpec := modelServer.Spec.WorkloadPort.TLS
+	if tlsSpec == nil {
+		tlsSpec = &v1alpha1.WorkloadTLS{}
+	}
+
+	caSecret, err := u.getSecret(modelServer.Namespace, tlsSpec.CASecretName)
+	if err != nil {
+		return nil, err
+	}
+	clientSecret, err := u.getSecret(modelServer.Namespace, tlsSpec.ClientCertificateSecretName)
+	if err != nil {
+		return nil, err
+	}
+	version := fmt.Sprintf("%s/%s/%s", modelServer.ResourceVersion, resourceVersion(caSecret), resourceVersion(clientSecret))
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	entry, ok := u.entries[name]
+	if ok && entry.version == version {
+		return entry.upstream, nil
+	}
+
+	tlsConfig, err := buildUpstreamTLSConfig(tlsSpec, caSecret, clientSecret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tls config of model server %s: %w", name, err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	if ok {
+		if old, isTransport := entry.upstream.Transport.(*http.Transport); isTransport {
+			old.CloseIdleConnections()
+		}
+	}
+	klog.V(4).Infof("build https upstream for model server %s", name)
+	upstream := &connectors.Upstream{
+		Scheme:    protocolHTTPS,
+		Transport: transport,
+	}
+	u.entries[name] = &upstreamEntry{version: version, upstream: upstream}
+	return upstream, nil
+}
+
+// Delete drops the upstream of the deleted model server and closes its idle connections.
+func (u *upstreamCache) Delete(name types.NamespacedName) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	entry, ok := u.entries[name]
+	if !ok {
+		return
+	}
+	if transport, isTransport := entry.upstream.Transport.(*http.Transport); isTransport {
+		transport.CloseIdleConnections()
+	}
+	delete(u.entries, name)
+	klog.V(4).Infof("drop https upstream of deleted model server %s", name)
+}
+
+func (u *upstreamCache) getSecret(namespace, name string) (*corev1.Secret, error) {
+	if name == "" {
+		return nil, nil
+	}
+	secret := u.store.GetSecret(types.NamespacedName{Namespace: namespace, Name: name})
+	if secret == nil {
+		return nil, fmt.Errorf("secret %s/%s not found", namespace, name)
+	}
+	return secret, nil
+}
+
+func resourceVersion(secret *corev1.Secret) string {
+	if secret == nil {
+		return ""
+	}
+	return secret.ResourceVersion
+}
+
+func buildUpstreamTLSConfig(tlsSpec *v1alpha1.WorkloadTLS, caSecret, clientSecret *corev1.Secret) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         tlsSpec.ServerName,
+		InsecureSkipVerify: tlsSpec.InsecureSkipVerify,
+	}
+
+	if caSecret != nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caSecret.Data[caBundleKey]) {
+			return nil, fmt.Errorf("no valid certificate found under %q in secret %s/%s", caBundleKey, caSecret.Namespace, caSecret.Name)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if clientSecret != nil {
+		cert, err := tls.X509KeyPair(clientSecret.Data[corev1.TLSCertKey], clientSecret.Data[corev1.TLSPrivateKeyKey])
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate from secret %s/%s: %w", clientSecret.Namespace, clientSecret.Name, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
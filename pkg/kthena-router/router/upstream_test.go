@@ -0,0 +1,178 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"bytes"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"istio.io/istio/pkg/util/sets"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	aiv1alpha1 "github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/datastore"
+)
+
+func TestBuildUpstreamTLSConfig(t *testing.T) {
+	caSecret := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{Name: "ca", Namespace: "default"},
+		Data:       map[string][]byte{caBundleKey: []byte("not a certificate")},
+	}
+	_, err := buildUpstreamTLSConfig(&aiv1alpha1.WorkloadTLS{}, caSecret, nil)
+	assert.Error(t, err)
+
+	clientSecret := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{Name: "client", Namespace: "default"},
+		Data:       map[string][]byte{},
+	}
+	_, err = buildUpstreamTLSConfig(&aiv1alpha1.WorkloadTLS{}, nil, clientSecret)
+	assert.Error(t, err)
+
+	tlsConfig, err := buildUpstreamTLSConfig(&aiv1alpha1.WorkloadTLS{ServerName: "model.example.com"}, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "model.example.com", tlsConfig.ServerName)
+	assert.Nil(t, tlsConfig.RootCAs)
+}
+
+func setupHTTPSModelServer(t *testing.T, backend *httptest.Server, store datastore.Store, tlsSpec *aiv1alpha1.WorkloadTLS) {
+	backendURL, _ := url.Parse(backend.URL)
+	backendPort, _ := strconv.Atoi(backendURL.Port())
+
+	modelServer := &aiv1alpha1.ModelServer{
+		ObjectMeta: v1.ObjectMeta{Name: "ms-1", Namespace: "default", ResourceVersion: "1"},
+		Spec: aiv1alpha1.ModelServerSpec{
+			Model: func(s string) *string { return &s }("test-model-base"),
+			WorkloadPort: aiv1alpha1.WorkloadPort{
+				Port:     int32(backendPort),
+				Protocol: "https",
+				TLS:      tlsSpec,
+			},
+			InferenceEngine: "vLLM",
+		},
+	}
+	pod1 := &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{Name: "pod-1", Namespace: "default"},
+		Status:     corev1.PodStatus{PodIP: backendURL.Hostname(), Phase: corev1.PodRunning},
+	}
+	modelRoute := &aiv1alpha1.ModelRoute{
+		ObjectMeta: v1.ObjectMeta{Name: "mr-1", Namespace: "default"},
+		Spec: aiv1alpha1.ModelRouteSpec{
+			ModelName: "test-model",
+			Rules: []*aiv1alpha1.Rule{
+				{
+					TargetModels: []*aiv1alpha1.TargetModel{
+						{ModelServerName: "ms-1"},
+					},
+				},
+			},
+		},
+	}
+
+	store.AddOrUpdateModelServer(modelServer, sets.New(types.NamespacedName{Name: "pod-1", Namespace: "default"}))
+	store.AddOrUpdatePod(pod1, []*aiv1alpha1.ModelServer{modelServer})
+	store.AddOrUpdateModelRoute(modelRoute)
+}
+
+func TestRouter_HandlerFunc_HTTPSUpstream(t *testing.T) {
+	backendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NotNil(t, r.TLS)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"id":"response-id"}`)
+	})
+	backend := httptest.NewTLSServer(backendHandler)
+	defer backend.Close()
+
+	tests := []struct {
+		name     string
+		secrets  []*corev1.Secret
+		wantCode int
+	}{
+		{
+			name: "verified with ca bundle",
+			secrets: []*corev1.Secret{{
+				ObjectMeta: v1.ObjectMeta{Name: "model-ca", Namespace: "default", ResourceVersion: "1"},
+				Data: map[string][]byte{
+					caBundleKey: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: backend.Certificate().Raw}),
+				},
+			}},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "ca secret not found",
+			wantCode: http.StatusBadGateway,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := datastore.New()
+			router := NewRouter(store, "../scheduler/testdata/configmap.yaml")
+			for _, secret := range tt.secrets {
+				assert.NoError(t, store.AddOrUpdateSecret(secret))
+			}
+			setupHTTPSModelServer(t, backend, store, &aiv1alpha1.WorkloadTLS{CASecretName: "model-ca"})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model": "test-model", "prompt": "hello"}`))
+			c.Request.Header.Set("Content-Type", "application/json")
+			router.HandlerFunc()(c)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.NotContains(t, w.Body.String(), "model-ca")
+		})
+	}
+}
+
+func TestUpstreamCache_DeleteModelServer(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	store := datastore.New()
+	router := NewRouter(store, "../scheduler/testdata/configmap.yaml")
+	assert.NoError(t, store.AddOrUpdateSecret(&corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{Name: "model-ca", Namespace: "default", ResourceVersion: "1"},
+		Data: map[string][]byte{
+			caBundleKey: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: backend.Certificate().Raw}),
+		},
+	}))
+	setupHTTPSModelServer(t, backend, store, &aiv1alpha1.WorkloadTLS{CASecretName: "model-ca"})
+
+	name := types.NamespacedName{Namespace: "default", Name: "ms-1"}
+	_, err := router.upstreams.Get(store.GetModelServer(name))
+	assert.NoError(t, err)
+	assert.Contains(t, router.upstreams.entries, name)
+
+	assert.NoError(t, store.DeleteModelServer(name))
+	assert.Eventually(t, func() bool {
+		router.upstreams.mutex.Lock()
+		defer router.upstreams.mutex.Unlock()
+		_, ok := router.upstreams.entries[name]
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,148 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/datastore"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/framework"
+)
+
+// Explanation is how the scheduler filters and scores the pods of a request.
+type Explanation struct {
+	// Profile is the scheduler profile of the model server, empty for the default profile.
+	Profile string         `json:"profile,omitempty"`
+	Filters []FilterResult `json:"filters"`
+	Scores  []ScoreResult  `json:"scores"`
+	// Ranking is the pods passing the filters by their final score, best first.
+	Ranking []PodScore `json:"ranking"`
+	// Error is why the request cannot be scheduled.
+	Error string `json:"error,omitempty"`
+}
+
+// FilterResult is the pods dropped by a filter plugin.
+type FilterResult struct {
+	Plugin  string   `json:"plugin"`
+	Dropped []string `json:"dropped"`
+}
+
+// ScoreResult is the scores of the pods by a score plugin.
+type ScoreResult struct {
+	Plugin string `json:"plugin"`
+	Weight int    `json:"weight"`
+	// Scores are the scores of the plugin, within [0, 100], and WeightedScores are the scores
+	// multiplied by the weight.
+	Scores         map[string]int `json:"scores"`
+	WeightedScores map[string]int `json:"weightedScores"`
+}
+
+// PodScore is the final score of a pod.
+type PodScore struct {
+	Pod   string `json:"pod"`
+	Score int    `json:"score"`
+}
+
+func podName(pod *datastore.PodInfo) string {
+	if pod.Pod == nil {
+		return ""
+	}
+	return pod.Pod.Namespace + "/" + pod.Pod.Name
+}
+
+// Explain runs the filter and score plugins of the profile of the request like Schedule, without
+// running the post schedule hooks. For a PD disaggregated model server, the decode pods are
+// filtered and scored, since the prefill pods are paired with the best of them.
+func (s *SchedulerImpl) Explain(ctx *framework.Context, pods []*datastore.PodInfo) *Explanation {
+	ctx.Profile = s.selectProfile(ctx)
+	profile := s.profileOf(ctx)
+	explanation := &Explanation{
+		Profile: ctx.Profile,
+		Filters: []FilterResult{},
+		Scores:  []ScoreResult{},
+		Ranking: []PodScore{},
+	}
+
+	if ctx.PDGroup != nil {
+		decodePods, err := s.store.GetDecodePods(ctx.ModelServerName)
+		if err != nil {
+			explanation.Error = fmt.Sprintf("failed to get decode pods: %v", err)
+			return explanation
+		}
+		if len(decodePods) == 0 {
+			explanation.Error = "no decode pod found"
+			return explanation
+		}
+		pods = decodePods
+	}
+
+	for _, filterPlugin := range profile.filterPlugins {
+		// The filters may filter the pods in place.
+		remaining := filterPlugin.Filter(ctx, slices.Clone(pods))
+		kept := make(map[*datastore.PodInfo]bool, len(remaining))
+		for _, pod := range remaining {
+			kept[pod] = true
+		}
+		dropped := []string{}
+		for _, pod := range pods {
+			if !kept[pod] {
+				dropped = append(dropped, podName(pod))
+			}
+		}
+		explanation.Filters = append(explanation.Filters, FilterResult{Plugin: filterPlugin.Name(), Dropped: dropped})
+		if len(remaining) == 0 {
+			explanation.Error = fmt.Sprintf("pods have all been filtered out by %q", filterPlugin.Name())
+			return explanation
+		}
+		pods = remaining
+	}
+
+	total := make(map[*datastore.PodInfo]int, len(pods))
+	for _, pod := range pods {
+		total[pod] = 0
+	}
+	for _, scorePlugin := range profile.scorePlugins {
+		result := ScoreResult{
+			Plugin:         scorePlugin.plugin.Name(),
+			Weight:         scorePlugin.weight,
+			Scores:         map[string]int{},
+			WeightedScores: map[string]int{},
+		}
+		for pod, score := range scorePlugin.plugin.Score(ctx, pods) {
+			result.Scores[podName(pod)] = score
+			result.WeightedScores[podName(pod)] = score * scorePlugin.weight
+			total[pod] += score * scorePlugin.weight
+		}
+		explanation.Scores = append(explanation.Scores, result)
+	}
+	sort.Slice(explanation.Scores, func(i, j int) bool {
+		return explanation.Scores[i].Plugin < explanation.Scores[j].Plugin
+	})
+
+	for pod, score := range total {
+		explanation.Ranking = append(explanation.Ranking, PodScore{Pod: podName(pod), Score: score})
+	}
+	sort.Slice(explanation.Ranking, func(i, j int) bool {
+		if explanation.Ranking[i].Score != explanation.Ranking[j].Score {
+			return explanation.Ranking[i].Score > explanation.Ranking[j].Score
+		}
+		return explanation.Ranking[i].Pod < explanation.Ranking[j].Pod
+	})
+	return explanation
+}
@@ -0,0 +1,98 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	aiv1alpha1 "github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/datastore"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/framework"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
+)
+
+func TestExplain(t *testing.T) {
+	routerConfig, err := conf.ParseRouterConfigData([]byte(`
+scheduler:
+  pluginConfig:
+  - name: least-request
+    args:
+      maxWaitingRequests: 10
+  plugins:
+    Filter:
+      enabled:
+        - least-request
+    Score:
+      enabled:
+        - name: least-request
+          weight: 2
+`))
+	require.NoError(t, err)
+	store := datastore.New()
+	scheduler, err := BuildScheduler(store, routerConfig, nil)
+	require.NoError(t, err)
+
+	modelServerName := types.NamespacedName{Namespace: "default", Name: "ms-1"}
+	modelServer := &aiv1alpha1.ModelServer{ObjectMeta: metav1.ObjectMeta{Name: "ms-1", Namespace: "default"}}
+	require.NoError(t, store.AddOrUpdateModelServer(modelServer, nil))
+	for _, name := range []string{"pod-1", "pod-2", "pod-3"} {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+		require.NoError(t, store.AddOrUpdatePod(pod, []*aiv1alpha1.ModelServer{modelServer}))
+	}
+	pods, err := store.GetPodsByModelServer(modelServerName)
+	require.NoError(t, err)
+	for _, pod := range pods {
+		switch pod.Pod.Name {
+		case "pod-1":
+			pod.RequestWaitingNum = 20
+		case "pod-2":
+			pod.RequestRunningNum = 5
+		}
+	}
+
+	explanation := scheduler.Explain(&framework.Context{Model: "test-model", ModelServerName: modelServerName}, pods)
+
+	assert.Empty(t, explanation.Error)
+	assert.Equal(t, []FilterResult{{Plugin: "least-request", Dropped: []string{"default/pod-1"}}}, explanation.Filters)
+	require.Len(t, explanation.Scores, 1)
+	score := explanation.Scores[0]
+	assert.Equal(t, "least-request", score.Plugin)
+	assert.Equal(t, 2, score.Weight)
+	assert.Len(t, score.Scores, 2)
+	for pod, raw := range score.Scores {
+		assert.Equal(t, 2*raw, score.WeightedScores[pod])
+	}
+	require.Len(t, explanation.Ranking, 2)
+	assert.Equal(t, "default/pod-3", explanation.Ranking[0].Pod)
+	assert.Equal(t, "default/pod-2", explanation.Ranking[1].Pod)
+	assert.Greater(t, explanation.Ranking[0].Score, explanation.Ranking[1].Score)
+
+	// The pods passed in are left as they are.
+	assert.Len(t, pods, 3)
+	for _, pod := range pods {
+		pod.RequestWaitingNum = 20
+	}
+	explanation = scheduler.Explain(&framework.Context{Model: "test-model", ModelServerName: modelServerName}, pods)
+	assert.Equal(t, `pods have all been filtered out by "least-request"`, explanation.Error)
+	assert.Empty(t, explanation.Ranking)
+}
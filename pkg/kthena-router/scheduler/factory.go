@@ -17,6 +17,8 @@ limitations under the License.
 package scheduler
 
 import (
+	"fmt"
+
 	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/framework"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -85,6 +87,9 @@ func registerDefaultPlugins(registry *PluginRegistry) {
 	registry.registerScorePlugin(plugins.KVCacheAwarePluginName, func(args runtime.RawExtension) framework.ScorePlugin {
 		return plugins.NewKVCacheAware(args)
 	})
+	registry.registerScorePlugin(plugins.SessionAffinityPluginName, func(args runtime.RawExtension) framework.ScorePlugin {
+		return plugins.NewSessionAffinity(args)
+	})
 	// filterPlugin
 	registry.registerFilterPlugin(plugins.LeastRequestPluginName, func(args runtime.RawExtension) framework.FilterPlugin {
 		return plugins.NewLeastRequest(args)
@@ -92,6 +97,24 @@ func registerDefaultPlugins(registry *PluginRegistry) {
 	registry.registerFilterPlugin(plugins.LoraAffinityPluginName, func(args runtime.RawExtension) framework.FilterPlugin {
 		return plugins.NewLoraAffinity()
 	})
+	registry.registerFilterPlugin(plugins.OutlierDetectionPluginName, func(args runtime.RawExtension) framework.FilterPlugin {
+		return plugins.NewOutlierDetection()
+	})
+}
+
+// validatePlugins returns an error if a configured plugin is not registered.
+func validatePlugins(registry *PluginRegistry, scorePluginMap map[string]int, filterPluginMap []string) error {
+	for _, pluginName := range filterPluginMap {
+		if _, exist := registry.getFilterPlugin(pluginName); !exist {
+			return fmt.Errorf("unknown filter plugin %s", pluginName)
+		}
+	}
+	for pluginName := range scorePluginMap {
+		if _, exist := registry.getScorePlugin(pluginName); !exist {
+			return fmt.Errorf("unknown score plugin %s", pluginName)
+		}
+	}
+	return nil
 }
 
 func getFilterPlugins(registry *PluginRegistry, filterPluginMap []string, pluginsArgMap map[string]runtime.RawExtension) []framework.FilterPlugin {
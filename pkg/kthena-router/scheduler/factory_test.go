@@ -53,6 +53,7 @@ func TestRegisterDefaultPlugins(t *testing.T) {
 		plugins.RandomPluginName,
 		plugins.PrefixCachePluginName,
 		plugins.KVCacheAwarePluginName,
+		plugins.SessionAffinityPluginName,
 	}
 
 	for _, pluginName := range expectedScorePlugins {
@@ -75,6 +76,7 @@ func TestRegisterDefaultPlugins(t *testing.T) {
 	expectedFilterPlugins := []string{
 		plugins.LeastRequestPluginName,
 		plugins.LoraAffinityPluginName,
+		plugins.OutlierDetectionPluginName,
 	}
 
 	for _, pluginName := range expectedFilterPlugins {
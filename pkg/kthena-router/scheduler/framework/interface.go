@@ -17,6 +17,9 @@ limitations under the License.
 package framework
 
 import (
+	"context"
+	"net/http"
+
 	"k8s.io/apimachinery/pkg/types"
 
 	aiv1alpha1 "github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
@@ -31,10 +34,18 @@ type Context struct {
 	Prompt common.ChatMessage
 
 	Hashes []uint64
+	// MediaHashes are the hashes of the image, audio and video inputs of a multimodal prompt.
+	MediaHashes []uint64
+
+	// Headers and Body of the request, for the plugins keyed on the caller, such as session affinity.
+	Headers http.Header
+	Body    map[string]interface{}
 
 	// ModelServer information for efficient PDGroup scheduling
 	ModelServerName types.NamespacedName
 	PDGroup         *aiv1alpha1.PDGroup
+	// Profile is the scheduler profile selected for the model server, empty for the default one.
+	Profile string
 	// 1. In PD Disaggregated mode, both DecodePods and PrefillPods are set.
 	DecodePods  []*datastore.PodInfo
 	PrefillPods []*datastore.PodInfo
@@ -44,6 +55,9 @@ type Context struct {
 
 	// MetricsRecorder for recording scheduler plugin metrics
 	MetricsRecorder *metrics.RequestMetricsRecorder
+	// TraceContext carries the span of the scheduling, whose children are the spans of the plugins.
+	// It is nil if the request is not traced.
+	TraceContext context.Context
 }
 
 type ScorePlugin interface {
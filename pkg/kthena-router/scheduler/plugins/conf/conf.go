@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/yaml"
@@ -28,11 +29,38 @@ import (
 type RouterConfiguration struct {
 	Scheduler SchedulerConfiguration `yaml:"scheduler"`
 	Auth      AuthenticationConfig   `yaml:"auth"`
+	Tokenizer TokenizerConfiguration `yaml:"tokenizer"`
+	Tracing   TracingConfiguration   `yaml:"tracing"`
+	AccessLog AccessLogConfiguration `yaml:"accessLog"`
 }
 
 type SchedulerConfiguration struct {
 	PluginConfig []PluginConfig `yaml:"pluginConfig"`
 	Plugins      Plugins        `yaml:"plugins"`
+	// Profiles are named sets of plugins, selected by the ModelServers annotated with
+	// networking.serving.volcano.sh/scheduler-profile. The other ModelServers are scheduled with
+	// the plugins above.
+	Profiles []SchedulerProfile `yaml:"profiles"`
+}
+
+// SchedulerProfile is a named set of plugins. The args of its plugins default to the pluginConfig
+// of the scheduler.
+type SchedulerProfile struct {
+	Name         string         `yaml:"name"`
+	PluginConfig []PluginConfig `yaml:"pluginConfig"`
+	Plugins      Plugins        `yaml:"plugins"`
+}
+
+// ProfileConfiguration returns the scheduler configuration of the profile, with the pluginConfig
+// of the profile overriding that of the scheduler.
+func (c *SchedulerConfiguration) ProfileConfiguration(profile *SchedulerProfile) *SchedulerConfiguration {
+	pluginConfig := make([]PluginConfig, 0, len(c.PluginConfig)+len(profile.PluginConfig))
+	pluginConfig = append(pluginConfig, c.PluginConfig...)
+	pluginConfig = append(pluginConfig, profile.PluginConfig...)
+	return &SchedulerConfiguration{
+		PluginConfig: pluginConfig,
+		Plugins:      profile.Plugins,
+	}
 }
 
 type Plugins struct {
@@ -61,9 +89,215 @@ type PluginConfig struct {
 }
 
 type AuthenticationConfig struct {
+	// Issuer, Audiences and JwksUri configure a single JWT issuer, in addition to Issuers.
+	Issuer    string   `yaml:"issuer"`
+	Audiences []string `yaml:"audiences"`
+	JwksUri   string   `yaml:"jwksUri"`
+	// Issuers are the JWT issuers trusted by the router. The issuer of a token is selected by its
+	// iss claim.
+	Issuers []JWTIssuerConfig `yaml:"issuers"`
+	// Authorization restricts the models each authenticated caller may use.
+	Authorization AuthorizationConfig `yaml:"authorization"`
+	// APIKey configures the authentication with the API keys stored in Secrets.
+	APIKey APIKeyConfig `yaml:"apiKey"`
+}
+
+// APIKeyConfig configures the authentication with API keys. The keys are stored hashed in the
+// Secrets labelled networking.serving.volcano.sh/api-key=true.
+type APIKeyConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Namespaces are the namespaces the API key Secrets are accepted from, in addition to the
+	// namespace of the router.
+	Namespaces []string `yaml:"namespaces"`
+}
+
+// JWTIssuerConfig configures a JWT issuer.
+type JWTIssuerConfig struct {
 	Issuer    string   `yaml:"issuer"`
 	Audiences []string `yaml:"audiences"`
 	JwksUri   string   `yaml:"jwksUri"`
+	// RefreshInterval is the interval the JWKS is fetched at, 7 days by default.
+	RefreshInterval metav1.Duration `yaml:"refreshInterval"`
+	// UserClaim is the claim identifying the user of a token, sub by default.
+	UserClaim string `yaml:"userClaim"`
+	// ClaimMappings set claims of the tokens from other claims, e.g. tenant from org_id, so that
+	// authorization rules and rate limits apply to the tokens of all issuers alike. A nested claim
+	// is named by its path.
+	ClaimMappings map[string]string `yaml:"claimMappings"`
+}
+
+// JWTIssuers returns the JWT issuers of the configuration, the single issuer first.
+func (c *AuthenticationConfig) JWTIssuers() []JWTIssuerConfig {
+	issuers := make([]JWTIssuerConfig, 0, len(c.Issuers)+1)
+	if c.JwksUri != "" {
+		issuers = append(issuers, JWTIssuerConfig{Issuer: c.Issuer, Audiences: c.Audiences, JwksUri: c.JwksUri})
+	}
+	for _, issuer := range c.Issuers {
+		if issuer.JwksUri != "" {
+			issuers = append(issuers, issuer)
+		}
+	}
+	return issuers
+}
+
+// AuthorizationConfig is the policy of the models callers may use, based on the claims of their
+// JWT. A request matching a Deny rule is denied. Otherwise, if there are Allow rules, a request is
+// only allowed if it matches one of them.
+type AuthorizationConfig struct {
+	Rules []AuthorizationRule `yaml:"rules"`
+}
+
+// AuthorizationRule matches the requests of the callers holding all its claims, to the models of
+// the rule. Each empty list matches anything.
+type AuthorizationRule struct {
+	// Name identifies the rule in the errors of denied requests.
+	Name string `yaml:"name"`
+	// Action is Allow or Deny.
+	Action string `yaml:"action"`
+	// Claims are the claims the JWT must hold, each with one of the values. A nested claim is
+	// named by its path, e.g. realm_access.roles.
+	Claims map[string][]string `yaml:"claims"`
+	// ModelRoutes are the ModelRoutes serving the request, as namespace/name.
+	ModelRoutes []string `yaml:"modelRoutes"`
+	// Models are the base models of the request, whether the request is for the model or one of its
+	// LoRA adapters. A trailing * matches any suffix.
+	Models []string `yaml:"models"`
+	// LoraAdapters are the LoRA adapters of the request. A trailing * matches any suffix.
+	LoraAdapters []string `yaml:"loraAdapters"`
+}
+
+// TokenizerConfiguration configures the tokenizers counting the tokens of prompts for rate limiting
+// and metrics.
+type TokenizerConfiguration struct {
+	// Default is the tokenizer of the models without a tokenizer of their own. It estimates the
+	// tokens from the length of the prompt if it is not set.
+	Default TokenizerSpec `yaml:"default"`
+	// Models are the tokenizers of specific models.
+	Models []ModelTokenizer `yaml:"models"`
+	// CacheSize is the number of token counts of prompts kept in cache.
+	CacheSize int `yaml:"cacheSize"`
+}
+
+// TokenizerSpec describes a tokenizer.
+type TokenizerSpec struct {
+	// Type is one of estimate, tiktoken and remote.
+	Type string `yaml:"type"`
+	// Encoding is the encoding of a tiktoken tokenizer.
+	Encoding string `yaml:"encoding"`
+	// Endpoint is the base URL of the inference engine serving /tokenize for a remote tokenizer,
+	// e.g. the Service of the model.
+	Endpoint string `yaml:"endpoint"`
+}
+
+// ModelTokenizer is the tokenizer of a model.
+type ModelTokenizer struct {
+	Model         string `yaml:"model"`
+	TokenizerSpec `yaml:",inline"`
+}
+
+// TracingConfiguration configures the OpenTelemetry tracing of the requests, whose spans are
+// exported to an OTLP collector.
+type TracingConfiguration struct {
+	Enabled bool `yaml:"enabled"`
+	// Endpoint is the host:port of the collector. The OTEL_EXPORTER_OTLP_* environment variables
+	// apply if it is not set.
+	Endpoint string `yaml:"endpoint"`
+	// Protocol is the OTLP protocol, grpc by default or http/protobuf.
+	Protocol string `yaml:"protocol"`
+	// Insecure disables TLS to the collector.
+	Insecure bool `yaml:"insecure"`
+	// SamplingRatio is the ratio of the requests traced, 1 by default. The requests whose
+	// traceparent is sampled are always traced, and those whose traceparent is not never are.
+	SamplingRatio *float64 `yaml:"samplingRatio"`
+}
+
+// AccessLogConfiguration configures the access log of the requests, written to one or more sinks.
+type AccessLogConfiguration struct {
+	// Enabled turns the access log on, which it is by default.
+	Enabled *bool `yaml:"enabled"`
+	// Sinks are where the entries are written. They are written as text to stdout if there is none.
+	Sinks []AccessLogSink `yaml:"sinks"`
+	// Headers are the request headers logged, case insensitive, or * for all of them.
+	Headers []string `yaml:"headers"`
+	// BodyFields are the top-level fields of the request body logged, e.g. temperature, or * for
+	// all of them.
+	BodyFields []string `yaml:"bodyFields"`
+	// Redact lists the headers and body fields whose values are replaced with [REDACTED].
+	Redact AccessLogRedaction `yaml:"redact"`
+}
+
+// AccessLogRedaction lists the values that must not be logged. The Authorization,
+// Proxy-Authorization, Cookie and X-Api-Key headers are always redacted.
+type AccessLogRedaction struct {
+	Headers []string `yaml:"headers"`
+	// BodyFields are dot-separated paths into the body, e.g. metadata.email or messages.content,
+	// the latter redacting the content of every message.
+	BodyFields []string `yaml:"bodyFields"`
+}
+
+// AccessLogSink is a destination of the access log.
+type AccessLogSink struct {
+	// Name identifies the sink in the logs of the router.
+	Name string `yaml:"name"`
+	// Type is one of stdout, stderr, file, otlp and http.
+	Type string `yaml:"type"`
+	// Format is the format of the stdout, stderr and file sinks, text by default or json.
+	Format string `yaml:"format"`
+	// Filter selects the entries written to the sink, all of them by default.
+	Filter AccessLogFilter `yaml:"filter"`
+
+	File *AccessLogFileSink `yaml:"file"`
+	OTLP *AccessLogOTLPSink `yaml:"otlp"`
+	HTTP *AccessLogHTTPSink `yaml:"http"`
+}
+
+// AccessLogFilter selects the entries of a sink. The entries of failed requests, whose status
+// code is 400 or more, are always written.
+type AccessLogFilter struct {
+	// ErrorsOnly writes the entries of failed requests only.
+	ErrorsOnly bool `yaml:"errorsOnly"`
+	// SuccessSamplingRatio is the ratio of the entries of successful requests written, 1 by default.
+	SuccessSamplingRatio *float64 `yaml:"successSamplingRatio"`
+}
+
+// AccessLogFileSink is a file rotated when it reaches its maximum size.
+type AccessLogFileSink struct {
+	Path string `yaml:"path"`
+	// MaxSizeMB is the size in megabytes at which the file is rotated, 100 by default.
+	MaxSizeMB int `yaml:"maxSizeMB"`
+	// MaxAgeDays is how long rotated files are kept, forever by default.
+	MaxAgeDays int `yaml:"maxAgeDays"`
+	// MaxBackups is the number of rotated files kept, all of them by default.
+	MaxBackups int `yaml:"maxBackups"`
+	// Compress compresses the rotated files with gzip.
+	Compress bool `yaml:"compress"`
+}
+
+// AccessLogOTLPSink exports the entries as OpenTelemetry log records to an OTLP collector.
+type AccessLogOTLPSink struct {
+	// Endpoint is the host:port of the collector. The OTEL_EXPORTER_OTLP_* environment variables
+	// apply if it is not set.
+	Endpoint string `yaml:"endpoint"`
+	// Protocol is the OTLP protocol, grpc by default or http/protobuf.
+	Protocol string `yaml:"protocol"`
+	// Insecure disables TLS to the collector.
+	Insecure bool `yaml:"insecure"`
+}
+
+// AccessLogHTTPSink posts the entries in batches, as a JSON array, to an HTTP endpoint.
+type AccessLogHTTPSink struct {
+	URL string `yaml:"url"`
+	// Headers are added to the requests, e.g. to authenticate with the endpoint.
+	Headers map[string]string `yaml:"headers"`
+	// BatchSize is the maximum number of entries of a request, 100 by default.
+	BatchSize int `yaml:"batchSize"`
+	// FlushInterval is the maximum time an entry waits for its batch to fill, 5s by default.
+	FlushInterval metav1.Duration `yaml:"flushInterval"`
+	// Timeout bounds each request, 10s by default.
+	Timeout metav1.Duration `yaml:"timeout"`
+	// QueueSize is the maximum number of entries waiting to be sent, 10000 by default. The entries
+	// logged while the queue is full are dropped.
+	QueueSize int `yaml:"queueSize"`
 }
 
 func ParseRouterConfig(configMapPath string) (*RouterConfiguration, error) {
@@ -71,6 +305,11 @@ func ParseRouterConfig(configMapPath string) (*RouterConfiguration, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file %s: %w", configMapPath, err)
 	}
+	return ParseRouterConfigData(data)
+}
+
+// ParseRouterConfigData parses the content of a router configuration file.
+func ParseRouterConfigData(data []byte) (*RouterConfiguration, error) {
 	var routerConfig RouterConfiguration
 	if err := yaml.Unmarshal(data, &routerConfig); err != nil {
 		klog.Errorf("failed to Unmarshal routerConfiguration: %v", err)
@@ -17,8 +17,12 @@ limitations under the License.
 package conf
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadSchedulerConfig(t *testing.T) {
@@ -63,6 +67,78 @@ func TestLoadSchedulerConfig(t *testing.T) {
 	}
 }
 
+func TestParseTokenizerConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "routerConfiguration")
+	config := `
+tokenizer:
+  default:
+    type: tiktoken
+    encoding: o200k_base
+  models:
+  - model: deepseek-r1
+    type: remote
+    endpoint: http://deepseek-r1.default.svc:8000
+  cacheSize: 500
+`
+	if err := os.WriteFile(configFile, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	routerConf, err := ParseRouterConfig(configFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := TokenizerConfiguration{
+		Default: TokenizerSpec{Type: "tiktoken", Encoding: "o200k_base"},
+		Models: []ModelTokenizer{{
+			Model:         "deepseek-r1",
+			TokenizerSpec: TokenizerSpec{Type: "remote", Endpoint: "http://deepseek-r1.default.svc:8000"},
+		}},
+		CacheSize: 500,
+	}
+	if !reflect.DeepEqual(expected, routerConf.Tokenizer) {
+		t.Errorf("expected %+v, got %+v", expected, routerConf.Tokenizer)
+	}
+}
+
+func TestJWTIssuers(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "routerConfiguration")
+	config := `
+auth:
+  issuer: https://sso.example.com
+  jwksUri: https://sso.example.com/jwks.json
+  issuers:
+  - issuer: https://machines.example.com
+    jwksUri: https://machines.example.com/jwks.json
+    audiences: ["kthena"]
+    refreshInterval: 1h
+    userClaim: client_id
+    claimMappings:
+      tenant: org_id
+  - issuer: https://disabled.example.com
+`
+	if err := os.WriteFile(configFile, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	routerConf, err := ParseRouterConfig(configFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	issuers := routerConf.Auth.JWTIssuers()
+	// Issuers without a JWKS URI are ignored.
+	if len(issuers) != 2 {
+		t.Fatalf("expected 2 issuers, got %d", len(issuers))
+	}
+	if issuers[0].Issuer != "https://sso.example.com" || issuers[0].JwksUri != "https://sso.example.com/jwks.json" {
+		t.Errorf("unexpected single issuer %+v", issuers[0])
+	}
+	machines := issuers[1]
+	if machines.RefreshInterval.Duration != time.Hour || machines.UserClaim != "client_id" || machines.ClaimMappings["tenant"] != "org_id" {
+		t.Errorf("unexpected issuer %+v", machines)
+	}
+}
+
 func TestHandleRandomPluginConflicts(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -36,7 +36,9 @@ import (
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/common"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/datastore"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/filters/tokenizer"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/framework"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/tokenization"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/utils"
@@ -147,7 +149,27 @@ func (t *KVCacheAware) normalizeAndTokenizePrompt(ctx *framework.Context, pods [
 	if t.tokenizerManager == nil {
 		return nil, fmt.Errorf("tokenizer manager not available")
 	}
-	return t.tokenizerManager.TokenizePrompt(ctx.Model, ctx.Prompt, pods)
+	prompt := ctx.Prompt
+	if len(ctx.MediaHashes) > 0 {
+		// The engine hashes the blocks holding media tokens together with the media, which
+		// cannot be reproduced from the text. Only the messages before the first media part
+		// form a prefix that can be matched.
+		prompt = textPrefix(prompt)
+		if len(prompt.Messages) == 0 {
+			return nil, nil
+		}
+	}
+	return t.tokenizerManager.TokenizePrompt(ctx.Model, prompt, pods)
+}
+
+// textPrefix returns the leading messages of the prompt that contain no media parts.
+func textPrefix(prompt common.ChatMessage) common.ChatMessage {
+	for i, msg := range prompt.Messages {
+		if len(msg.MediaHashes) > 0 {
+			return common.ChatMessage{Messages: prompt.Messages[:i]}
+		}
+	}
+	return prompt
 }
 
 func (t *KVCacheAware) Score(ctx *framework.Context, pods []*datastore.PodInfo) map[*datastore.PodInfo]int {
@@ -169,6 +191,12 @@ func (t *KVCacheAware) Score(ctx *framework.Context, pods []*datastore.PodInfo)
 	if err != nil || len(tokens) == 0 {
 		return scoreResults
 	}
+	if ctx.Prompt.Text != "" {
+		// The engine tokenized the raw text prompt, so the count is shared with rate limiting and
+		// metrics. Chat messages are tokenized with the chat template of the model, which the
+		// counts of the other tokenizers leave out.
+		tokenizer.DefaultManager.RecordTokens(ctx.Model, ctx.Prompt.Text, len(tokens))
+	}
 
 	blockHashes := t.processor.TokensToBlockHashes(tokens, t.maxBlocksToMatch)
 	if len(blockHashes) == 0 {
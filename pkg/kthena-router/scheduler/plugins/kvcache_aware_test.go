@@ -2144,3 +2144,22 @@ func TestKVCacheAware_Integration_Comprehensive_Core(t *testing.T) {
 		})
 	}
 }
+
+func TestTextPrefix(t *testing.T) {
+	prompt := common.ChatMessage{
+		Messages: []common.Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "describe", MediaHashes: []uint64{1}},
+			{Role: "user", Content: "and this"},
+		},
+	}
+	got := textPrefix(prompt)
+	if !reflect.DeepEqual(got.Messages, prompt.Messages[:1]) {
+		t.Errorf("Expected only the system message, got %v", got.Messages)
+	}
+
+	textOnly := common.ChatMessage{Messages: []common.Message{{Role: "user", Content: "hello"}}}
+	if got := textPrefix(textOnly); !reflect.DeepEqual(got, textOnly) {
+		t.Errorf("Expected prompt without media to be unchanged, got %v", got)
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugins
+
+import (
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/datastore"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/framework"
+)
+
+type noopPodRuntimeInspector struct{}
+
+func (noopPodRuntimeInspector) GetPodMetrics(string, *corev1.Pod, map[string]*dto.Histogram) (map[string]float64, map[string]*dto.Histogram) {
+	return nil, nil
+}
+
+func (noopPodRuntimeInspector) GetPodModels(string, *corev1.Pod) ([]string, error) {
+	return nil, nil
+}
+
+func TestOutlierDetection_Filter(t *testing.T) {
+	cfg := datastore.DefaultOutlierDetectionConfig()
+	cfg.ConsecutiveFailures = 1
+	store := datastore.New(datastore.WithOutlierDetectionConfig(cfg), datastore.WithPodRuntimeInspector(noopPodRuntimeInspector{}))
+	var pods []*datastore.PodInfo
+	for _, name := range []string{"pod-1", "pod-2", "pod-3"} {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+		assert.NoError(t, store.AddOrUpdatePod(pod, nil))
+		pods = append(pods, store.GetPodInfo(types.NamespacedName{Namespace: "default", Name: name}))
+	}
+
+	plugin := NewOutlierDetection()
+	assert.Equal(t, OutlierDetectionPluginName, plugin.Name())
+	ctx := &framework.Context{Model: "test-model"}
+
+	assert.Len(t, plugin.Filter(ctx, append([]*datastore.PodInfo{}, pods...)), 3)
+
+	pods[1].RecordRequestResult(pods[1].StartRequest(), false)
+	filtered := plugin.Filter(ctx, append([]*datastore.PodInfo{}, pods...))
+	assert.Equal(t, []*datastore.PodInfo{pods[0], pods[2]}, filtered)
+
+	// When all the pods are ejected, none is filtered out.
+	pods[0].RecordRequestResult(pods[0].StartRequest(), false)
+	pods[2].RecordRequestResult(pods[2].StartRequest(), false)
+	assert.Len(t, plugin.Filter(ctx, append([]*datastore.PodInfo{}, pods...)), 3)
+}
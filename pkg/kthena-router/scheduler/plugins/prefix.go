@@ -161,7 +161,7 @@ func (p *PrefixCache) Name() string {
 
 func (p *PrefixCache) Score(ctx *framework.Context, pods []*datastore.PodInfo) map[*datastore.PodInfo]int {
 	// Hash the prompt
-	hashes := p.hashPrompt(ctx.Model, utils.GetPromptString(ctx.Prompt))
+	hashes := p.hashPrompt(ctx.Model, utils.GetPrefixString(ctx.Prompt))
 	if len(hashes) == 0 {
 		return nil
 	}
@@ -0,0 +1,257 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugins
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/cespare/xxhash"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/datastore"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/framework"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/cache"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/utils"
+)
+
+const SessionAffinityPluginName = "session-affinity"
+
+const (
+	// SessionKeySourceHeader takes the session key from a request header.
+	SessionKeySourceHeader = "header"
+	// SessionKeySourceJWTClaim takes the session key from a claim of the bearer token.
+	SessionKeySourceJWTClaim = "jwtClaim"
+	// SessionKeySourceUser takes the session key from the `user` field of the request body.
+	SessionKeySourceUser = "user"
+
+	defaultSessionHeader      = "x-session-id"
+	defaultSessionClaim       = "sub"
+	defaultSessionLoadBound   = 1.25
+	defaultSessionVirtualNode = 100
+	// sessionRingCacheSize is the number of hash rings kept, one per distinct set of pods.
+	sessionRingCacheSize = 64
+)
+
+var _ framework.ScorePlugin = &SessionAffinity{}
+
+type SessionAffinityArgs struct {
+	// KeySource is where the session key is taken from: header, jwtClaim or user.
+	KeySource string `json:"keySource,omitempty"`
+	// Header is the request header holding the session key when KeySource is header.
+	Header string `json:"header,omitempty"`
+	// Claim is the claim of the bearer token holding the session key when KeySource is jwtClaim.
+	Claim string `json:"claim,omitempty"`
+	// LoadBound is how far above the average load a pod may be and still receive its sessions.
+	// With 1.25, no pod gets more than 125% of the average number of requests.
+	LoadBound float64 `json:"loadBound,omitempty"`
+	// VirtualNodes is the number of points of each pod on the hash ring.
+	VirtualNodes int `json:"virtualNodes,omitempty"`
+}
+
+// SessionAffinity scores the pod owning the session of the request with 100, so that the requests
+// of a multi-turn conversation land on the pod which holds their KV cache. Sessions are mapped to
+// pods with consistent hashing with bounded loads: adding or removing a pod only moves the sessions
+// of a few pods, and a pod over the load bound passes its sessions on to the next pod of the ring.
+type SessionAffinity struct {
+	name         string
+	keySource    string
+	header       string
+	claim        string
+	loadBound    float64
+	virtualNodes int
+
+	rings *cache.LRUCache[uint64, *hashRing]
+}
+
+func NewSessionAffinity(pluginArg runtime.RawExtension) *SessionAffinity {
+	var args SessionAffinityArgs
+	if len(pluginArg.Raw) > 0 {
+		if err := yaml.Unmarshal(pluginArg.Raw, &args); err != nil {
+			klog.Warningf("Failed to unmarshal SessionAffinityArgs: %v", err)
+		}
+	}
+
+	s := &SessionAffinity{
+		name:         SessionAffinityPluginName,
+		keySource:    args.KeySource,
+		header:       args.Header,
+		claim:        args.Claim,
+		loadBound:    args.LoadBound,
+		virtualNodes: args.VirtualNodes,
+	}
+	switch s.keySource {
+	case SessionKeySourceHeader, SessionKeySourceJWTClaim, SessionKeySourceUser:
+	case "":
+		s.keySource = SessionKeySourceHeader
+	default:
+		klog.Warningf("Unknown session key source %q, using %s", s.keySource, SessionKeySourceHeader)
+		s.keySource = SessionKeySourceHeader
+	}
+	if s.header == "" {
+		s.header = defaultSessionHeader
+	}
+	if s.claim == "" {
+		s.claim = defaultSessionClaim
+	}
+	// A bound of 1 or less leaves no room for the pods of the ring to hold their sessions.
+	if s.loadBound <= 1 {
+		s.loadBound = defaultSessionLoadBound
+	}
+	if s.virtualNodes <= 0 {
+		s.virtualNodes = defaultSessionVirtualNode
+	}
+	s.rings, _ = cache.NewLRUCache[uint64, *hashRing](sessionRingCacheSize, nil)
+	return s
+}
+
+func (s *SessionAffinity) Name() string {
+	return s.name
+}
+
+func (s *SessionAffinity) Score(ctx *framework.Context, pods []*datastore.PodInfo) map[*datastore.PodInfo]int {
+	scoreResults := make(map[*datastore.PodInfo]int, len(pods))
+	for _, info := range pods {
+		scoreResults[info] = 0
+	}
+	if len(pods) == 0 {
+		return scoreResults
+	}
+
+	key := s.sessionKey(ctx)
+	if key == "" {
+		return scoreResults
+	}
+
+	if pod := s.pick(key, pods); pod != nil {
+		scoreResults[pod] = 100
+	}
+	return scoreResults
+}
+
+// sessionKey returns the session key of the request, or an empty string if it has none.
+func (s *SessionAffinity) sessionKey(ctx *framework.Context) string {
+	switch s.keySource {
+	case SessionKeySourceJWTClaim:
+		if ctx.Headers == nil {
+			return ""
+		}
+		// The token is not verified: the session key only steers scheduling, and the token is
+		// authenticated by the auth filter when it is enabled.
+		return utils.BearerTokenClaim(ctx.Headers.Get("Authorization"), s.claim)
+	case SessionKeySourceUser:
+		user, _ := ctx.Body["user"].(string)
+		return user
+	default:
+		if ctx.Headers == nil {
+			return ""
+		}
+		return ctx.Headers.Get(s.header)
+	}
+}
+
+// pick returns the pod owning the session: the first pod met clockwise from the hash of the key
+// on the ring whose load is within the bound.
+func (s *SessionAffinity) pick(key string, pods []*datastore.PodInfo) *datastore.PodInfo {
+	byName := make(map[string]*datastore.PodInfo, len(pods))
+	names := make([]string, 0, len(pods))
+	totalLoad := 0.0
+	for _, info := range pods {
+		name := podKey(info)
+		byName[name] = info
+		names = append(names, name)
+		totalLoad += podLoad(info)
+	}
+	ring := s.getRing(names)
+
+	// Every pod may take up to loadBound times the average load, counting this request.
+	bound := math.Ceil(s.loadBound * (totalLoad + 1) / float64(len(pods)))
+	hash := xxhash.Sum64String(key)
+	start := sort.Search(len(ring.hashes), func(i int) bool { return ring.hashes[i] >= hash })
+	visited := make(map[string]bool, len(pods))
+	var owner *datastore.PodInfo
+	for i := 0; i < len(ring.hashes) && len(visited) < len(pods); i++ {
+		name := ring.owners[(start+i)%len(ring.hashes)]
+		if visited[name] {
+			continue
+		}
+		visited[name] = true
+		info := byName[name]
+		if owner == nil {
+			owner = info
+		}
+		if podLoad(info)+1 <= bound {
+			return info
+		}
+	}
+	// The bound always leaves room on some pod, but the load is read from metrics which may
+	// change while it is summed up.
+	return owner
+}
+
+func (s *SessionAffinity) getRing(names []string) *hashRing {
+	sort.Strings(names)
+	fingerprint := xxhash.Sum64String(strings.Join(names, ","))
+	if ring, ok := s.rings.Get(fingerprint); ok {
+		return ring
+	}
+	ring := newHashRing(names, s.virtualNodes)
+	s.rings.Add(fingerprint, ring)
+	return ring
+}
+
+// hashRing is a consistent hash ring with virtualNodes points for each pod.
+type hashRing struct {
+	hashes []uint64
+	owners []string
+}
+
+func newHashRing(names []string, virtualNodes int) *hashRing {
+	type point struct {
+		hash  uint64
+		owner string
+	}
+	points := make([]point, 0, len(names)*virtualNodes)
+	for _, name := range names {
+		for i := 0; i < virtualNodes; i++ {
+			points = append(points, point{hash: xxhash.Sum64String(fmt.Sprintf("%s#%d", name, i)), owner: name})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].hash < points[j].hash })
+
+	ring := &hashRing{
+		hashes: make([]uint64, len(points)),
+		owners: make([]string, len(points)),
+	}
+	for i, p := range points {
+		ring.hashes[i] = p.hash
+		ring.owners[i] = p.owner
+	}
+	return ring
+}
+
+func podKey(info *datastore.PodInfo) string {
+	return info.Pod.Namespace + "/" + info.Pod.Name
+}
+
+func podLoad(info *datastore.PodInfo) float64 {
+	return info.GetRequestRunningNum() + info.GetRequestWaitingNum()
+}
@@ -0,0 +1,198 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugins
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/datastore"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/framework"
+)
+
+func newSessionAffinityPods(n int) []*datastore.PodInfo {
+	pods := make([]*datastore.PodInfo, 0, n)
+	for i := 0; i < n; i++ {
+		pods = append(pods, &datastore.PodInfo{
+			Pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("pod-%d", i), Namespace: "default"}},
+		})
+	}
+	return pods
+}
+
+func sessionContext(session string) *framework.Context {
+	return &framework.Context{Model: "test-model", Headers: http.Header{"X-Session-Id": []string{session}}}
+}
+
+// chosenPod returns the name of the pod scored 100, or an empty string if there is none.
+func chosenPod(t *testing.T, scores map[*datastore.PodInfo]int) string {
+	chosen := ""
+	for info, score := range scores {
+		if score == 100 {
+			assert.Empty(t, chosen, "more than one pod is scored 100")
+			chosen = info.Pod.Name
+		} else {
+			assert.Equal(t, 0, score)
+		}
+	}
+	return chosen
+}
+
+func TestSessionAffinity_Stable(t *testing.T) {
+	plugin := NewSessionAffinity(runtime.RawExtension{})
+	assert.Equal(t, SessionAffinityPluginName, plugin.Name())
+	pods := newSessionAffinityPods(4)
+
+	used := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		session := fmt.Sprintf("session-%d", i)
+		first := chosenPod(t, plugin.Score(sessionContext(session), pods))
+		assert.NotEmpty(t, first)
+		// The order of the pods does not matter.
+		reversed := []*datastore.PodInfo{pods[3], pods[2], pods[1], pods[0]}
+		assert.Equal(t, first, chosenPod(t, plugin.Score(sessionContext(session), reversed)))
+		used[first] = true
+	}
+	assert.Len(t, used, 4)
+}
+
+func TestSessionAffinity_NoSessionKey(t *testing.T) {
+	plugin := NewSessionAffinity(runtime.RawExtension{})
+	pods := newSessionAffinityPods(3)
+
+	scores := plugin.Score(&framework.Context{Model: "test-model"}, pods)
+	assert.Len(t, scores, 3)
+	assert.Empty(t, chosenPod(t, scores))
+}
+
+func TestSessionAffinity_PodRemoved(t *testing.T) {
+	plugin := NewSessionAffinity(runtime.RawExtension{})
+	pods := newSessionAffinityPods(5)
+
+	const sessions = 1000
+	before := make(map[string]string, sessions)
+	for i := 0; i < sessions; i++ {
+		session := fmt.Sprintf("session-%d", i)
+		before[session] = chosenPod(t, plugin.Score(sessionContext(session), pods))
+	}
+
+	// Only the sessions of the removed pod move to other pods.
+	remaining := pods[:4]
+	moved := 0
+	for session, pod := range before {
+		after := chosenPod(t, plugin.Score(sessionContext(session), remaining))
+		if pod == "pod-4" {
+			assert.NotEqual(t, "pod-4", after)
+			continue
+		}
+		if after != pod {
+			moved++
+		}
+	}
+	assert.Zero(t, moved)
+}
+
+func TestSessionAffinity_LoadBound(t *testing.T) {
+	plugin := NewSessionAffinity(runtime.RawExtension{Raw: []byte(`loadBound: 1.5`)})
+	pods := newSessionAffinityPods(3)
+
+	owner := chosenPod(t, plugin.Score(sessionContext("session"), pods))
+	for _, info := range pods {
+		if info.Pod.Name == owner {
+			// The average load is (30+1)/3, so the bound is ceil(1.5*31/3) = 16.
+			info.RequestRunningNum = 30
+		}
+	}
+	spilled := chosenPod(t, plugin.Score(sessionContext("session"), pods))
+	assert.NotEmpty(t, spilled)
+	assert.NotEqual(t, owner, spilled)
+
+	// Once the owner is back under the bound, the session returns to it.
+	for _, info := range pods {
+		info.RequestRunningNum = 0
+	}
+	assert.Equal(t, owner, chosenPod(t, plugin.Score(sessionContext("session"), pods)))
+}
+
+func TestSessionAffinity_KeySources(t *testing.T) {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"alice","tenant":"team-a"}`))
+	token := "Bearer header." + payload + ".signature"
+
+	tests := []struct {
+		name string
+		args string
+		ctx  *framework.Context
+		key  string
+	}{
+		{
+			name: "default header",
+			args: ``,
+			ctx:  sessionContext("session-1"),
+			key:  "session-1",
+		},
+		{
+			name: "custom header",
+			args: `header: x-conversation-id`,
+			ctx:  &framework.Context{Headers: http.Header{"X-Conversation-Id": []string{"conversation-1"}}},
+			key:  "conversation-1",
+		},
+		{
+			name: "default jwt claim",
+			args: `keySource: jwtClaim`,
+			ctx:  &framework.Context{Headers: http.Header{"Authorization": []string{token}}},
+			key:  "alice",
+		},
+		{
+			name: "custom jwt claim",
+			args: "keySource: jwtClaim\nclaim: tenant",
+			ctx:  &framework.Context{Headers: http.Header{"Authorization": []string{token}}},
+			key:  "team-a",
+		},
+		{
+			name: "malformed jwt",
+			args: `keySource: jwtClaim`,
+			ctx:  &framework.Context{Headers: http.Header{"Authorization": []string{"Bearer not-a-jwt"}}},
+			key:  "",
+		},
+		{
+			name: "user field",
+			args: `keySource: user`,
+			ctx:  &framework.Context{Body: map[string]interface{}{"model": "test-model", "user": "user-1"}},
+			key:  "user-1",
+		},
+		{
+			name: "no user field",
+			args: `keySource: user`,
+			ctx:  &framework.Context{Body: map[string]interface{}{"model": "test-model"}},
+			key:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := NewSessionAffinity(runtime.RawExtension{Raw: []byte(tt.args)})
+			assert.Equal(t, tt.key, plugin.sessionKey(tt.ctx))
+		})
+	}
+}
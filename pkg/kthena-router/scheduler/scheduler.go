@@ -25,4 +25,6 @@ import (
 type Scheduler interface {
 	Schedule(ctx *framework.Context, pods []*datastore.PodInfo) error
 	RunPostHooks(ctx *framework.Context, index int)
+	// Explain runs the plugins like Schedule, and returns how the pods are filtered and scored.
+	Explain(ctx *framework.Context, pods []*datastore.PodInfo) *Explanation
 }
@@ -18,9 +18,12 @@ package scheduler
 
 import (
 	"fmt"
+	"slices"
 	"sort"
+	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/v2"
@@ -30,6 +33,7 @@ import (
 	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/framework"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/tracing"
 )
 
 const (
@@ -37,9 +41,35 @@ const (
 	topN = 5
 )
 
+// SchedulerProfileAnnotation selects the scheduler profile of a ModelServer by name.
+const SchedulerProfileAnnotation = "networking.serving.volcano.sh/scheduler-profile"
+
 type SchedulerImpl struct {
 	store datastore.Store
 
+	// defaultProfile schedules the model servers without a profile, and profiles are the named
+	// profiles.
+	defaultProfile *profile
+	profiles       map[string]*profile
+
+	// prefixCaches are the prefix caches of the profiles by their arguments, so that the profiles
+	// with the same arguments share a prefix cache. They are reused by the schedulers of reloaded
+	// configurations.
+	prefixCaches map[string]*plugins.PrefixCache
+
+	// unknownProfiles are the unknown profiles of model servers that have been warned about, so
+	// that the warning is not repeated for every request.
+	unknownProfiles sync.Map
+}
+
+// unknownProfile is a profile selected by the annotation of a model server but not configured.
+type unknownProfile struct {
+	modelServer types.NamespacedName
+	name        string
+}
+
+// profile is a set of filter and score plugins, with the post schedule hooks of its plugins.
+type profile struct {
 	filterPlugins []framework.FilterPlugin
 	scorePlugins  []*scorePlugin
 
@@ -57,11 +87,90 @@ type podInfoWithValue struct {
 }
 
 func NewScheduler(store datastore.Store, routerConfig *conf.RouterConfiguration) Scheduler {
+	scheduler, err := BuildScheduler(store, routerConfig, nil)
+	if err != nil {
+		klog.Fatalf("failed to Load Scheduler: %v", err)
+	}
+	return scheduler
+}
+
+// BuildScheduler builds the scheduler of the router configuration. Unlike NewScheduler, an invalid
+// configuration is returned as an error, so that a reloaded configuration can be rejected while the
+// previous scheduler keeps serving. The prefix caches of the previous scheduler are carried over if
+// their arguments are unchanged, so that the cached prefixes survive a reload.
+func BuildScheduler(store datastore.Store, routerConfig *conf.RouterConfiguration, previous Scheduler) (Scheduler, error) {
 	// For backward compatibility, use the default registry and ensure plugins are registered
 	registry := NewPluginRegistry()
 	registerDefaultPlugins(registry)
 
-	// Default plugin configuration.
+	s := &SchedulerImpl{
+		store:        store,
+		profiles:     map[string]*profile{},
+		prefixCaches: map[string]*plugins.PrefixCache{},
+	}
+	prev, _ := previous.(*SchedulerImpl)
+
+	if routerConfig == nil {
+		// If no scheduler configuration is provided, use the default configuration
+		klog.Warning("No scheduler configuration found, using default configuration")
+		scorePluginMap, filterPluginMap, pluginsArgMap := defaultSchedulerConfig()
+		defaultProfile, err := s.buildProfile(registry, scorePluginMap, filterPluginMap, pluginsArgMap, prev)
+		if err != nil {
+			return nil, err
+		}
+		s.defaultProfile = defaultProfile
+		return s, nil
+	}
+
+	defaultProfile, err := s.loadProfile(registry, &routerConfig.Scheduler, prev)
+	if err != nil {
+		return nil, err
+	}
+	s.defaultProfile = defaultProfile
+	for i := range routerConfig.Scheduler.Profiles {
+		config := &routerConfig.Scheduler.Profiles[i]
+		if config.Name == "" {
+			return nil, fmt.Errorf("scheduler profile without a name")
+		}
+		if _, exists := s.profiles[config.Name]; exists {
+			return nil, fmt.Errorf("scheduler profile %s is configured more than once", config.Name)
+		}
+		p, err := s.loadProfile(registry, routerConfig.Scheduler.ProfileConfiguration(config), prev)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scheduler profile %s: %w", config.Name, err)
+		}
+		s.profiles[config.Name] = p
+	}
+	s.validateProfileAnnotations()
+	return s, nil
+}
+
+// validateProfileAnnotations warns about the model servers whose annotation selects a profile that
+// is not configured, when the configuration is loaded rather than when they are first requested.
+func (s *SchedulerImpl) validateProfileAnnotations() {
+	if s.store == nil {
+		return
+	}
+	for name, modelServer := range s.store.GetAllModelServers() {
+		if profile := modelServer.Annotations[SchedulerProfileAnnotation]; profile != "" {
+			if _, ok := s.profiles[profile]; !ok {
+				s.warnUnknownProfile(name, profile)
+			}
+		}
+	}
+}
+
+// warnUnknownProfile warns once that the profile of the model server is not configured.
+func (s *SchedulerImpl) warnUnknownProfile(modelServer types.NamespacedName, name string) {
+	if _, warned := s.unknownProfiles.LoadOrStore(unknownProfile{modelServer: modelServer, name: name}, struct{}{}); warned {
+		klog.V(4).Infof("scheduler profile %s of model server %s is not configured, using the default profile", name, modelServer)
+		return
+	}
+	klog.Warningf("scheduler profile %s of model server %s is not configured, using the default profile", name, modelServer)
+}
+
+// defaultSchedulerConfig is the scheduler configuration used without a router configuration.
+func defaultSchedulerConfig() (map[string]int, []string, map[string]runtime.RawExtension) {
 	scorePluginMap := map[string]int{
 		"least-request": 1,
 		"least-latency": 1,
@@ -75,35 +184,81 @@ func NewScheduler(store datastore.Store, routerConfig *conf.RouterConfiguration)
 		"least-latency": {Raw: []byte(`{"TTFTTPOTWeightFactor": 0.5}`)},
 		"prefix-cache":  {Raw: []byte(`{"blockSizeToHash": 64, "maxBlocksToMatch": 128, "maxHashCacheSize": 50000, "topKMatches": 5}`)},
 	}
+	return scorePluginMap, filterPluginMap, pluginsArgMap
+}
 
-	var err error
-	if routerConfig == nil {
-		// If no scheduler configuration is provided, use the default configuration
-		klog.Warning("No scheduler configuration found, using default configuration")
-	} else {
-		scorePluginMap, filterPluginMap, pluginsArgMap, err = conf.LoadSchedulerConfig(&routerConfig.Scheduler)
-		if err != nil {
-			klog.Fatalf("failed to Load Scheduler: %v", err)
-		}
+// loadProfile builds the profile of the scheduler configuration.
+func (s *SchedulerImpl) loadProfile(registry *PluginRegistry, schedulerConfig *conf.SchedulerConfiguration, prev *SchedulerImpl) (*profile, error) {
+	scorePluginMap, filterPluginMap, pluginsArgMap, err := conf.LoadSchedulerConfig(schedulerConfig)
+	if err != nil {
+		return nil, err
 	}
+	return s.buildProfile(registry, scorePluginMap, filterPluginMap, pluginsArgMap, prev)
+}
 
-	prefixCache := plugins.NewPrefixCache(store, pluginsArgMap[plugins.PrefixCachePluginName])
-	return &SchedulerImpl{
-		store:         store,
+func (s *SchedulerImpl) buildProfile(registry *PluginRegistry, scorePluginMap map[string]int, filterPluginMap []string, pluginsArgMap map[string]runtime.RawExtension, prev *SchedulerImpl) (*profile, error) {
+	if err := validatePlugins(registry, scorePluginMap, filterPluginMap); err != nil {
+		return nil, err
+	}
+	prefixCache := s.prefixCache(pluginsArgMap[plugins.PrefixCachePluginName], prev)
+	return &profile{
 		filterPlugins: getFilterPlugins(registry, filterPluginMap, pluginsArgMap),
 		scorePlugins:  getScorePlugins(registry, prefixCache, scorePluginMap, pluginsArgMap),
 		postScheduleHooks: []framework.PostScheduleHook{
 			prefixCache,
 		},
+	}, nil
+}
+
+// prefixCache returns the prefix cache with the arguments, shared by the profiles of the scheduler
+// and carried over from the previous scheduler.
+func (s *SchedulerImpl) prefixCache(args runtime.RawExtension, prev *SchedulerImpl) *plugins.PrefixCache {
+	key := string(args.Raw)
+	if prefixCache, ok := s.prefixCaches[key]; ok {
+		return prefixCache
+	}
+	var prefixCache *plugins.PrefixCache
+	if prev != nil {
+		prefixCache = prev.prefixCaches[key]
+	}
+	if prefixCache == nil {
+		prefixCache = plugins.NewPrefixCache(s.store, args)
 	}
+	s.prefixCaches[key] = prefixCache
+	return prefixCache
 }
 
-func (s *SchedulerImpl) Schedule(ctx *framework.Context, pods []*datastore.PodInfo) error {
-	// first filter out invalid pods that wonot be selected to loadbalance to.
-	pods, err := s.RunFilterPlugins(pods, ctx)
-	if err != nil {
-		return err
+// selectProfile selects the profile of the model server of the request by its annotation, and
+// returns the name of the profile, or an empty string for the default profile.
+func (s *SchedulerImpl) selectProfile(ctx *framework.Context) string {
+	if len(s.profiles) == 0 {
+		return ""
+	}
+	modelServer := s.store.GetModelServer(ctx.ModelServerName)
+	if modelServer == nil {
+		return ""
 	}
+	name := modelServer.Annotations[SchedulerProfileAnnotation]
+	if name == "" {
+		return ""
+	}
+	if _, ok := s.profiles[name]; !ok {
+		s.warnUnknownProfile(ctx.ModelServerName, name)
+		return ""
+	}
+	return name
+}
+
+// profileOf returns the profile the request is scheduled with.
+func (s *SchedulerImpl) profileOf(ctx *framework.Context) *profile {
+	if p, ok := s.profiles[ctx.Profile]; ok {
+		return p
+	}
+	return s.defaultProfile
+}
+
+func (s *SchedulerImpl) Schedule(ctx *framework.Context, pods []*datastore.PodInfo) error {
+	ctx.Profile = s.selectProfile(ctx)
 
 	if ctx.PDGroup != nil {
 		// Use optimized PDGroup scheduling with pre-categorized pods from store
@@ -118,6 +273,12 @@ func (s *SchedulerImpl) Schedule(ctx *framework.Context, pods []*datastore.PodIn
 		if len(decodePods) == 0 {
 			return fmt.Errorf("no decode pod found")
 		}
+		// The decode and the prefill pods are filtered separately, e.g. so that the ejected pods of
+		// each role are skipped. The filters may filter the pods in place.
+		decodePods, err = s.RunFilterPlugins(slices.Clone(decodePods), ctx)
+		if err != nil {
+			return err
+		}
 
 		klog.V(4).Info("Running score plugins for decode pod")
 		scores := s.RunScorePlugins(decodePods, ctx)
@@ -138,6 +299,11 @@ func (s *SchedulerImpl) Schedule(ctx *framework.Context, pods []*datastore.PodIn
 				klog.V(4).InfoS("prefill pods for decode group not found", "decode instance", klog.KObj(decodePod.Pod), "error", err)
 				continue
 			}
+			selectedPods, err = s.RunFilterPlugins(slices.Clone(selectedPods), ctx)
+			if err != nil {
+				klog.V(4).InfoS("prefill pods for decode group filtered out", "decode instance", klog.KObj(decodePod.Pod), "error", err)
+				continue
+			}
 
 			klog.V(4).Info("Running score plugins for prefill pod")
 			scores = s.RunScorePlugins(selectedPods, ctx)
@@ -157,6 +323,12 @@ func (s *SchedulerImpl) Schedule(ctx *framework.Context, pods []*datastore.PodIn
 		return nil
 	}
 
+	// first filter out invalid pods that wonot be selected to loadbalance to.
+	pods, err := s.RunFilterPlugins(pods, ctx)
+	if err != nil {
+		return err
+	}
+
 	klog.V(4).Info("Running score plugins for PD aggregated pod")
 	scores := s.RunScorePlugins(pods, ctx)
 	ctx.BestPods = TopNPodInfos(scores, topN)
@@ -165,11 +337,17 @@ func (s *SchedulerImpl) Schedule(ctx *framework.Context, pods []*datastore.PodIn
 }
 
 func (s *SchedulerImpl) RunFilterPlugins(pods []*datastore.PodInfo, ctx *framework.Context) ([]*datastore.PodInfo, error) {
-	for _, filterPlugin := range s.filterPlugins {
+	for _, filterPlugin := range s.profileOf(ctx).filterPlugins {
 		// Record filter plugin execution time
+		_, span := tracing.Start(ctx.TraceContext, "filter/"+filterPlugin.Name(), trace.WithAttributes(
+			tracing.PluginKey.String(filterPlugin.Name()),
+			tracing.CandidatePodsKey.Int(len(pods)),
+		))
 		startTime := time.Now()
 		pods = filterPlugin.Filter(ctx, pods)
 		duration := time.Since(startTime)
+		span.SetAttributes(tracing.RemainingPodsKey.Int(len(pods)))
+		span.End()
 
 		// Use the MetricsRecorder from context to record plugin duration
 		if ctx.MetricsRecorder != nil {
@@ -186,11 +364,17 @@ func (s *SchedulerImpl) RunFilterPlugins(pods []*datastore.PodInfo, ctx *framewo
 
 func (s *SchedulerImpl) RunScorePlugins(pods []*datastore.PodInfo, ctx *framework.Context) map[*datastore.PodInfo]int {
 	res := make(map[*datastore.PodInfo]int)
-	for _, scorePlugin := range s.scorePlugins {
+	for _, scorePlugin := range s.profileOf(ctx).scorePlugins {
 		// Record score plugin execution time
+		_, span := tracing.Start(ctx.TraceContext, "score/"+scorePlugin.plugin.Name(), trace.WithAttributes(
+			tracing.PluginKey.String(scorePlugin.plugin.Name()),
+			tracing.CandidatePodsKey.Int(len(pods)),
+			tracing.PluginWeightKey.Int(scorePlugin.weight),
+		))
 		startTime := time.Now()
 		scores := scorePlugin.plugin.Score(ctx, pods)
 		duration := time.Since(startTime)
+		span.End()
 
 		// Use the MetricsRecorder from context to record plugin duration
 		if ctx.MetricsRecorder != nil {
@@ -223,7 +407,7 @@ func (s *SchedulerImpl) RunScorePlugins(pods []*datastore.PodInfo, ctx *framewor
 }
 
 func (s *SchedulerImpl) RunPostHooks(ctx *framework.Context, index int) {
-	for _, hook := range s.postScheduleHooks {
+	for _, hook := range s.profileOf(ctx).postScheduleHooks {
 		hook.PostSchedule(ctx, index)
 	}
 }
@@ -17,7 +17,10 @@ limitations under the License.
 package scheduler
 
 import (
+	"fmt"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -28,6 +31,7 @@ import (
 	aiv1alpha1 "github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/datastore"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/framework"
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
 )
 
 // TestTopNPodInfos tests the TopNPodInfos function
@@ -247,6 +251,89 @@ func TestSchedulePDGroup(t *testing.T) {
 	}
 }
 
+// TestSchedulePDGroupOutlierDetection verifies that the ejected decode and prefill pods are not
+// scheduled, unless all the pods of their role are ejected.
+func TestSchedulePDGroupOutlierDetection(t *testing.T) {
+	routerConfig, err := conf.ParseRouterConfigData([]byte(`
+scheduler:
+  plugins:
+    Filter:
+      enabled:
+        - outlier-detection
+    Score:
+      enabled:
+        - name: least-request
+          weight: 1
+`))
+	require.NoError(t, err)
+	store := datastore.New(datastore.WithOutlierDetectionConfig(datastore.OutlierDetectionConfig{
+		ConsecutiveFailures: 1,
+		MinRequests:         10,
+		Interval:            10 * time.Second,
+		BaseEjectionTime:    time.Minute,
+		MaxEjectionTime:     time.Minute,
+		HalfOpenRequests:    1,
+	}))
+	pdGroup := &aiv1alpha1.PDGroup{
+		GroupKey:      "pd-group",
+		DecodeLabels:  map[string]string{"role": "decode"},
+		PrefillLabels: map[string]string{"role": "prefill"},
+	}
+	modelServer := &aiv1alpha1.ModelServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-model-server", Namespace: "default"},
+		Spec: aiv1alpha1.ModelServerSpec{
+			WorkloadSelector: &aiv1alpha1.WorkloadSelector{PDGroup: pdGroup},
+		},
+	}
+	modelServerName := types.NamespacedName{Namespace: "default", Name: "test-model-server"}
+	require.NoError(t, store.AddOrUpdateModelServer(modelServer, nil))
+	addPod := func(name, group, role string) *datastore.PodInfo {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{"pd-group": group, "role": role},
+			},
+			Status: corev1.PodStatus{PodIP: "10.0.0.1"},
+		}
+		require.NoError(t, store.AddOrUpdatePod(pod, []*aiv1alpha1.ModelServer{modelServer}))
+		return store.GetPodInfo(types.NamespacedName{Namespace: "default", Name: name})
+	}
+	eject := func(pod *datastore.PodInfo) {
+		pod.RecordRequestResult(pod.StartRequest(), false)
+		require.True(t, pod.IsEjected())
+	}
+	healthyDecode := addPod("decode-pod-0", "group-0", "decode")
+	ejectedPrefill := addPod("prefill-pod-0a", "group-0", "prefill")
+	addPod("prefill-pod-0b", "group-0", "prefill")
+	ejectedDecode := addPod("decode-pod-1", "group-1", "decode")
+	addPod("prefill-pod-1", "group-1", "prefill")
+	eject(ejectedDecode)
+	eject(ejectedPrefill)
+
+	scheduler, err := BuildScheduler(store, routerConfig, nil)
+	require.NoError(t, err)
+	pods, err := store.GetPodsByModelServer(modelServerName)
+	require.NoError(t, err)
+
+	ctx := &framework.Context{Model: "test-model", ModelServerName: modelServerName, PDGroup: pdGroup}
+	require.NoError(t, scheduler.Schedule(ctx, pods))
+	require.Len(t, ctx.DecodePods, 1)
+	assert.Equal(t, "decode-pod-0", ctx.DecodePods[0].Pod.Name)
+	require.Len(t, ctx.PrefillPods, 1)
+	assert.Equal(t, "prefill-pod-0b", ctx.PrefillPods[0].Pod.Name)
+
+	explanation := scheduler.(*SchedulerImpl).Explain(&framework.Context{Model: "test-model", ModelServerName: modelServerName, PDGroup: pdGroup}, pods)
+	require.Len(t, explanation.Filters, 1)
+	assert.Equal(t, []string{"default/decode-pod-1"}, explanation.Filters[0].Dropped)
+
+	// When all the decode pods are ejected, they are all kept.
+	eject(healthyDecode)
+	ctx = &framework.Context{Model: "test-model", ModelServerName: modelServerName, PDGroup: pdGroup}
+	require.NoError(t, scheduler.Schedule(ctx, pods))
+	assert.Len(t, ctx.DecodePods, 2)
+}
+
 // TestScheduleNonPDGroupWithEmptyScores tests non-PD scheduling with empty scores
 func TestScheduleNonPDGroupWithEmptyScores(t *testing.T) {
 	store := datastore.New()
@@ -315,3 +402,147 @@ func createTestPodInfo(name string) *datastore.PodInfo {
 		},
 	}
 }
+
+func TestBuildScheduler(t *testing.T) {
+	store := datastore.New()
+	config := func(prefixCacheArgs, scorePlugin string) *conf.RouterConfiguration {
+		routerConfig, err := conf.ParseRouterConfigData([]byte(fmt.Sprintf(`
+scheduler:
+  pluginConfig:
+  - name: prefix-cache
+    args: %s
+  plugins:
+    Filter:
+      enabled:
+        - least-request
+    Score:
+      enabled:
+        - name: prefix-cache
+          weight: 1
+        - name: %s
+          weight: 1
+`, prefixCacheArgs, scorePlugin)))
+		require.NoError(t, err)
+		return routerConfig
+	}
+
+	first, err := BuildScheduler(store, config(`{"blockSizeToHash": 64}`, "least-request"), nil)
+	require.NoError(t, err)
+	prefixCache := first.(*SchedulerImpl).defaultProfile.postScheduleHooks[0]
+
+	// The prefix cache survives a reload with the same arguments.
+	second, err := BuildScheduler(store, config(`{"blockSizeToHash": 64}`, "least-latency"), first)
+	require.NoError(t, err)
+	assert.Same(t, prefixCache, second.(*SchedulerImpl).defaultProfile.postScheduleHooks[0])
+
+	third, err := BuildScheduler(store, config(`{"blockSizeToHash": 32}`, "least-latency"), second)
+	require.NoError(t, err)
+	assert.NotSame(t, prefixCache, third.(*SchedulerImpl).defaultProfile.postScheduleHooks[0])
+
+	_, err = BuildScheduler(store, config(`{"blockSizeToHash": 64}`, "no-such-plugin"), third)
+	assert.ErrorContains(t, err, "unknown score plugin no-such-plugin")
+}
+
+func TestSchedulerProfiles(t *testing.T) {
+	routerConfig, err := conf.ParseRouterConfigData([]byte(`
+scheduler:
+  pluginConfig:
+  - name: least-request
+    args:
+      maxWaitingRequests: 10
+  - name: prefix-cache
+    args:
+      blockSizeToHash: 64
+  plugins:
+    Filter:
+      enabled:
+        - least-request
+    Score:
+      enabled:
+        - name: least-request
+          weight: 1
+  profiles:
+  - name: code-completion
+    plugins:
+      Score:
+        enabled:
+          - name: prefix-cache
+            weight: 10
+          - name: least-request
+            weight: 1
+  - name: batch
+    pluginConfig:
+    - name: prefix-cache
+      args:
+        blockSizeToHash: 128
+    plugins:
+      Score:
+        enabled:
+          - name: least-request
+            weight: 1
+`))
+	require.NoError(t, err)
+
+	store := datastore.New()
+	scheduler, err := BuildScheduler(store, routerConfig, nil)
+	require.NoError(t, err)
+	s := scheduler.(*SchedulerImpl)
+	require.Len(t, s.profiles, 2)
+
+	codeCompletion := s.profiles["code-completion"]
+	assert.Empty(t, codeCompletion.filterPlugins)
+	weights := map[string]int{}
+	for _, plugin := range codeCompletion.scorePlugins {
+		weights[plugin.plugin.Name()] = plugin.weight
+	}
+	assert.Equal(t, map[string]int{"prefix-cache": 10, "least-request": 1}, weights)
+	// The profiles with the same prefix cache args share the prefix cache.
+	assert.Same(t, s.defaultProfile.postScheduleHooks[0], codeCompletion.postScheduleHooks[0])
+	assert.NotSame(t, s.defaultProfile.postScheduleHooks[0], s.profiles["batch"].postScheduleHooks[0])
+
+	modelServer := func(name, profile string) types.NamespacedName {
+		ms := &aiv1alpha1.ModelServer{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+		if profile != "" {
+			ms.Annotations = map[string]string{SchedulerProfileAnnotation: profile}
+		}
+		require.NoError(t, store.AddOrUpdateModelServer(ms, nil))
+		return types.NamespacedName{Namespace: "default", Name: name}
+	}
+	coder := modelServer("coder", "code-completion")
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "default"}}
+	require.NoError(t, store.AddOrUpdatePod(pod, []*aiv1alpha1.ModelServer{store.GetModelServer(coder)}))
+	pods, err := store.GetPodsByModelServer(coder)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name            string
+		modelServerName types.NamespacedName
+		profile         string
+	}{
+		{name: "annotated", modelServerName: coder, profile: "code-completion"},
+		{name: "not annotated", modelServerName: modelServer("chat", ""), profile: ""},
+		{name: "unknown profile", modelServerName: modelServer("other", "no-such-profile"), profile: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &framework.Context{Model: "test-model", ModelServerName: tt.modelServerName}
+			require.NoError(t, scheduler.Schedule(ctx, slices.Clone(pods)))
+			assert.Equal(t, tt.profile, ctx.Profile)
+			assert.Len(t, ctx.BestPods, 1)
+		})
+	}
+	// The unknown profile is warned about once, rather than for every request.
+	other := types.NamespacedName{Namespace: "default", Name: "other"}
+	_, warned := s.unknownProfiles.Load(unknownProfile{modelServer: other, name: "no-such-profile"})
+	assert.True(t, warned)
+
+	// The annotations are validated when the configuration is loaded.
+	reloaded, err := BuildScheduler(store, routerConfig, scheduler)
+	require.NoError(t, err)
+	_, warned = reloaded.(*SchedulerImpl).unknownProfiles.Load(unknownProfile{modelServer: other, name: "no-such-profile"})
+	assert.True(t, warned)
+
+	routerConfig.Scheduler.Profiles = append(routerConfig.Scheduler.Profiles, conf.SchedulerProfile{Name: "batch"})
+	_, err = BuildScheduler(store, routerConfig, scheduler)
+	assert.ErrorContains(t, err, "scheduler profile batch is configured more than once")
+}
@@ -0,0 +1,133 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
+)
+
+const (
+	serviceName = "kthena-router"
+
+	ProtocolGRPC         = "grpc"
+	ProtocolHTTPProtobuf = "http/protobuf"
+)
+
+// Provider is the tracer provider of a tracing configuration.
+type Provider struct {
+	trace.TracerProvider
+	// sdk exports the spans, nil if tracing is disabled.
+	sdk *sdktrace.TracerProvider
+}
+
+// NewProvider returns the tracer provider exporting the spans to the OTLP collector of the
+// configuration, or a provider recording nothing if tracing is disabled. The trace context of the
+// requests is propagated to the model servers either way.
+func NewProvider(config *conf.TracingConfiguration) (*Provider, error) {
+	if !config.Enabled {
+		return &Provider{TracerProvider: noop.NewTracerProvider()}, nil
+	}
+
+	ratio := 1.0
+	if config.SamplingRatio != nil {
+		ratio = *config.SamplingRatio
+	}
+	if ratio < 0 || ratio > 1 {
+		return nil, fmt.Errorf("samplingRatio %v is not between 0 and 1", ratio)
+	}
+
+	client, err := newClient(config)
+	if err != nil {
+		return nil, err
+	}
+	// The exporter connects to the collector lazily, so that the router starts without it.
+	exporter, err := otlptrace.New(context.Background(), client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+	res, err := NewResource()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tracing resource: %w", err)
+	}
+
+	sdk := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	return &Provider{TracerProvider: sdk, sdk: sdk}, nil
+}
+
+// NewResource returns the OpenTelemetry resource of the router, completed by the
+// OTEL_RESOURCE_ATTRIBUTES environment variable.
+func NewResource() (*resource.Resource, error) {
+	return resource.New(context.Background(),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+	)
+}
+
+func newClient(config *conf.TracingConfiguration) (otlptrace.Client, error) {
+	switch config.Protocol {
+	case "", ProtocolGRPC:
+		var opts []otlptracegrpc.Option
+		if config.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.NewClient(opts...), nil
+	case ProtocolHTTPProtobuf:
+		var opts []otlptracehttp.Option
+		if config.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.NewClient(opts...), nil
+	default:
+		return nil, fmt.Errorf("unknown OTLP protocol %q, expected %s or %s", config.Protocol, ProtocolGRPC, ProtocolHTTPProtobuf)
+	}
+}
+
+// Enabled returns whether the spans of the provider are exported.
+func (p *Provider) Enabled() bool {
+	return p.sdk != nil
+}
+
+// Shutdown exports the spans that are not exported yet, and stops the exporter.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p.sdk == nil {
+		return nil
+	}
+	return p.sdk.Shutdown(ctx)
+}
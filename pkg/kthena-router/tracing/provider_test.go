@@ -0,0 +1,99 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/volcano-sh/kthena/pkg/kthena-router/scheduler/plugins/conf"
+)
+
+func TestNewProvider(t *testing.T) {
+	ratio := func(r float64) *float64 { return &r }
+	tests := []struct {
+		name    string
+		config  conf.TracingConfiguration
+		enabled bool
+		err     string
+	}{
+		{
+			name:   "disabled",
+			config: conf.TracingConfiguration{Endpoint: "collector:4317"},
+		},
+		{
+			name:    "grpc",
+			config:  conf.TracingConfiguration{Enabled: true, Endpoint: "collector:4317", Insecure: true, SamplingRatio: ratio(0.1)},
+			enabled: true,
+		},
+		{
+			name:    "http",
+			config:  conf.TracingConfiguration{Enabled: true, Endpoint: "collector:4318", Protocol: ProtocolHTTPProtobuf},
+			enabled: true,
+		},
+		{
+			name:   "unknown protocol",
+			config: conf.TracingConfiguration{Enabled: true, Protocol: "thrift"},
+			err:    `unknown OTLP protocol "thrift"`,
+		},
+		{
+			name:   "invalid sampling ratio",
+			config: conf.TracingConfiguration{Enabled: true, SamplingRatio: ratio(1.5)},
+			err:    "samplingRatio 1.5 is not between 0 and 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := NewProvider(&tt.config)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.enabled, provider.Enabled())
+			// The exporter connects lazily, so nothing is exported without a collector.
+			assert.NoError(t, provider.Shutdown(context.Background()))
+		})
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := Extract(context.Background(), http.Header{"Traceparent": []string{traceparent}})
+	assert.True(t, trace.SpanContextFromContext(ctx).IsRemote())
+
+	// Without a recording tracer provider, the trace context of the client is propagated as is.
+	ctx, span := Start(ctx, SpanUpstream)
+	End(span, nil)
+	header := http.Header{}
+	Inject(ctx, header)
+	assert.Equal(t, traceparent, header.Get("traceparent"))
+
+	// Nothing is propagated without a trace context, e.g. the scheduling of a request not traced.
+	var untraced context.Context
+	_, span = Start(untraced, SpanSchedule)
+	assert.False(t, span.SpanContext().IsValid())
+	header = http.Header{}
+	Inject(context.Background(), header)
+	assert.Empty(t, header)
+}
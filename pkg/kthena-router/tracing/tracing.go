@@ -0,0 +1,93 @@
+/*
+Copyright The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is the name of the tracer and of the OTLP access logger of the router.
+const InstrumentationName = "github.com/volcano-sh/kthena/pkg/kthena-router"
+
+// Names of the spans of a request.
+const (
+	SpanParseRequest  = "parse_request"
+	SpanAuthenticate  = "authenticate"
+	SpanRateLimit     = "rate_limit"
+	SpanFairnessQueue = "fairness_queue"
+	SpanSchedule      = "schedule"
+	SpanUpstream      = "upstream"
+	SpanPrefill       = "prefill"
+	SpanDecode        = "decode"
+)
+
+// Attributes of the spans, besides the semantic conventions.
+const (
+	ModelRouteKey       = attribute.Key("kthena.model_route")
+	ModelServerKey      = attribute.Key("kthena.model_server")
+	PodKey              = attribute.Key("kthena.pod")
+	PrefillPodKey       = attribute.Key("kthena.prefill_pod")
+	SchedulerProfileKey = attribute.Key("kthena.scheduler.profile")
+	PluginKey           = attribute.Key("kthena.scheduler.plugin")
+	CandidatePodsKey    = attribute.Key("kthena.scheduler.candidate_pods")
+	RemainingPodsKey    = attribute.Key("kthena.scheduler.remaining_pods")
+	PluginWeightKey     = attribute.Key("kthena.scheduler.plugin_weight")
+	AttemptKey          = attribute.Key("kthena.upstream.attempt")
+	HedgedKey           = attribute.Key("kthena.upstream.hedged")
+	FallbackKey         = attribute.Key("kthena.fallback")
+	ErrorTypeKey        = attribute.Key("kthena.error.type")
+	FairnessPriorityKey = attribute.Key("kthena.fairness.priority")
+)
+
+// propagator reads and writes the W3C traceparent and tracestate headers.
+var propagator = propagation.TraceContext{}
+
+// Extract returns a copy of ctx carrying the trace context of the headers of an incoming request.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// Inject sets the trace context of ctx in the headers of an outgoing request. The headers are left
+// as they are if ctx carries no trace context.
+func Inject(ctx context.Context, header http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Start starts a child span of the span of ctx, with the tracer provider of that span. Nothing is
+// recorded if ctx carries no span or a span of a request that is not sampled. ctx may be nil.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer(InstrumentationName)
+	return tracer.Start(ctx, name, opts...)
+}
+
+// End ends the span, with an error status if err is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -17,10 +17,14 @@ limitations under the License.
 package utils
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
+	"github.com/cespare/xxhash"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/common"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -71,15 +75,18 @@ func ParsePrompt(body map[string]interface{}) (common.ChatMessage, error) {
 				continue
 			}
 
-			content, ok := msgMap["content"].(string)
-			if !ok {
+			var msg common.Message
+			switch content := msgMap["content"].(type) {
+			case string:
+				msg = common.Message{Role: role, Content: content}
+			case []interface{}:
+				msg = parseContentParts(content)
+				msg.Role = role
+			default:
 				continue
 			}
 
-			msgs = append(msgs, common.Message{
-				Role:    role,
-				Content: content,
-			})
+			msgs = append(msgs, msg)
 		}
 
 		return common.ChatMessage{
@@ -90,7 +97,193 @@ func ParsePrompt(body map[string]interface{}) (common.ChatMessage, error) {
 	return common.ChatMessage{}, fmt.Errorf("prompt or messages not found in request body")
 }
 
+// parseContentParts parses the content parts of a multimodal chat message, e.g.
+// [{"type": "text", "text": "..."}, {"type": "image_url", "image_url": {"url": "..."}}].
+// The text parts are joined by new lines, and the other parts are hashed by their url or data.
+func parseContentParts(parts []interface{}) common.Message {
+	var msg common.Message
+	texts := make([]string, 0, len(parts))
+	// offset is the length of the text parts joined so far.
+	offset := 0
+	for _, part := range parts {
+		partMap, ok := part.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		partType, _ := partMap["type"].(string)
+		if partType == "text" {
+			if text, ok := partMap["text"].(string); ok {
+				if len(texts) > 0 {
+					offset++
+				}
+				texts = append(texts, text)
+				offset += len(text)
+			}
+			continue
+		}
+		msg.MediaHashes = append(msg.MediaHashes, hashMediaPart(partType, partMap))
+		msg.MediaOffsets = append(msg.MediaOffsets, offset)
+	}
+	msg.Content = strings.Join(texts, "\n")
+	return msg
+}
+
+// hashMediaPart hashes a non-text content part, such as image_url, input_audio or video_url.
+// Parts carrying inline data (e.g. base64 data urls) are hashed by a digest of the data.
+func hashMediaPart(partType string, part map[string]interface{}) uint64 {
+	switch v := part[partType].(type) {
+	case string:
+		return xxhash.Sum64String(partType + ":" + v)
+	case map[string]interface{}:
+		for _, key := range []string{"url", "data"} {
+			if s, ok := v[key].(string); ok {
+				return xxhash.Sum64String(partType + ":" + s)
+			}
+		}
+	}
+	// Unknown part layout, fall back to hashing the whole part.
+	data, _ := json.Marshal(part)
+	return xxhash.Sum64(data)
+}
+
+// GetMediaHashes returns the hashes of all the multimodal parts of the prompt, in order.
+func GetMediaHashes(chatMessage common.ChatMessage) []uint64 {
+	var hashes []uint64
+	for _, msg := range chatMessage.Messages {
+		hashes = append(hashes, msg.MediaHashes...)
+	}
+	return hashes
+}
+
+// EndpointType is the kind of OpenAI compatible API that a request is sent to.
+type EndpointType string
+
+const (
+	// EndpointGeneration covers the completions and chat completions APIs, which carry `prompt` or `messages`.
+	EndpointGeneration EndpointType = "generation"
+	// EndpointEmbeddings is the embeddings API, which carries `input`.
+	EndpointEmbeddings EndpointType = "embeddings"
+	// EndpointRerank is the rerank API, which carries `query` and `documents`.
+	EndpointRerank EndpointType = "rerank"
+	// EndpointScore is the score API, which carries `text_1` and `text_2`.
+	EndpointScore EndpointType = "score"
+)
+
+// GetEndpointType returns the endpoint type of a request path, e.g. /v1/embeddings or /v2/rerank.
+func GetEndpointType(path string) EndpointType {
+	path = strings.TrimSuffix(path, "/")
+	switch {
+	case strings.HasSuffix(path, "/embeddings"):
+		return EndpointEmbeddings
+	case strings.HasSuffix(path, "/rerank"):
+		return EndpointRerank
+	case strings.HasSuffix(path, "/score"):
+		return EndpointScore
+	default:
+		return EndpointGeneration
+	}
+}
+
+// ParseEndpointPrompt extracts the text to tokenize and hash from a request sent to the given endpoint.
+// The inputs of embeddings, rerank and score requests are returned as a text prompt, in which multiple
+// inputs are separated by new lines. For rerank the query comes first, so that requests with the same
+// query share a prefix.
+func ParseEndpointPrompt(endpoint EndpointType, body map[string]interface{}) (common.ChatMessage, error) {
+	var fields []string
+	switch endpoint {
+	case EndpointEmbeddings:
+		fields = []string{"input"}
+	case EndpointRerank:
+		fields = []string{"query", "documents"}
+	case EndpointScore:
+		fields = []string{"text_1", "text_2"}
+	default:
+		return ParsePrompt(body)
+	}
+
+	parts := make([]string, 0, len(fields))
+	for _, field := range fields {
+		value, ok := body[field]
+		if !ok {
+			return common.ChatMessage{}, fmt.Errorf("%s not found in %s request body", field, endpoint)
+		}
+		texts, err := inputToTexts(value)
+		if err != nil {
+			return common.ChatMessage{}, fmt.Errorf("invalid %s: %w", field, err)
+		}
+		parts = append(parts, texts...)
+	}
+	text := strings.Join(parts, "\n")
+	if text == "" {
+		return common.ChatMessage{}, fmt.Errorf("empty %s request", endpoint)
+	}
+	return common.ChatMessage{Text: text}, nil
+}
+
+// inputToTexts flattens an input of embeddings, rerank or score requests into texts.
+// An input is either a string, a list of token ids, a document object with a `text` field,
+// or a list of any of these. Token ids are rendered as space separated numbers.
+func inputToTexts(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}, nil
+	case map[string]interface{}:
+		text, ok := v["text"].(string)
+		if !ok {
+			return nil, fmt.Errorf("document has no text")
+		}
+		return []string{text}, nil
+	case []interface{}:
+		if isTokenIDs(v) {
+			ids := make([]string, 0, len(v))
+			for _, id := range v {
+				ids = append(ids, strconv.FormatFloat(id.(float64), 'f', -1, 64))
+			}
+			return []string{strings.Join(ids, " ")}, nil
+		}
+		texts := make([]string, 0, len(v))
+		for _, item := range v {
+			itemTexts, err := inputToTexts(item)
+			if err != nil {
+				return nil, err
+			}
+			texts = append(texts, itemTexts...)
+		}
+		return texts, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %T", value)
+	}
+}
+
+func isTokenIDs(list []interface{}) bool {
+	if len(list) == 0 {
+		return false
+	}
+	for _, item := range list {
+		if _, ok := item.(float64); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// GetPromptString returns the text of the prompt, with the chat messages in ChatML format, whose
+// tokens are counted for rate limiting and metrics. Media parts are left out, since their tokens
+// cannot be counted from the text.
 func GetPromptString(chatMessage common.ChatMessage) string {
+	return promptString(chatMessage, false)
+}
+
+// GetPrefixString returns the prompt like GetPromptString, with placeholders carrying the hashes of
+// the media parts where they appear, so that prompts with different images or audios do not share
+// a prefix beyond them. It is only meant for hashing the prefixes of prompts.
+func GetPrefixString(chatMessage common.ChatMessage) string {
+	return promptString(chatMessage, true)
+}
+
+func promptString(chatMessage common.ChatMessage, withMedia bool) string {
 	// If Text field is present, return text directly (for prompt format)
 	if chatMessage.Text != "" {
 		return chatMessage.Text
@@ -99,11 +292,62 @@ func GetPromptString(chatMessage common.ChatMessage) string {
 	// For chat messages, convert to ChatML format
 	var result strings.Builder
 	for _, msg := range chatMessage.Messages {
-		fmt.Fprintf(&result, "<|im_start|>%s\n%s<|im_end|>\n", msg.Role, msg.Content)
+		fmt.Fprintf(&result, "<|im_start|>%s\n", msg.Role)
+		if withMedia {
+			writeContentWithMedia(&result, msg)
+		} else {
+			result.WriteString(msg.Content)
+		}
+		result.WriteString("<|im_end|>\n")
 	}
 	return result.String()
 }
 
+// writeContentWithMedia writes the content of the message with the placeholders of its media parts
+// at their offsets. The media parts without an offset are written before the content.
+func writeContentWithMedia(result *strings.Builder, msg common.Message) {
+	written := 0
+	for i, hash := range msg.MediaHashes {
+		offset := written
+		if i < len(msg.MediaOffsets) {
+			offset = min(max(msg.MediaOffsets[i], written), len(msg.Content))
+		}
+		result.WriteString(msg.Content[written:offset])
+		written = max(written, offset)
+		fmt.Fprintf(result, "<|media:%016x|>", hash)
+	}
+	result.WriteString(msg.Content[written:])
+}
+
+// BearerTokenClaim returns a string or numeric claim of the bearer token of the Authorization
+// header, or an empty string if there is none. The token is not verified.
+func BearerTokenClaim(authorization, claim string) string {
+	token, found := strings.CutPrefix(authorization, "Bearer ")
+	if !found {
+		return ""
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return ""
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	switch value := claims[claim].(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
 func LoadEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
 	if value == "" {
@@ -21,6 +21,8 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/cespare/xxhash"
+	"github.com/stretchr/testify/assert"
 	"github.com/volcano-sh/kthena/pkg/kthena-router/common"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -90,6 +92,36 @@ func TestParsePrompt(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "messages with content parts",
+			body: map[string]interface{}{
+				"messages": []interface{}{
+					map[string]interface{}{
+						"role": "user",
+						"content": []interface{}{
+							map[string]interface{}{"type": "text", "text": "describe"},
+							map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "https://example.com/a.png"}},
+							map[string]interface{}{"type": "text", "text": "briefly"},
+							map[string]interface{}{"type": "input_audio", "input_audio": map[string]interface{}{"data": "UklGRg==", "format": "wav"}},
+						},
+					},
+				},
+			},
+			want: common.ChatMessage{
+				Messages: []common.Message{
+					{
+						Role:    "user",
+						Content: "describe\nbriefly",
+						MediaHashes: []uint64{
+							xxhash.Sum64String("image_url:https://example.com/a.png"),
+							xxhash.Sum64String("input_audio:UklGRg=="),
+						},
+						MediaOffsets: []int{8, 16},
+					},
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name: "messages not a list",
 			body: map[string]interface{}{
@@ -122,6 +154,122 @@ func TestParsePrompt(t *testing.T) {
 	}
 }
 
+func TestGetEndpointType(t *testing.T) {
+	tests := map[string]EndpointType{
+		"/v1/completions":      EndpointGeneration,
+		"/v1/chat/completions": EndpointGeneration,
+		"/v1/embeddings":       EndpointEmbeddings,
+		"/v1/rerank":           EndpointRerank,
+		"/v2/rerank/":          EndpointRerank,
+		"/rerank":              EndpointRerank,
+		"/score":               EndpointScore,
+		"/v1/score":            EndpointScore,
+	}
+	for path, want := range tests {
+		if got := GetEndpointType(path); got != want {
+			t.Errorf("GetEndpointType(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestParseEndpointPrompt(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint EndpointType
+		body     map[string]interface{}
+		want     common.ChatMessage
+		wantErr  bool
+	}{
+		{
+			name:     "generation falls back to prompt",
+			endpoint: EndpointGeneration,
+			body:     map[string]interface{}{"prompt": "hello"},
+			want:     common.ChatMessage{Text: "hello"},
+		},
+		{
+			name:     "embeddings with string input",
+			endpoint: EndpointEmbeddings,
+			body:     map[string]interface{}{"input": "hello"},
+			want:     common.ChatMessage{Text: "hello"},
+		},
+		{
+			name:     "embeddings with list input",
+			endpoint: EndpointEmbeddings,
+			body:     map[string]interface{}{"input": []interface{}{"hello", "world"}},
+			want:     common.ChatMessage{Text: "hello\nworld"},
+		},
+		{
+			name:     "embeddings with token ids",
+			endpoint: EndpointEmbeddings,
+			body: map[string]interface{}{"input": []interface{}{
+				[]interface{}{float64(1), float64(2)},
+				[]interface{}{float64(3)},
+			}},
+			want: common.ChatMessage{Text: "1 2\n3"},
+		},
+		{
+			name:     "embeddings without input",
+			endpoint: EndpointEmbeddings,
+			body:     map[string]interface{}{"prompt": "hello"},
+			wantErr:  true,
+		},
+		{
+			name:     "rerank with string documents",
+			endpoint: EndpointRerank,
+			body: map[string]interface{}{
+				"query":     "what is kthena",
+				"documents": []interface{}{"a router", "a controller"},
+			},
+			want: common.ChatMessage{Text: "what is kthena\na router\na controller"},
+		},
+		{
+			name:     "rerank with document objects",
+			endpoint: EndpointRerank,
+			body: map[string]interface{}{
+				"query":     "what is kthena",
+				"documents": []interface{}{map[string]interface{}{"text": "a router"}},
+			},
+			want: common.ChatMessage{Text: "what is kthena\na router"},
+		},
+		{
+			name:     "rerank without query",
+			endpoint: EndpointRerank,
+			body:     map[string]interface{}{"documents": []interface{}{"a router"}},
+			wantErr:  true,
+		},
+		{
+			name:     "score",
+			endpoint: EndpointScore,
+			body: map[string]interface{}{
+				"text_1": "what is kthena",
+				"text_2": []interface{}{"a router"},
+			},
+			want: common.ChatMessage{Text: "what is kthena\na router"},
+		},
+		{
+			name:     "score with invalid input",
+			endpoint: EndpointScore,
+			body: map[string]interface{}{
+				"text_1": "what is kthena",
+				"text_2": true,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseEndpointPrompt(tt.endpoint, tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseEndpointPrompt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseEndpointPrompt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestGetPromptString(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -145,6 +293,15 @@ func TestGetPromptString(t *testing.T) {
 			},
 			want: "<|im_start|>user\nhi<|im_end|>\n<|im_start|>assistant\nhello<|im_end|>\n",
 		},
+		{
+			name: "messages with media",
+			chatMessage: common.ChatMessage{
+				Messages: []common.Message{
+					{Role: "user", Content: "describe", MediaHashes: []uint64{0xab}},
+				},
+			},
+			want: "<|im_start|>user\ndescribe<|im_end|>\n",
+		},
 		{
 			name:        "both empty",
 			chatMessage: common.ChatMessage{},
@@ -161,6 +318,79 @@ func TestGetPromptString(t *testing.T) {
 	}
 }
 
+func TestGetPrefixString(t *testing.T) {
+	tests := []struct {
+		name        string
+		chatMessage common.ChatMessage
+		want        string
+	}{
+		{
+			name: "text field present",
+			chatMessage: common.ChatMessage{
+				Text: "hello",
+			},
+			want: "hello",
+		},
+		{
+			name: "media before the text",
+			chatMessage: common.ChatMessage{
+				Messages: []common.Message{
+					{Role: "user", Content: "describe", MediaHashes: []uint64{0xab}, MediaOffsets: []int{0}},
+				},
+			},
+			want: "<|im_start|>user\n<|media:00000000000000ab|>describe<|im_end|>\n",
+		},
+		{
+			name: "media between and after the text",
+			chatMessage: common.ChatMessage{
+				Messages: []common.Message{
+					{Role: "user", Content: "describe\nbriefly", MediaHashes: []uint64{0xab, 0xcd}, MediaOffsets: []int{8, 16}},
+				},
+			},
+			want: "<|im_start|>user\ndescribe<|media:00000000000000ab|>\nbriefly<|media:00000000000000cd|><|im_end|>\n",
+		},
+		{
+			name: "media without offsets",
+			chatMessage: common.ChatMessage{
+				Messages: []common.Message{
+					{Role: "user", Content: "describe", MediaHashes: []uint64{0xab}},
+				},
+			},
+			want: "<|im_start|>user\n<|media:00000000000000ab|>describe<|im_end|>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, GetPrefixString(tt.chatMessage))
+		})
+	}
+}
+
+func TestHashMediaPart(t *testing.T) {
+	image := func(url string) map[string]interface{} {
+		return map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": url}}
+	}
+	assert.Equal(t, hashMediaPart("image_url", image("data:image/png;base64,AAAA")), hashMediaPart("image_url", image("data:image/png;base64,AAAA")))
+	assert.NotEqual(t, hashMediaPart("image_url", image("data:image/png;base64,AAAA")), hashMediaPart("image_url", image("data:image/png;base64,BBBB")))
+
+	// Parts of unknown layout are hashed as a whole.
+	unknown := map[string]interface{}{"type": "image_embeds", "image_embeds": []interface{}{1.0, 2.0}}
+	assert.NotZero(t, hashMediaPart("image_embeds", unknown))
+}
+
+func TestGetMediaHashes(t *testing.T) {
+	prompt := common.ChatMessage{
+		Messages: []common.Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "compare", MediaHashes: []uint64{1, 2}},
+			{Role: "user", Content: "and", MediaHashes: []uint64{3}},
+		},
+	}
+	assert.Equal(t, []uint64{1, 2, 3}, GetMediaHashes(prompt))
+	assert.Empty(t, GetMediaHashes(common.ChatMessage{Text: "hello"}))
+}
+
 func TestLoadEnv(t *testing.T) {
 	key := "TEST_ENV_VAR"
 	defaultValue := "default"
@@ -26,6 +26,7 @@ import (
 
 	admissionv1 "k8s.io/api/admission/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
@@ -170,6 +171,10 @@ func (v *KthenaRouterValidator) validateModelRoute(modelRoute *networkingv1alpha
 		}
 	}
 
+	if modelRoute.Spec.RateLimit != nil && modelRoute.Spec.RateLimit.Key != nil {
+		allErrs = append(allErrs, validateRateLimitKey(modelRoute.Spec.RateLimit.Key, specField.Child("rateLimit", "key"))...)
+	}
+
 	rulesField := specField.Child("rules")
 	for i, rule := range modelRoute.Spec.Rules {
 		if rule == nil {
@@ -180,6 +185,24 @@ func (v *KthenaRouterValidator) validateModelRoute(modelRoute *networkingv1alpha
 		if len(rule.TargetModels) == 0 {
 			allErrs = append(allErrs, field.Required(ruleField.Child("targetModels"), "each rule must have at least one target model"))
 		}
+		for j, target := range rule.TargetModels {
+			if target != nil && target.ModelName != nil && *target.ModelName == "" {
+				allErrs = append(allErrs, field.Invalid(ruleField.Child("targetModels").Index(j).Child("modelName"), "", "model name cannot be an empty string"))
+			}
+		}
+		if rule.Hedging != nil {
+			allErrs = append(allErrs, validateHedging(rule.Hedging, ruleField.Child("hedging"))...)
+		}
+		if rule.Mirror != nil && rule.Mirror.ModelServerName == "" {
+			allErrs = append(allErrs, field.Required(ruleField.Child("mirror", "modelServerName"), "mirror must have a model server"))
+		}
+		allErrs = append(allErrs, validateFallbacks(rule.Fallbacks, ruleField.Child("fallbacks"))...)
+		if rule.RequestHeaderModifier != nil {
+			allErrs = append(allErrs, validateHeaderModifier(rule.RequestHeaderModifier, ruleField.Child("requestHeaderModifier"))...)
+		}
+		if rule.ResponseHeaderModifier != nil {
+			allErrs = append(allErrs, validateHeaderModifier(rule.ResponseHeaderModifier, ruleField.Child("responseHeaderModifier"))...)
+		}
 	}
 
 	if len(allErrs) > 0 {
@@ -192,6 +215,96 @@ func (v *KthenaRouterValidator) validateModelRoute(modelRoute *networkingv1alpha
 	return true, ""
 }
 
+// validateHedging validates the hedging of a rule, whose delay is either fixed or a percentile.
+func validateHedging(hedging *networkingv1alpha1.Hedging, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if hedging.Delay == nil && hedging.DelayPercentile == nil {
+		allErrs = append(allErrs, field.Required(fldPath, "either delay or delayPercentile must be specified"))
+	}
+	if hedging.Delay != nil && hedging.DelayPercentile != nil {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("delayPercentile"), "delay and delayPercentile cannot both be specified"))
+	}
+	if hedging.Delay != nil && hedging.Delay.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("delay"), hedging.Delay.Duration.String(), "delay must be positive"))
+	}
+	return allErrs
+}
+
+// validateFallbacks validates the fallback targets of a rule.
+func validateFallbacks(fallbacks []*networkingv1alpha1.FallbackTarget, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, fallback := range fallbacks {
+		if fallback == nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), fallback, "fallback must not be nil"))
+			continue
+		}
+		if fallback.ModelServerName == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Index(i).Child("modelServerName"), "fallback must have a model server"))
+		}
+		for j, trigger := range fallback.On {
+			switch trigger {
+			case networkingv1alpha1.FallbackOnNoEndpoints, networkingv1alpha1.FallbackOn5xx,
+				networkingv1alpha1.FallbackOnTimeout, networkingv1alpha1.FallbackOnRateLimited:
+			default:
+				allErrs = append(allErrs, field.NotSupported(fldPath.Index(i).Child("on").Index(j), trigger, []networkingv1alpha1.FallbackTrigger{
+					networkingv1alpha1.FallbackOnNoEndpoints, networkingv1alpha1.FallbackOn5xx,
+					networkingv1alpha1.FallbackOnTimeout, networkingv1alpha1.FallbackOnRateLimited,
+				}))
+			}
+		}
+	}
+	return allErrs
+}
+
+// validateHeaderModifier validates the header names of a header modifier. A header may only be
+// modified once by each of set and add.
+func validateHeaderModifier(modifier *networkingv1alpha1.HeaderModifier, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	validateHeaders := func(headers []networkingv1alpha1.HTTPHeader, fldPath *field.Path) {
+		seen := make(map[string]bool, len(headers))
+		for i, header := range headers {
+			for _, msg := range validation.IsHTTPHeaderName(header.Name) {
+				allErrs = append(allErrs, field.Invalid(fldPath.Index(i).Child("name"), header.Name, msg))
+			}
+			name := http.CanonicalHeaderKey(header.Name)
+			if seen[name] {
+				allErrs = append(allErrs, field.Duplicate(fldPath.Index(i).Child("name"), header.Name))
+			}
+			seen[name] = true
+		}
+	}
+	validateHeaders(modifier.Set, fldPath.Child("set"))
+	validateHeaders(modifier.Add, fldPath.Child("add"))
+	for i, name := range modifier.Remove {
+		for _, msg := range validation.IsHTTPHeaderName(name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("remove").Index(i), name, msg))
+		}
+	}
+	return allErrs
+}
+
+// validateRateLimitKey validates the source of the rate limit key, and that a header key names
+// its header.
+func validateRateLimitKey(key *networkingv1alpha1.RateLimitKey, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	switch key.Source {
+	case networkingv1alpha1.RateLimitKeySourceHeader:
+		if key.Header == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("header"), "header must be specified when the source is Header"))
+			break
+		}
+		for _, msg := range validation.IsHTTPHeaderName(key.Header) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("header"), key.Header, msg))
+		}
+	case networkingv1alpha1.RateLimitKeySourceJWTClaim, networkingv1alpha1.RateLimitKeySourceUser:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("source"), key.Source, []networkingv1alpha1.RateLimitKeySource{
+			networkingv1alpha1.RateLimitKeySourceHeader, networkingv1alpha1.RateLimitKeySourceJWTClaim, networkingv1alpha1.RateLimitKeySourceUser,
+		}))
+	}
+	return allErrs
+}
+
 // validateModelServer validates the ModelServer resource
 func (v *KthenaRouterValidator) validateModelServer(*networkingv1alpha1.ModelServer) (bool, string) {
 	return true, ""
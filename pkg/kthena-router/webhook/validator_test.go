@@ -18,10 +18,12 @@ package webhook
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
 
 	networkingv1alpha1 "github.com/volcano-sh/kthena/pkg/apis/networking/v1alpha1"
 )
@@ -353,6 +355,259 @@ func TestValidateModelRoute(t *testing.T) {
 			expectValid:    false,
 			expectedReason: "validation failed:   - spec: Required value: either modelName or loraAdapters must be specified  - spec.rules[0].targetModels: Required value: each rule must have at least one target model",
 		},
+		{
+			name: "valid model route with hedging",
+			modelRoute: &networkingv1alpha1.ModelRoute{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "networking.serving.volcano.sh/v1alpha1",
+					Kind:       "ModelRoute",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-route",
+					Namespace: "default",
+				},
+				Spec: networkingv1alpha1.ModelRouteSpec{
+					ModelName: "test-model",
+					Rules: []*networkingv1alpha1.Rule{
+						{
+							Name: "test-rule",
+							TargetModels: []*networkingv1alpha1.TargetModel{
+								{
+									ModelServerName: "test-server",
+								},
+							},
+							Hedging: &networkingv1alpha1.Hedging{DelayPercentile: ptr.To[int32](95)},
+						},
+					},
+				},
+			},
+			expectValid: true,
+		},
+		{
+			name: "invalid model route - hedging without delay",
+			modelRoute: &networkingv1alpha1.ModelRoute{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "networking.serving.volcano.sh/v1alpha1",
+					Kind:       "ModelRoute",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-route",
+					Namespace: "default",
+				},
+				Spec: networkingv1alpha1.ModelRouteSpec{
+					ModelName: "test-model",
+					Rules: []*networkingv1alpha1.Rule{
+						{
+							Name: "test-rule",
+							TargetModels: []*networkingv1alpha1.TargetModel{
+								{
+									ModelServerName: "test-server",
+								},
+							},
+							Hedging: &networkingv1alpha1.Hedging{MaxHedgedPercent: ptr.To[int32](10)},
+						},
+					},
+				},
+			},
+			expectValid:    false,
+			expectedReason: "validation failed:   - spec.rules[0].hedging: Required value: either delay or delayPercentile must be specified",
+		},
+		{
+			name: "invalid model route - hedging with both delays",
+			modelRoute: &networkingv1alpha1.ModelRoute{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "networking.serving.volcano.sh/v1alpha1",
+					Kind:       "ModelRoute",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-route",
+					Namespace: "default",
+				},
+				Spec: networkingv1alpha1.ModelRouteSpec{
+					ModelName: "test-model",
+					Rules: []*networkingv1alpha1.Rule{
+						{
+							Name: "test-rule",
+							TargetModels: []*networkingv1alpha1.TargetModel{
+								{
+									ModelServerName: "test-server",
+								},
+							},
+							Hedging: &networkingv1alpha1.Hedging{
+								Delay:           &metav1.Duration{Duration: time.Second},
+								DelayPercentile: ptr.To[int32](95),
+							},
+						},
+					},
+				},
+			},
+			expectValid:    false,
+			expectedReason: "validation failed:   - spec.rules[0].hedging.delayPercentile: Forbidden: delay and delayPercentile cannot both be specified",
+		},
+		{
+			name: "invalid model route - mirror without model server",
+			modelRoute: &networkingv1alpha1.ModelRoute{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "networking.serving.volcano.sh/v1alpha1",
+					Kind:       "ModelRoute",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-route",
+					Namespace: "default",
+				},
+				Spec: networkingv1alpha1.ModelRouteSpec{
+					ModelName: "test-model",
+					Rules: []*networkingv1alpha1.Rule{
+						{
+							Name: "test-rule",
+							TargetModels: []*networkingv1alpha1.TargetModel{
+								{
+									ModelServerName: "test-server",
+								},
+							},
+							Mirror: &networkingv1alpha1.Mirror{Percent: ptr.To[int32](10)},
+						},
+					},
+				},
+			},
+			expectValid:    false,
+			expectedReason: "validation failed:   - spec.rules[0].mirror.modelServerName: Required value: mirror must have a model server",
+		},
+		{
+			name: "invalid model route - fallback with unknown trigger",
+			modelRoute: &networkingv1alpha1.ModelRoute{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "networking.serving.volcano.sh/v1alpha1",
+					Kind:       "ModelRoute",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-route",
+					Namespace: "default",
+				},
+				Spec: networkingv1alpha1.ModelRouteSpec{
+					ModelName: "test-model",
+					Rules: []*networkingv1alpha1.Rule{
+						{
+							Name: "test-rule",
+							TargetModels: []*networkingv1alpha1.TargetModel{
+								{
+									ModelServerName: "test-server",
+								},
+							},
+							Fallbacks: []*networkingv1alpha1.FallbackTarget{
+								{
+									ModelServerName: "fallback-server",
+									On:              []networkingv1alpha1.FallbackTrigger{networkingv1alpha1.FallbackOn5xx, "4xx"},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectValid:    false,
+			expectedReason: "validation failed:   - spec.rules[0].fallbacks[0].on[1]: Unsupported value: \"4xx\": supported values: \"NoEndpoints\", \"5xx\", \"Timeout\", \"RateLimited\"",
+		},
+		{
+			name: "invalid model route - header modifier with invalid and duplicate headers",
+			modelRoute: &networkingv1alpha1.ModelRoute{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "networking.serving.volcano.sh/v1alpha1",
+					Kind:       "ModelRoute",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-route",
+					Namespace: "default",
+				},
+				Spec: networkingv1alpha1.ModelRouteSpec{
+					ModelName: "test-model",
+					Rules: []*networkingv1alpha1.Rule{
+						{
+							Name: "test-rule",
+							TargetModels: []*networkingv1alpha1.TargetModel{
+								{
+									ModelServerName: "test-server",
+									ModelName:       ptr.To("qwen3-32b-instruct"),
+								},
+							},
+							RequestHeaderModifier: &networkingv1alpha1.HeaderModifier{
+								Set: []networkingv1alpha1.HTTPHeader{
+									{Name: "x-tenant", Value: "a"},
+									{Name: "X-Tenant", Value: "b"},
+								},
+							},
+							ResponseHeaderModifier: &networkingv1alpha1.HeaderModifier{
+								Remove: []string{"x internal"},
+							},
+						},
+					},
+				},
+			},
+			expectValid: false,
+			expectedReason: "validation failed:   - spec.rules[0].requestHeaderModifier.set[1].name: Duplicate value: \"X-Tenant\"" +
+				"  - spec.rules[0].responseHeaderModifier.remove[0]: Invalid value: \"x internal\": a valid HTTP header must consist of alphanumeric characters or '-' (e.g. 'X-Header-Name', regex used for validation is '[-A-Za-z0-9]+')",
+		},
+		{
+			name: "invalid model route - header rate limit key without header",
+			modelRoute: &networkingv1alpha1.ModelRoute{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "networking.serving.volcano.sh/v1alpha1",
+					Kind:       "ModelRoute",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-route",
+					Namespace: "default",
+				},
+				Spec: networkingv1alpha1.ModelRouteSpec{
+					ModelName: "test-model",
+					Rules: []*networkingv1alpha1.Rule{
+						{
+							Name: "test-rule",
+							TargetModels: []*networkingv1alpha1.TargetModel{
+								{ModelServerName: "test-server"},
+							},
+						},
+					},
+					RateLimit: &networkingv1alpha1.RateLimit{
+						InputTokensPerUnit: ptr.To[uint32](1000),
+						Unit:               networkingv1alpha1.Minute,
+						Key:                &networkingv1alpha1.RateLimitKey{Source: networkingv1alpha1.RateLimitKeySourceHeader},
+					},
+				},
+			},
+			expectValid:    false,
+			expectedReason: "validation failed:   - spec.rateLimit.key.header: Required value: header must be specified when the source is Header",
+		},
+		{
+			name: "invalid model route - unsupported rate limit key source",
+			modelRoute: &networkingv1alpha1.ModelRoute{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "networking.serving.volcano.sh/v1alpha1",
+					Kind:       "ModelRoute",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-route",
+					Namespace: "default",
+				},
+				Spec: networkingv1alpha1.ModelRouteSpec{
+					ModelName: "test-model",
+					Rules: []*networkingv1alpha1.Rule{
+						{
+							Name: "test-rule",
+							TargetModels: []*networkingv1alpha1.TargetModel{
+								{ModelServerName: "test-server"},
+							},
+						},
+					},
+					RateLimit: &networkingv1alpha1.RateLimit{
+						InputTokensPerUnit: ptr.To[uint32](1000),
+						Unit:               networkingv1alpha1.Minute,
+						Key:                &networkingv1alpha1.RateLimitKey{Source: "Cookie"},
+					},
+				},
+			},
+			expectValid:    false,
+			expectedReason: "validation failed:   - spec.rateLimit.key.source: Unsupported value: \"Cookie\": supported values: \"Header\", \"JWTClaim\", \"User\"",
+		},
 	}
 
 	// Create a validator instance